@@ -0,0 +1,188 @@
+package main
+
+import "strings"
+
+// Anchor is where the terms of a pattern must match on the line
+type Anchor int
+
+const (
+	AnchorNone  Anchor = iota
+	AnchorLine         // ^…$: the whole line
+	AnchorStart        // ^…: the start of the line
+	AnchorEnd          // …$: the end of the line
+)
+
+func (a Anchor) String() string {
+	switch a {
+	case AnchorLine:
+		return "whole line ^…$"
+	case AnchorStart:
+		return "starts with ^…"
+	case AnchorEnd:
+		return "ends with …$"
+	}
+	return "anywhere"
+}
+
+// patternAnchor returns the anchor shared by every term of an expression,
+// or AnchorNone when they differ
+func patternAnchor(input string) Anchor {
+	anchor := AnchorNone
+	first := true
+	groups := splitOperator(input, "||")
+	for g, group := range groups {
+		terms := splitOperator(group, "&&")
+		for t, term := range terms {
+			afterOp, beforeOp := termSides(g, len(groups), t, len(terms))
+			_, body, _ := splitTerm(term, afterOp, beforeOp)
+			start, _, end := splitAnchors(body)
+			a := AnchorNone
+			switch {
+			case start && end:
+				a = AnchorLine
+			case start:
+				a = AnchorStart
+			case end:
+				a = AnchorEnd
+			}
+			if !first && a != anchor {
+				return AnchorNone
+			}
+			anchor, first = a, false
+		}
+	}
+	return anchor
+}
+
+// anchorPattern rewrites every term of an expression to match at anchor,
+// dropping the anchors the terms had. Operators and negations are kept, and
+// a term with top-level alternation is grouped so the anchors bind to all
+// of it.
+func anchorPattern(input string, anchor Anchor) string {
+	groups := splitOperator(input, "||")
+	for g, group := range groups {
+		terms := splitOperator(group, "&&")
+		for t, term := range terms {
+			afterOp, beforeOp := termSides(g, len(groups), t, len(terms))
+			prefix, body, suffix := splitTerm(term, afterOp, beforeOp)
+			if body == "" {
+				continue
+			}
+			_, core, _ := splitAnchors(body)
+			if anchor != AnchorNone && topLevelAlternation(core) {
+				core = "(?:" + core + ")"
+			}
+			if anchor == AnchorLine || anchor == AnchorStart {
+				core = "^" + core
+			}
+			if anchor == AnchorLine || anchor == AnchorEnd {
+				core += "$"
+			}
+			terms[t] = prefix + core + suffix
+		}
+		groups[g] = strings.Join(terms, " && ")
+	}
+	return strings.Join(groups, " || ")
+}
+
+// splitTerm separates a term's regex from the negations before it and
+// the whitespace between it and the operators beside it, as compileQuery
+// reads them
+func splitTerm(term string, afterOp, beforeOp bool) (prefix, body, suffix string) {
+	body = trimTerm(term, false, beforeOp)
+	suffix = term[len(body):]
+	rest := trimTerm(body, afterOp, false)
+	for next, ok := cutNegation(rest); ok; next, ok = cutNegation(rest) {
+		rest = next
+	}
+	return body[:len(body)-len(rest)], rest, suffix
+}
+
+// splitAnchors strips a leading ^ and an unescaped trailing $ from a regex,
+// and the group anchorPattern adds around an alternation
+func splitAnchors(re string) (start bool, core string, end bool) {
+	core = re
+	if strings.HasPrefix(core, "^") {
+		start, core = true, core[1:]
+	}
+	if strings.HasSuffix(core, "$") {
+		slashes := 0
+		for i := len(core) - 2; i >= 0 && core[i] == '\\'; i-- {
+			slashes++
+		}
+		if slashes%2 == 0 {
+			end, core = true, core[:len(core)-1]
+		}
+	}
+	if (start || end) && strings.HasPrefix(core, "(?:") && strings.HasSuffix(core, ")") {
+		if inner := core[3 : len(core)-1]; topLevelAlternation(inner) && balanced(inner) {
+			core = inner
+		}
+	}
+	return start, core, end
+}
+
+// topLevelAlternation reports whether a regex has a | outside of groups
+// and character classes
+func topLevelAlternation(re string) bool {
+	alternation, _ := scanGroups(re)
+	return alternation
+}
+
+// balanced reports whether a regex's parentheses close in order, so that
+// a group around it spans the whole of it
+func balanced(re string) bool {
+	_, ok := scanGroups(re)
+	return ok
+}
+
+// scanGroups walks a regex's groups, skipping escapes and character classes
+func scanGroups(re string) (alternation, balanced bool) {
+	depth := 0
+	balanced = true
+	for i := 0; i < len(re); i++ {
+		switch re[i] {
+		case '\\':
+			i++
+		case '[':
+			// A ] first in the class is a literal
+			i++
+			if i < len(re) && re[i] == '^' {
+				i++
+			}
+			if i < len(re) && re[i] == ']' {
+				i++
+			}
+			for i < len(re) && re[i] != ']' {
+				if re[i] == '\\' {
+					i++
+				}
+				i++
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				balanced = false
+			}
+		case '|':
+			if depth == 0 {
+				alternation = true
+			}
+		}
+	}
+	return alternation, balanced && depth == 0
+}
+
+// cycleAnchor anchors the search input at the next of anywhere, the whole
+// line, its start and its end
+func (m *model) cycleAnchor() {
+	if strings.TrimSpace(m.searchInput) == "" {
+		m.statusMsg = "Type a pattern to anchor first"
+		return
+	}
+	next := (patternAnchor(m.searchInput) + 1) % 4
+	m.searchInput = anchorPattern(m.searchInput, next)
+	m.statusMsg = "Anchor: " + next.String()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// backupDir returns the directory holding copies of files zx has modified
+func backupDir() (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "zx", "backups"), nil
+}
+
+// backupFile copies path into the backup directory before it is modified
+// and returns the location of the copy
+func backupFile(path string) (string, error) {
+	dir, err := backupDir()
+	if err != nil {
+		return "", fmt.Errorf("no backup directory: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	stamp := time.Now().Format("20060102-150405")
+	dst := filepath.Join(dir, uniqueTrashName(dir, "", fmt.Sprintf("%s-%s", stamp, filepath.Base(path))))
+	if err := copyPath(path, dst); err != nil {
+		return "", fmt.Errorf("unable to back up %s: %v", path, err)
+	}
+	return dst, nil
+}
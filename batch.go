@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// editorDoneMsg reports that the editor opened on marked results exited
+type editorDoneMsg struct {
+	files int
+	err   error
+}
+
+// isMarked reports whether a result is marked for a batch action
+func (m model) isMarked(r SearchResult) bool {
+	return m.marked[lineKey{r.FilePath, r.LineNumber}]
+}
+
+// toggleMark marks or unmarks the selected result and moves to the next,
+// so holding space marks a run of results
+func (m *model) toggleMark() {
+	if m.resultIndex >= m.visibleResults.len() || m.resultFilter.Aggregate != AggregateNone {
+		m.statusMsg = "Only results can be marked (l shows matches again)"
+		return
+	}
+	r := m.visibleResults.at(m.resultIndex)
+	key := lineKey{r.FilePath, r.LineNumber}
+	if m.marked == nil {
+		m.marked = make(map[lineKey]bool)
+	}
+	if m.marked[key] {
+		delete(m.marked, key)
+	} else {
+		m.marked[key] = true
+	}
+	m.renderCache.forget(m.visibleResults.index(m.resultIndex))
+	m.moveCursor(1)
+	m.statusMsg = fmt.Sprintf("%d results marked (a: actions)", len(m.marked))
+}
+
+// clearMarks unmarks every result
+func (m *model) clearMarks() {
+	n := len(m.marked)
+	m.marked = nil
+	m.renderCache.reset()
+	m.statusMsg = fmt.Sprintf("Unmarked %d results", n)
+}
+
+// markedResults returns the marked results in the order of the results,
+// including those the filters hide
+func (m model) markedResults() []SearchResult {
+	var results []SearchResult
+	for _, r := range m.searchResults.Results {
+		if m.isMarked(r) {
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+// resultPaths returns the files of results, each once, in order
+func resultPaths(results []SearchResult) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if !seen[r.FilePath] {
+			seen[r.FilePath] = true
+			paths = append(paths, r.FilePath)
+		}
+	}
+	return paths
+}
+
+// renderMarks summarizes the marked results above the list
+func (m model) renderMarks() string {
+	if len(m.marked) == 0 {
+		return ""
+	}
+	files := make(map[string]bool)
+	for key := range m.marked {
+		files[key.path] = true
+	}
+	return statusStyle.Render(fmt.Sprintf("Marked: %d results in %d files (a: actions on them)",
+		len(m.marked), len(files))) + "\n"
+}
+
+// promptBatch asks what to do with the marked results
+func (m *model) promptBatch() {
+	marked := m.markedResults()
+	if len(marked) == 0 {
+		m.statusMsg = "No results marked (space marks the selected one)"
+		return
+	}
+	m.prompt = &inputPrompt{
+		label: fmt.Sprintf("%d marked: open in [e]ditor, copy [p]aths, e[x]port, [s]kip their files in the next search, or [c]lear marks? ",
+			len(marked)),
+		input:  "e",
+		cursor: 1,
+		onRun: func(m *model, value string) tea.Cmd {
+			switch strings.ToLower(value) {
+			case "e", "editor":
+				return m.openInEditor(marked)
+			case "p", "paths":
+				paths := resultPaths(marked)
+				copyToClipboard(strings.Join(paths, "\n"))
+				m.statusMsg = fmt.Sprintf("Copied %d paths", len(paths))
+			case "x", "export":
+				m.promptExportMarked(marked)
+			case "s", "skip":
+				m.skipMarked(marked)
+			case "c", "clear":
+				m.clearMarks()
+			default:
+				m.statusMsg = fmt.Sprintf("Unknown action: %s", value)
+			}
+			return nil
+		},
+	}
+}
+
+// configEditor is the editor command of config.toml, if set
+var configEditor string
+
+// editorCommand returns the user's editor from config.toml, $VISUAL or
+// $EDITOR, or vi
+func editorCommand() []string {
+	if fields := strings.Fields(configEditor); len(fields) > 0 {
+		return fields
+	}
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(name)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"vi"}
+}
+
+// openInEditor opens the files of results in the user's editor, at the
+// line when there's a single one, suspending the interface until it exits
+func (m *model) openInEditor(results []SearchResult) tea.Cmd {
+	var onDisk []SearchResult
+	for _, r := range results {
+		if unpreviewable(r) == "" {
+			onDisk = append(onDisk, r)
+		}
+	}
+	if len(onDisk) == 0 {
+		m.statusMsg = "None of the marked results are files on disk"
+		return nil
+	}
+	args := editorCommand()
+	paths := resultPaths(onDisk)
+	if len(paths) == 1 {
+		args = append(args, fmt.Sprintf("+%d", onDisk[0].LineNumber))
+	}
+	args = append(args, paths...)
+	return tea.ExecProcess(exec.Command(args[0], args[1:]...), func(err error) tea.Msg {
+		return editorDoneMsg{files: len(paths), err: err}
+	})
+}
+
+// promptExportMarked asks for a file and writes the marked results to it
+// in the format its extension names
+func (m *model) promptExportMarked(marked []SearchResult) {
+	m.prompt = &inputPrompt{
+		label:  "Export marked results to (.json, .jsonl, .csv, .tsv, .md, .sarif, else plain): ",
+		input:  "zx-marked.json",
+		cursor: len("zx-marked.json"),
+		onSubmit: func(m *model, value string) {
+			if value == "" {
+				m.statusMsg = "Cancelled"
+				return
+			}
+			report := m.report()
+			report.Results = marked
+			f := formatterForPath(value)
+			if err := writeReportTo(value, f, report); err != nil {
+				m.statusMsg = fmt.Sprintf("Error: %v", err)
+				return
+			}
+			m.statusMsg = fmt.Sprintf("Exported %d marked results as %s to %s", len(marked), f.Name(), value)
+		},
+	}
+}
+
+// skipMarked leaves the files of the marked results out of the next
+// search and starts typing it
+func (m *model) skipMarked(marked []SearchResult) {
+	m.skipNext = make(map[string]bool)
+	for _, path := range resultPaths(marked) {
+		m.skipNext[path] = true
+	}
+	m.mode = SearchInputMode
+	m.statusMsg = fmt.Sprintf("The next search skips the %d files of the marked results", len(m.skipNext))
+}
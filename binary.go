@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	sniffSize           = 8 << 10 // Bytes inspected to classify a file
+	maxInvalidUTF8Ratio = 0.3     // Above this share of invalid bytes, content is binary
+)
+
+// Reasons reported by classifyBinary
+const (
+	binaryReasonNUL        = "contains NUL bytes"
+	binaryReasonEncoding   = "mostly invalid UTF-8"
+	binaryReasonExtension  = "binary extension"
+	binaryReasonCompressed = "corrupt compressed data"
+)
+
+// Extensions skipped as binary without reading the file, unless
+// binary_exts in config.toml moves them to the text list
+var defaultBinaryExts = []string{
+	".exe", ".bin", ".so", ".dll", ".dylib", ".a", ".o",
+	".jpg", ".jpeg", ".png", ".gif", ".bmp", ".ico",
+	".mp3", ".mp4", ".avi", ".mov", ".wav", ".flac",
+	".zip", ".tar", ".7z",
+	".pdf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx",
+}
+
+// Extensions searched as text without sniffing, even when they hold NUL
+// bytes or invalid UTF-8, such as a log a crash left half written
+var defaultTextExts = []string{
+	".txt", ".md", ".go", ".js", ".ts", ".py", ".java", ".c", ".cpp", ".h", ".hpp",
+	".rs", ".rb", ".php", ".html", ".css", ".json", ".xml", ".yaml", ".yml", ".toml",
+	".sh", ".bash", ".zsh", ".fish", ".ps1", ".bat", ".cmd", ".sql", ".log", ".conf",
+	".cfg", ".ini", ".env", ".gitignore", ".dockerfile", ".makefile", ".cmake",
+}
+
+// classifyBinary reports whether a file is binary, along with the reason:
+// by the extension lists, else by sniffing the beginning of the file.
+// Files that cannot be read are text unless the binary list has them.
+func (c SearchConfig) classifyBinary(filePath string) (bool, string) {
+	// Documents with a content extractor are searched as text
+	if extractorFor(filePath) != nil {
+		return false, ""
+	}
+	if hasExtension(filePath, c.TextExts) {
+		return false, ""
+	}
+	if hasExtension(filePath, c.BinaryExts) {
+		return true, binaryReasonExtension
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, ""
+	}
+	defer file.Close()
+
+	buf := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, ""
+	}
+
+	// Compressed files are classified by their decompressed content
+	if kind := detectCompression(buf[:n]); kind != "" {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return true, binaryReasonCompressed
+		}
+		sample, err := decompressedSample(file, kind, sniffSize)
+		if err != nil {
+			return true, binaryReasonCompressed
+		}
+		buf, n = sample, len(sample)
+	}
+
+	// UTF-16 text is full of NUL bytes but is transcoded during search
+	if isUTF16(detectEncoding(buf[:n])) {
+		return false, ""
+	}
+	return sniffBinary(buf[:n])
+}
+
+// sniffBinary classifies a content sample
+func sniffBinary(data []byte) (bool, string) {
+	invalid := 0
+	for i := 0; i < len(data); {
+		if data[i] == 0 {
+			return true, binaryReasonNUL
+		}
+		if data[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		// Don't penalize a multi-byte rune cut off by the sample boundary
+		if !utf8.FullRune(data[i:]) {
+			break
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			invalid++
+		}
+		i += size
+	}
+
+	if len(data) > 0 && float64(invalid)/float64(len(data)) > maxInvalidUTF8Ratio {
+		return true, binaryReasonEncoding
+	}
+	return false, ""
+}
+
+// hasExtension reports whether a file name ends with one of exts, which
+// may span dots, as .log.gz does
+func hasExtension(filePath string, exts []string) bool {
+	name := strings.ToLower(filepath.Base(filePath))
+	for _, ext := range exts {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// extensionLists adds the text_exts and binary_exts of config.toml to the
+// built-in lists; an extension added to one leaves the other
+func extensionLists(text, binary []string) ([]string, []string, error) {
+	text, err := normalizeExts(text)
+	if err != nil {
+		return nil, nil, fmt.Errorf("text_exts: %v", err)
+	}
+	binary, err = normalizeExts(binary)
+	if err != nil {
+		return nil, nil, fmt.Errorf("binary_exts: %v", err)
+	}
+	return moveExts(defaultTextExts, text, binary), moveExts(defaultBinaryExts, binary, text), nil
+}
+
+// moveExts returns list with added appended and removed left out, as a new
+// slice
+func moveExts(list, added, removed []string) []string {
+	gone := make(map[string]bool)
+	for _, ext := range removed {
+		gone[ext] = true
+	}
+	for _, ext := range added {
+		gone[ext] = true
+	}
+	var exts []string
+	for _, ext := range list {
+		if !gone[ext] {
+			exts = append(exts, ext)
+		}
+	}
+	return append(exts, added...)
+}
+
+// promptExtensions edits the text (or binary) extension list in a prompt;
+// extensions added to it leave the other list
+func (m *model) promptExtensions(text bool) {
+	kind, list := "Binary", m.searchConfig.BinaryExts
+	if text {
+		kind, list = "Text", m.searchConfig.TextExts
+	}
+	input := strings.Join(list, " ")
+	m.prompt = &inputPrompt{
+		label:  kind + " extensions (space-separated): ",
+		input:  input,
+		cursor: len([]rune(input)),
+		onSubmit: func(m *model, value string) {
+			exts, err := normalizeExts(strings.Fields(value))
+			if err != nil {
+				m.statusMsg = fmt.Sprintf("Error: %v", err)
+				return
+			}
+			m.pushUndo("change " + strings.ToLower(kind) + " extensions")
+			if text {
+				m.searchConfig.TextExts = exts
+				m.searchConfig.BinaryExts = moveExts(m.searchConfig.BinaryExts, nil, exts)
+			} else {
+				m.searchConfig.BinaryExts = exts
+				m.searchConfig.TextExts = moveExts(m.searchConfig.TextExts, nil, exts)
+			}
+			m.statusMsg = fmt.Sprintf("%d %s extensions", len(exts), strings.ToLower(kind))
+		},
+	}
+}
+
+// extensionsLabel lists the first extensions of a list for ConfigMode
+func extensionsLabel(exts []string) string {
+	const shown = 10
+	if len(exts) == 0 {
+		return "none"
+	}
+	if len(exts) <= shown {
+		return strings.Join(exts, " ")
+	}
+	return fmt.Sprintf("%s … (%d more)", strings.Join(exts[:shown], " "), len(exts)-shown)
+}
+
+// normalizeExts lowercases extensions and gives them a leading dot
+func normalizeExts(exts []string) ([]string, error) {
+	var normalized []string
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" || ext == "." || strings.ContainsAny(ext, `/\`) {
+			return nil, fmt.Errorf("invalid extension %q", ext)
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		normalized = append(normalized, ext)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// bookmarksPath returns the file holding bookmarked directories
+func bookmarksPath() (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "bookmarks.json"), nil
+}
+
+// loadBookmarks reads the bookmarked directories, in the order they were
+// added
+func loadBookmarks() ([]string, error) {
+	path, err := bookmarksPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var bookmarks []string
+	if err := json.Unmarshal(data, &bookmarks); err != nil {
+		return nil, fmt.Errorf("invalid bookmarks %s: %v", path, err)
+	}
+	return bookmarks, nil
+}
+
+// writeBookmarks replaces the bookmarked directories
+func writeBookmarks(bookmarks []string) error {
+	path, err := bookmarksPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bookmarks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// toggleBookmark bookmarks the current directory, or removes its bookmark
+func (m *model) toggleBookmark() {
+	bookmarks := make([]string, 0, len(m.bookmarks)+1)
+	removed := false
+	for _, dir := range m.bookmarks {
+		if dir == m.currentDir {
+			removed = true
+			continue
+		}
+		bookmarks = append(bookmarks, dir)
+	}
+	if !removed {
+		bookmarks = append(bookmarks, m.currentDir)
+	}
+	if err := writeBookmarks(bookmarks); err != nil {
+		m.statusMsg = fmt.Sprintf("Error saving bookmarks: %v", err)
+		return
+	}
+	m.bookmarks = bookmarks
+
+	if removed {
+		m.statusMsg = fmt.Sprintf("Removed bookmark %s", m.currentDir)
+	} else if n := len(bookmarks); n <= 9 {
+		m.statusMsg = fmt.Sprintf("Bookmarked %s (Alt+%d jumps here)", m.currentDir, n)
+	} else {
+		m.statusMsg = fmt.Sprintf("Bookmarked %s (B lists bookmarks)", m.currentDir)
+	}
+}
+
+// jumpToBookmark opens the nth bookmarked directory, counting from 1
+func (m *model) jumpToBookmark(n int) {
+	if n < 1 || n > len(m.bookmarks) {
+		m.statusMsg = fmt.Sprintf("No bookmark %d (press b to bookmark a directory)", n)
+		return
+	}
+	dir := m.bookmarks[n-1]
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		m.statusMsg = fmt.Sprintf("Bookmark %d is gone: %s (press B, then x to remove it)", n, dir)
+		return
+	}
+	m.pickingBookmark = false
+	m.currentDir = dir
+	m.loadDirectory()
+	m.statusMsg = fmt.Sprintf("Jumped to bookmark %d: %s", n, dir)
+}
+
+// openBookmarkPicker lists the bookmarks in the file browser
+func (m *model) openBookmarkPicker() {
+	if len(m.bookmarks) == 0 {
+		m.statusMsg = "No bookmarks yet (press b to bookmark the current directory)"
+		return
+	}
+	m.pickingBookmark = true
+	m.bookmarkIndex = min(m.bookmarkIndex, len(m.bookmarks)-1)
+	m.statusMsg = "Choose a bookmark..."
+}
+
+// updateBookmarkPicker handles keys while the bookmark picker is open
+func (m *model) updateBookmarkPicker(key string) {
+	switch key {
+	case "esc", "q", "B":
+		m.pickingBookmark = false
+		m.statusMsg = "Bookmarks closed"
+
+	case "up", "k":
+		if m.bookmarkIndex > 0 {
+			m.bookmarkIndex--
+		}
+
+	case "down", "j":
+		if m.bookmarkIndex < len(m.bookmarks)-1 {
+			m.bookmarkIndex++
+		}
+
+	case "enter":
+		m.jumpToBookmark(m.bookmarkIndex + 1)
+
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+		m.jumpToBookmark(int(key[0] - '0'))
+
+	case "x", "delete":
+		dir := m.bookmarks[m.bookmarkIndex]
+		bookmarks := append(append([]string(nil), m.bookmarks[:m.bookmarkIndex]...), m.bookmarks[m.bookmarkIndex+1:]...)
+		if err := writeBookmarks(bookmarks); err != nil {
+			m.statusMsg = fmt.Sprintf("Error saving bookmarks: %v", err)
+			return
+		}
+		m.bookmarks = bookmarks
+		m.statusMsg = fmt.Sprintf("Removed bookmark %s", dir)
+		if len(bookmarks) == 0 {
+			m.pickingBookmark = false
+		}
+		m.bookmarkIndex = max(0, min(m.bookmarkIndex, len(bookmarks)-1))
+	}
+}
+
+// renderBookmarkPicker lists the bookmarks with their shortcut numbers
+func (m model) renderBookmarkPicker() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Bookmarks (1-9 or Enter: jump, x: remove, Esc: close):"))
+	b.WriteString("\n")
+	for i, dir := range m.bookmarks {
+		key := " "
+		if i < 9 {
+			key = fmt.Sprint(i + 1)
+		}
+		line := fmt.Sprintf("  %s  %s", key, dir)
+		if dir == m.currentDir {
+			line += "  (current)"
+		}
+		if i == m.bookmarkIndex {
+			b.WriteString(renderSelected(line))
+		} else if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			b.WriteString(helpStyle.Render(line + "  (missing)"))
+		} else {
+			b.WriteString(directoryStyle.Render(line))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
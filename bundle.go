@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bundle format identifiers, checked on import
+const (
+	bundleFormat       = "zx-bundle"
+	bundleVersion      = 2 // Version 2 stores every match span of a line
+	bundleContextLines = 2 // Lines of context saved before and after each match
+)
+
+// resultBundle is a portable, self-contained copy of a search: its results,
+// the settings that produced them, triage notes and optional file snippets,
+// so it can be reviewed elsewhere without rerunning the search
+type resultBundle struct {
+	Format     string         `json:"format"`
+	Version    int            `json:"version"`
+	Created    time.Time      `json:"created"`
+	Pattern    string         `json:"pattern"`
+	Target     string         `json:"target"`
+	Inverted   bool           `json:"inverted,omitempty"`
+	Truncated  bool           `json:"truncated,omitempty"`
+	TotalFiles int            `json:"total_files"`
+	SearchTime time.Duration  `json:"search_time_ns"`
+	Config     bundleConfig   `json:"config"`
+	Results    []bundleResult `json:"results"`
+	Notes      []bundleNote   `json:"notes,omitempty"`
+	Errors     []string       `json:"errors,omitempty"`
+}
+
+// bundleConfig records the search settings that affect which results exist
+type bundleConfig struct {
+	MaxFileSize    int64 `json:"max_file_size"`
+	MaxResults     int   `json:"max_results"`
+	MaxPerFile     int   `json:"max_per_file,omitempty"`
+	CaseSensitive  bool  `json:"case_sensitive,omitempty"`
+	InvertMatch    bool  `json:"invert_match,omitempty"`
+	FileLevelMatch bool  `json:"file_level_match,omitempty"`
+	NoIgnore       bool  `json:"no_ignore,omitempty"`
+	Gitignored     bool  `json:"include_gitignored,omitempty"`
+	SearchStashes  bool  `json:"search_stashes,omitempty"`
+	MaxDepth       int   `json:"max_depth,omitempty"`
+}
+
+type bundleResult struct {
+	File        string    `json:"file"`
+	Line        int       `json:"line"`
+	Content     string    `json:"content"`
+	Matches     [][2]int  `json:"matches,omitempty"`     // [start, end) byte spans
+	Terms       []int     `json:"terms,omitempty"`       // Query term of each span, when any isn't the first
+	MatchStart  int       `json:"match_start,omitempty"` // Single span of version 1 bundles
+	MatchEnd    int       `json:"match_end,omitempty"`
+	Column      int       `json:"column,omitempty"`
+	Offset      int64     `json:"offset,omitempty"`
+	Size        int64     `json:"size"`
+	Modified    time.Time `json:"modified"`
+	Encoding    string    `json:"encoding,omitempty"`
+	Compression string    `json:"compression,omitempty"`
+	Extractor   string    `json:"extractor,omitempty"`
+	Layer       string    `json:"layer,omitempty"`
+	Source      string    `json:"source,omitempty"`
+	Unit        string    `json:"unit,omitempty"`
+	Priority    string    `json:"priority,omitempty"`
+	Subject     string    `json:"subject,omitempty"`
+	MoreMatches int       `json:"more_matches,omitempty"` // Matches beyond the per-file cap
+	Before      []string  `json:"before,omitempty"`       // Snippet lines preceding the match
+	After       []string  `json:"after,omitempty"`        // Snippet lines following the match
+}
+
+type bundleNote struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Note string `json:"note"`
+}
+
+// newBundle captures the current results, settings and notes
+func (m *model) newBundle(snippets bool) resultBundle {
+	r := m.searchResults
+	cfg := m.searchConfig
+	bundle := resultBundle{
+		Format:     bundleFormat,
+		Version:    bundleVersion,
+		Created:    time.Now(),
+		Pattern:    r.Pattern,
+		Target:     r.Target,
+		Inverted:   r.Inverted,
+		Truncated:  r.Truncated,
+		TotalFiles: r.TotalFiles,
+		SearchTime: r.SearchTime,
+		Errors:     r.errorMessages(),
+		Config: bundleConfig{
+			MaxFileSize:    cfg.MaxFileSize,
+			MaxResults:     cfg.MaxResults,
+			MaxPerFile:     cfg.MaxPerFile,
+			CaseSensitive:  cfg.CaseSensitive,
+			InvertMatch:    cfg.InvertMatch,
+			FileLevelMatch: cfg.FileLevelMatch,
+			NoIgnore:       cfg.NoIgnore,
+			Gitignored:     cfg.IncludeGitignored,
+			SearchStashes:  cfg.SearchStashes,
+			MaxDepth:       cfg.MaxDepth,
+		},
+	}
+
+	for _, res := range r.Results {
+		var matches [][2]int
+		var terms []int
+		for i, match := range res.Matches {
+			matches = append(matches, [2]int{match.Start, match.End})
+			if match.Term != 0 && terms == nil {
+				terms = make([]int, i, len(res.Matches))
+			}
+			if terms != nil {
+				terms = append(terms, match.Term)
+			}
+		}
+		bundle.Results = append(bundle.Results, bundleResult{
+			File:        res.FilePath,
+			Line:        res.LineNumber,
+			Content:     res.LineContent,
+			Matches:     matches,
+			Terms:       terms,
+			Column:      res.Column,
+			Offset:      res.ByteOffset,
+			Size:        res.FileSize,
+			Modified:    res.LastModified,
+			Encoding:    res.Encoding,
+			Compression: res.Compression,
+			Extractor:   res.Extractor,
+			Layer:       res.Layer,
+			Source:      res.Source,
+			Unit:        res.Unit,
+			Priority:    res.Priority,
+			Subject:     res.Subject,
+			MoreMatches: res.MoreMatches,
+			Before:      res.Before,
+			After:       res.After,
+		})
+	}
+	if snippets {
+		addSnippets(bundle.Results, bundleContextLines)
+	}
+
+	for key, note := range m.notes {
+		bundle.Notes = append(bundle.Notes, bundleNote{File: key.path, Line: key.line, Note: note})
+	}
+	sort.Slice(bundle.Notes, func(i, j int) bool {
+		if bundle.Notes[i].File != bundle.Notes[j].File {
+			return bundle.Notes[i].File < bundle.Notes[j].File
+		}
+		return bundle.Notes[i].Line < bundle.Notes[j].Line
+	})
+	return bundle
+}
+
+// addSnippets fills in context lines around each result from the files on
+// disk. Results that can't be re-read as plain text keep what they have.
+func addSnippets(results []bundleResult, context int) {
+	byFile := make(map[string][]int)
+	var files []string
+	for i, r := range results {
+		if r.Compression != "" || r.Extractor != "" || r.Layer != "" || r.Source != "" || strings.HasPrefix(r.File, "stash@{") {
+			continue
+		}
+		if _, ok := byFile[r.File]; !ok {
+			files = append(files, r.File)
+		}
+		byFile[r.File] = append(byFile[r.File], i)
+	}
+
+	for _, path := range files {
+		wanted := make(map[int]bool)
+		for _, i := range byFile[path] {
+			for n := results[i].Line - context; n <= results[i].Line+context; n++ {
+				wanted[n] = true
+			}
+		}
+		lines, err := readLines(path, wanted)
+		if err != nil {
+			continue
+		}
+		for _, i := range byFile[path] {
+			r := &results[i]
+			r.Before, r.After = nil, nil
+			for n := r.Line - context; n < r.Line; n++ {
+				if line, ok := lines[n]; ok {
+					r.Before = append(r.Before, line)
+				}
+			}
+			for n := r.Line + 1; n <= r.Line+context; n++ {
+				if line, ok := lines[n]; ok {
+					r.After = append(r.After, line)
+				}
+			}
+		}
+	}
+}
+
+// readLines returns the requested line numbers of a text file, decoded to UTF-8
+func readLines(path string, wanted map[int]bool) (map[int]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, BufferSize)
+	sample, _ := reader.Peek(sniffSize)
+	scanner := bufio.NewScanner(decodingReader(reader, detectEncoding(sample)))
+	scanner.Buffer(make([]byte, 0, BufferSize), BufferSize)
+
+	last := 0
+	for n := range wanted {
+		last = max(last, n)
+	}
+	lines := make(map[int]string)
+	for n := 1; n <= last && scanner.Scan(); n++ {
+		if wanted[n] {
+			lines[n] = scanner.Text()
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// writeBundle saves a bundle as indented JSON
+func writeBundle(path string, bundle resultBundle) error {
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// readBundle loads and validates a bundle file
+func readBundle(path string) (resultBundle, error) {
+	var bundle resultBundle
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return bundle, err
+	}
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return bundle, fmt.Errorf("invalid bundle %s: %v", path, err)
+	}
+	if bundle.Format != bundleFormat {
+		return bundle, fmt.Errorf("%s is not a zx result bundle", path)
+	}
+	if bundle.Version > bundleVersion {
+		return bundle, fmt.Errorf("%s uses bundle version %d; this zx reads up to %d", path, bundle.Version, bundleVersion)
+	}
+	return bundle, nil
+}
+
+// loadBundle replaces the current results with a bundle's and opens the
+// results view on them
+func (m *model) loadBundle(path string, bundle resultBundle) {
+	results := SearchResults{
+		Pattern:    bundle.Pattern,
+		Target:     bundle.Target,
+		Inverted:   bundle.Inverted,
+		Truncated:  bundle.Truncated,
+		TotalFiles: bundle.TotalFiles,
+		SearchTime: bundle.SearchTime,
+		Errors:     bundle.Errors,
+		Bundle:     path,
+	}
+	for _, r := range bundle.Results {
+		var matches []MatchRange
+		for i, match := range r.Matches {
+			span := MatchRange{Start: match[0], End: match[1]}
+			if i < len(r.Terms) {
+				span.Term = r.Terms[i]
+			}
+			matches = append(matches, span)
+		}
+		if len(matches) == 0 && r.MatchEnd > r.MatchStart {
+			// Version 1 bundles have a row per match; merge rows of a line
+			match := MatchRange{Start: r.MatchStart, End: r.MatchEnd}
+			if n := len(results.Results); n > 0 && results.Results[n-1].FilePath == r.File && results.Results[n-1].LineNumber == r.Line {
+				results.Results[n-1].Matches = append(results.Results[n-1].Matches, match)
+				continue
+			}
+			matches = []MatchRange{match}
+		}
+		results.Results = append(results.Results, SearchResult{
+			FilePath:     r.File,
+			LineNumber:   r.Line,
+			LineContent:  r.Content,
+			Matches:      matches,
+			Column:       r.Column,
+			ByteOffset:   r.Offset,
+			FileSize:     r.Size,
+			LastModified: r.Modified,
+			Encoding:     r.Encoding,
+			Compression:  r.Compression,
+			Extractor:    r.Extractor,
+			Layer:        r.Layer,
+			Source:       r.Source,
+			Unit:         r.Unit,
+			Priority:     r.Priority,
+			Subject:      r.Subject,
+			MoreMatches:  r.MoreMatches,
+			Before:       r.Before,
+			After:        r.After,
+		})
+	}
+
+	m.cancelSearch()
+	m.pushUndo("import bundle")
+	m.searchResults = results
+	m.searchInput = bundle.Pattern
+	m.searchConfig.CaseSensitive = bundle.Config.CaseSensitive
+	m.searchConfig.InvertMatch = bundle.Config.InvertMatch
+	m.searchConfig.FileLevelMatch = bundle.Config.FileLevelMatch
+	m.searchConfig.NoIgnore = bundle.Config.NoIgnore
+	m.searchConfig.IncludeGitignored = bundle.Config.Gitignored
+	m.searchConfig.SearchStashes = bundle.Config.SearchStashes
+	m.searchConfig.MaxDepth = bundle.Config.MaxDepth
+	m.searchConfig.Query, _ = compileQuery(bundle.Pattern)
+
+	for _, n := range bundle.Notes {
+		if m.notes == nil {
+			m.notes = make(map[lineKey]string)
+		}
+		m.notes[lineKey{n.File, n.Line}] = n.Note
+	}
+
+	m.resultIndex = 0
+	m.viewport.offset = 0
+	m.showRefs = false
+	m.mode = SearchResultsMode
+	m.applyResultFilters()
+	m.statusMsg = fmt.Sprintf("Imported %d results and %d notes from %s", len(results.Results), len(bundle.Notes), path)
+}
+
+// promptExportBundle asks where to save the bundle and whether to include
+// file snippets
+func (m *model) promptExportBundle() {
+	m.prompt = &inputPrompt{
+		label:  "Export bundle to: ",
+		input:  "zx-bundle.json",
+		cursor: len("zx-bundle.json"),
+		onSubmit: func(m *model, path string) {
+			if path == "" {
+				m.statusMsg = "Cancelled"
+				return
+			}
+			m.prompt = &inputPrompt{
+				label: fmt.Sprintf("Include %d lines of file context per match? [Y/n] ", bundleContextLines),
+				onSubmit: func(m *model, answer string) {
+					snippets := !strings.EqualFold(answer, "n") && !strings.EqualFold(answer, "no")
+					bundle := m.newBundle(snippets)
+					if err := writeBundle(path, bundle); err != nil {
+						m.statusMsg = fmt.Sprintf("Error: %v", err)
+						return
+					}
+					m.statusMsg = fmt.Sprintf("Exported %d results and %d notes to %s", len(bundle.Results), len(bundle.Notes), path)
+				},
+			}
+		},
+	}
+}
+
+// promptImportBundle asks for a bundle file and opens it
+func (m *model) promptImportBundle() {
+	m.prompt = &inputPrompt{
+		label: "Import bundle from: ",
+		onSubmit: func(m *model, path string) {
+			if path == "" {
+				m.statusMsg = "Cancelled"
+				return
+			}
+			bundle, err := readBundle(path)
+			if err != nil {
+				m.statusMsg = fmt.Sprintf("Error: %v", err)
+				return
+			}
+			m.loadBundle(path, bundle)
+		},
+	}
+}
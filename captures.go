@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// captureGroup names one capture group of a positive query term
+type captureGroup struct {
+	term  int // Index into Query.Patterns
+	group int // Submatch index within the term's regex
+	name  string
+}
+
+// captureGroups lists the capture groups of the contributing terms, named
+// after the group (or its number) and, when several terms capture, the term
+func (q *Query) captureGroups() []captureGroup {
+	capturing := 0
+	for i, re := range q.Patterns {
+		if q.Positive[i] && re.NumSubexp() > 0 {
+			capturing++
+		}
+	}
+
+	var groups []captureGroup
+	for i, re := range q.Patterns {
+		if !q.Positive[i] {
+			continue
+		}
+		for g, name := range re.SubexpNames()[1:] {
+			if name == "" {
+				name = strconv.Itoa(g + 1)
+			}
+			if capturing > 1 {
+				name = fmt.Sprintf("%d.%s", i+1, name)
+			}
+			groups = append(groups, captureGroup{term: i, group: g + 1, name: name})
+		}
+	}
+	return groups
+}
+
+// Captures returns one row per match on the line of a capturing term, with
+// a value for each of captureGroups; groups of other terms stay empty
+func (q *Query) Captures(line string) [][]string {
+	groups := q.captureGroups()
+	var rows [][]string
+	for i, re := range q.Patterns {
+		if !q.Positive[i] || re.NumSubexp() == 0 {
+			continue
+		}
+		for _, match := range re.FindAllStringSubmatch(line, -1) {
+			row := make([]string, len(groups))
+			for c, g := range groups {
+				if g.term == i {
+					row[c] = match[g.group]
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// renderCaptures formats the captured values of the selected result for
+// the detail panel, one line per match
+func (m model) renderCaptures(result SearchResult) string {
+	query := m.searchConfig.Query
+	if query == nil || result.Matches == nil {
+		return ""
+	}
+	groups := query.captureGroups()
+	var b strings.Builder
+	for _, row := range query.Captures(result.LineContent) {
+		var fields []string
+		for c, g := range groups {
+			fields = append(fields, fmt.Sprintf("%s=%q", g.name, row[c]))
+		}
+		b.WriteString(statusStyle.Render("      " + strings.Join(fields, "  ")))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// promptExportCaptures asks where to write the captured values of the
+// visible results; a .json name writes JSON, anything else CSV
+func (m *model) promptExportCaptures() {
+	query := m.searchConfig.Query
+	if query == nil || len(query.captureGroups()) == 0 {
+		m.statusMsg = "The pattern has no capture groups to export"
+		return
+	}
+	m.prompt = &inputPrompt{
+		label:  "Export captures to (.csv or .json): ",
+		input:  "zx-captures.csv",
+		cursor: len("zx-captures.csv"),
+		onSubmit: func(m *model, path string) {
+			if path == "" {
+				m.statusMsg = "Cancelled"
+				return
+			}
+			rows, err := m.writeCaptures(path)
+			if err != nil {
+				m.statusMsg = fmt.Sprintf("Error: %v", err)
+				return
+			}
+			m.statusMsg = fmt.Sprintf("Exported %d captured rows to %s", rows, path)
+		},
+	}
+}
+
+// writeCaptures writes a file, line and captured-group row per match and
+// returns the number of rows. Aggregated views still export every line the
+// filters keep, not just the rows shown per file.
+func (m *model) writeCaptures(path string) (int, error) {
+	query := m.searchConfig.Query
+	groups := query.captureGroups()
+	header := []string{"file", "line"}
+	for _, g := range groups {
+		header = append(header, g.name)
+	}
+
+	lines := m.visibleResults
+	if m.resultFilter.Aggregate != AggregateNone {
+		lines = m.filteredResults()
+	}
+
+	var records [][]string
+	for _, r := range lines.slice() {
+		if r.Matches == nil {
+			continue
+		}
+		for _, row := range query.Captures(r.LineContent) {
+			records = append(records, append([]string{r.FilePath, strconv.Itoa(r.LineNumber)}, row...))
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		objects := make([]map[string]any, 0, len(records))
+		for _, rec := range records {
+			line, _ := strconv.Atoi(rec[1])
+			obj := map[string]any{"file": rec[0], "line": line}
+			for c, g := range groups {
+				obj[g.name] = rec[c+2]
+			}
+			objects = append(objects, obj)
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(objects); err != nil {
+			return 0, err
+		}
+	} else {
+		w := csv.NewWriter(f)
+		w.Write(header)
+		w.WriteAll(records)
+		if err := w.Error(); err != nil {
+			return 0, err
+		}
+	}
+	return len(records), f.Close()
+}
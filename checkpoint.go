@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// checkpointEvery is how long a search runs before its first checkpoint,
+// and the time between checkpoints
+const checkpointEvery = 30 * time.Second
+
+// searchCheckpoint is the saved state of a long search: the files searched
+// to the end and their results. A crashed, killed or stopped search resumes
+// from it with the other files.
+type searchCheckpoint struct {
+	Key     string
+	Pattern string
+	Targets []string
+	Done    []searchedFile
+	Results []SearchResult
+	Started time.Time
+	Saved   time.Time
+}
+
+// searchedFile is a file a checkpoint has results for, with the size and
+// modification time it had when it was searched
+type searchedFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// unchanged reports whether the file still has the size and modification
+// time it was searched with
+func (f searchedFile) unchanged() bool {
+	info, err := os.Stat(f.Path)
+	return err == nil && info.Size() == f.Size && info.ModTime().Equal(f.ModTime)
+}
+
+// checkpointKey identifies a search by its pattern, targets and the
+// settings that decide what it finds. The limits auto-configuration picks
+// for the targets are left out, since each run picks them again.
+func checkpointKey(pattern string, targets []string, config SearchConfig) string {
+	config.Query = nil
+	config.MaxFileSize, config.MaxResults, config.MaxConcurrency = 0, 0, 0
+	config.AutoConfigured, config.NetworkSafe = false, false
+	config.Suggest = SuggestConfig{}
+	data, _ := json.Marshal(struct {
+		Pattern string
+		Targets []string
+		Config  SearchConfig
+	}{pattern, targets, config})
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// checkpointFile returns where the checkpoint of a search is stored
+func checkpointFile(key string) (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "zx", "checkpoints", key+".ckpt"), nil
+}
+
+// loadCheckpoint reads the checkpoint of a search; a search without one
+// yields nil
+func loadCheckpoint(key string) (*searchCheckpoint, error) {
+	path, err := checkpointFile(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var ck searchCheckpoint
+	if err := gob.NewDecoder(bufio.NewReaderSize(f, BufferSize)).Decode(&ck); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %v", path, err)
+	}
+	return &ck, nil
+}
+
+// removeCheckpoint deletes the checkpoint of a search that finished
+func removeCheckpoint(key string) {
+	if path, err := checkpointFile(key); err == nil {
+		os.Remove(path)
+	}
+}
+
+// save writes the checkpoint, replacing the previous one only once the new
+// one is complete
+func (ck *searchCheckpoint) save() error {
+	path, err := checkpointFile(ck.Key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".ckpt-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriterSize(tmp, BufferSize)
+	err = gob.NewEncoder(w).Encode(ck)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// checkpointer records the files a search's workers finish and saves them
+// every checkpointEvery. The model and the search goroutine share it.
+type checkpointer struct {
+	key     string
+	pattern string
+	targets []string
+	resume  *searchCheckpoint // Checkpoint the search continues, if any
+
+	mu      sync.Mutex
+	done    []searchedFile
+	results []SearchResult
+	started time.Time
+	saved   time.Time // Zero until the first checkpoint
+	saving  int32
+	err     error // First failed save
+}
+
+// newCheckpointer sets up checkpoints for a search, resuming resume if
+// it isn't nil
+func newCheckpointer(key, pattern string, targets []string, resume *searchCheckpoint) *checkpointer {
+	c := &checkpointer{key: key, pattern: pattern, targets: targets, resume: resume, started: time.Now()}
+	if resume != nil {
+		c.started = resume.Started
+	}
+	return c
+}
+
+// prepare drops the resumed files changed since they were searched and
+// returns the others, to skip, with their results
+func (c *checkpointer) prepare() (skip map[string]bool, prior []SearchResult) {
+	if c.resume == nil {
+		return nil, nil
+	}
+	skip = make(map[string]bool, len(c.resume.Done))
+	var kept []searchedFile
+	for _, file := range c.resume.Done {
+		if file.unchanged() {
+			skip[file.Path] = true
+			kept = append(kept, file)
+		}
+	}
+	for _, r := range c.resume.Results {
+		if skip[r.FilePath] {
+			prior = append(prior, r)
+		}
+	}
+	c.mu.Lock()
+	c.done = append(c.done, kept...)
+	c.results = append(c.results, prior...)
+	c.mu.Unlock()
+	return skip, prior
+}
+
+// finished records a file searched to the end
+func (c *checkpointer) finished(file searchedFile, results []SearchResult) {
+	c.mu.Lock()
+	c.done = append(c.done, file)
+	c.results = append(c.results, results...)
+	c.mu.Unlock()
+}
+
+// tick saves a checkpoint in the background when one is due
+func (c *checkpointer) tick() {
+	c.mu.Lock()
+	due := time.Since(c.started) >= checkpointEvery && time.Since(c.saved) >= checkpointEvery
+	c.mu.Unlock()
+	if due && atomic.CompareAndSwapInt32(&c.saving, 0, 1) {
+		go func() {
+			defer atomic.StoreInt32(&c.saving, 0)
+			c.save()
+		}()
+	}
+}
+
+// save writes what has been searched so far
+func (c *checkpointer) save() error {
+	c.mu.Lock()
+	ck := &searchCheckpoint{
+		Key:     c.key,
+		Pattern: c.pattern,
+		Targets: c.targets,
+		Done:    append([]searchedFile(nil), c.done...),
+		Results: append([]SearchResult(nil), c.results...),
+		Started: c.started,
+		Saved:   time.Now(),
+	}
+	c.saved = ck.Saved
+	c.mu.Unlock()
+
+	err := ck.save()
+	if err != nil {
+		c.mu.Lock()
+		if c.err == nil {
+			c.err = err
+		}
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// finish keeps a checkpoint of a search that stopped early, once it has run
+// long enough to have one, and deletes the checkpoint of a search that ran
+// to the end. It returns the error of a failed save.
+func (c *checkpointer) finish(stopped bool) error {
+	for atomic.LoadInt32(&c.saving) != 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !stopped {
+		removeCheckpoint(c.key)
+		return nil
+	}
+	c.mu.Lock()
+	keep := !c.saved.IsZero() || c.resume != nil
+	c.mu.Unlock()
+	if keep && c.save() == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// kept reports whether the search has a checkpoint on disk
+func (c *checkpointer) kept() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.saved.IsZero()
+}
+
+// keepCheckpoints saves the checkpoints of the searches still running in
+// any tab, as zx quits without waiting for them
+func (m *model) keepCheckpoints() {
+	if m.searching && m.checkpoint != nil {
+		m.checkpoint.finish(true)
+	}
+	for i, t := range m.tabs {
+		if i != m.activeTab && t.searching && t.checkpoint != nil {
+			t.checkpoint.finish(true)
+		}
+	}
+}
+
+// resumableCheckpoint returns the checkpoint of the search the input would
+// start, if an earlier run of it left one
+func (m *model) resumableCheckpoint() *searchCheckpoint {
+	c := *m
+	c.restoreQuickLimits()
+	targets, _, _, _ := c.searchTargets()
+	ck, err := loadCheckpoint(checkpointKey(c.searchInput, targets, c.searchConfig))
+	if err != nil {
+		m.statusMsg = fmt.Sprintf("Ignoring checkpoint: %v", err)
+		return nil
+	}
+	return ck
+}
+
+// promptResume offers to continue a search from its checkpoint
+func (m *model) promptResume(ck *searchCheckpoint) {
+	m.prompt = &inputPrompt{
+		label: fmt.Sprintf("This search was stopped %s after %d files (%d results): [r]esume it or [s]tart over? ",
+			ck.Saved.Format("Jan 2 15:04"), len(ck.Done), len(ck.Results)),
+		input:  "r",
+		cursor: 1,
+		onRun: func(m *model, value string) tea.Cmd {
+			switch value {
+			case "r", "resume":
+				m.resumeFrom = ck
+			case "s", "start":
+				removeCheckpoint(ck.Key)
+			default:
+				m.statusMsg = fmt.Sprintf("Unknown choice %q (use r or s)", value)
+				return nil
+			}
+			return m.performSearch()
+		},
+	}
+}
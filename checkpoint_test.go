@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCheckpointerPrepare(t *testing.T) {
+	dir := t.TempDir()
+	searched := func(name, content string) searchedFile {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return searchedFile{Path: path, Size: info.Size(), ModTime: info.ModTime()}
+	}
+	kept := searched("kept", "match\n")
+	grown := searched("grown", "match\n")
+	touched := searched("touched", "match\n")
+	removed := searched("removed", "match\n")
+	empty := searched("empty", "")
+
+	// The files change after they were searched, each in its own way
+	if err := os.WriteFile(grown.Path, []byte("match\nmore\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := touched.ModTime.Add(time.Hour)
+	if err := os.Chtimes(touched.Path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(removed.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []SearchResult
+	for _, f := range []searchedFile{kept, grown, touched, removed} {
+		results = append(results, SearchResult{FilePath: f.Path, LineNumber: 1, LineContent: "match"})
+	}
+	resume := &searchCheckpoint{
+		Done:    []searchedFile{kept, grown, touched, removed, empty},
+		Results: results,
+		Started: time.Now().Add(-time.Minute),
+	}
+	c := newCheckpointer("key", "match", []string{dir}, resume)
+	skip, prior := c.prepare()
+
+	var skipped []string
+	for path := range skip {
+		skipped = append(skipped, filepath.Base(path))
+	}
+	sort.Strings(skipped)
+	if want := []string{"empty", "kept"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("prepare skips %v; want %v", skipped, want)
+	}
+	if len(prior) != 1 || prior[0].FilePath != kept.Path {
+		t.Errorf("prepare kept results %v; want only those of %s", prior, kept.Path)
+	}
+	if want := []searchedFile{kept, empty}; !reflect.DeepEqual(c.done, want) {
+		t.Errorf("checkpoint carries %v; want %v", c.done, want)
+	}
+	if !c.started.Equal(resume.Started) {
+		t.Errorf("started %v; want the resumed search's %v", c.started, resume.Started)
+	}
+
+	// A search that isn't resumed skips nothing
+	if skip, prior := newCheckpointer("key", "match", []string{dir}, nil).prepare(); skip != nil || prior != nil {
+		t.Errorf("fresh prepare = %v, %v; want nothing", skip, prior)
+	}
+}
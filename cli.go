@@ -0,0 +1,470 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// usage is printed by `zx help` and for -h before the top-level flags
+const usage = `Usage:
+  zx [flags]                         Browse the current directory
+  zx [flags] <pattern> <path>        Search a file or directory (- for stdin) and print the results
+  zx [flags] -files-from <list> <pattern>
+                                     Search the files listed in a file (- for stdin)
+  zx [flags] -f <patterns> <path>    Search for any of the patterns in a file, one per line
+  zx browse [flags] [dir]            Browse a directory, searching it interactively
+  zx search [flags] <pattern> [path...]
+                                     Search paths, or a -workspace's roots, and print the results
+  zx analyze [flags] [dir]           Print what a search of a directory would cover
+  zx index [flags] [dir...]          Build trigram indexes that let searches skip files
+  zx image [flags] <ref> <pattern>   Search the layers of a container image
+  zx k8s [flags] <pattern> [pod...]  Search Kubernetes pod logs
+  zx journal [flags] <pattern>       Search the systemd journal
+  zx eventlog [flags] <pattern>      Search the Windows Event Log
+  zx help                            Show this help
+
+Flags may come before or after the other arguments; "--" ends them, so
+"zx -- -v file" searches for "-v". Run "zx <command> -h" for a command's flags.
+
+Settings come from config.toml in the user config directory (-config file
+or $ZX_CONFIG for another), then the nearest .zx.toml in the current
+directory or its parents, then ZX_* variables such as ZX_THEME or
+ZX_MAX_FILE_SIZE; flags override them all.
+`
+
+// pinned records the limits set on the command line, which auto
+// configuration then leaves alone
+var pinned struct {
+	maxFileSize, maxResults, workers bool
+}
+
+// configFlags are the command-line flags for the settings of SearchConfig,
+// shared by the interactive mode and the commands that search
+type configFlags struct {
+	maxFileSize     string
+	maxResults      int
+	maxPerFile      int
+	workers         int
+	include         string
+	exclude         string
+	invert          bool
+	fileLevel       bool
+	noIgnore        bool
+	hidden          bool
+	minified        bool
+	stashes         bool
+	maxDepth        int
+	noIndex         bool
+	noSkipDirs      bool
+	minSize         string
+	maxSize         string
+	modifiedWithin  string
+	always          string
+	network         string
+	suggestMode     string
+	suggestDistance int
+	suggestMax      int
+}
+
+// register defines the flags on fs, defaulting to the values f holds
+// (from the [search] table of the config)
+func (f *configFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.maxFileSize, "max-file-size", f.maxFileSize, "Skip files larger than this, e.g. 500MB (default 100MB; interactive searches pick one for the files)")
+	fs.IntVar(&f.maxResults, "max-results", f.maxResults, "Keep at most this many results (default all when printing; interactive searches pick a limit for the files)")
+	fs.IntVar(&f.maxPerFile, "max-per-file", f.maxPerFile, "Report at most this many matching lines per file, counting the rest (0 = unlimited)")
+	fs.IntVar(&f.workers, "workers", f.workers, "Files an interactive search reads at once (default from the number of CPUs and files)")
+	fs.StringVar(&f.include, "include", f.include, "Only search files whose names match these comma-separated extensions or globs, e.g. go,md or *_test.go")
+	fs.StringVar(&f.exclude, "exclude", f.exclude, "Leave out files and directories whose names match these comma-separated globs, e.g. vendor,*.lock")
+	fs.BoolVar(&f.invert, "v", false, "Invert match: show lines that do NOT match the pattern")
+	fs.BoolVar(&f.fileLevel, "file-level", false, "Evaluate && / ! combinators over whole files instead of lines")
+	fs.BoolVar(&f.noIgnore, "no-ignore", f.noIgnore, "Don't respect .gitignore, .ignore and .zxignore files (include git-ignored files)")
+	fs.BoolVar(&f.hidden, "hidden", f.hidden, "Also search hidden files (dotfiles such as .env)")
+	fs.BoolVar(&f.minified, "minified", f.minified, "Also search minified assets (*.min.js, very long lines) and source maps")
+	fs.BoolVar(&f.stashes, "stashes", f.stashes, "Also search files saved in git stashes")
+	fs.IntVar(&f.maxDepth, "max-depth", f.maxDepth, "Descend at most this many directory levels below each target (0 = unlimited)")
+	fs.BoolVar(&f.noIndex, "no-index", f.noIndex, "Don't use trigram indexes built with 'zx index'")
+	fs.BoolVar(&f.noSkipDirs, "no-skip-dirs", f.noSkipDirs, "Also search .git, node_modules, vendor, target and the other skip_dirs of the config")
+	fs.StringVar(&f.minSize, "min-size", "", "Only search files at least this large, e.g. 1KB")
+	fs.StringVar(&f.maxSize, "max-size", "", "Only search files at most this large, e.g. 5MB")
+	fs.StringVar(&f.modifiedWithin, "modified-within", "", "Only search files modified within this window, e.g. 12h or 7d")
+	fs.StringVar(&f.always, "always", "", "Comma-separated globs or paths of files to search even if hidden, binary or too large")
+	fs.StringVar(&f.network, "network", "", "Network safe mode for NFS/SMB mounts: auto, on, off (default from config, else auto)")
+	fs.StringVar(&f.suggestMode, "suggest", "", "Suggestions when nothing matches: tokens, lines, off (default from config, else tokens)")
+	fs.IntVar(&f.suggestDistance, "suggest-distance", 0, "Edits allowed between the pattern and a suggestion (default 2)")
+	fs.IntVar(&f.suggestMax, "suggest-max", 0, "Show at most this many suggestions (default 5)")
+}
+
+// apply sets the flags that were given on config, which holds the defaults
+// and the config file's settings
+func (f *configFlags) apply(config SearchConfig) (SearchConfig, error) {
+	var err error
+	if f.maxFileSize != "" {
+		if config.MaxFileSize, err = parseSize(f.maxFileSize); err != nil {
+			return config, err
+		}
+		pinned.maxFileSize = true
+	}
+	if f.maxResults > 0 {
+		config.MaxResults = f.maxResults
+		pinned.maxResults = true
+	}
+	if f.workers > 0 {
+		config.MaxConcurrency = f.workers
+		pinned.workers = true
+	}
+	if f.maxPerFile > 0 {
+		config.MaxPerFile = f.maxPerFile
+	}
+	if f.include != "" {
+		config.IncludePatterns = typePatterns(f.include)
+	}
+	if f.exclude != "" {
+		config.ExcludePatterns = strings.FieldsFunc(f.exclude, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	for _, patterns := range [][]string{config.IncludePatterns, config.ExcludePatterns} {
+		for _, pattern := range patterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return config, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	config.InvertMatch = config.InvertMatch || f.invert
+	config.FileLevelMatch = config.FileLevelMatch || f.fileLevel
+	config.NoIgnore = config.NoIgnore || f.noIgnore
+	config.IncludeHidden = config.IncludeHidden || f.hidden
+	config.IncludeMinified = config.IncludeMinified || f.minified
+	config.SearchStashes = config.SearchStashes || f.stashes
+	config.NoIndex = config.NoIndex || f.noIndex
+	config.NoSkipDirs = config.NoSkipDirs || f.noSkipDirs
+	if f.maxDepth > 0 {
+		config.MaxDepth = f.maxDepth
+	}
+	if f.minSize != "" {
+		if config.MinSize, err = parseSize(f.minSize); err != nil {
+			return config, err
+		}
+	}
+	if f.maxSize != "" {
+		if config.MaxSize, err = parseSize(f.maxSize); err != nil {
+			return config, err
+		}
+	}
+	if f.modifiedWithin != "" {
+		if config.ModifiedWithin, err = parseAge(f.modifiedWithin); err != nil {
+			return config, err
+		}
+	}
+
+	extra, err := alwaysSearchPatterns(strings.Split(f.always, ","))
+	if err != nil {
+		return config, err
+	}
+	config.AlwaysSearch = append(config.AlwaysSearch, extra...)
+	if f.network != "" {
+		if config.Network, err = parseNetworkMode(f.network); err != nil {
+			return config, err
+		}
+	}
+	if config.Suggest, err = config.Suggest.withMode(f.suggestMode); err != nil {
+		return config, err
+	}
+	if f.suggestDistance > 0 {
+		config.Suggest.MaxDistance = f.suggestDistance
+	}
+	if f.suggestMax > 0 {
+		config.Suggest.MaxSuggestions = f.suggestMax
+	}
+	return config, nil
+}
+
+// uiFlags are the command-line flags for how zx looks
+type uiFlags struct {
+	theme      string
+	palette    string
+	matchStyle string
+	color      string
+	icons      string
+	columns    string
+}
+
+// uiSettings are the themes uiFlags chose, for the interactive mode
+type uiSettings struct {
+	theme, matchMode, icons int
+}
+
+// register defines the flags on fs
+func (f *uiFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.theme, "theme", "", "Theme: "+strings.Join(themeNames(), ", ")+" (default from config, else default)")
+	fs.StringVar(&f.palette, "palette", "", "Alias of -theme")
+	fs.StringVar(&f.matchStyle, "match-style", "color", "Match highlight: color, underline, reverse, bold")
+	fs.StringVar(&f.color, "color", "", "Colors: auto, truecolor, 256, 16, mono, none (default from config, else auto)")
+	fs.StringVar(&f.icons, "icons", "", "Icon theme: emoji, nerd-font, ascii, none (default from config, else emoji)")
+	fs.StringVar(&f.columns, "columns", "", "Columns of CSV and TSV output and exports, e.g. file,line,match (default file,line,column,content,note)")
+}
+
+// apply sets the colors, theme, icons and export columns the flags and
+// the config file ask for
+func (f *uiFlags) apply(fileConfig FileConfig) (uiSettings, error) {
+	var ui uiSettings
+	columns := fileConfig.CSVColumns
+	if f.columns != "" {
+		columns = []string{f.columns}
+	}
+	if len(columns) > 0 {
+		var err error
+		if exportColumns, err = parseColumns(columns); err != nil {
+			return ui, err
+		}
+	}
+	if f.color != "" {
+		level, err := resolveColorLevel(f.color)
+		if err != nil {
+			return ui, err
+		}
+		setColorLevel(level)
+	}
+	name := f.theme
+	if name == "" {
+		name = f.palette
+	}
+	if name == "" {
+		name = fileConfig.Theme
+	}
+	var err error
+	if ui.theme, err = findTheme(name); err != nil {
+		return ui, err
+	}
+	if ui.matchMode, err = findMatchMode(f.matchStyle); err != nil {
+		return ui, err
+	}
+	applyTheme(themes[ui.theme], matchModes[ui.matchMode])
+
+	name = f.icons
+	if name == "" {
+		name = fileConfig.IconTheme
+	}
+	if name != "" {
+		if ui.icons, err = findIconTheme(name); err != nil {
+			return ui, err
+		}
+	}
+	icons = iconThemes[ui.icons]
+	setIconOverrides(fileConfig.Icons)
+	return ui, setUserPatterns(fileConfig.Patterns)
+}
+
+// templateOutput returns the formatter of -template; conflict reports
+// whether another output flag (-format, -json, -l or -c) was given too
+func templateOutput(text string, conflict bool) (Formatter, error) {
+	if conflict {
+		return nil, fmt.Errorf("-template can't be combined with -format, -json, -l or -c")
+	}
+	return newTemplateFormatter(text)
+}
+
+// registerLayout defines the flags of outputLayout on fs; any of them asks
+// for printed results, so set reports whether one was given
+func registerLayout(fs *flag.FlagSet, set *bool) {
+	flagSet := func(apply func(on bool)) func(string) error {
+		return func(value string) error {
+			on, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			apply(on)
+			*set = true
+			return nil
+		}
+	}
+	null := flagSet(func(on bool) { outputLayout.null = on })
+	fs.BoolFunc("0", "End each path with a NUL byte instead of ':' or a newline, for xargs -0 (prints the results)", null)
+	fs.BoolFunc("print0", "Same as -0", null)
+	fs.BoolFunc("heading", "Print each file's path once above its lines (prints the results)", flagSet(func(on bool) { outputLayout.heading = on }))
+	fs.BoolFunc("no-heading", "Repeat the path on every line (the default; prints the results)", flagSet(func(on bool) { outputLayout.heading = !on }))
+	fs.BoolFunc("with-filename", "Print paths, on every line unless -heading (the default; prints the results)", flagSet(func(on bool) { outputLayout.noPath = !on }))
+	fs.BoolFunc("no-filename", "Leave paths out: line:col:text lines, or bare counts with -c (prints the results)", flagSet(func(on bool) { outputLayout.noPath = on }))
+}
+
+// readListFile reads the entries of a list file for -f or -files-from,
+// "-" meaning standard input: its lines, or NUL-separated entries as
+// written by find -print0. Blank entries are dropped.
+func readListFile(path string) ([]string, error) {
+	var data []byte
+	var err error
+	if path == stdinTarget {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sep := "\n"
+	if strings.Contains(string(data), "\x00") {
+		sep = "\x00"
+	}
+	var entries []string
+	for _, entry := range strings.Split(string(data), sep) {
+		if entry = strings.TrimSuffix(entry, "\r"); strings.TrimSpace(entry) != "" {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s lists nothing", listName(path))
+	}
+	return entries, nil
+}
+
+// listName names a list file in messages
+func listName(path string) string {
+	if path == stdinTarget {
+		return "standard input"
+	}
+	return path
+}
+
+// readPatterns reads the patterns of -f, one per line, as one expression
+// matching any of them. A line can itself be an expression such as
+// "error && ! test", as typed in the search box.
+func readPatterns(path string) (string, error) {
+	patterns, err := readListFile(path)
+	if err != nil {
+		return "", err
+	}
+	for i, p := range patterns {
+		patterns[i] = strings.TrimSpace(p)
+	}
+	return strings.Join(patterns, " || "), nil
+}
+
+// legacyInputs works out the pattern and what to search from the
+// arguments of a pattern-and-target search, which -f (patternsFile) and
+// -files-from (filesFrom) stand in for; files is set for -files-from
+func legacyInputs(args []string, patternsFile, filesFrom string) (pattern, target string, files []string, err error) {
+	if patternsFile == stdinTarget && filesFrom == stdinTarget {
+		return "", "", nil, fmt.Errorf("-f and -files-from can't both read standard input")
+	}
+	if patternsFile == stdinTarget && len(args) > 0 && args[0] == stdinTarget {
+		return "", "", nil, fmt.Errorf("-f - reads the patterns from standard input, so it can't be searched too")
+	}
+	if patternsFile != "" {
+		if pattern, err = readPatterns(patternsFile); err != nil {
+			return "", "", nil, err
+		}
+	} else if len(args) > 0 {
+		pattern, args = args[0], args[1:]
+	} else {
+		return "", "", nil, fmt.Errorf("no pattern to search for (give one, or -f with a file of patterns)")
+	}
+	if filesFrom != "" {
+		if len(args) > 0 {
+			return "", "", nil, fmt.Errorf("give either -files-from or a target, not both")
+		}
+		files, err = readListFile(filesFrom)
+		return pattern, listName(filesFrom), files, err
+	}
+	if len(args) == 0 {
+		return "", "", nil, fmt.Errorf("no target to search (give a path, - for standard input, or -files-from)")
+	}
+	return pattern, args[0], nil, nil
+}
+
+// parseInterspersed parses flags anywhere among args, as in "zx search
+// TODO . -hidden", and returns the other arguments in order. Arguments
+// after "--" are never flags.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var rest []string
+	for len(args) > 0 {
+		fs.Parse(args)
+		// Parse drops the "--" it stops at
+		consumed := len(args) - fs.NArg()
+		if consumed > 0 && args[consumed-1] == "--" {
+			return append(rest, fs.Args()...)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	return rest
+}
+
+// runAnalyzeCommand prints the analysis of the directory in args, or the
+// current one, with the settings of config
+func runAnalyzeCommand(args []string, config SearchConfig, jsonOut bool, w io.Writer) error {
+	dir := "."
+	if len(args) > 1 {
+		return fmt.Errorf("usage: zx analyze [flags] [dir]")
+	}
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	m := initialModel()
+	m.searchConfig = config
+	m.analysis = m.analyzeFolderStructure([]string{dir})
+	if jsonOut {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m.analysis)
+	}
+	fmt.Fprintln(w, m.renderAnalysis())
+	return nil
+}
+
+// runInteractive opens the interface on dir with config, and a bundle
+// first if importPath names one
+func runInteractive(dir string, config SearchConfig, ui uiSettings, importPath string) error {
+	m := initialModel()
+	if dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		if info, err := os.Stat(abs); err != nil {
+			return err
+		} else if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		m.currentDir = abs
+		m.loadDirectory()
+	}
+	m.searchConfig = config
+	m.themeIndex = ui.theme
+	m.matchModeIndex = ui.matchMode
+	m.iconIndex = ui.icons
+	var err error
+	if err := m.loadSearchHistory(); err != nil {
+		m.statusMsg = fmt.Sprintf("Error loading search history: %v", err)
+	}
+	if m.bookmarks, err = loadBookmarks(); err != nil {
+		m.statusMsg = fmt.Sprintf("Error loading bookmarks: %v", err)
+	}
+	if importPath != "" {
+		bundle, err := readBundle(importPath)
+		if err != nil {
+			return err
+		}
+		m.loadBundle(importPath, bundle)
+	}
+	p := tea.NewProgram(m, tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		return fmt.Errorf("running program: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/muesli/termenv"
+)
+
+// clipboardCommands are tried in order to copy text on the local machine
+var clipboardCommands = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+}
+
+// copyToClipboard copies text with the first available clipboard command,
+// falling back to the OSC 52 escape sequence, which most terminals (also
+// over SSH) turn into a clipboard write
+func copyToClipboard(text string) {
+	commands := clipboardCommands
+	if runtime.GOOS == "windows" {
+		commands = [][]string{{"clip"}}
+	}
+	for _, args := range commands {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if cmd.Run() == nil {
+			return
+		}
+	}
+	termenv.Copy(text)
+}
+
+// promptCopy asks what of the selected result (or of all visible results)
+// to copy to the clipboard
+func (m *model) promptCopy() {
+	if m.visibleResults.len() == 0 {
+		m.statusMsg = "No results to copy"
+		return
+	}
+	m.prompt = &inputPrompt{
+		label:  "Copy the [p]ath, path:[l]ine, the [m]atch, the whole line [t]ext, or [a]ll visible results? ",
+		input:  "l",
+		cursor: 1,
+		onSubmit: func(m *model, value string) {
+			m.copyResults(strings.ToLower(value))
+		},
+	}
+}
+
+// copyResults copies part of the selected result, or every visible result
+// as path:line:col:text lines
+func (m *model) copyResults(what string) {
+	if what == "a" || what == "all" {
+		var b strings.Builder
+		plainFormatter{}.Format(&b, resultReport{Results: m.visibleResults.slice()})
+		copyToClipboard(b.String())
+		m.statusMsg = fmt.Sprintf("Copied %d results (%d bytes)", m.visibleResults.len(), b.Len())
+		return
+	}
+
+	r := m.visibleResults.at(min(m.resultIndex, m.visibleResults.len()-1))
+	var text, desc string
+	switch what {
+	case "p", "path":
+		text, desc = r.FilePath, "path"
+	case "l", "line":
+		text, desc = fmt.Sprintf("%s:%d", r.FilePath, r.LineNumber), "location"
+	case "m", "match":
+		var matches []string
+		for _, match := range r.Matches {
+			if match.Start < match.End && match.End <= len(r.LineContent) {
+				matches = append(matches, r.LineContent[match.Start:match.End])
+			}
+		}
+		if len(matches) == 0 {
+			m.statusMsg = "The selected line has no match to copy (try t for the whole line)"
+			return
+		}
+		text, desc = strings.Join(matches, "\n"), "match"
+		if len(matches) > 1 {
+			desc = fmt.Sprintf("%d matches", len(matches))
+		}
+	case "t", "text":
+		text, desc = r.LineContent, "line"
+	default:
+		m.statusMsg = fmt.Sprintf("Unknown choice %q (use p, l, m, t or a)", what)
+		return
+	}
+	copyToClipboard(text)
+	m.statusMsg = fmt.Sprintf("Copied %s: %s", desc, clipPreview(text))
+}
+
+// clipPreview shortens copied text to its start, for the status line
+func clipPreview(text string) string {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i] + " ..."
+	}
+	if runes := []rune(text); len(runes) > 60 {
+		text = string(runes[:57]) + "..."
+	}
+	return text
+}
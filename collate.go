@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// nameOrder is how the file browser compares entry names
+type nameOrder int
+
+const (
+	orderLocale nameOrder = iota // Collation rules of the locale, so Äpfel sorts with apple
+	orderFold                    // Unicode case folding, the same in every locale
+	orderBytes                   // Byte order: uppercase before lowercase, accents last
+)
+
+// nameOrderNames are the values of sort in the config file, by nameOrder
+var nameOrderNames = []string{"locale", "fold", "bytes"}
+
+var (
+	nameSort     = orderLocale
+	collatorMu   sync.Mutex // A Collator reuses buffers between calls; held by nameKeys
+	nameCollator = collate.New(language.Und)
+)
+
+// setNameOrder selects how names are sorted; locale overrides the
+// language read from LC_ALL, LC_COLLATE or LANG
+func setNameOrder(order, locale string) error {
+	nameSort = orderLocale
+	if order != "" {
+		found := false
+		for i, name := range nameOrderNames {
+			if strings.EqualFold(name, order) {
+				nameSort, found = nameOrder(i), true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown sort order %q (use %s)", order, strings.Join(nameOrderNames, ", "))
+		}
+	}
+
+	tag := envLocale()
+	if locale != "" {
+		var err error
+		if tag, err = language.Parse(locale); err != nil {
+			return fmt.Errorf("invalid sort_locale %q: %v", locale, err)
+		}
+	}
+	collatorMu.Lock()
+	nameCollator = collate.New(tag)
+	collatorMu.Unlock()
+	return nil
+}
+
+// envLocale returns the collation language of the environment; C, POSIX
+// and unparsable locales use the root collation order
+func envLocale() language.Tag {
+	name := ""
+	for _, key := range []string{"LC_ALL", "LC_COLLATE", "LANG"} {
+		if name = os.Getenv(key); name != "" {
+			break
+		}
+	}
+	// de_DE.UTF-8@euro -> de-DE
+	if i := strings.IndexAny(name, ".@"); i >= 0 {
+		name = name[:i]
+	}
+	if name == "" || name == "C" || name == "POSIX" {
+		return language.Und
+	}
+	tag, err := language.Parse(strings.ReplaceAll(name, "_", "-"))
+	if err != nil {
+		return language.Und
+	}
+	return tag
+}
+
+// nameKeys computes the sort keys of entry names under nameSort. Keys
+// compare with bytes.Compare the way their names collate, so a sort keys
+// each name once instead of collating both names of every comparison.
+// Callers hold collatorMu while using it.
+type nameKeys struct {
+	buf collate.Buffer // Backs the locale keys handed out, until dropped
+}
+
+// key returns the sort key of name
+func (k *nameKeys) key(name string) []byte {
+	switch nameSort {
+	case orderLocale:
+		return nameCollator.KeyFromString(&k.buf, name)
+	case orderFold:
+		return []byte(cases.Fold().String(name))
+	}
+	return []byte(name)
+}
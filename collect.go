@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// collectTree counts the files a search collects per directory while it
+// walks its targets, so a directory that balloons can be pruned while the
+// walk goes on. The model and the search goroutine share it.
+type collectTree struct {
+	mu       sync.Mutex
+	roots    []string
+	files    map[string]int64    // Files collected under each directory
+	children map[string][]string // Subdirectories found so far
+	pruned   map[string]bool
+	done     bool
+
+	open map[string]bool // Directories expanded in the view; only the UI uses it
+}
+
+// collectRow is one line of the collection tree view
+type collectRow struct {
+	dir    string
+	depth  int
+	files  int64
+	pruned bool
+	open   bool
+	leaf   bool
+}
+
+// newCollectTree prepares the tree for a search of roots
+func newCollectTree(roots []string) *collectTree {
+	t := &collectTree{
+		roots:    roots,
+		files:    make(map[string]int64),
+		children: make(map[string][]string),
+		pruned:   make(map[string]bool),
+		open:     make(map[string]bool),
+	}
+	for _, root := range roots {
+		t.open[root] = true
+	}
+	return t
+}
+
+// addDir records a directory found below root
+func (t *collectTree) addDir(root, dir string) {
+	if dir == root {
+		return
+	}
+	t.mu.Lock()
+	parent := filepath.Dir(dir)
+	t.children[parent] = append(t.children[parent], dir)
+	t.mu.Unlock()
+}
+
+// addFile counts a collected file in each directory from its own up to root
+func (t *collectTree) addFile(root, path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		t.files[dir]++
+		if dir == root || dir == filepath.Dir(dir) {
+			return
+		}
+	}
+}
+
+// isPruned reports whether dir or a directory above it, up to root, was
+// pruned
+func (t *collectTree) isPruned(root, dir string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pruned) == 0 {
+		return false
+	}
+	for ; ; dir = filepath.Dir(dir) {
+		if t.pruned[dir] {
+			return true
+		}
+		if dir == root || dir == filepath.Dir(dir) {
+			return false
+		}
+	}
+}
+
+// prune drops dir from the search, leaving out the files in it not yet
+// searched; it reports false once collection is over
+func (t *collectTree) prune(dir string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return false
+	}
+	t.pruned[dir] = !t.pruned[dir]
+	if !t.pruned[dir] {
+		delete(t.pruned, dir)
+	}
+	return true
+}
+
+// finish ends collection and drops the files collected before their
+// directory was pruned, returning the others
+func (t *collectTree) finish(files []string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done = true
+	if len(t.pruned) == 0 {
+		return files
+	}
+
+	kept := files[:0]
+	for _, file := range files {
+		drop := false
+		for dir := filepath.Dir(file); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			if t.pruned[dir] {
+				drop = true
+				break
+			}
+		}
+		if !drop {
+			kept = append(kept, file)
+		}
+	}
+	return kept
+}
+
+// collecting reports whether the search is still collecting files
+func (t *collectTree) collecting() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.done
+}
+
+// totals returns the files collected and directories found so far
+func (t *collectTree) totals() (files int64, dirs int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, root := range t.roots {
+		files += t.files[root]
+	}
+	for _, children := range t.children {
+		dirs += len(children)
+	}
+	return files, dirs
+}
+
+// rows flattens the expanded part of the tree, largest directories first
+func (t *collectTree) rows() []collectRow {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var rows []collectRow
+	var visit func(dir string, depth int)
+	visit = func(dir string, depth int) {
+		children := t.children[dir]
+		rows = append(rows, collectRow{
+			dir:    dir,
+			depth:  depth,
+			files:  t.files[dir],
+			pruned: t.pruned[dir],
+			open:   t.open[dir],
+			leaf:   len(children) == 0,
+		})
+		if !t.open[dir] || t.pruned[dir] {
+			return
+		}
+		sorted := append([]string(nil), children...)
+		sort.Slice(sorted, func(i, j int) bool {
+			if t.files[sorted[i]] != t.files[sorted[j]] {
+				return t.files[sorted[i]] > t.files[sorted[j]]
+			}
+			return sorted[i] < sorted[j]
+		})
+		for _, child := range sorted {
+			visit(child, depth+1)
+		}
+	}
+	for _, root := range t.roots {
+		if info, err := os.Stat(root); err == nil && info.IsDir() {
+			visit(root, 0)
+		}
+	}
+	return rows
+}
+
+// toggleOpen expands or collapses a directory of the view
+func (t *collectTree) toggleOpen(dir string, open bool) {
+	t.mu.Lock()
+	t.open[dir] = open
+	t.mu.Unlock()
+}
+
+// updateCollectTree handles keys in SearchProgressMode while files are
+// being collected
+func (m *model) updateCollectTree(key string) {
+	rows := m.collect.rows()
+	m.collectIndex = max(0, min(m.collectIndex, len(rows)-1))
+	if len(rows) == 0 {
+		return
+	}
+	row := rows[m.collectIndex]
+	switch key {
+	case "up", "k":
+		if m.collectIndex > 0 {
+			m.collectIndex--
+		}
+
+	case "down", "j":
+		if m.collectIndex < len(rows)-1 {
+			m.collectIndex++
+		}
+
+	case "enter", "right", "l":
+		m.collect.toggleOpen(row.dir, !row.open || key != "enter")
+
+	case "left", "h":
+		m.collect.toggleOpen(row.dir, false)
+
+	case "x", "delete":
+		if !m.collect.prune(row.dir) {
+			m.statusMsg = "Collection is over; every file found is being searched"
+		} else if row.pruned {
+			m.statusMsg = fmt.Sprintf("Searching %s again", row.dir)
+		} else {
+			m.statusMsg = fmt.Sprintf("Pruned %s (%d files so far); x again to keep it", row.dir, row.files)
+		}
+	}
+}
+
+// renderCollectTree shows the directories found so far with their file
+// counts
+func (m model) renderCollectTree() string {
+	var b strings.Builder
+	files, dirs := m.collect.totals()
+	b.WriteString(headerStyle.Render(fmt.Sprintf("Collecting files: %d in %d directories so far", files, dirs)))
+	b.WriteString("\n")
+	progress := m.searchResults.Progress
+	b.WriteString(fmt.Sprintf("Searched %d of them, %d matches so far", progress.ProcessedFiles, progress.Matches))
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render("↑↓: choose, Enter/→: expand, ←: collapse, x: prune"))
+	b.WriteString("\n\n")
+
+	rows := m.collect.rows()
+	height := max(1, m.viewport.height-4)
+	index := min(m.collectIndex, len(rows)-1)
+	start := max(0, index-height+1)
+	end := min(start+height, len(rows))
+	for i := start; i < end; i++ {
+		row := rows[i]
+		marker := "▸ "
+		switch {
+		case row.leaf:
+			marker = "  "
+		case row.open && !row.pruned:
+			marker = "▾ "
+		}
+		name := filepath.Base(row.dir) + string(filepath.Separator)
+		if row.depth == 0 {
+			name = row.dir
+		}
+		line := fmt.Sprintf("%s%s%s  %d files", strings.Repeat("  ", row.depth), marker, name, row.files)
+		switch {
+		case i == index:
+			if row.pruned {
+				line += "  (pruned)"
+			}
+			b.WriteString(renderSelected(line))
+		case row.pruned:
+			b.WriteString(helpStyle.Render(line + "  (pruned)"))
+		default:
+			b.WriteString(directoryStyle.Render(line))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// sortField is what the file browser orders entries by
+type sortField int
+
+const (
+	sortByName sortField = iota
+	sortBySize
+	sortByModified
+	sortByExtension
+)
+
+var sortFieldNames = []string{"name", "size", "modified", "extension"}
+
+// browserSort is the order of the file browser's entries; like nameSort
+// it's shared by every tab
+var browserSort = struct {
+	field   sortField
+	reverse bool
+}{}
+
+// keyedItem is an entry with the keys of its name and extension under
+// nameSort, computed once per sort
+type keyedItem struct {
+	FileItem
+	name, ext []byte
+}
+
+// keyItems pairs items with their sort keys
+func keyItems(items []FileItem) []keyedItem {
+	collatorMu.Lock()
+	defer collatorMu.Unlock()
+	var keys nameKeys
+	keyed := make([]keyedItem, len(items))
+	for i, item := range items {
+		keyed[i] = keyedItem{FileItem: item, name: keys.key(item.Name)}
+		if browserSort.field == sortByExtension && !item.IsDir {
+			keyed[i].ext = keys.key(strings.ToLower(filepath.Ext(item.Name)))
+		}
+	}
+	return keyed
+}
+
+// compareItems orders two entries of the same kind by browserSort, by
+// name among equals. Names equal under collation or folding fall back to
+// byte order, so the order is still total.
+func compareItems(a, b keyedItem) int {
+	c := 0
+	switch browserSort.field {
+	case sortBySize:
+		if !a.IsDir && !b.IsDir {
+			c = compareInts(a.Size, b.Size)
+		}
+	case sortByModified:
+		c = a.ModTime.Compare(b.ModTime)
+	case sortByExtension:
+		if !a.IsDir && !b.IsDir {
+			c = bytes.Compare(a.ext, b.ext)
+		}
+	}
+	if c == 0 {
+		c = bytes.Compare(a.name, b.name)
+	}
+	if c == 0 {
+		c = strings.Compare(a.Name, b.Name)
+	}
+	if browserSort.reverse {
+		return -c
+	}
+	return c
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// cycleSort moves to the next sort field, or flips the direction
+func (m *model) cycleSort(flip bool) {
+	if flip {
+		browserSort.reverse = !browserSort.reverse
+	} else {
+		browserSort.field = (browserSort.field + 1) % sortField(len(sortFieldNames))
+		// Newest and largest first read better
+		browserSort.reverse = browserSort.field == sortBySize || browserSort.field == sortByModified
+	}
+	if m.treeView {
+		// Expanded directories are listed again, each sorted in turn
+		m.refreshDirectory()
+	} else if len(m.files) > 0 {
+		current := m.files[m.selectedFile].Path
+		sortFileItems(m.files)
+		for i, file := range m.files {
+			if file.Path == current {
+				m.selectedFile = i
+				break
+			}
+		}
+		m.adjustViewport()
+	}
+	m.statusMsg = "Sorted by " + sortLabel()
+}
+
+// sortLabel names the sort field and direction
+func sortLabel() string {
+	arrow := "↑"
+	if browserSort.reverse {
+		arrow = "↓"
+	}
+	return sortFieldNames[browserSort.field] + " " + arrow
+}
+
+// formatModTime shows a modification time like ls: the time of day this
+// year, the year for older ones
+func formatModTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if t.Year() == time.Now().Year() {
+		return t.Format("Jan _2 15:04")
+	}
+	return t.Format("Jan _2  2006")
+}
+
+// browserRow lays out an entry of the file browser as aligned columns:
+// name, size, modification time and permissions. name is the entry's
+// icon and name, with its tree indentation.
+func (m model) browserRow(file FileItem, name string) string {
+	if file.Name == ".." {
+		return name
+	}
+	size := formatSize(file.Size)
+	if file.IsDir {
+		size = "-"
+	}
+	details := fmt.Sprintf("  %9s  %-12s  %s", size, formatModTime(file.ModTime), file.Mode.String())
+
+	width := m.viewport.width
+	if width <= 0 {
+		width = 80
+	}
+	nameWidth := max(20, width-lipgloss.Width(details)-1)
+	if w := lipgloss.Width(name); w > nameWidth {
+		name = truncateWidth(name, nameWidth-1) + "…"
+	} else {
+		name += strings.Repeat(" ", nameWidth-w)
+	}
+	return name + details
+}
+
+// truncateWidth cuts s to at most width terminal columns
+func truncateWidth(s string, width int) string {
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		w := lipgloss.Width(string(r))
+		if used+w > width {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+
+	"github.com/ulikunitz/xz"
+)
+
+// Compression formats recognized by detectCompression
+const (
+	CompressionGzip  = "gzip"
+	CompressionBzip2 = "bzip2"
+	CompressionXz    = "xz"
+)
+
+var (
+	magicGzip  = []byte{0x1F, 0x8B}
+	magicBzip2 = []byte("BZh")
+	magicXz    = []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}
+
+	// A bzip2 stream's first block, or its end when there are no blocks,
+	// follows the "BZh" and block size digit
+	magicBzip2Block = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+	magicBzip2End   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+// magicSize is the sample detectCompression needs to recognize every format
+const magicSize = 10
+
+// detectCompression identifies a compressed stream by its magic bytes
+func detectCompression(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, magicGzip):
+		return CompressionGzip
+	case isBzip2(sample):
+		return CompressionBzip2
+	case bytes.HasPrefix(sample, magicXz):
+		return CompressionXz
+	}
+	return ""
+}
+
+// isBzip2 requires more than "BZh", which plenty of text files start with
+func isBzip2(sample []byte) bool {
+	if len(sample) < magicSize || !bytes.HasPrefix(sample, magicBzip2) {
+		return false
+	}
+	if sample[3] < '1' || sample[3] > '9' {
+		return false
+	}
+	return bytes.Equal(sample[4:magicSize], magicBzip2Block) || bytes.Equal(sample[4:magicSize], magicBzip2End)
+}
+
+// decompressingReader wraps r with a streaming decoder for kind
+func decompressingReader(r io.Reader, kind string) (io.Reader, error) {
+	switch kind {
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionBzip2:
+		return bzip2.NewReader(r), nil
+	case CompressionXz:
+		return xz.NewReader(r)
+	default:
+		return r, nil
+	}
+}
+
+// decompressedSample returns up to n bytes of decompressed content
+func decompressedSample(r io.Reader, kind string, n int) ([]byte, error) {
+	dr, err := decompressingReader(r, kind)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	read, err := io.ReadFull(dr, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return buf[:read], nil
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// projectConfigName is the file of a project's settings, looked for in the
+// current directory and its parents
+const projectConfigName = ".zx.toml"
+
+// FileConfig is the user configuration loaded from config.toml, with a
+// project's .zx.toml and ZX_* environment variables over it
+type FileConfig struct {
+	// Extractors maps a file extension to an external command that prints
+	// the file's text to stdout. {path} is replaced by the file path, e.g.
+	//
+	//	[extractors]
+	//	".pdf" = "pdftotext -q {path} -"
+	Extractors map[string]string `toml:"extractors"`
+
+	// Color sets the color level (auto, truecolor, 256, 16, mono, none)
+	Color string `toml:"color"`
+
+	// Theme selects a built-in theme or one defined under [themes]
+	Theme string `toml:"theme"`
+
+	// Themes defines color themes; unset colors come from the base theme, e.g.
+	//
+	//	[themes.mine]
+	//	base = "dracula"
+	//	match_bg = "#FFB86C"
+	Themes map[string]Theme `toml:"themes"`
+
+	// Sort orders file browser entries: locale (default), fold or bytes
+	Sort string `toml:"sort"`
+
+	// SortLocale overrides the collation language of LC_ALL, LC_COLLATE
+	// or LANG, e.g. "sv" to sort å, ä and ö after z
+	SortLocale string `toml:"sort_locale"`
+
+	// SplitView shows the preview pane beside search results when the
+	// terminal is wide enough (default true)
+	SplitView *bool `toml:"split_view"`
+
+	// IconTheme selects the icon theme (emoji, nerd-font, ascii, none)
+	IconTheme string `toml:"icon_theme"`
+
+	// Icons overrides the glyph per file extension, or for "dir"
+	Icons map[string]string `toml:"icons"`
+
+	// Patterns adds named patterns to the preset picker (Ctrl+P), e.g.
+	//
+	//	[patterns]
+	//	jira = '\b[A-Z]+-\d+\b'
+	Patterns map[string]string `toml:"patterns"`
+
+	// Workspaces names sets of roots for `zx search -workspace`, e.g.
+	//
+	//	[workspaces]
+	//	myrepos = ["~/src/api", "~/src/web"]
+	//
+	//	[workspaces.dumps]
+	//	roots = ["/data/exports"]
+	//	always_search = ["*.dat"]
+	Workspaces map[string]Workspace `toml:"workspaces"`
+
+	// AlwaysSearch lists files searched even when hidden, binary-looking,
+	// minified or over the size limit: globs of file names, or paths
+	// (with globs) of files and directories, e.g.
+	//
+	//	always_search = ["*.dat", "~/dumps/export.txt"]
+	AlwaysSearch []string `toml:"always_search"`
+
+	// Rules override the skips, size limit and concurrency of the files
+	// matching a glob; the first matching rule applies (see RuleFileConfig)
+	Rules []RuleFileConfig `toml:"rules"`
+
+	// SkipDirs replaces the names (or globs) of the directories every walk
+	// prunes, by default .git, node_modules, vendor, target and a few more
+	//
+	//	skip_dirs = [".git", "node_modules", "dist"]
+	SkipDirs []string `toml:"skip_dirs"`
+
+	// TextExts and BinaryExts add extensions, or longer endings such as
+	// .log.gz, to the lists searched as text without sniffing and skipped
+	// as binary without reading; one added to a list leaves the other
+	//
+	//	text_exts = [".proto", ".tf", ".ipynb"]
+	//	binary_exts = [".log.gz"]
+	TextExts   []string `toml:"text_exts"`
+	BinaryExts []string `toml:"binary_exts"`
+
+	// Network sets when network safe mode applies: auto (for targets on
+	// NFS, SMB and similar mounts), on or off
+	Network string `toml:"network"`
+
+	// CSVColumns sets the columns of CSV and TSV output and exports, e.g.
+	//
+	//	csv_columns = ["file", "line", "match", "size"]
+	CSVColumns []string `toml:"csv_columns"`
+
+	// Suggest tunes the suggestions shown when a search matches nothing
+	Suggest SuggestFileConfig `toml:"suggest"`
+
+	// Live tunes the hit counts shown while typing a pattern
+	Live LiveFileConfig `toml:"live"`
+
+	// Search sets the defaults of the search flags (see SearchFileConfig)
+	Search SearchFileConfig `toml:"search"`
+
+	// Editor is the command e opens results with, before $VISUAL and
+	// $EDITOR; the line is passed as +N, e.g.
+	//
+	//	editor = "nvim"
+	Editor string `toml:"editor"`
+
+	// Keys binds keys to the built-in ones they act as outside text
+	// input, e.g.
+	//
+	//	[keys]
+	//	"ctrl+j" = "down"
+	//	"ctrl+k" = "up"
+	Keys map[string]string `toml:"keys"`
+}
+
+// SearchFileConfig is the [search] table of config.toml, the defaults of
+// the flags of the same names, e.g.
+//
+//	[search]
+//	max_file_size = "20MB"
+//	exclude = ["target", "dist", "*.lock"]
+//	hidden = true
+type SearchFileConfig struct {
+	MaxFileSize string   `toml:"max_file_size"`
+	MaxResults  int      `toml:"max_results"`
+	MaxPerFile  int      `toml:"max_per_file"`
+	Workers     int      `toml:"workers"`
+	MaxDepth    int      `toml:"max_depth"`
+	Include     []string `toml:"include"`
+	Exclude     []string `toml:"exclude"`
+	Hidden      bool     `toml:"hidden"`
+	NoIgnore    bool     `toml:"no_ignore"`
+	Minified    bool     `toml:"minified"`
+	Stashes     bool     `toml:"stashes"`
+	NoIndex     bool     `toml:"no_index"`
+	NoSkipDirs  bool     `toml:"no_skip_dirs"`
+}
+
+// flags returns the search flags with the table's settings as their values
+// before parsing
+func (s SearchFileConfig) flags() configFlags {
+	return configFlags{
+		maxFileSize: s.MaxFileSize,
+		maxResults:  s.MaxResults,
+		maxPerFile:  s.MaxPerFile,
+		workers:     s.Workers,
+		maxDepth:    s.MaxDepth,
+		include:     strings.Join(s.Include, ","),
+		exclude:     strings.Join(s.Exclude, ","),
+		hidden:      s.Hidden,
+		noIgnore:    s.NoIgnore,
+		minified:    s.Minified,
+		stashes:     s.Stashes,
+		noIndex:     s.NoIndex,
+		noSkipDirs:  s.NoSkipDirs,
+	}
+}
+
+// SuggestFileConfig is the [suggest] table of config.toml, e.g.
+//
+//	[suggest]
+//	mode = "lines"      # tokens, lines or off
+//	max_distance = 3
+//	max_suggestions = 10
+type SuggestFileConfig struct {
+	Mode           string `toml:"mode"`
+	MaxDistance    int    `toml:"max_distance"`
+	MaxSuggestions int    `toml:"max_suggestions"`
+}
+
+// LiveFileConfig is the [live] table of config.toml, e.g.
+//
+//	[live]
+//	max_files = 5000    # Count only in scopes up to this many files
+//	max_mb = 100        # and this many megabytes
+//	delay_ms = 500      # after typing pauses this long
+//	off = false
+type LiveFileConfig struct {
+	Off      bool `toml:"off"`
+	MaxFiles int  `toml:"max_files"`
+	MaxMB    int  `toml:"max_mb"`
+	DelayMs  int  `toml:"delay_ms"`
+}
+
+// configPath returns the location of the user configuration file
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "zx", "config.toml"), nil
+}
+
+// loadConfig reads the user configuration, from path if it's set (-config
+// or $ZX_CONFIG), then the project's .zx.toml over it and the ZX_*
+// environment variables over both; missing files yield defaults
+func loadConfig(path string) (FileConfig, error) {
+	var cfg FileConfig
+
+	if path == "" {
+		path = os.Getenv("ZX_CONFIG")
+	}
+	if path != "" {
+		if _, err := toml.DecodeFile(path, &cfg); err != nil {
+			return cfg, fmt.Errorf("invalid config %s: %v", path, err)
+		}
+	} else if path, err := configPath(); err == nil {
+		if _, err := toml.DecodeFile(path, &cfg); err != nil && !os.IsNotExist(err) {
+			return cfg, fmt.Errorf("invalid config %s: %v", path, err)
+		}
+	}
+
+	// Keys the project doesn't set keep the user's values
+	if project := findProjectConfig(); project != "" {
+		meta, err := toml.DecodeFile(project, &cfg)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid config %s: %v", project, err)
+		}
+		// A cloned repository mustn't choose the commands zx runs
+		for _, key := range []string{"extractors", "editor"} {
+			if meta.IsDefined(key) {
+				return cfg, fmt.Errorf("%s: %s can only be set in your own config.toml", project, key)
+			}
+		}
+	}
+	return cfg, applyEnvConfig(&cfg)
+}
+
+// findProjectConfig returns the .zx.toml of the current directory or the
+// nearest parent that has one, or ""
+func findProjectConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// envSetting is a ZX_* environment variable and the setting it overrides
+type envSetting struct {
+	name string
+	set  func(cfg *FileConfig, value string) error
+}
+
+// envSettings lists the environment variables read over the config files
+var envSettings = []envSetting{
+	{"ZX_THEME", envString(func(c *FileConfig) *string { return &c.Theme })},
+	{"ZX_COLOR", envString(func(c *FileConfig) *string { return &c.Color })},
+	{"ZX_ICONS", envString(func(c *FileConfig) *string { return &c.IconTheme })},
+	{"ZX_SORT", envString(func(c *FileConfig) *string { return &c.Sort })},
+	{"ZX_NETWORK", envString(func(c *FileConfig) *string { return &c.Network })},
+	{"ZX_EDITOR", envString(func(c *FileConfig) *string { return &c.Editor })},
+	{"ZX_MAX_FILE_SIZE", envString(func(c *FileConfig) *string { return &c.Search.MaxFileSize })},
+	{"ZX_MAX_RESULTS", envInt(func(c *FileConfig) *int { return &c.Search.MaxResults })},
+	{"ZX_MAX_PER_FILE", envInt(func(c *FileConfig) *int { return &c.Search.MaxPerFile })},
+	{"ZX_WORKERS", envInt(func(c *FileConfig) *int { return &c.Search.Workers })},
+	{"ZX_MAX_DEPTH", envInt(func(c *FileConfig) *int { return &c.Search.MaxDepth })},
+	{"ZX_INCLUDE", envList(func(c *FileConfig) *[]string { return &c.Search.Include })},
+	{"ZX_EXCLUDE", envList(func(c *FileConfig) *[]string { return &c.Search.Exclude })},
+	{"ZX_HIDDEN", envBool(func(c *FileConfig) *bool { return &c.Search.Hidden })},
+	{"ZX_NO_IGNORE", envBool(func(c *FileConfig) *bool { return &c.Search.NoIgnore })},
+	{"ZX_MINIFIED", envBool(func(c *FileConfig) *bool { return &c.Search.Minified })},
+}
+
+// applyEnvConfig sets the settings of the ZX_* variables that are set
+func applyEnvConfig(cfg *FileConfig) error {
+	for _, s := range envSettings {
+		value, ok := os.LookupEnv(s.name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := s.set(cfg, value); err != nil {
+			return fmt.Errorf("invalid %s %q: %v", s.name, value, err)
+		}
+	}
+	return nil
+}
+
+// envString, envInt, envBool and envList set a field of the config from a
+// variable's value
+func envString(field func(*FileConfig) *string) func(*FileConfig, string) error {
+	return func(c *FileConfig, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func envInt(field func(*FileConfig) *int) func(*FileConfig, string) error {
+	return func(c *FileConfig, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("not a count")
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func envBool(field func(*FileConfig) *bool) func(*FileConfig, string) error {
+	return func(c *FileConfig, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("not true or false")
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func envList(field func(*FileConfig) *[]string) func(*FileConfig, string) error {
+	return func(c *FileConfig, value string) error {
+		// Comma-separated, as the flags of lists
+		*field(c) = strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+		return nil
+	}
+}
+
+// configArg removes -config file (or --config=file) from args, which the
+// config is read from before any command parses its flags
+func configArg(args []string) (string, []string, error) {
+	var path string
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return "", nil, fmt.Errorf("flag needs an argument: -config")
+			}
+			i++
+			value = args[i]
+		}
+		path = value
+	}
+	return path, rest, nil
+}
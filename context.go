@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// maxContextLines is the widest context + shows around a result
+const maxContextLines = 20
+
+// maxContextEntries bounds the context cache; the oldest entries go first
+const maxContextEntries = 512
+
+// contextCache keeps the lines around results read for the selected
+// result, so results carry no context of their own. Entries are checked
+// against the file's modification time and size before they're used. Like
+// resultRenderCache it's shared by the model's copies.
+type contextCache struct {
+	entries map[lineKey]contextEntry
+	order   []lineKey // Oldest first
+}
+
+// contextEntry is the context read around one result line
+type contextEntry struct {
+	modTime time.Time
+	size    int64
+	lines   int // Lines read on each side
+	before  []string
+	after   []string
+	err     error
+}
+
+func newContextCache() *contextCache {
+	return &contextCache{entries: make(map[lineKey]contextEntry)}
+}
+
+// noContext says why context can't be read for a result from its file, or
+// returns ""
+func noContext(result SearchResult) string {
+	if reason := unpreviewable(result); reason != "" {
+		return reason
+	}
+	if result.Compression != "" || result.Extractor != "" {
+		return "No context for lines of decompressed or extracted text"
+	}
+	return ""
+}
+
+// get returns n lines before and after a result, reading the file when
+// the cache has fewer lines or the file changed since they were read
+func (c *contextCache) get(result SearchResult, n int) (before, after []string, modTime time.Time, err error) {
+	info, err := os.Stat(result.FilePath)
+	if err != nil {
+		return nil, nil, modTime, err
+	}
+	key := lineKey{result.FilePath, result.LineNumber}
+	entry, ok := c.entries[key]
+	if !ok || entry.lines < n || !entry.modTime.Equal(info.ModTime()) || entry.size != info.Size() {
+		entry = readContext(result.FilePath, result.LineNumber, n)
+		entry.modTime, entry.size = info.ModTime(), info.Size()
+		if !ok {
+			c.order = append(c.order, key)
+		}
+		c.entries[key] = entry
+		if len(c.order) > maxContextEntries {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+	}
+	if entry.err != nil {
+		return nil, nil, entry.modTime, entry.err
+	}
+	before = entry.before[max(0, len(entry.before)-n):]
+	after = entry.after[:min(n, len(entry.after))]
+	return before, after, entry.modTime, nil
+}
+
+// readContext reads n lines on each side of a line of a file
+func readContext(path string, line, n int) contextEntry {
+	wanted := make(map[int]bool)
+	for i := line - n; i <= line+n; i++ {
+		wanted[i] = true
+	}
+	lines, err := readLines(path, wanted)
+	entry := contextEntry{lines: n, err: err}
+	for i := line - n; i < line; i++ {
+		if text, ok := lines[i]; ok {
+			entry.before = append(entry.before, text)
+		}
+	}
+	for i := line + 1; i <= line+n; i++ {
+		if text, ok := lines[i]; ok {
+			entry.after = append(entry.after, text)
+		}
+	}
+	return entry
+}
+
+// resultContext returns the context lines shown around the selected
+// result: those saved in a bundle, or else contextLines lines read from
+// the file. warning is set when they can't be read or the file changed
+// since the search.
+func (m model) resultContext(result SearchResult) (before, after []string, warning string) {
+	if len(result.Before) > 0 || len(result.After) > 0 || m.contextLines == 0 {
+		return result.Before, result.After, ""
+	}
+	if reason := noContext(result); reason != "" {
+		return nil, nil, reason
+	}
+	c := m.contextCache
+	if c == nil {
+		c = newContextCache()
+	}
+	before, after, modTime, err := c.get(result, m.contextLines)
+	if err != nil {
+		return nil, nil, fmt.Sprintf("No context: %v", err)
+	}
+	if !result.LastModified.IsZero() && !modTime.Equal(result.LastModified) {
+		warning = "The file changed since the search; context is from the current file"
+	}
+	return before, after, warning
+}
+
+// widenContext changes how many lines are shown around the selected result
+func (m *model) widenContext(step int) {
+	m.contextLines = max(0, min(m.contextLines+step, maxContextLines))
+	if m.contextLines == 0 {
+		m.statusMsg = "No context around the selected result"
+	} else {
+		m.statusMsg = fmt.Sprintf("Showing %d lines of context around the selected result (+/- to change)", m.contextLines)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "time"
+
+// processCPUTime is unknown on this platform
+func processCPUTime() time.Duration {
+	return 0
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// detailContext is the number of lines the detail view shows on each side
+// of the result's line
+const detailContext = 20
+
+// openDetail shows the selected result in the detail view: the lines
+// around it with its matches highlighted, what is known about its file, and
+// keys to edit, copy or step to the file's other results
+func (m *model) openDetail() {
+	if m.resultIndex >= m.visibleResults.len() || m.resultFilter.Aggregate != AggregateNone {
+		return
+	}
+	result := m.visibleResults.at(m.resultIndex)
+	if reason := unpreviewable(result); reason != "" {
+		m.statusMsg = reason
+		return
+	}
+	back := m.mode
+	if m.preview != nil && m.preview.detail {
+		back = m.preview.back
+	}
+	p, err := loadPreview(result.FilePath, m.searchConfig)
+	if err != nil {
+		m.statusMsg = fmt.Sprintf("Error: %v", err)
+		return
+	}
+	p.back = back
+	p.detail = true
+	p.focus(result.LineNumber, result.Matches, m.resultLines(result.FilePath))
+	m.preview = p
+	m.mode = PreviewMode
+	m.centerPreview()
+	m.statusMsg = "n/N steps through the file's results, Esc returns to the list"
+}
+
+// stepDetail moves the detail view to the next (step 1) or previous (-1)
+// visible result in the same file, wrapping around
+func (m *model) stepDetail(step int) {
+	path := m.preview.path
+	var rows []int
+	current := -1
+	for i := 0; i < m.visibleResults.len(); i++ {
+		if m.visibleResults.at(i).FilePath == path {
+			if i == m.resultIndex {
+				current = len(rows)
+			}
+			rows = append(rows, i)
+		}
+	}
+	if len(rows) < 2 || current < 0 {
+		m.statusMsg = "No other results in this file"
+		return
+	}
+	next := (current + step + len(rows)) % len(rows)
+	m.resultIndex = rows[next]
+	m.adjustViewport()
+	r := m.visibleResults.at(m.resultIndex)
+	m.preview.focus(r.LineNumber, r.Matches, m.resultLines(path))
+	m.centerPreview()
+	m.statusMsg = fmt.Sprintf("Result %d/%d in this file, line %d", next+1, len(rows), r.LineNumber)
+}
+
+// updateDetail handles the keys of the detail view that differ from the
+// preview's; the rest scroll as in the preview
+func (m model) updateDetail(msg tea.KeyMsg) (tea.Model, tea.Cmd, bool) {
+	p := m.preview
+	switch msg.String() {
+	case "esc", "q", "enter":
+		m.mode = p.back
+		m.preview = nil
+		m.statusMsg = "Closed the result"
+	case "n", "right", "l":
+		m.stepDetail(1)
+	case "N", "left", "h":
+		m.stepDetail(-1)
+	case "e":
+		r := m.visibleResults.at(m.resultIndex)
+		return m, m.openInEditor([]SearchResult{r}), true
+	case "c":
+		m.promptCopy()
+	case "v":
+		// The whole file, as the preview shows it
+		p.detail = false
+		m.centerPreview()
+		m.statusMsg = fmt.Sprintf("Previewing %s (%d lines)", p.path, len(p.lines))
+	case "?":
+		m.showHelp = !m.showHelp
+	default:
+		return m, nil, false
+	}
+	return m, nil, true
+}
+
+// renderDetail draws the detail view: the result's file and location, then
+// the lines around it
+func (m model) renderDetail() string {
+	p := m.preview
+	var b strings.Builder
+	r := m.visibleResults.at(min(m.resultIndex, m.visibleResults.len()-1))
+
+	b.WriteString(headerStyle.Render(fmt.Sprintf("%s:%d:%d", m.relPath(r.FilePath), r.LineNumber, r.Column)))
+	b.WriteString("\n")
+	meta := []string{formatSize(r.FileSize), "modified " + r.LastModified.Format("2006-01-02 15:04")}
+	if r.Encoding != "" {
+		meta = append(meta, r.Encoding)
+	}
+	if r.Compression != "" {
+		meta = append(meta, "decompressed from "+r.Compression)
+	}
+	if r.Extractor != "" {
+		meta = append(meta, "text from "+r.Extractor)
+	}
+	if r.rawOffsets() {
+		meta = append(meta, fmt.Sprintf("byte %d", r.ByteOffset))
+	}
+	meta = append(meta, fmt.Sprintf("%d lines", len(p.lines)))
+	if lang := languageByExt[strings.ToLower(filepath.Ext(r.FilePath))]; lang != "" {
+		meta = append(meta, lang)
+	}
+	b.WriteString(helpStyle.Render(strings.Join(meta, " · ")))
+	b.WriteString("\n")
+	if note := m.noteFor(r); note != "" {
+		b.WriteString(statusStyle.Render("Note: " + note))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	end := min(p.offset+m.previewHeight(), len(p.lines))
+	b.WriteString(m.renderPreviewLines(p, end))
+	return b.String()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// dirBatchSize is the number of entries read per batch; directories that
+// fit in one batch load synchronously as before
+const dirBatchSize = 2000
+
+// dirLoader reads the rest of a large directory in the background
+type dirLoader struct {
+	id      int
+	dir     string
+	file    *os.File
+	started bool // readDirBatch has been scheduled
+}
+
+// dirBatchMsg carries one batch of entries from a dirLoader
+type dirBatchMsg struct {
+	id    int
+	items []FileItem
+	done  bool
+	err   error
+}
+
+// lessFileItem orders the parent entry first, then directories, then
+// files, both by browserSort
+func lessFileItem(a, b keyedItem) bool {
+	if (a.Name == "..") != (b.Name == "..") {
+		return a.Name == ".."
+	}
+	if a.IsDir != b.IsDir {
+		return a.IsDir
+	}
+	return compareItems(a, b) < 0
+}
+
+// sortFileItems sorts items by lessFileItem
+func sortFileItems(items []FileItem) {
+	keyed := keyItems(items)
+	sort.Slice(keyed, func(i, j int) bool { return lessFileItem(keyed[i], keyed[j]) })
+	for i := range keyed {
+		items[i] = keyed[i].FileItem
+	}
+}
+
+// fileItems converts directory entries, skipping those that vanished
+func fileItems(dir string, entries []os.DirEntry) []FileItem {
+	items := make([]FileItem, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		items = append(items, FileItem{
+			Name:    entry.Name(),
+			Path:    filepath.Join(dir, entry.Name()),
+			IsDir:   entry.IsDir(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Mode:    info.Mode(),
+		})
+	}
+	return items
+}
+
+// readDirBatch reads the next batch of a loader's directory
+func readDirBatch(l *dirLoader) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := l.file.ReadDir(dirBatchSize)
+		msg := dirBatchMsg{id: l.id, items: fileItems(l.dir, entries)}
+		if err != nil || len(entries) < dirBatchSize {
+			l.file.Close()
+			msg.done = true
+			if err != io.EOF {
+				msg.err = err
+			}
+		}
+		return msg
+	}
+}
+
+// stopDirLoad abandons a background directory load, if any
+func (m *model) stopDirLoad() {
+	if m.dirLoad != nil {
+		m.dirLoad.file.Close()
+		m.dirLoad = nil
+	}
+}
+
+// startDirLoad returns the command reading the next batch of a directory
+// load that hasn't been scheduled yet
+func (m *model) startDirLoad() tea.Cmd {
+	if m.dirLoad == nil || m.dirLoad.started {
+		return nil
+	}
+	m.dirLoad.started = true
+	return readDirBatch(m.dirLoad)
+}
+
+// handleDirBatch merges a batch into the sorted file list, keeping the
+// cursor on the same entry, and asks for the next batch
+func (m *model) handleDirBatch(msg dirBatchMsg) tea.Cmd {
+	if m.dirLoad == nil || msg.id != m.dirLoad.id {
+		return nil
+	}
+
+	var current string
+	if m.selectedFile < len(m.files) {
+		current = m.files[m.selectedFile].Path
+	}
+	m.markSelected(msg.items)
+	sortFileItems(msg.items)
+	m.files = mergeFileItems(m.files, msg.items)
+	for i, file := range m.files {
+		if file.Path == current {
+			m.selectedFile = i
+			break
+		}
+	}
+	m.adjustViewport()
+	m.focusFile()
+
+	if msg.done {
+		m.dirLoad = nil
+		m.expandTree()
+		m.statusMsg = fmt.Sprintf("Loaded %d items", len(m.files))
+		if msg.err != nil {
+			m.statusMsg = fmt.Sprintf("Error reading directory: %v (loaded %d items)", msg.err, len(m.files))
+		}
+		return nil
+	}
+	m.statusMsg = fmt.Sprintf("Loading... %d items so far", len(m.files))
+	return readDirBatch(m.dirLoad)
+}
+
+// mergeFileItems merges two lists sorted by lessFileItem
+func mergeFileItems(a, b []FileItem) []FileItem {
+	merged := make([]FileItem, 0, len(a)+len(b))
+	keyedA, keyedB := keyItems(a), keyItems(b)
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if lessFileItem(keyedB[j], keyedA[i]) {
+			merged = append(merged, b[j])
+			j++
+		} else {
+			merged = append(merged, a[i])
+			i++
+		}
+	}
+	merged = append(merged, a[i:]...)
+	return append(merged, b[j:]...)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// utf8BOM prefixes the first line of files detected as EncodingUTF8BOM
+const utf8BOM = "\xef\xbb\xbf"
+
+// replaceLine rewrites line lineNumber of path from oldLine to newLine,
+// keeping its line ending. The file is backed up first and replaced
+// atomically; the edit is refused if the line changed since the search. A
+// symlink is followed to the file it points at, and a file with other hard
+// links, or whose owner can't be kept, is rewritten in place instead.
+func replaceLine(path string, lineNumber int, oldLine, newLine string) (string, error) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".zx-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed into place
+
+	reader := bufio.NewReaderSize(in, BufferSize)
+	writer := bufio.NewWriterSize(tmp, BufferSize)
+	found := false
+	for n := 1; ; n++ {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			tmp.Close()
+			return "", readErr
+		}
+		if line == "" && readErr == io.EOF {
+			break
+		}
+
+		if n == lineNumber {
+			content := strings.TrimSuffix(line, "\n")
+			ending := line[len(content):]
+			prefix := ""
+			if n == 1 && strings.HasPrefix(content, utf8BOM) {
+				prefix = utf8BOM
+				content = content[len(utf8BOM):]
+			}
+			// Scanned lines keep the \r of CRLF endings; treat it as the ending
+			if strings.HasSuffix(content, "\r") && !strings.HasSuffix(oldLine, "\r") {
+				content = strings.TrimSuffix(content, "\r")
+				ending = "\r" + ending
+			}
+			if content != oldLine {
+				tmp.Close()
+				return "", fmt.Errorf("line %d of %s changed since the search", lineNumber, path)
+			}
+			line = prefix + newLine + ending
+			found = true
+		}
+
+		if _, err := writer.WriteString(line); err != nil {
+			tmp.Close()
+			return "", err
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	if !found {
+		tmp.Close()
+		return "", fmt.Errorf("%s has no line %d", path, lineNumber)
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return "", err
+	}
+
+	backup, err := backupFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !keepOwner(tmp.Name(), info) {
+		return backup, overwriteFile(path, tmp.Name())
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+// overwriteFile copies src over the contents of path, keeping path's inode
+// and with it its links, owner and extended attributes
+func overwriteFile(path, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// editableResult reports why a result's line can't be written back, if so
+func editableResult(result SearchResult) error {
+	switch {
+	case strings.HasPrefix(result.FilePath, "stash@{"):
+		return fmt.Errorf("stashed files can't be edited")
+	case result.Compression != "":
+		return fmt.Errorf("%s files can't be edited in place", result.Compression)
+	case result.Source != "":
+		return fmt.Errorf("%s output can't be edited", result.Source)
+	case result.Layer != "":
+		return fmt.Errorf("files in container images can't be edited")
+	case result.Extractor != "":
+		return fmt.Errorf("%s documents can't be edited in place", result.Extractor)
+	case result.Encoding != "" && result.Encoding != EncodingUTF8 && result.Encoding != EncodingUTF8BOM:
+		return fmt.Errorf("%s files can't be edited in place", result.Encoding)
+	case result.LineNumber <= 0:
+		return fmt.Errorf("no line selected")
+	}
+	return nil
+}
+
+// editSelectedResult opens the line editor on the selected result's line
+func (m *model) editSelectedResult() {
+	if m.resultIndex >= m.visibleResults.len() {
+		return
+	}
+	result := m.visibleResults.at(m.resultIndex)
+	if err := editableResult(result); err != nil {
+		m.statusMsg = fmt.Sprintf("Error: %v", err)
+		return
+	}
+
+	m.prompt = &inputPrompt{
+		label:  fmt.Sprintf("Edit line %d: ", result.LineNumber),
+		input:  result.LineContent,
+		cursor: len([]rune(result.LineContent)),
+		raw:    true,
+		onSubmit: func(m *model, value string) {
+			if value == result.LineContent {
+				m.statusMsg = "No changes"
+				return
+			}
+			backup, err := replaceLine(result.FilePath, result.LineNumber, result.LineContent, value)
+			if err != nil {
+				m.statusMsg = fmt.Sprintf("Error: %v", err)
+				return
+			}
+			m.updateEditedLine(result.FilePath, result.LineNumber, value)
+			m.statusMsg = fmt.Sprintf("Saved %s:%d (backup: %s)", filepath.Base(result.FilePath), result.LineNumber, backup)
+		},
+	}
+}
+
+// updateEditedLine refreshes the results for a line that was rewritten,
+// replacing its rows with ones matched against the new content
+func (m *model) updateEditedLine(path string, lineNumber int, content string) {
+	// The change in the line's length moves the offsets of later lines
+	var shift int64
+	for _, r := range m.searchResults.Results {
+		if r.FilePath == path && r.LineNumber == lineNumber {
+			shift = int64(len(content) - len(r.LineContent))
+			break
+		}
+	}
+
+	var results []SearchResult
+	replaced := false
+	for _, r := range m.searchResults.Results {
+		if r.FilePath == path && r.LineNumber > lineNumber && r.rawOffsets() {
+			r.ByteOffset += shift
+		}
+		if r.FilePath != path || r.LineNumber != lineNumber {
+			results = append(results, r)
+			continue
+		}
+		if replaced {
+			continue
+		}
+		replaced = true
+
+		base := r
+		lineStart := r.ByteOffset
+		if len(r.Matches) > 0 {
+			lineStart -= int64(r.Matches[0].Start)
+		}
+		if m.searchConfig.Query != nil && !m.searchResults.Inverted {
+			results = append(results, m.lineResult(m.searchConfig.Query, base, lineNumber, lineStart, content))
+		} else {
+			base.LineContent = content
+			base.Column, base.ByteOffset = 1, lineStart
+			results = append(results, base)
+		}
+	}
+	m.searchResults.Results = results
+	m.paneCache.reset()
+	m.applyResultFilters()
+}
@@ -0,0 +1,10 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// keepOwner has nothing to carry over on this platform
+func keepOwner(tmp string, info os.FileInfo) bool {
+	return true
+}
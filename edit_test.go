@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestReplaceLine(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	tests := []struct {
+		name    string
+		content string
+		line    int
+		oldLine string
+		newLine string
+		want    string
+		err     string // Substring of the error, if the edit is refused
+	}{
+		{"middle", "a\nb\nc\n", 2, "b", "B", "a\nB\nc\n", ""},
+		{"first", "a\nb\n", 1, "a", "A", "A\nb\n", ""},
+		{"last without newline", "a\nb", 2, "b", "B", "a\nB", ""},
+		{"CRLF", "a\r\nb\r\nc\r\n", 2, "b", "B", "a\r\nB\r\nc\r\n", ""},
+		{"CR kept in the line", "a\r\nb\r\n", 1, "a\r", "A\r", "A\r\nb\r\n", ""},
+		{"BOM", utf8BOM + "a\nb\n", 1, "a", "A", utf8BOM + "A\nb\n", ""},
+		{"emptied", "a\nb\nc\n", 2, "b", "", "a\n\nc\n", ""},
+		{"changed since", "a\nx\nc\n", 2, "b", "B", "a\nx\nc\n", "changed since the search"},
+		{"no such line", "a\nb\n", 5, "b", "B", "a\nb\n", "has no line 5"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "file.txt")
+		if err := os.WriteFile(path, []byte(tt.content), 0o640); err != nil {
+			t.Fatal(err)
+		}
+		backup, err := replaceLine(path, tt.line, tt.oldLine, tt.newLine)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error %v; want one containing %q", tt.name, err, tt.err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if saved, err := os.ReadFile(backup); err != nil || string(saved) != tt.content {
+			t.Errorf("%s: backup %q (%v); want %q", tt.name, saved, err, tt.content)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s: file is %q; want %q", tt.name, data, tt.want)
+		}
+		if info, err := os.Stat(path); err != nil {
+			t.Fatal(err)
+		} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0o640 {
+			t.Errorf("%s: mode %v; want 0640", tt.name, info.Mode().Perm())
+		}
+	}
+}
+
+func TestReplaceLineLinks(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	if err := os.WriteFile(target, []byte("a\nb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// An edit through a symlink changes the file it points at and leaves
+	// the link alone
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink("target.txt", link); err == nil {
+		if _, err := replaceLine(link, 1, "a", "A"); err != nil {
+			t.Fatal(err)
+		}
+		if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("link replaced by a file (%v)", err)
+		}
+		if data, _ := os.ReadFile(target); string(data) != "A\nb\n" {
+			t.Errorf("target is %q; want %q", data, "A\nb\n")
+		}
+	}
+
+	// A file with another hard link is rewritten in place, so both names
+	// see the edit
+	other := filepath.Join(dir, "other.txt")
+	if err := os.Link(target, other); err != nil {
+		t.Skip("no hard links:", err)
+	}
+	if _, err := replaceLine(other, 2, "b", "B"); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{target, other} {
+		if data, _ := os.ReadFile(path); !strings.HasSuffix(string(data), "\nB\n") {
+			t.Errorf("%s is %q; want the edited line", filepath.Base(path), data)
+		}
+	}
+}
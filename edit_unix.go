@@ -0,0 +1,22 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// keepOwner gives the rewritten copy at tmp the owner and group of the file
+// it replaces. It reports false when the file has other hard links or its
+// owner can't be given to the copy, so the file must be rewritten in place.
+func keepOwner(tmp string, info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return true
+	}
+	if st.Nlink > 1 {
+		return false
+	}
+	return os.Lchown(tmp, int(st.Uid), int(st.Gid)) == nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Text encodings recognized by detectEncoding
+const (
+	EncodingUTF8    = "UTF-8"
+	EncodingUTF8BOM = "UTF-8 (BOM)"
+	EncodingUTF16LE = "UTF-16LE"
+	EncodingUTF16BE = "UTF-16BE"
+	EncodingLatin1  = "Latin-1"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// detectEncoding guesses the text encoding of a content sample from its BOM,
+// the distribution of NUL bytes (BOM-less UTF-16) and UTF-8 validity
+func detectEncoding(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, bomUTF8):
+		return EncodingUTF8BOM
+	case bytes.HasPrefix(sample, bomUTF16LE):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(sample, bomUTF16BE):
+		return EncodingUTF16BE
+	}
+
+	if enc := detectUTF16(sample); enc != "" {
+		return enc
+	}
+
+	// Ignore a multi-byte rune cut off at the end of the sample
+	trimmed := sample
+	for i := len(sample) - 1; i >= 0 && i >= len(sample)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(sample[i]) {
+			if !utf8.FullRune(sample[i:]) {
+				trimmed = sample[:i]
+			}
+			break
+		}
+	}
+	if !utf8.Valid(trimmed) && bytes.IndexByte(sample, 0) < 0 {
+		return EncodingLatin1
+	}
+	return EncodingUTF8
+}
+
+// detectUTF16 recognizes BOM-less UTF-16 text, in which mostly-ASCII
+// content leaves NUL bytes at every other position
+func detectUTF16(sample []byte) string {
+	if len(sample) < 4 {
+		return ""
+	}
+	evenZeros, oddZeros := 0, 0
+	for i, c := range sample {
+		if c != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenZeros++
+		} else {
+			oddZeros++
+		}
+	}
+	half := len(sample) / 2
+	switch {
+	case oddZeros > half*2/5 && evenZeros <= half/20:
+		return EncodingUTF16LE
+	case evenZeros > half*2/5 && oddZeros <= half/20:
+		return EncodingUTF16BE
+	}
+	return ""
+}
+
+// isUTF16 reports whether enc is one of the UTF-16 variants
+func isUTF16(enc string) bool {
+	return enc == EncodingUTF16LE || enc == EncodingUTF16BE
+}
+
+// decodingReader wraps r so that it yields UTF-8 for content in enc,
+// dropping any byte order mark
+func decodingReader(r io.Reader, enc string) io.Reader {
+	switch enc {
+	case EncodingUTF8BOM:
+		return transform.NewReader(r, unicode.UTF8BOM.NewDecoder())
+	case EncodingUTF16LE:
+		return transform.NewReader(r, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder())
+	case EncodingUTF16BE:
+		return transform.NewReader(r, unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewDecoder())
+	case EncodingLatin1:
+		return transform.NewReader(r, charmap.ISO8859_1.NewDecoder())
+	default:
+		return r
+	}
+}
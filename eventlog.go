@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// eventLogSource marks results read from the Windows Event Log
+const eventLogSource = "wevtutil"
+
+// eventLevels names the standard event levels 1-5
+var eventLevels = []string{"", "critical", "error", "warning", "information", "verbose"}
+
+// eventLogOptions selects the Windows events to search
+type eventLogOptions struct {
+	Channels []string      // Logs to query, e.g. System, Application
+	MaxLevel int           // Only levels 1..MaxLevel (1 critical ... 5 verbose); 0 for all
+	Since    time.Duration // Only events newer than this; 0 for all
+	Max      int           // Newest N events per channel; 0 for all
+}
+
+// xpath builds the wevtutil query for the level and time filters
+func (o eventLogOptions) xpath() string {
+	var conds []string
+	if o.MaxLevel > 0 {
+		var levels []string
+		for l := 1; l <= o.MaxLevel && l < len(eventLevels); l++ {
+			levels = append(levels, fmt.Sprintf("Level=%d", l))
+		}
+		conds = append(conds, "("+strings.Join(levels, " or ")+")")
+	}
+	if o.Since > 0 {
+		conds = append(conds, fmt.Sprintf("TimeCreated[timediff(@SystemTime) <= %d]", o.Since.Milliseconds()))
+	}
+	if len(conds) == 0 {
+		return "*"
+	}
+	return "*[System[" + strings.Join(conds, " and ") + "]]"
+}
+
+// windowsEvent is the subset of a rendered event's XML that zx uses
+type windowsEvent struct {
+	System struct {
+		Provider struct {
+			Name string `xml:"Name,attr"`
+		} `xml:"Provider"`
+		EventID     int `xml:"EventID"`
+		Level       int `xml:"Level"`
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		} `xml:"TimeCreated"`
+		Channel string `xml:"Channel"`
+	} `xml:"System"`
+	RenderingInfo struct {
+		Message string `xml:"Message"`
+		Level   string `xml:"Level"`
+	} `xml:"RenderingInfo"`
+}
+
+// level returns the event's level name, rendered or standard
+func (e windowsEvent) level() string {
+	if e.RenderingInfo.Level != "" {
+		return strings.ToLower(e.RenderingInfo.Level)
+	}
+	if e.System.Level > 0 && e.System.Level < len(eventLevels) {
+		return eventLevels[e.System.Level]
+	}
+	return ""
+}
+
+// searchEventLog runs the query over the rendered messages of the selected
+// events. Results are named after channel and provider and show the event's
+// time and level; LineNumber is the event's position in its channel's output.
+func (m *model) searchEventLog(ctx context.Context, opts eventLogOptions) SearchResults {
+	start := time.Now()
+	results := SearchResults{Pattern: m.searchInput, Target: "event log", Inverted: m.searchConfig.InvertMatch}
+	if runtime.GOOS != "windows" {
+		results.Errors = append(results.Errors, "the Windows Event Log can only be searched on Windows")
+		return results
+	}
+
+	for _, channel := range opts.Channels {
+		if ctx.Err() != nil {
+			break
+		}
+		found, events, err := m.searchEventChannel(ctx, channel, opts)
+		results.Results = append(results.Results, found...)
+		results.TotalFiles += events
+		if err != nil {
+			results.Errors = append(results.Errors, err.Error())
+		}
+	}
+	results.SearchTime = time.Since(start)
+	return results
+}
+
+// searchEventChannel queries one channel with wevtutil, newest events first
+func (m *model) searchEventChannel(ctx context.Context, channel string, opts eventLogOptions) ([]SearchResult, int, error) {
+	args := []string{"qe", channel, "/q:" + opts.xpath(), "/f:RenderedXml", "/rd:true"}
+	if opts.Max > 0 {
+		args = append(args, fmt.Sprintf("/c:%d", opts.Max))
+	}
+	cmd := exec.CommandContext(ctx, "wevtutil", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, 0, fmt.Errorf("unable to run wevtutil: %v", err)
+	}
+
+	// The output is a sequence of <Event> elements without a root
+	lm := m.newLineMatcher(SearchResult{Source: eventLogSource})
+	decoder := xml.NewDecoder(stdout)
+	events := 0
+	var decodeErr error
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			if err != io.EOF {
+				decodeErr = err
+			}
+			break
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok || el.Name.Local != "Event" {
+			continue
+		}
+		var event windowsEvent
+		if err := decoder.DecodeElement(&event, &el); err != nil {
+			decodeErr = err
+			break
+		}
+		events++
+
+		lm.base.FilePath = fmt.Sprintf("eventlog:%s/%s", channel, event.System.Provider.Name)
+		lm.base.Unit = fmt.Sprintf("%s %d", event.System.Provider.Name, event.System.EventID)
+		lm.base.Priority = event.level()
+		lm.base.LastModified, _ = time.Parse(time.RFC3339Nano, event.System.TimeCreated.SystemTime)
+		for _, line := range strings.Split(strings.ReplaceAll(event.RenderingInfo.Message, "\r\n", "\n"), "\n") {
+			lm.match(events, 0, []byte(line))
+		}
+	}
+
+	// Output that can't be decoded ends the read; stop wevtutil rather
+	// than wait on it blocked writing the rest, or on output its children
+	// still hold open
+	if decodeErr != nil {
+		cmd.WaitDelay = time.Second
+		cmd.Process.Kill()
+		cmd.Wait()
+		return lm.finish(), events, fmt.Errorf("wevtutil %s: unreadable output: %v", channel, decodeErr)
+	}
+	if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return lm.finish(), events, fmt.Errorf("wevtutil %s: %s", channel, msg)
+	}
+	return lm.finish(), events, nil
+}
+
+// runEventLogCommand implements `zx eventlog [flags] pattern`
+func runEventLogCommand(args []string, config SearchConfig, opts eventLogOptions) (SearchResults, error) {
+	if len(args) != 1 {
+		return SearchResults{}, fmt.Errorf("usage: zx eventlog [flags] <pattern>")
+	}
+	query, err := compileQuery(args[0])
+	if err != nil {
+		return SearchResults{}, fmt.Errorf("invalid regex pattern: %v", err)
+	}
+	if len(opts.Channels) == 0 {
+		opts.Channels = []string{"System", "Application"}
+	}
+	config.Query = query
+	m := &model{searchConfig: config, searchInput: args[0]}
+	return m.searchEventLog(context.Background(), opts), nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Extractor converts a document into plain text that can be searched
+type Extractor interface {
+	// Name identifies the extractor in search results
+	Name() string
+	// Extract returns the document's text
+	Extract(ctx context.Context, path string) (io.Reader, error)
+}
+
+// extractors maps lower-case file extensions to the extractor handling them
+var extractors = map[string]Extractor{
+	".docx": ooxmlExtractor{name: "docx", parts: []string{"word/document.xml"}, paragraph: "p", text: "t"},
+	".pptx": ooxmlExtractor{name: "pptx", partPrefix: "ppt/slides/slide", paragraph: "p", text: "t"},
+	".xlsx": xlsxExtractor{},
+}
+
+func init() {
+	// Use pdftotext for PDFs when it is installed
+	if _, err := exec.LookPath("pdftotext"); err == nil {
+		registerExtractor(".pdf", commandExtractor{ext: ".pdf", args: []string{"pdftotext", "-q", "{path}", "-"}})
+	}
+}
+
+// registerExtractor installs (or replaces) the extractor for ext
+func registerExtractor(ext string, e Extractor) {
+	extractors[strings.ToLower(ext)] = e
+}
+
+// registerCommandExtractors installs external commands from the config file
+func registerCommandExtractors(commands map[string]string) error {
+	for ext, command := range commands {
+		args := strings.Fields(command)
+		if len(args) == 0 {
+			return fmt.Errorf("empty extractor command for %s", ext)
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		registerExtractor(ext, commandExtractor{ext: ext, args: args})
+	}
+	return nil
+}
+
+// extractorFor returns the extractor registered for the file, if any
+func extractorFor(path string) Extractor {
+	return extractors[strings.ToLower(filepath.Ext(path))]
+}
+
+// commandExtractor runs an external program and searches its stdout
+type commandExtractor struct {
+	ext  string
+	args []string // {path} is replaced by the document path
+}
+
+func (c commandExtractor) Name() string { return strings.TrimPrefix(c.ext, ".") }
+
+func (c commandExtractor) Extract(ctx context.Context, path string) (io.Reader, error) {
+	args := make([]string, len(c.args))
+	for i, arg := range c.args {
+		args[i] = strings.ReplaceAll(arg, "{path}", path)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return bytes.NewReader(out), nil
+}
+
+// ooxmlExtractor pulls text runs out of Office Open XML (zip + XML) parts
+type ooxmlExtractor struct {
+	name       string
+	parts      []string // Exact part names to read
+	partPrefix string   // Or: read all parts with this prefix, in numeric order
+	paragraph  string   // Element that ends a line
+	text       string   // Element holding text
+}
+
+func (o ooxmlExtractor) Name() string { return o.name }
+
+func (o ooxmlExtractor) Extract(ctx context.Context, path string) (io.Reader, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var files []*zip.File
+	for _, f := range zr.File {
+		if o.partPrefix != "" && strings.HasPrefix(f.Name, o.partPrefix) && strings.HasSuffix(f.Name, ".xml") {
+			files = append(files, f)
+		}
+		for _, part := range o.parts {
+			if f.Name == part {
+				files = append(files, f)
+			}
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return partNumber(files[i].Name) < partNumber(files[j].Name) })
+
+	var out bytes.Buffer
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		err = xmlText(rc, &out, o.paragraph, o.text)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+	}
+	return &out, nil
+}
+
+// partNumber extracts the trailing number of a part such as slide12.xml
+func partNumber(name string) int {
+	base := strings.TrimSuffix(filepath.Base(name), ".xml")
+	i := len(base)
+	for i > 0 && base[i-1] >= '0' && base[i-1] <= '9' {
+		i--
+	}
+	n, _ := strconv.Atoi(base[i:])
+	return n
+}
+
+// xmlText writes the character data of text elements, ending a line at
+// each paragraph element and translating tabs and breaks
+func xmlText(r io.Reader, w *bytes.Buffer, paragraph, text string) error {
+	dec := xml.NewDecoder(r)
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case text:
+				inText = true
+			case "tab":
+				w.WriteByte('\t')
+			case "br":
+				w.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case text:
+				inText = false
+			case paragraph:
+				w.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				w.Write(t)
+			}
+		}
+	}
+}
+
+// xlsxExtractor renders each worksheet row as a tab-separated line
+type xlsxExtractor struct{}
+
+func (xlsxExtractor) Name() string { return "xlsx" }
+
+func (xlsxExtractor) Extract(ctx context.Context, path string) (io.Reader, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var shared []string
+	var sheets []*zip.File
+	for _, f := range zr.File {
+		switch {
+		case f.Name == "xl/sharedStrings.xml":
+			if shared, err = readSharedStrings(f); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(f.Name, "xl/worksheets/sheet") && strings.HasSuffix(f.Name, ".xml"):
+			sheets = append(sheets, f)
+		}
+	}
+	sort.Slice(sheets, func(i, j int) bool { return partNumber(sheets[i].Name) < partNumber(sheets[j].Name) })
+
+	var out bytes.Buffer
+	for _, f := range sheets {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := writeSheetRows(f, shared, &out); err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+	}
+	return &out, nil
+}
+
+// readSharedStrings returns the workbook's shared string table
+func readSharedStrings(f *zip.File) ([]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var table struct {
+		Items []struct {
+			Text string `xml:"t"`
+			Runs []struct {
+				Text string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&table); err != nil {
+		return nil, err
+	}
+
+	shared := make([]string, len(table.Items))
+	for i, item := range table.Items {
+		text := item.Text
+		for _, run := range item.Runs {
+			text += run.Text
+		}
+		shared[i] = text
+	}
+	return shared, nil
+}
+
+// writeSheetRows writes one line per worksheet row
+func writeSheetRows(f *zip.File, shared []string, w *bytes.Buffer) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var sheet struct {
+		Rows []struct {
+			Cells []struct {
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&sheet); err != nil {
+		return err
+	}
+
+	for _, row := range sheet.Rows {
+		cells := make([]string, 0, len(row.Cells))
+		for _, c := range row.Cells {
+			switch c.Type {
+			case "s":
+				if idx, err := strconv.Atoi(c.Value); err == nil && idx >= 0 && idx < len(shared) {
+					cells = append(cells, shared[idx])
+				}
+			case "inlineStr":
+				cells = append(cells, c.Inline)
+			default:
+				cells = append(cells, c.Value)
+			}
+		}
+		w.WriteString(strings.Join(cells, "\t"))
+		w.WriteByte('\n')
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// failurePreview is the number of paths the error panel lists per kind
+const failurePreview = 5
+
+// failureKind is why a search left a file out
+type failureKind int
+
+const (
+	failPermission failureKind = iota // Reading it was denied
+	failTooLarge                      // Over the size limit
+	failBinary                        // Its content looks binary
+	failRead                          // Any other error reading it
+)
+
+// failureKinds lists the kinds in the order the error panel shows them
+var failureKinds = []failureKind{failPermission, failTooLarge, failBinary, failRead}
+
+func (k failureKind) String() string {
+	switch k {
+	case failPermission:
+		return "permission denied"
+	case failTooLarge:
+		return "too large"
+	case failBinary:
+		return "binary"
+	default:
+		return "read error"
+	}
+}
+
+// failedFile is a file or directory a search skipped or couldn't read
+type failedFile struct {
+	Path   string
+	Kind   failureKind
+	Reason string // The error, or why the file was skipped
+}
+
+// failureOf classifies an error reading path
+func failureOf(path string, err error) failedFile {
+	kind := failRead
+	if errors.Is(err, fs.ErrPermission) {
+		kind = failPermission
+	}
+	return failedFile{Path: path, Kind: kind, Reason: err.Error()}
+}
+
+// sortFailures orders failures by path
+func sortFailures(failed []failedFile) {
+	sort.SliceStable(failed, func(i, j int) bool { return failed[i].Path < failed[j].Path })
+}
+
+// errorMessages returns the search's errors and those of the files it
+// couldn't read, for exports
+func (r SearchResults) errorMessages() []string {
+	messages := append([]string(nil), r.Errors...)
+	for _, f := range r.Failed {
+		if f.Kind == failPermission || f.Kind == failRead {
+			messages = append(messages, f.Reason)
+		}
+	}
+	return messages
+}
+
+// failureCounts counts failures by kind
+func failureCounts(failed []failedFile) map[failureKind]int {
+	counts := make(map[failureKind]int)
+	for _, f := range failed {
+		counts[f.Kind]++
+	}
+	return counts
+}
+
+// failureSummary lists the counts of each kind, such as "2 permission
+// denied · 14 binary"
+func failureSummary(failed []failedFile) string {
+	counts := failureCounts(failed)
+	var parts []string
+	for _, kind := range failureKinds {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// renderFailures shows the search's errors and, in a panel E expands,
+// the files it skipped or couldn't read, by kind
+func (m model) renderFailures() string {
+	r := m.searchResults
+	if len(r.Errors) == 0 && len(r.Failed) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n")
+	if len(r.Errors) > 0 {
+		b.WriteString(errorStyle.Render("Errors encountered:"))
+		b.WriteString("\n")
+		for _, err := range r.Errors {
+			b.WriteString("  ")
+			b.WriteString(errorStyle.Render(err))
+			b.WriteString("\n")
+		}
+	}
+	if len(r.Failed) == 0 {
+		return b.String()
+	}
+
+	toggle := "E to list them"
+	if m.showFailures {
+		toggle = "E to hide them"
+	}
+	b.WriteString(warningStyle.Render(fmt.Sprintf("Files left out: %s", failureSummary(r.Failed))))
+	b.WriteString(helpStyle.Render(fmt.Sprintf(" (%s, R to retry)", toggle)))
+	b.WriteString("\n")
+	if !m.showFailures {
+		return b.String()
+	}
+	for _, kind := range failureKinds {
+		var paths []failedFile
+		for _, f := range r.Failed {
+			if f.Kind == kind {
+				paths = append(paths, f)
+			}
+		}
+		if len(paths) == 0 {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("  %s (%d):\n", kind, len(paths)))
+		for _, f := range paths[:min(len(paths), failurePreview)] {
+			// Read errors name the file themselves
+			line := f.Path
+			switch kind {
+			case failTooLarge, failBinary:
+				line += ": " + f.Reason
+			case failRead:
+				line = f.Reason
+			}
+			b.WriteString(helpStyle.Render("    " + line))
+			b.WriteString("\n")
+		}
+		if len(paths) > failurePreview {
+			b.WriteString(helpStyle.Render(fmt.Sprintf("    … and %d more", len(paths)-failurePreview)))
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// toggleFailures shows or hides the files the search left out
+func (m *model) toggleFailures() {
+	if len(m.searchResults.Failed) == 0 {
+		m.statusMsg = "No files were left out"
+		return
+	}
+	m.showFailures = !m.showFailures
+}
+
+// promptRetry asks which of the files the search left out to search again
+func (m *model) promptRetry() {
+	if m.searching {
+		m.statusMsg = "Files can be retried once the search finishes"
+		return
+	}
+	if len(m.searchResults.Failed) == 0 {
+		m.statusMsg = "No files to retry"
+		return
+	}
+	m.prompt = &inputPrompt{
+		label:  fmt.Sprintf("Retry [a]ll %d, [p]ermission denied, too [l]arge, [b]inary or [r]ead errors? ", len(m.searchResults.Failed)),
+		input:  "a",
+		cursor: 1,
+		onRun: func(m *model, value string) tea.Cmd {
+			kinds := map[string][]failureKind{
+				"a": failureKinds, "all": failureKinds,
+				"p": {failPermission}, "l": {failTooLarge}, "b": {failBinary}, "r": {failRead},
+			}[strings.ToLower(value)]
+			if kinds == nil {
+				m.statusMsg = fmt.Sprintf("Unknown choice: %s", value)
+				return nil
+			}
+			return m.retryFailures(kinds)
+		},
+	}
+}
+
+// retryCompleteMsg carries the outcome of searching skipped files again
+type retryCompleteMsg struct {
+	id      int            // searchID of the results the files were left out of
+	results []SearchResult // Results from the files searched this time
+	failed  []failedFile   // Files still left out
+	kept    []failedFile   // Files of the kinds not retried
+	retried int
+}
+
+// retryFailures searches the files of the given kinds the search left out
+// again, with the current settings, to add their results to the view
+func (m *model) retryFailures(kinds []failureKind) tea.Cmd {
+	var retry, kept []failedFile
+	for _, f := range m.searchResults.Failed {
+		retried := false
+		for _, kind := range kinds {
+			retried = retried || f.Kind == kind
+		}
+		if retried {
+			retry = append(retry, f)
+		} else {
+			kept = append(kept, f)
+		}
+	}
+	if len(retry) == 0 {
+		m.statusMsg = "No files of that kind to retry"
+		return nil
+	}
+	m.statusMsg = fmt.Sprintf("Retrying %d files...", len(retry))
+
+	id := m.searchID
+	return func() tea.Msg {
+		ctx := context.Background()
+		msg := retryCompleteMsg{id: id, kept: kept, retried: len(retry)}
+		search := func(path string) {
+			results, _, err := m.searchFileOptimized(ctx, path)
+			if err != nil {
+				msg.failed = append(msg.failed, failureOf(path, err))
+				return
+			}
+			msg.results = append(msg.results, results...)
+		}
+		for _, f := range retry {
+			info, err := os.Stat(f.Path)
+			switch {
+			case err != nil:
+				msg.failed = append(msg.failed, failureOf(f.Path, err))
+			case info.IsDir():
+				files, _, failed := m.collectFilesFromDir(ctx, f.Path)
+				msg.failed = append(msg.failed, failed...)
+				for _, path := range files {
+					search(path)
+				}
+			default:
+				if ok, skipped := m.checkFile(f.Path, info); ok {
+					search(f.Path)
+				} else if skipped != nil {
+					msg.failed = append(msg.failed, *skipped)
+				}
+			}
+		}
+		return msg
+	}
+}
+
+// handleRetryComplete adds the results of retried files to the view
+func (m *model) handleRetryComplete(msg retryCompleteMsg) {
+	merged, added := mergeResults(m.searchResults.Results, msg.results)
+	m.searchResults.Results = sortResults(merged)
+	m.searchResults.Failed = append(msg.kept, msg.failed...)
+	sortFailures(m.searchResults.Failed)
+	m.applyResultFilters()
+	m.statusMsg = fmt.Sprintf("Retried %d files: %d new results, %d still left out",
+		msg.retried, len(added), len(msg.failed))
+}
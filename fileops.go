@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fileOperation is a copy or move of browser items running in the
+// background. The model and the goroutine doing the work share it.
+type fileOperation struct {
+	move   bool
+	items  []fileOpItem
+	cancel context.CancelFunc
+
+	total int64 // Bytes to copy or move, once counted
+	done  int64 // Bytes copied or moved so far
+
+	mu      sync.Mutex
+	current string // Item being copied or moved
+}
+
+// fileOpItem is one item of a file operation and where it goes
+type fileOpItem struct {
+	src, dst string
+}
+
+// fileOpTickMsg asks for the progress of a file operation to be shown again
+type fileOpTickMsg struct {
+	op *fileOperation
+}
+
+// fileOpDoneMsg reports a finished file operation
+type fileOpDoneMsg struct {
+	op   *fileOperation
+	done int
+	errs []string
+}
+
+// verb names the operation for status messages
+func (op *fileOperation) verb() (present, past string) {
+	if op.move {
+		return "Moving", "Moved"
+	}
+	return "Copying", "Copied"
+}
+
+// promptRename asks for a new name for the item under the cursor
+func (m *model) promptRename() {
+	if len(m.files) == 0 || m.files[m.selectedFile].Name == ".." {
+		m.statusMsg = "Nothing to rename"
+		return
+	}
+	item := m.files[m.selectedFile]
+	m.prompt = &inputPrompt{
+		label:  fmt.Sprintf("Rename %s to: ", item.Name),
+		input:  item.Name,
+		cursor: len([]rune(item.Name)),
+		onSubmit: func(m *model, name string) {
+			switch {
+			case name == "" || name == item.Name:
+				m.statusMsg = "Rename cancelled"
+				return
+			case name == "." || name == ".." || strings.ContainsAny(name, `/\`):
+				m.statusMsg = fmt.Sprintf("Invalid name %q (use M to move it elsewhere)", name)
+				return
+			}
+			dst := filepath.Join(filepath.Dir(item.Path), name)
+			if _, err := os.Lstat(dst); err == nil {
+				m.statusMsg = fmt.Sprintf("Can't rename: %s already exists", name)
+				return
+			}
+			if err := os.Rename(item.Path, dst); err != nil {
+				m.statusMsg = fmt.Sprintf("Error renaming %s: %v", item.Name, err)
+				return
+			}
+			m.moveSelection(item.Path, dst)
+			m.refreshDirectory()
+			m.focusPath = dst
+			m.focusFile()
+			m.statusMsg = fmt.Sprintf("Renamed %s to %s", item.Name, name)
+		},
+	}
+}
+
+// promptFileOp asks where to copy or move the selected items, or the item
+// under the cursor
+func (m *model) promptFileOp(move bool) {
+	if m.fileOp != nil {
+		present, _ := m.fileOp.verb()
+		m.statusMsg = fmt.Sprintf("%s is still in progress (Esc stops it)", strings.ToLower(present))
+		return
+	}
+	targets := m.deleteTargets()
+	if len(targets) == 0 {
+		m.statusMsg = "Nothing to copy or move"
+		return
+	}
+	verb := "Copy"
+	if move {
+		verb = "Move"
+	}
+	what := targets[0].Name
+	if len(targets) > 1 {
+		what = fmt.Sprintf("%d items", len(targets))
+	}
+	dir := m.currentDir + string(filepath.Separator)
+	m.prompt = &inputPrompt{
+		label:  fmt.Sprintf("%s %s to: ", verb, what),
+		input:  dir,
+		cursor: len([]rune(dir)),
+		onRun: func(m *model, dest string) tea.Cmd {
+			if dest == "" {
+				m.statusMsg = "Cancelled"
+				return nil
+			}
+			items, err := fileOpItems(targets, m.resolvePath(dest))
+			if err != nil {
+				m.statusMsg = fmt.Sprintf("Can't %s: %v", strings.ToLower(verb), err)
+				return nil
+			}
+			return m.startFileOp(move, items)
+		},
+	}
+}
+
+// resolvePath expands ~ and makes a typed path absolute, relative to the
+// current directory
+func (m *model) resolvePath(path string) string {
+	if strings.HasPrefix(path, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = home + path[1:]
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.currentDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// fileOpItems pairs each target with where it goes: into dest when dest
+// is a directory, or to dest itself for a single target
+func fileOpItems(targets []FileItem, dest string) ([]fileOpItem, error) {
+	info, err := os.Stat(dest)
+	into := err == nil && info.IsDir()
+	if !into && len(targets) > 1 {
+		return nil, fmt.Errorf("%s is not a directory", dest)
+	}
+	if !into {
+		if info, err := os.Stat(filepath.Dir(dest)); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("no directory %s", filepath.Dir(dest))
+		}
+	}
+
+	var items []fileOpItem
+	for _, target := range targets {
+		dst := dest
+		if into {
+			dst = filepath.Join(dest, target.Name)
+		}
+		if dst == target.Path || strings.HasPrefix(dst, target.Path+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s can't go into itself", target.Name)
+		}
+		items = append(items, fileOpItem{src: target.Path, dst: dst})
+	}
+	return items, nil
+}
+
+// startFileOp copies or moves items in the background
+func (m *model) startFileOp(move bool, items []fileOpItem) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	op := &fileOperation{move: move, items: items, cancel: cancel}
+	m.fileOp = op
+	present, _ := op.verb()
+	m.statusMsg = fmt.Sprintf("%s %d items... (Esc stops)", present, len(items))
+
+	run := func() tea.Msg {
+		defer cancel()
+		sizes := make([]int64, len(items))
+		var total int64
+		for i, item := range items {
+			_, sizes[i] = pathStats(item.src)
+			total += sizes[i]
+		}
+		atomic.StoreInt64(&op.total, total)
+
+		result := fileOpDoneMsg{op: op}
+		progress := func(n int64) { atomic.AddInt64(&op.done, n) }
+		for i, item := range items {
+			if ctx.Err() != nil {
+				result.errs = append(result.errs, "stopped")
+				break
+			}
+			op.mu.Lock()
+			op.current = filepath.Base(item.src)
+			op.mu.Unlock()
+
+			before := atomic.LoadInt64(&op.done)
+			var err error
+			if _, lerr := os.Lstat(item.dst); lerr == nil {
+				err = fmt.Errorf("%s already exists", item.dst)
+			} else if move {
+				err = movePathProgress(ctx, item.src, item.dst, progress)
+			} else {
+				err = copyPathProgress(ctx, item.src, item.dst, progress)
+				if err != nil {
+					os.RemoveAll(item.dst)
+				}
+			}
+			if err != nil {
+				result.errs = append(result.errs, fmt.Sprintf("%s: %v", filepath.Base(item.src), err))
+				continue
+			}
+			// A rename moves everything without copying a byte
+			atomic.StoreInt64(&op.done, before+sizes[i])
+			result.done++
+		}
+		return result
+	}
+	return tea.Batch(run, op.tick())
+}
+
+// tick schedules the next progress update of a file operation
+func (op *fileOperation) tick() tea.Cmd {
+	return tea.Tick(time.Millisecond*ProgressUpdateMs, func(time.Time) tea.Msg {
+		return fileOpTickMsg{op: op}
+	})
+}
+
+// handleFileOpTick keeps the progress of the running operation current
+func (m *model) handleFileOpTick(msg fileOpTickMsg) tea.Cmd {
+	if m.fileOp != msg.op {
+		return nil
+	}
+	return msg.op.tick()
+}
+
+// handleFileOpDone reports a finished file operation and lists the
+// directory again
+func (m *model) handleFileOpDone(msg fileOpDoneMsg) {
+	if m.fileOp == msg.op {
+		m.fileOp = nil
+	}
+	m.refreshDirectory()
+	_, past := msg.op.verb()
+	if len(msg.errs) > 0 {
+		m.statusMsg = fmt.Sprintf("%s %d items, %d failed: %s", past, msg.done, len(msg.errs), msg.errs[0])
+	} else {
+		m.statusMsg = fmt.Sprintf("%s %d items", past, msg.done)
+	}
+}
+
+// stopFileOp stops the running copy or move; a partly copied item is
+// removed
+func (m *model) stopFileOp() {
+	if m.fileOp != nil {
+		m.fileOp.cancel()
+		m.statusMsg = "Stopping..."
+	}
+}
+
+// renderFileOp shows the progress of the running copy or move
+func (m model) renderFileOp() string {
+	op := m.fileOp
+	present, _ := op.verb()
+	total := atomic.LoadInt64(&op.total)
+	done := atomic.LoadInt64(&op.done)
+	op.mu.Lock()
+	current := op.current
+	op.mu.Unlock()
+	if total == 0 {
+		return progressStyle.Render(fmt.Sprintf("%s %d items... %s", present, len(op.items), current))
+	}
+	percent := float64(done) / float64(total) * 100
+	return progressStyle.Render(fmt.Sprintf("%s %s of %s ", present, formatSize(done), formatSize(total))) +
+		m.renderProgressBar(percent, 20) + " " + helpStyle.Render(current+" (Esc stops)")
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PerFileMode controls how many matches per file are presented
+type PerFileMode int
+
+const (
+	PerFileAll   PerFileMode = iota // Show every match
+	PerFileFirst                    // Show only the first match in each file
+	PerFileLast                     // Show only the last match in each file
+)
+
+func (p PerFileMode) String() string {
+	switch p {
+	case PerFileFirst:
+		return "first match per file"
+	case PerFileLast:
+		return "last match per file"
+	default:
+		return "all matches"
+	}
+}
+
+// AggregateMode controls whether results are listed per match or per file
+type AggregateMode int
+
+const (
+	AggregateNone   AggregateMode = iota // One row per match
+	AggregateFiles                       // Files with at least one match, with match counts
+	AggregateCounts                      // Match counts per file and overall
+)
+
+func (a AggregateMode) String() string {
+	switch a {
+	case AggregateFiles:
+		return "files with matches"
+	case AggregateCounts:
+		return "match counts"
+	default:
+		return "matches"
+	}
+}
+
+// ResultFilter narrows the displayed results without re-running the search
+type ResultFilter struct {
+	MinFileMatches int           // Only show files with at least this many matches
+	MinLineMatches int           // Only show lines with at least this many occurrences
+	PerFile        PerFileMode   // Collapse each file to its first or last match
+	Aggregate      AggregateMode // Collapse to one row per file
+}
+
+// lineKey identifies a single line of a file
+type lineKey struct {
+	path string
+	line int
+}
+
+func (f ResultFilter) active() bool {
+	return f.thresholds() || f.PerFile != PerFileAll || f.Aggregate != AggregateNone
+}
+
+// thresholds reports whether the filter drops files or lines with too few
+// matches
+func (f ResultFilter) thresholds() bool {
+	return f.MinFileMatches > 1 || f.MinLineMatches > 1
+}
+
+// describe returns a short human-readable summary of the active filters
+func (f ResultFilter) describe() string {
+	var parts []string
+	if f.MinFileMatches > 1 {
+		parts = append(parts, fmt.Sprintf("files with ≥ %d matches", f.MinFileMatches))
+	}
+	if f.MinLineMatches > 1 {
+		parts = append(parts, fmt.Sprintf("lines with ≥ %d occurrences", f.MinLineMatches))
+	}
+	if f.PerFile != PerFileAll {
+		parts = append(parts, f.PerFile.String())
+	}
+	if f.Aggregate != AggregateNone {
+		parts = append(parts, f.Aggregate.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// applyResultFilters recomputes visibleResults from searchResults and the
+// current filters, keeping the cursor within bounds
+func (m *model) applyResultFilters() {
+	m.renderCache.reset()
+	f := m.resultFilter
+	list := m.filteredResults()
+	m.visibleResults = list
+
+	m.fileCounts = nil
+	if f.Aggregate != AggregateNone {
+		var files []SearchResult
+		files, m.fileCounts = aggregateByFile(list.slice())
+		m.visibleResults = resultList{base: files, counts: list.counts}
+	}
+
+	if m.resultIndex >= m.visibleResults.len() {
+		m.resultIndex = max(m.visibleResults.len()-1, 0)
+	}
+	if m.viewport.offset > m.resultIndex {
+		m.viewport.offset = m.resultIndex
+	}
+}
+
+// filteredResults returns the result lines the current filters keep, before
+// any aggregation per file
+func (m *model) filteredResults() resultList {
+	all := m.searchResults.Results
+	f := m.resultFilter
+
+	list := resultList{base: all}
+	if f.active() {
+		list.counts = newResultCounts()
+		list.counts.add(all, f)
+		if f.thresholds() {
+			list.rows = list.counts.filter(all, 0, f)
+		}
+		if f.PerFile != PerFileAll {
+			list.rows = pickPerFile(list, f.PerFile)
+		}
+	}
+	return list
+}
+
+// addResults adds a batch of streamed results. Without filters, or with
+// only thresholds, the batch's rows are added to the visible ones and the
+// rows already styled stay valid; picking and aggregating per file filter
+// everything again.
+func (m *model) addResults(batch []SearchResult) {
+	from := len(m.searchResults.Results)
+	m.searchResults.Results = append(m.searchResults.Results, batch...)
+	f := m.resultFilter
+	l := m.visibleResults
+	if f.PerFile != PerFileAll || f.Aggregate != AggregateNone || len(l.base) != from || (f.active() && l.counts == nil) {
+		m.applyResultFilters()
+		return
+	}
+	if c := m.renderCache; c != nil && c.matches >= 0 {
+		c.matches += totalMatches(batch)
+	}
+
+	l.base = m.searchResults.Results
+	if f.thresholds() {
+		files, lines := l.counts.add(l.base, f)
+		if lines {
+			l.rows = l.counts.filter(l.base, 0, f)
+		} else {
+			// Files that reached the threshold bring in their earlier results
+			var earlier []int
+			for _, path := range files {
+				for _, i := range l.counts.rows[path] {
+					if i < from && l.counts.passes(l.base[i], f) {
+						earlier = append(earlier, i)
+					}
+				}
+			}
+			if len(earlier) > 0 {
+				sort.Ints(earlier)
+				l.rows = mergeRows(l.rows, earlier)
+			}
+			l.rows = append(l.rows, l.counts.filter(l.base, from, f)...)
+		}
+	}
+	m.visibleResults = l
+}
+
+// pickPerFile returns the indices of the first or last row of each file.
+// Rows are expected to be grouped by file and ordered by line number.
+func pickPerFile(l resultList, mode PerFileMode) []int {
+	path := func(i int) string { return l.base[l.index(i)].FilePath }
+	picked := make([]int, 0)
+	for i := 0; i < l.len(); i++ {
+		switch mode {
+		case PerFileFirst:
+			if i == 0 || path(i-1) != path(i) {
+				picked = append(picked, l.index(i))
+			}
+		case PerFileLast:
+			if i == l.len()-1 || path(i+1) != path(i) {
+				picked = append(picked, l.index(i))
+			}
+		}
+	}
+	return picked
+}
+
+// aggregateByFile collapses results to the first one of each file, in
+// order of appearance, and counts the matches in each file
+func aggregateByFile(results []SearchResult) ([]SearchResult, map[string]int) {
+	counts := make(map[string]int)
+	var files []SearchResult
+	for _, r := range results {
+		if counts[r.FilePath] == 0 {
+			files = append(files, r)
+		}
+		counts[r.FilePath] += r.matchCount() + r.MoreMatches
+	}
+	return files, counts
+}
+
+// fileMatchCount returns the number of unfiltered results in the given file
+func (m *model) fileMatchCount(path string) int {
+	if c := m.visibleResults.counts; c != nil {
+		return len(c.rows[path])
+	}
+	count := 0
+	for _, r := range m.searchResults.Results {
+		if r.FilePath == path {
+			count++
+		}
+	}
+	return count
+}
+
+// promptThreshold asks for a numeric threshold and applies it via set
+func (m *model) promptThreshold(label string, set func(f *ResultFilter, n int)) {
+	m.prompt = &inputPrompt{
+		label: label,
+		onSubmit: func(m *model, value string) {
+			n := 0
+			if value != "" {
+				var err error
+				n, err = strconv.Atoi(value)
+				if err != nil || n < 0 {
+					m.statusMsg = fmt.Sprintf("Invalid number: %s", value)
+					return
+				}
+			}
+			m.pushUndo("change result filter")
+			set(&m.resultFilter, n)
+			m.applyResultFilters()
+			if m.resultFilter.active() {
+				m.statusMsg = fmt.Sprintf("Showing %d of %d results (%s)",
+					m.visibleResults.len(), len(m.searchResults.Results), m.resultFilter.describe())
+			} else {
+				m.statusMsg = "Result filters cleared"
+			}
+		},
+	}
+}
@@ -0,0 +1,894 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// resultReport is what a Formatter writes: a search's results, with the
+// notes attached to their lines
+type resultReport struct {
+	Pattern   string
+	Target    string
+	Filters   string // Description of the result filters, if any
+	Results   []SearchResult
+	Notes     map[lineKey]string
+	Errors    []string
+	Generated time.Time
+	Config    *SearchConfig // Settings the search ran with, if known
+	Stats     *reportStats  // Totals of the whole search, if known
+}
+
+// reportStats are the totals of a search, which the results alone can't
+// tell once they are filtered or truncated
+type reportStats struct {
+	FilesSearched int   `json:"files_searched"`
+	FilesMatched  int   `json:"files_matched"`
+	Lines         int   `json:"lines"`
+	Matches       int   `json:"matches"`
+	FilesSkipped  int   `json:"files_skipped"`           // Left out for their size or content, or unreadable
+	IndexSkipped  int   `json:"index_skipped,omitempty"` // Ruled out by a trigram index
+	Truncated     bool  `json:"truncated,omitempty"`
+	ElapsedMs     int64 `json:"elapsed_ms"`
+}
+
+// searchStats totals the results of a search
+func searchStats(results SearchResults) *reportStats {
+	files, _ := aggregateByFile(results.Results)
+	return &reportStats{
+		FilesSearched: results.TotalFiles,
+		FilesMatched:  len(files),
+		Lines:         len(results.Results),
+		Matches:       totalMatches(results.Results),
+		FilesSkipped:  len(results.Failed),
+		IndexSkipped:  results.IndexSkipped,
+		Truncated:     results.Truncated,
+		ElapsedMs:     results.SearchTime.Milliseconds(),
+	}
+}
+
+// Formatter writes search results in one output format
+type Formatter interface {
+	// Name selects the formatter with -format
+	Name() string
+	// Format writes the report
+	Format(w io.Writer, r resultReport) error
+}
+
+// formatters maps format names to the formatter writing them; the CLI
+// (-format, zx search -format) and the TUI exports all go through it
+var formatters = map[string]Formatter{}
+
+// formatExtensions picks the format of an export from its file name
+var formatExtensions = map[string]string{
+	".txt":      "plain",
+	".json":     "json",
+	".jsonl":    "jsonl",
+	".ndjson":   "jsonl",
+	".csv":      "csv",
+	".tsv":      "tsv",
+	".md":       "markdown",
+	".markdown": "markdown",
+	".sarif":    "sarif",
+}
+
+func init() {
+	for _, f := range []Formatter{
+		plainFormatter{}, vimgrepFormatter{}, jsonFormatter{}, jsonlFormatter{},
+		delimitedFormatter{"csv", ','}, delimitedFormatter{"tsv", '\t'},
+		markdownFormatter{}, sarifFormatter{},
+	} {
+		registerFormatter(f)
+	}
+}
+
+// registerFormatter installs (or replaces) the formatter of its name
+func registerFormatter(f Formatter) {
+	formatters[f.Name()] = f
+}
+
+// formatterNames lists the registered formats alphabetically
+func formatterNames() []string {
+	var names []string
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatterFor returns the named formatter
+func formatterFor(name string) (Formatter, error) {
+	if f, ok := formatters[strings.ToLower(name)]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (use %s)", name, strings.Join(formatterNames(), ", "))
+}
+
+// formatterForPath returns the formatter for an export file by its
+// extension, plain for unknown ones
+func formatterForPath(path string) Formatter {
+	if name, ok := formatExtensions[strings.ToLower(filepath.Ext(path))]; ok {
+		if f, ok := formatters[name]; ok {
+			return f
+		}
+	}
+	return formatters["plain"]
+}
+
+// noteFor returns the note attached to a result's line
+func (r resultReport) noteFor(result SearchResult) string {
+	return r.Notes[lineKey{result.FilePath, result.LineNumber}]
+}
+
+// plainLayout is how printed results show their paths, from -heading,
+// -no-filename and -0
+type plainLayout struct {
+	heading bool // Each file's path once, above its lines
+	noPath  bool // No paths at all
+	null    bool // A NUL byte after each path instead of ':' or a newline, for xargs -0
+}
+
+// outputLayout is the layout of the results a command-line search prints
+var outputLayout plainLayout
+
+// pathEnd is what follows a path before the rest of its line: sep, or a
+// NUL byte with -0
+func (l plainLayout) pathEnd(sep string) string {
+	if l.null {
+		return "\x00"
+	}
+	return sep
+}
+
+// plainFormatter prints one path:line:col:text line per result, the form
+// vim's quickfix list and VS Code's terminal links understand; log entries
+// show their time and unit instead. With highlight, for a terminal, paths
+// and matches are colored like grep --color.
+type plainFormatter struct {
+	highlight bool
+	layout    plainLayout
+}
+
+func (plainFormatter) Name() string { return "plain" }
+
+func (f plainFormatter) Format(w io.Writer, r resultReport) error {
+	out := bufio.NewWriter(w)
+	for i, res := range r.Results {
+		text, path := res.LineContent, res.FilePath
+		if f.highlight {
+			text, path = highlightMatches(text, res.Matches), fileStyle.Render(path)
+		}
+		switch {
+		case res.logEntry():
+			fmt.Fprintf(out, "%s %s: %s\n", res.LastModified.Format(time.RFC3339), res.Unit, text)
+		case f.layout.noPath:
+			fmt.Fprintf(out, "%d:%d:%s\n", res.LineNumber, res.Column, text)
+		case f.layout.heading:
+			if i == 0 || res.FilePath != r.Results[i-1].FilePath {
+				if i > 0 {
+					out.WriteString("\n")
+				}
+				fmt.Fprintf(out, "%s%s", path, f.layout.pathEnd("\n"))
+			}
+			fmt.Fprintf(out, "%d:%d:%s\n", res.LineNumber, res.Column, text)
+		default:
+			fmt.Fprintf(out, "%s%s%d:%d:%s\n", path, f.layout.pathEnd(":"), res.LineNumber, res.Column, text)
+		}
+	}
+	return out.Flush()
+}
+
+// vimgrepFormatter prints a path:line:col:text line per match, like
+// `rg --vimgrep`, so every occurrence gets a quickfix entry
+type vimgrepFormatter struct{}
+
+func (vimgrepFormatter) Name() string { return "vimgrep" }
+
+func (vimgrepFormatter) Format(w io.Writer, r resultReport) error {
+	out := bufio.NewWriter(w)
+	for _, res := range r.Results {
+		if len(res.Matches) == 0 {
+			fmt.Fprintf(out, "%s:%d:%d:%s\n", res.FilePath, res.LineNumber, res.Column, res.LineContent)
+			continue
+		}
+		for _, match := range res.Matches {
+			fmt.Fprintf(out, "%s:%d:%d:%s\n", res.FilePath, res.LineNumber, matchColumn(res.LineContent, match), res.LineContent)
+		}
+	}
+	return out.Flush()
+}
+
+// matchColumn is the column (in runes, from 1) where a match starts
+func matchColumn(line string, match MatchRange) int {
+	return utf8.RuneCountInString(line[:min(match.Start, len(line))]) + 1
+}
+
+// jsonResult is one result line in the JSON formats, with what is known
+// about its file
+type jsonResult struct {
+	File        string     `json:"file"`
+	Line        int        `json:"line"`
+	Column      int        `json:"column"`
+	ByteOffset  *int64     `json:"byte_offset,omitempty"` // Left out for text that isn't the file's own bytes
+	Content     string     `json:"content"`
+	Matches     []jsonSpan `json:"matches,omitempty"`
+	MoreMatches int        `json:"more_matches,omitempty"` // Matches in the file past -max-per-file
+	Note        string     `json:"note,omitempty"`
+	Size        int64      `json:"size,omitempty"`
+	Modified    string     `json:"modified,omitempty"` // RFC 3339; the entry's time for logs
+	Encoding    string     `json:"encoding,omitempty"`
+	Compression string     `json:"compression,omitempty"`
+	Extractor   string     `json:"extractor,omitempty"`
+	Layer       string     `json:"layer,omitempty"`
+	Source      string     `json:"source,omitempty"`
+	Unit        string     `json:"unit,omitempty"`
+	Priority    string     `json:"priority,omitempty"`
+	Subject     string     `json:"subject,omitempty"`
+}
+
+// jsonSpan is a match: its byte range within the content, the column it
+// starts at and the text it matched
+type jsonSpan struct {
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Column int    `json:"column"`
+	Text   string `json:"text"`
+}
+
+func (r resultReport) jsonResults() []jsonResult {
+	results := make([]jsonResult, 0, len(r.Results))
+	for _, res := range r.Results {
+		results = append(results, r.jsonResult(res))
+	}
+	return results
+}
+
+func (r resultReport) jsonResult(res SearchResult) jsonResult {
+	var spans []jsonSpan
+	for _, match := range res.Matches {
+		end := min(match.End, len(res.LineContent))
+		spans = append(spans, jsonSpan{match.Start, match.End, matchColumn(res.LineContent, match),
+			res.LineContent[min(match.Start, end):end]})
+	}
+	var modified string
+	if !res.LastModified.IsZero() {
+		modified = res.LastModified.Format(time.RFC3339)
+	}
+	var offset *int64
+	if res.rawOffsets() {
+		offset = &res.ByteOffset
+	}
+	return jsonResult{
+		File:        res.FilePath,
+		Line:        res.LineNumber,
+		Column:      res.Column,
+		ByteOffset:  offset,
+		Content:     res.LineContent,
+		Matches:     spans,
+		MoreMatches: res.MoreMatches,
+		Note:        r.noteFor(res),
+		Size:        res.FileSize,
+		Modified:    modified,
+		Encoding:    res.Encoding,
+		Compression: res.Compression,
+		Extractor:   res.Extractor,
+		Layer:       res.Layer,
+		Source:      res.Source,
+		Unit:        res.Unit,
+		Priority:    res.Priority,
+		Subject:     res.Subject,
+	}
+}
+
+// jsonConfig is the part of a SearchConfig the JSON document reports
+type jsonConfig struct {
+	CaseSensitive  bool     `json:"case_sensitive"`
+	Invert         bool     `json:"invert"`
+	FileLevel      bool     `json:"file_level"`
+	MaxFileSize    int64    `json:"max_file_size"`
+	MaxResults     int      `json:"max_results"` // 0 = unlimited
+	MaxPerFile     int      `json:"max_per_file"`
+	Include        []string `json:"include,omitempty"`
+	Exclude        []string `json:"exclude,omitempty"`
+	Hidden         bool     `json:"hidden"`
+	NoIgnore       bool     `json:"no_ignore"`
+	Gitignored     bool     `json:"include_gitignored,omitempty"`
+	Minified       bool     `json:"minified"`
+	Stashes        bool     `json:"stashes"`
+	MaxDepth       int      `json:"max_depth,omitempty"`
+	MinSize        int64    `json:"min_size,omitempty"`
+	MaxSize        int64    `json:"max_size,omitempty"`
+	ModifiedWithin string   `json:"modified_within,omitempty"`
+	AlwaysSearch   []string `json:"always_search,omitempty"`
+	Workers        int      `json:"workers"`
+	NetworkSafe    bool     `json:"network_safe,omitempty"`
+}
+
+func newJSONConfig(c *SearchConfig) *jsonConfig {
+	if c == nil {
+		return nil
+	}
+	jc := &jsonConfig{
+		CaseSensitive: c.CaseSensitive,
+		Invert:        c.InvertMatch,
+		FileLevel:     c.FileLevelMatch,
+		MaxFileSize:   c.MaxFileSize,
+		MaxResults:    c.MaxResults,
+		MaxPerFile:    c.MaxPerFile,
+		Include:       c.IncludePatterns,
+		Exclude:       c.ExcludePatterns,
+		Hidden:        c.IncludeHidden,
+		NoIgnore:      c.NoIgnore,
+		Gitignored:    c.IncludeGitignored,
+		Minified:      c.IncludeMinified,
+		Stashes:       c.SearchStashes,
+		MaxDepth:      c.MaxDepth,
+		MinSize:       c.MinSize,
+		MaxSize:       c.MaxSize,
+		AlwaysSearch:  c.AlwaysSearch,
+		Workers:       c.MaxConcurrency,
+		NetworkSafe:   c.NetworkSafe,
+	}
+	if c.ModifiedWithin > 0 {
+		jc.ModifiedWithin = c.ModifiedWithin.String()
+	}
+	return jc
+}
+
+// jsonFormatter writes one document with the search, its settings and
+// totals, and its results
+type jsonFormatter struct{}
+
+func (jsonFormatter) Name() string { return "json" }
+
+func (jsonFormatter) Format(w io.Writer, r resultReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Pattern string       `json:"pattern"`
+		Target  string       `json:"target,omitempty"`
+		Filters string       `json:"filters,omitempty"`
+		Config  *jsonConfig  `json:"config,omitempty"`
+		Stats   *reportStats `json:"stats,omitempty"`
+		Results []jsonResult `json:"results"`
+		Errors  []string     `json:"errors,omitempty"`
+	}{r.Pattern, r.Target, r.Filters, newJSONConfig(r.Config), r.Stats, r.jsonResults(), r.Errors})
+}
+
+// jsonlFormatter writes one JSON object per result line, for streaming
+// into tools like jq
+type jsonlFormatter struct{}
+
+func (jsonlFormatter) Name() string { return "jsonl" }
+
+func (jsonlFormatter) Format(w io.Writer, r resultReport) error {
+	out := bufio.NewWriter(w)
+	enc := json.NewEncoder(out)
+	for _, res := range r.jsonResults() {
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// cliStream writes the results of a headless -format jsonl search as each
+// file is searched; nil when the results are printed once it ends
+var cliStream *jsonlStream
+
+// jsonlStream writes result lines as JSON objects while the search runs.
+// Roots of a workspace search share it, so writes are serialized.
+type jsonlStream struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// newJSONLStream streams results to w
+func newJSONLStream(w io.Writer) *jsonlStream {
+	return &jsonlStream{enc: json.NewEncoder(w)}
+}
+
+// write prints a file's results; it keeps the first error for finish
+func (s *jsonlStream) write(results []SearchResult) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var r resultReport
+	for _, res := range results {
+		if s.err == nil {
+			s.err = s.enc.Encode(r.jsonResult(res))
+		}
+	}
+}
+
+// finish returns the first error writing the results
+func (s *jsonlStream) finish() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// templateFields maps the placeholders of the simple -template syntax to
+// the template text they stand for
+var templateFields = map[string]string{
+	"path":     "{{.File}}",
+	"file":     "{{.File}}",
+	"line":     "{{.Line}}",
+	"col":      "{{.Column}}",
+	"column":   "{{.Column}}",
+	"text":     "{{.Content}}",
+	"content":  "{{.Content}}",
+	"match":    "{{.Match}}",
+	"matches":  "{{len .Matches}}",
+	"byte":     "{{with .ByteOffset}}{{.}}{{end}}",
+	"note":     "{{.Note}}",
+	"size":     "{{.Size}}",
+	"modified": "{{.Modified}}",
+	"encoding": "{{.Encoding}}",
+	"ext":      "{{.Ext}}",
+}
+
+// simplePlaceholder matches a {name} placeholder of the simple syntax
+var simplePlaceholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// templateFormatter writes each result through a -template: Go
+// text/template over the fields of jsonResult, or the simple syntax of
+// {path}:{line}:{col} {text} placeholders
+type templateFormatter struct {
+	tmpl *template.Template
+}
+
+func (templateFormatter) Name() string { return "template" }
+
+// newTemplateFormatter parses a -template. Templates without {{ use the
+// simple syntax, where \t and \n stand for a tab and a newline. Each
+// result ends with a newline unless the template does.
+func newTemplateFormatter(text string) (templateFormatter, error) {
+	if !strings.Contains(text, "{{") {
+		var unknown string
+		text = simplePlaceholder.ReplaceAllStringFunc(text, func(p string) string {
+			name := strings.ToLower(p[1 : len(p)-1])
+			field, ok := templateFields[name]
+			if !ok && unknown == "" {
+				unknown = name
+			}
+			return field
+		})
+		if unknown != "" {
+			var names []string
+			for name := range templateFields {
+				names = append(names, "{"+name+"}")
+			}
+			sort.Strings(names)
+			return templateFormatter{}, fmt.Errorf("unknown placeholder {%s} (use %s, or Go template syntax)", unknown, strings.Join(names, ", "))
+		}
+		text = strings.NewReplacer(`\t`, "\t", `\n`, "\n", `\\`, `\`).Replace(text)
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	tmpl, err := template.New("result").Funcs(template.FuncMap{
+		"base": filepath.Base,
+		"dir":  filepath.Dir,
+		"trim": strings.TrimSpace,
+	}).Parse(text)
+	if err != nil {
+		return templateFormatter{}, fmt.Errorf("invalid template: %v", err)
+	}
+	return templateFormatter{tmpl: tmpl}, nil
+}
+
+func (f templateFormatter) Format(w io.Writer, r resultReport) error {
+	out := bufio.NewWriter(w)
+	for _, res := range r.Results {
+		if err := f.tmpl.Execute(out, r.jsonResult(res)); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// Match is the text of the result's matches, separated by spaces, for
+// templates
+func (r jsonResult) Match() string {
+	var texts []string
+	for _, span := range r.Matches {
+		texts = append(texts, span.Text)
+	}
+	return strings.Join(texts, " ")
+}
+
+// Ext is the extension of the result's file, for templates
+func (r jsonResult) Ext() string {
+	return filepath.Ext(r.File)
+}
+
+// delimitedColumns are the columns the CSV and TSV formats can write, in
+// the order -columns lists them, with each one's value for a result
+var delimitedColumns = []struct {
+	name  string
+	value func(r resultReport, res SearchResult) string
+}{
+	{"file", func(_ resultReport, res SearchResult) string { return res.FilePath }},
+	{"line", func(_ resultReport, res SearchResult) string { return strconv.Itoa(res.LineNumber) }},
+	{"column", func(_ resultReport, res SearchResult) string { return strconv.Itoa(res.Column) }},
+	{"byte_offset", func(_ resultReport, res SearchResult) string {
+		if !res.rawOffsets() {
+			return ""
+		}
+		return strconv.FormatInt(res.ByteOffset, 10)
+	}},
+	{"content", func(_ resultReport, res SearchResult) string { return res.LineContent }},
+	{"match", func(_ resultReport, res SearchResult) string {
+		var texts []string
+		for _, match := range res.Matches {
+			texts = append(texts, res.LineContent[min(match.Start, len(res.LineContent)):min(match.End, len(res.LineContent))])
+		}
+		return strings.Join(texts, " ")
+	}},
+	{"matches", func(_ resultReport, res SearchResult) string { return strconv.Itoa(len(res.Matches)) }},
+	{"note", func(r resultReport, res SearchResult) string { return r.noteFor(res) }},
+	{"size", func(_ resultReport, res SearchResult) string { return strconv.FormatInt(res.FileSize, 10) }},
+	{"modified", func(_ resultReport, res SearchResult) string {
+		if res.LastModified.IsZero() {
+			return ""
+		}
+		return res.LastModified.Format(time.RFC3339)
+	}},
+	{"encoding", func(_ resultReport, res SearchResult) string { return res.Encoding }},
+	{"extension", func(_ resultReport, res SearchResult) string { return filepath.Ext(res.FilePath) }},
+}
+
+// exportColumns are the columns of CSV and TSV output, from -columns or
+// csv_columns in the config file
+var exportColumns = []string{"file", "line", "column", "content", "note"}
+
+// parseColumns checks a list of column names, split on commas and spaces
+func parseColumns(list []string) ([]string, error) {
+	var columns []string
+	for _, item := range list {
+		for _, name := range strings.FieldsFunc(item, func(r rune) bool { return r == ',' || r == ' ' }) {
+			name = strings.ToLower(name)
+			if columnIndex(name) < 0 {
+				var names []string
+				for _, c := range delimitedColumns {
+					names = append(names, c.name)
+				}
+				return nil, fmt.Errorf("unknown column %q (use %s)", name, strings.Join(names, ", "))
+			}
+			columns = append(columns, name)
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns given")
+	}
+	return columns, nil
+}
+
+// columnIndex returns the position of a column in delimitedColumns, or -1
+func columnIndex(name string) int {
+	for i, c := range delimitedColumns {
+		if c.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// delimitedFormatter writes a header and one row per result line, with
+// the columns of exportColumns separated by comma: CSV, or TSV for
+// spreadsheets that paste tab-separated text into cells
+type delimitedFormatter struct {
+	name  string
+	comma rune
+}
+
+func (f delimitedFormatter) Name() string { return f.name }
+
+func (f delimitedFormatter) Format(w io.Writer, r resultReport) error {
+	out := csv.NewWriter(w)
+	out.Comma = f.comma
+	out.Write(exportColumns)
+	row := make([]string, len(exportColumns))
+	for _, res := range r.Results {
+		for i, name := range exportColumns {
+			row[i] = delimitedColumns[columnIndex(name)].value(r, res)
+		}
+		out.Write(row)
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// markdownFormatter writes a report with one entry per matched line,
+// grouped by file, and the notes under their lines
+type markdownFormatter struct{}
+
+func (markdownFormatter) Name() string { return "markdown" }
+
+func (markdownFormatter) Format(w io.Writer, r resultReport) error {
+	var b strings.Builder
+
+	annotated := 0
+	seen := make(map[lineKey]bool)
+	var lines []SearchResult
+	for _, res := range r.Results {
+		key := lineKey{res.FilePath, res.LineNumber}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		lines = append(lines, res)
+		if r.Notes[key] != "" {
+			annotated++
+		}
+	}
+
+	b.WriteString(fmt.Sprintf("# zx report: %s\n\n", markdownCode(r.Pattern)))
+	b.WriteString(fmt.Sprintf("- Target: %s\n", r.Target))
+	b.WriteString(fmt.Sprintf("- Lines: %d (%d annotated)\n", len(lines), annotated))
+	if r.Filters != "" {
+		b.WriteString(fmt.Sprintf("- Filters: %s\n", r.Filters))
+	}
+	b.WriteString(fmt.Sprintf("- Generated: %s\n", r.Generated.Format("2006-01-02 15:04")))
+
+	currentFile := ""
+	for _, res := range lines {
+		if res.FilePath != currentFile {
+			currentFile = res.FilePath
+			b.WriteString(fmt.Sprintf("\n## %s\n\n", currentFile))
+		}
+		b.WriteString(fmt.Sprintf("- L%d: %s\n", res.LineNumber, markdownCode(strings.TrimSpace(res.LineContent))))
+		if note := r.noteFor(res); note != "" {
+			b.WriteString(fmt.Sprintf("  - **Note:** %s\n", note))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sarifLevel is the level of SARIF results and rules, from -sarif-level
+var sarifLevel = "note"
+
+// sarifLevels are the levels SARIF defines for results
+var sarifLevels = []string{"none", "note", "warning", "error"}
+
+// setSARIFLevel checks and sets the level of SARIF results
+func setSARIFLevel(level string) error {
+	for _, l := range sarifLevels {
+		if strings.EqualFold(l, level) {
+			sarifLevel = l
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown SARIF level %q (use %s)", level, strings.Join(sarifLevels, ", "))
+}
+
+// sarifFormatter writes a SARIF 2.1.0 log for code scanning dashboards:
+// a rule per term of the pattern, and a result per match under the rule
+// of the term that matched
+type sarifFormatter struct{}
+
+func (sarifFormatter) Name() string { return "sarif" }
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	ID                   string    `json:"id"`
+	ShortDescription     sarifText `json:"shortDescription"`
+	FullDescription      sarifText `json:"fullDescription"`
+	DefaultConfiguration struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI       string `json:"uri"`
+			URIBaseID string `json:"uriBaseId,omitempty"`
+		} `json:"artifactLocation"`
+		Region sarifRegion `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifText         `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+// sarifSourceRoot is the base of relative result paths: the directory zx
+// ran in, which is the checkout root in a CI job
+const sarifSourceRoot = "%SRCROOT%"
+
+// sarifURI turns a path into the URI SARIF expects: relative paths stay
+// relative (to sarifSourceRoot), absolute ones become file URIs
+func sarifURI(path string) string {
+	uri := filepath.ToSlash(path)
+	if !filepath.IsAbs(path) {
+		return uri
+	}
+	if !strings.HasPrefix(uri, "/") {
+		uri = "/" + uri // C:/dir on Windows
+	}
+	return "file://" + uri
+}
+
+// sarifRuleID turns a term into a rule ID: its letters, digits, dots and
+// underscores, with dashes for the rest
+func sarifRuleID(term string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range term {
+		if c < utf8.RuneSelf && (c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 64 {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "pattern"
+	}
+	return b.String()
+}
+
+// sarifRules makes a rule for each term of the pattern that reports
+// matches, returning the rule of each term by its index in Query.Patterns;
+// an inverted search has a single rule for the lines without a match
+func sarifRules(r resultReport) ([]sarifRule, map[int]int) {
+	newRule := func(id, short string) sarifRule {
+		rule := sarifRule{ID: id, ShortDescription: sarifText{short}, FullDescription: sarifText{"zx search for " + r.Pattern}}
+		rule.DefaultConfiguration.Level = sarifLevel
+		return rule
+	}
+	query, err := compileQuery(r.Pattern)
+	if err != nil || r.Config != nil && r.Config.InvertMatch {
+		id := sarifRuleID(r.Pattern)
+		if err == nil {
+			id = "not-" + id
+			return []sarifRule{newRule(id, "Lines without a match for "+r.Pattern)}, nil
+		}
+		return []sarifRule{newRule(id, "Matches "+r.Pattern)}, nil
+	}
+	var rules []sarifRule
+	ruleOf := make(map[int]int)
+	seen := make(map[string]int)
+	for i, re := range query.Patterns {
+		if !query.Positive[i] {
+			continue
+		}
+		id := sarifRuleID(re.String())
+		if seen[id]++; seen[id] > 1 {
+			id = fmt.Sprintf("%s-%d", id, seen[id])
+		}
+		ruleOf[i] = len(rules)
+		rules = append(rules, newRule(id, "Matches "+re.String()))
+	}
+	if len(rules) == 0 {
+		rules = append(rules, newRule(sarifRuleID(r.Pattern), "Matches "+r.Pattern))
+	}
+	return rules, ruleOf
+}
+
+// sarifFingerprint identifies a finding across runs by its rule, file and
+// line text rather than its line number, so code scanning keeps tracking
+// an alert after the lines above it change; n tells apart a rule's
+// matches on the same line
+func sarifFingerprint(ruleID, path, line string, n int) string {
+	sum := sha256.Sum256([]byte(ruleID + "\x00" + filepath.ToSlash(path) + "\x00" + strings.TrimSpace(line)))
+	return fmt.Sprintf("%x:%d", sum[:16], n)
+}
+
+func (sarifFormatter) Format(w io.Writer, r resultReport) error {
+	rules, ruleOf := sarifRules(r)
+	results := []sarifResult{}
+	relative := false
+	for _, res := range r.Results {
+		spans := res.Matches
+		if len(spans) == 0 {
+			spans = []MatchRange{{}}
+		}
+		perRule := make(map[int]int)
+		for _, span := range spans {
+			var loc sarifLocation
+			loc.PhysicalLocation.ArtifactLocation.URI = sarifURI(res.FilePath)
+			if !filepath.IsAbs(res.FilePath) {
+				loc.PhysicalLocation.ArtifactLocation.URIBaseID = sarifSourceRoot
+				relative = true
+			}
+			loc.PhysicalLocation.Region = sarifRegion{StartLine: res.LineNumber, StartColumn: res.Column}
+			rule := 0
+			if span.End > span.Start {
+				loc.PhysicalLocation.Region.StartColumn = matchColumn(res.LineContent, span)
+				loc.PhysicalLocation.Region.EndColumn = matchColumn(res.LineContent, MatchRange{Start: span.End})
+				rule = ruleOf[span.Term] // The first rule for a term it doesn't know
+			}
+			perRule[rule]++
+
+			result := sarifResult{
+				RuleID:    rules[rule].ID,
+				RuleIndex: rule,
+				Level:     sarifLevel,
+				Message:   sarifText{strings.TrimSpace(res.LineContent)},
+				Locations: []sarifLocation{loc},
+				PartialFingerprints: map[string]string{
+					"zxLineHash/v1": sarifFingerprint(rules[rule].ID, res.FilePath, res.LineContent, perRule[rule]),
+				},
+			}
+			if note := r.noteFor(res); note != "" {
+				result.Message.Text = note
+			}
+			results = append(results, result)
+		}
+	}
+
+	var run struct {
+		Tool struct {
+			Driver struct {
+				Name           string      `json:"name"`
+				InformationURI string      `json:"informationUri"`
+				Rules          []sarifRule `json:"rules"`
+			} `json:"driver"`
+		} `json:"tool"`
+		OriginalURIBaseIDs map[string]struct {
+			URI string `json:"uri"`
+		} `json:"originalUriBaseIds,omitempty"`
+		ColumnKind string        `json:"columnKind"`
+		Results    []sarifResult `json:"results"`
+	}
+	run.Tool.Driver.Name = "zx"
+	run.Tool.Driver.InformationURI = "https://github.com/ajaikumarvs/zx"
+	run.Tool.Driver.Rules = rules
+	if dir, err := os.Getwd(); err == nil && relative {
+		run.OriginalURIBaseIDs = map[string]struct {
+			URI string `json:"uri"`
+		}{sarifSourceRoot: {strings.TrimSuffix(sarifURI(dir), "/") + "/"}}
+	}
+	run.ColumnKind = "unicodeCodePoints"
+	run.Results = results
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs":    []any{run},
+	})
+}
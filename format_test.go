@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// sampleReport is a search for "foo || bar" with a result in a plain file,
+// with a note, and one in a decompressed file
+func sampleReport() resultReport {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return resultReport{
+		Pattern: "foo || bar",
+		Target:  "proj",
+		Results: []SearchResult{
+			{
+				FilePath:     "src/main.go",
+				LineNumber:   3,
+				LineContent:  "\tx := foo(bar)",
+				Matches:      []MatchRange{{Start: 6, End: 9, Term: 0}, {Start: 10, End: 13, Term: 1}},
+				Column:       7,
+				ByteOffset:   40,
+				FileSize:     100,
+				LastModified: modified,
+			},
+			{
+				FilePath:    "logs/app.log.gz",
+				LineNumber:  10,
+				LineContent: "café, bar",
+				Matches:     []MatchRange{{Start: 7, End: 10, Term: 1}},
+				Column:      7,
+				Compression: CompressionGzip,
+			},
+		},
+		Notes:     map[lineKey]string{{"src/main.go", 3}: "check this"},
+		Generated: modified,
+	}
+}
+
+func TestFormatterOutput(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"plain", "src/main.go:3:7:\tx := foo(bar)\n" +
+			"logs/app.log.gz:10:7:café, bar\n"},
+		{"vimgrep", "src/main.go:3:7:\tx := foo(bar)\n" +
+			"src/main.go:3:11:\tx := foo(bar)\n" +
+			"logs/app.log.gz:10:7:café, bar\n"},
+		{"csv", "file,line,column,content,note\n" +
+			"src/main.go,3,7,\"\tx := foo(bar)\",check this\n" +
+			"logs/app.log.gz,10,7,\"café, bar\",\n"},
+		{"tsv", "file\tline\tcolumn\tcontent\tnote\n" +
+			"src/main.go\t3\t7\t\"\tx := foo(bar)\"\tcheck this\n" +
+			"logs/app.log.gz\t10\t7\tcafé, bar\t\n"},
+		// The decompressed line has no byte offset
+		{"jsonl", `{"file":"src/main.go","line":3,"column":7,"byte_offset":40,"content":"\tx := foo(bar)",` +
+			`"matches":[{"start":6,"end":9,"column":7,"text":"foo"},{"start":10,"end":13,"column":11,"text":"bar"}],` +
+			`"note":"check this","size":100,"modified":"2024-05-01T12:00:00Z"}` + "\n" +
+			`{"file":"logs/app.log.gz","line":10,"column":7,"content":"café, bar",` +
+			`"matches":[{"start":7,"end":10,"column":7,"text":"bar"}],"compression":"gzip"}` + "\n"},
+		{"markdown", "# zx report: `foo || bar`\n\n" +
+			"- Target: proj\n" +
+			"- Lines: 2 (1 annotated)\n" +
+			"- Generated: 2024-05-01 12:00\n\n" +
+			"## src/main.go\n\n" +
+			"- L3: `x := foo(bar)`\n" +
+			"  - **Note:** check this\n\n" +
+			"## logs/app.log.gz\n\n" +
+			"- L10: `café, bar`\n"},
+	}
+	for _, tt := range tests {
+		f, err := formatterFor(tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := f.Format(&buf, sampleReport()); err != nil {
+			t.Errorf("%s: %v", tt.format, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s output:\n%s\nwant:\n%s", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestPlainLayouts(t *testing.T) {
+	tests := []struct {
+		layout plainLayout
+		want   string
+	}{
+		{plainLayout{heading: true}, "src/main.go\n3:7:\tx := foo(bar)\n\nlogs/app.log.gz\n10:7:café, bar\n"},
+		{plainLayout{noPath: true}, "3:7:\tx := foo(bar)\n10:7:café, bar\n"},
+		{plainLayout{null: true}, "src/main.go\x003:7:\tx := foo(bar)\nlogs/app.log.gz\x0010:7:café, bar\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := (plainFormatter{layout: tt.layout}).Format(&buf, sampleReport()); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%+v: %q; want %q", tt.layout, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateOutput(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{path}:{line}:{col} {match}", "src/main.go:3:7 foo bar\nlogs/app.log.gz:10:7 bar\n"},
+		{`{file}\t{matches}\t{byte}\t{note}`, "src/main.go\t2\t40\tcheck this\nlogs/app.log.gz\t1\t\t\n"},
+		{"{{base .File}} {{.Size}}{{.Ext}}", "main.go 100.go\napp.log.gz 0.gz\n"},
+		{"{{trim .Content}}\n", "x := foo(bar)\ncafé, bar\n"},
+	}
+	for _, tt := range tests {
+		f, err := newTemplateFormatter(tt.template)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.template, err)
+		}
+		var buf bytes.Buffer
+		if err := f.Format(&buf, sampleReport()); err != nil {
+			t.Errorf("%q: %v", tt.template, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%q: %q; want %q", tt.template, got, tt.want)
+		}
+	}
+	if _, err := newTemplateFormatter("{path} {nope}"); err == nil {
+		t.Error("unknown placeholder accepted")
+	}
+}
+
+func TestSARIFOutput(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (sarifFormatter{}).Format(&buf, sampleReport()); err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID    string
+				RuleIndex int
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine, StartColumn, EndColumn int }
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("SARIF log version %q with %d runs", log.Version, len(log.Runs))
+	}
+	run := log.Runs[0]
+	var rules []string
+	for _, rule := range run.Tool.Driver.Rules {
+		rules = append(rules, rule.ID)
+	}
+	if len(rules) != 2 || rules[0] != "foo" || rules[1] != "bar" {
+		t.Errorf("rules %v; want [foo bar]", rules)
+	}
+
+	// One result per match, with the rule of the term that matched it
+	want := []struct {
+		rule       string
+		index      int
+		uri        string
+		line       int
+		start, end int
+		message    string
+	}{
+		{"foo", 0, "src/main.go", 3, 7, 10, "check this"},
+		{"bar", 1, "src/main.go", 3, 11, 14, "check this"},
+		{"bar", 1, "logs/app.log.gz", 10, 7, 10, "café, bar"},
+	}
+	if len(run.Results) != len(want) {
+		t.Fatalf("%d results; want %d", len(run.Results), len(want))
+	}
+	for i, w := range want {
+		r := run.Results[i]
+		loc := r.Locations[0].PhysicalLocation
+		if r.RuleID != w.rule || r.RuleIndex != w.index || r.Message.Text != w.message ||
+			loc.ArtifactLocation.URI != w.uri || loc.Region.StartLine != w.line ||
+			loc.Region.StartColumn != w.start || loc.Region.EndColumn != w.end {
+			t.Errorf("result %d = %+v; want %+v", i, r, w)
+		}
+	}
+}
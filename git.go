@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitStash is one entry of `git stash list`
+type gitStash struct {
+	Ref     string // e.g. stash@{0}
+	Subject string
+	Time    time.Time
+}
+
+// runGit runs git in repo and returns its standard output
+func runGit(ctx context.Context, repo string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", repo}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+	}
+	return out, nil
+}
+
+// listStashes returns the stashes of the repository at repo
+func listStashes(ctx context.Context, repo string) ([]gitStash, error) {
+	out, err := runGit(ctx, repo, "stash", "list", "--format=%gd%x00%ct%x00%s")
+	if err != nil {
+		return nil, err
+	}
+
+	var stashes []gitStash
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.SplitN(line, "\x00", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		stash := gitStash{Ref: fields[0], Subject: fields[2]}
+		if secs, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			stash.Time = time.Unix(secs, 0)
+		}
+		stashes = append(stashes, stash)
+	}
+	return stashes, nil
+}
+
+// stashTrees returns, for each tree recorded by a stash, the paths it
+// contains: modified tracked files live in the stash commit itself and
+// untracked files (git stash -u) in its third parent
+func stashTrees(ctx context.Context, repo, ref string) map[string][]string {
+	trees := make(map[string][]string)
+	if out, err := runGit(ctx, repo, "diff", "--name-only", "--diff-filter=d", ref+"^1", ref); err == nil {
+		trees[ref] = splitLines(out)
+	}
+	if out, err := runGit(ctx, repo, "ls-tree", "-r", "--name-only", ref+"^3"); err == nil {
+		trees[ref+"^3"] = splitLines(out)
+	}
+	return trees
+}
+
+func splitLines(out []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// searchStashes searches the stashed versions of files under targets. Each
+// result's FilePath is reported as <stash>:<path relative to repo>.
+func (m *model) searchStashes(ctx context.Context, targets []string) ([]SearchResult, []string) {
+	var results []SearchResult
+	var errs []string
+
+	// Group targets by repository so each stash is searched once
+	scopes := make(map[string][]string)
+	var repos []string
+	for _, target := range targets {
+		abs, err := filepath.Abs(target)
+		if err != nil {
+			continue
+		}
+		repo := findRepoRoot(abs)
+		if repo == "" {
+			continue
+		}
+		if _, seen := scopes[repo]; !seen {
+			repos = append(repos, repo)
+		}
+		rel, _ := filepath.Rel(repo, abs)
+		scopes[repo] = append(scopes[repo], filepath.ToSlash(rel))
+	}
+
+	for _, repo := range repos {
+		stashes, err := listStashes(ctx, repo)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		for _, stash := range stashes {
+			for tree, paths := range stashTrees(ctx, repo, stash.Ref) {
+				for _, path := range paths {
+					if !inScope(path, scopes[repo]) {
+						continue
+					}
+
+					content, err := runGit(ctx, repo, "show", tree+":"+path)
+					if err != nil {
+						errs = append(errs, err.Error())
+						continue
+					}
+					if binary, _ := sniffBinary(content[:min(len(content), sniffSize)]); binary {
+						continue
+					}
+
+					base := SearchResult{
+						FilePath:     fmt.Sprintf("%s:%s", stash.Ref, filepath.Join(repo, path)),
+						FileSize:     int64(len(content)),
+						LastModified: stash.Time,
+					}
+					fileResults, err := m.searchReader(ctx, bytes.NewReader(content), base)
+					if err != nil {
+						errs = append(errs, err.Error())
+					}
+					results = append(results, fileResults...)
+				}
+			}
+		}
+	}
+	return results, errs
+}
+
+// inScope reports whether a repo-relative path lies under one of the scopes
+func inScope(path string, scopes []string) bool {
+	for _, scope := range scopes {
+		if scope == "." || path == scope || strings.HasPrefix(path, scope+"/") {
+			return true
+		}
+	}
+	return false
+}
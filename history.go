@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// historyLimit is the number of past searches kept; the history file is
+// trimmed back to it once it grows past twice that
+const historyLimit = 500
+
+// historyEntry is a past or saved search: the pattern, what it searched
+// and the settings it ran with
+type historyEntry struct {
+	Name     string            `json:"name,omitempty"` // Set for saved searches
+	Pattern  string            `json:"pattern"`
+	Template string            `json:"template,omitempty"` // Pattern with {{name}} placeholders it was filled from
+	Params   map[string]string `json:"params,omitempty"`   // Values the placeholders were filled with
+	Targets  []string          `json:"targets"`
+	Config   SearchConfig      `json:"config"`
+	Time     time.Time         `json:"time"`
+}
+
+// historyPath returns the file past searches are appended to, one JSON
+// object per line
+func historyPath() (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "history"), nil
+}
+
+// savedSearchesPath returns the file holding named searches
+func savedSearchesPath() (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "saved.json"), nil
+}
+
+// loadHistory reads past searches, oldest first. Unreadable lines are
+// skipped so a damaged file doesn't lose the rest.
+func loadHistory() ([]historyEntry, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry historyEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.Pattern != "" {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return entries, err
+	}
+
+	if len(entries) > 2*historyLimit {
+		entries = entries[len(entries)-historyLimit:]
+		if err := writeHistory(path, entries); err != nil {
+			return entries, err
+		}
+	}
+	return entries, nil
+}
+
+// writeHistory replaces the history file with entries
+func writeHistory(path string, entries []historyEntry) error {
+	var b strings.Builder
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteString("\n")
+	}
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// appendHistory adds a search to the history file
+func appendHistory(entry historyEntry) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// loadSavedSearches reads the named searches
+func loadSavedSearches() ([]historyEntry, error) {
+	path, err := savedSearchesPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var saved []historyEntry
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("invalid saved searches %s: %v", path, err)
+	}
+	return saved, nil
+}
+
+// writeSavedSearches replaces the named searches
+func writeSavedSearches(saved []historyEntry) error {
+	path, err := savedSearchesPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// loadSearchHistory fills the model's history and saved searches
+func (m *model) loadSearchHistory() error {
+	history, err := loadHistory()
+	m.history = history
+	if err != nil {
+		return err
+	}
+	if m.savedSearches, err = loadSavedSearches(); err != nil {
+		return err
+	}
+	m.trends, err = loadTrends()
+	return err
+}
+
+// recordSearch remembers the search about to run; consecutive repeats of
+// the same pattern over the same targets are stored once
+func (m *model) recordSearch(targets []string) {
+	entry := historyEntry{
+		Pattern: m.searchInput,
+		Targets: targets,
+		Config:  m.searchConfig,
+		Time:    time.Now(),
+	}
+	entry.Config.Query = nil
+	if t := m.searchTemplate; t != nil && t.Pattern == entry.Pattern {
+		entry.Template, entry.Params = t.Template, t.Params
+	}
+	m.searchTemplate = nil
+	m.lastSearch = &entry
+	m.historyPos = 0
+
+	if n := len(m.history); n > 0 && m.history[n-1].Pattern == entry.Pattern &&
+		strings.Join(m.history[n-1].Targets, "\x00") == strings.Join(targets, "\x00") {
+		return
+	}
+	m.history = append(m.history, entry)
+	if len(m.history) > historyLimit {
+		m.history = m.history[len(m.history)-historyLimit:]
+	}
+	appendHistory(entry)
+}
+
+// recallHistory steps through past searches in search input mode: step 1
+// goes to an older search, -1 to a newer one. Past the newest, the
+// pattern and settings from before browsing come back.
+func (m *model) recallHistory(step int) {
+	pos := max(0, min(m.historyPos+step, len(m.history)))
+	if pos == m.historyPos {
+		return
+	}
+	if m.historyPos == 0 {
+		m.historyDraft = historyEntry{Pattern: m.searchInput, Config: m.searchConfig}
+	}
+	m.historyPos = pos
+
+	if pos == 0 {
+		m.searchInput = m.historyDraft.Pattern
+		m.searchConfig = m.historyDraft.Config
+		m.recalledTargets = nil
+		m.statusMsg = "Back to the current search"
+		return
+	}
+	entry := m.history[len(m.history)-pos]
+	m.useSearch(entry)
+	m.statusMsg = fmt.Sprintf("History %d/%d: %s, %s", pos, len(m.history), entry.Time.Format("2006-01-02 15:04"), m.targetsLabel(entry.Targets))
+}
+
+// useSearch makes a past or saved search the one Enter runs
+func (m *model) useSearch(entry historyEntry) {
+	m.searchInput = entry.Pattern
+	m.searchConfig = entry.Config
+	m.recalledTargets = entry.Targets
+}
+
+// rerunLastSearch repeats the most recent search with the same pattern,
+// targets and settings
+func (m *model) rerunLastSearch() tea.Cmd {
+	if m.lastSearch == nil {
+		m.statusMsg = "No search to repeat yet"
+		return nil
+	}
+	entry := *m.lastSearch
+	if entry.Template != "" {
+		m.searchTemplate = &entry
+	}
+	m.useSearch(entry)
+	m.skipNext = entry.Config.SkipFiles
+	return m.performSearch()
+}
+
+// rerunHint reminds of the search . repeats, for the footer
+func (m model) rerunHint() string {
+	if m.lastSearch == nil || m.searching {
+		return ""
+	}
+	pattern := m.lastSearch.Pattern
+	if len([]rune(pattern)) > 20 {
+		pattern = string([]rune(pattern)[:19]) + "…"
+	}
+	return fmt.Sprintf(".:rerun %q | ", pattern)
+}
+
+// runSavedSearch reruns the nth saved search (from 1)
+func (m *model) runSavedSearch(n int) tea.Cmd {
+	if n < 1 || n > len(m.savedSearches) {
+		m.statusMsg = fmt.Sprintf("No saved search %d", n)
+		return nil
+	}
+	if entry := m.savedSearches[n-1]; entry.Template != "" {
+		m.promptTemplate(entry)
+		return nil
+	}
+	m.useSearch(m.savedSearches[n-1])
+	m.savedRun = m.savedSearches[n-1].Name
+	return m.performSearch()
+}
+
+// promptSaveSearch names the last search and saves it, replacing a saved
+// search of the same name
+func (m *model) promptSaveSearch() {
+	if m.lastSearch == nil {
+		m.statusMsg = "No search to save"
+		return
+	}
+	entry := *m.lastSearch
+	name := entry.Pattern
+	if entry.Template != "" {
+		name = entry.Template
+	}
+	m.prompt = &inputPrompt{
+		label:  "Save search as: ",
+		input:  name,
+		cursor: len([]rune(name)),
+		onSubmit: func(m *model, name string) {
+			if name == "" {
+				m.statusMsg = "Cancelled"
+				return
+			}
+			entry.Name = name
+			saved := append([]historyEntry(nil), m.savedSearches...)
+			slot := len(saved)
+			for i, s := range saved {
+				if s.Name == name {
+					slot = i
+				}
+			}
+			if slot == len(saved) {
+				saved = append(saved, entry)
+			} else {
+				saved[slot] = entry
+			}
+			if err := writeSavedSearches(saved); err != nil {
+				m.statusMsg = fmt.Sprintf("Error: %v", err)
+				return
+			}
+			m.savedSearches = saved
+			if slot < 9 && entry.Template != "" {
+				m.statusMsg = fmt.Sprintf("Saved template %q; press %d in the file browser to fill it in and run it", name, slot+1)
+			} else if slot < 9 {
+				m.statusMsg = fmt.Sprintf("Saved %q; press %d in the file browser to rerun it", name, slot+1)
+			} else {
+				m.statusMsg = fmt.Sprintf("Saved %q", name)
+			}
+		},
+	}
+}
+
+// renderSavedSearches lists the saved searches that have a number key
+func (m model) renderSavedSearches() string {
+	if len(m.savedSearches) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(helpStyle.Render("Saved searches (press the number in the file browser):"))
+	b.WriteString("\n")
+	for i, s := range m.savedSearches {
+		if i == 9 {
+			break
+		}
+		pattern := s.Pattern
+		if s.Template != "" {
+			pattern = s.Template
+		}
+		line := fmt.Sprintf("  %d  %-16s %s", i+1, s.Name, pattern)
+		if runs := m.trends[s.Name]; len(runs) > 0 {
+			line += "  " + trendSummary(runs, 10)
+		}
+		b.WriteString(helpStyle.Render(line))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
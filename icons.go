@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// iconTheme is a named set of glyphs drawn in front of files and messages
+type iconTheme struct {
+	Name     string
+	Dir      string
+	File     string
+	Selected string
+	Result   string // Before each result's file header
+	Warning  string
+	Error    string
+	OK       string
+	Note     string            // Before result notes
+	ByExt    map[string]string // Per-extension file glyphs
+}
+
+// Built-in icon themes. Emoji are double width in most terminals but not
+// all, which misaligns rows; nerd-font and ascii glyphs are single width.
+var iconThemes = []iconTheme{
+	{
+		Name: "emoji",
+		Dir:  "📁", File: "📄", Selected: "✅", Result: "📁",
+		Warning: "⚠️ ", Error: "❌", OK: "✅", Note: "📝",
+	},
+	{
+		Name: "nerd-font",
+		Dir:  "\uf07b", File: "\uf15b", Selected: "\uf00c", Result: "\uf15c",
+		Warning: "\uf071", Error: "\uf00d", OK: "\uf00c", Note: "\uf249",
+		ByExt: map[string]string{
+			".go": "\ue627", ".py": "\ue606", ".js": "\ue74e", ".ts": "\ue628",
+			".rs": "\ue7a8", ".rb": "\ue739", ".java": "\ue738", ".c": "\ue61e",
+			".cpp": "\ue61d", ".h": "\ue61e", ".php": "\ue73d", ".md": "\ue609",
+			".json": "\ue60b", ".html": "\ue736", ".css": "\ue749", ".sh": "\ue795",
+			".yaml": "\ue6a8", ".yml": "\ue6a8", ".toml": "\ue6b2", ".lua": "\ue620",
+		},
+	},
+	{
+		Name: "ascii",
+		Dir:  "+", File: "-", Selected: "*", Result: ">",
+		Warning: "!", Error: "x", OK: "v", Note: "#",
+	},
+	{
+		Name: "none",
+	},
+}
+
+// icons is the active icon theme
+var icons = iconThemes[0]
+
+// iconOverrides maps an extension (".go") or "dir" to a user-chosen glyph,
+// taking precedence over the active theme
+var iconOverrides = map[string]string{}
+
+// findIconTheme returns the index of the named icon theme
+func findIconTheme(name string) (int, error) {
+	for i, t := range iconThemes {
+		if strings.EqualFold(t.Name, name) {
+			return i, nil
+		}
+	}
+	names := make([]string, len(iconThemes))
+	for i, t := range iconThemes {
+		names[i] = t.Name
+	}
+	return 0, fmt.Errorf("unknown icon theme %q (use %s)", name, strings.Join(names, ", "))
+}
+
+// setIconOverrides installs per-type glyphs from the config file
+func setIconOverrides(overrides map[string]string) {
+	for key, glyph := range overrides {
+		key = strings.ToLower(key)
+		if key != "dir" && !strings.HasPrefix(key, ".") {
+			key = "." + key
+		}
+		iconOverrides[key] = glyph
+	}
+}
+
+// fileIcon returns the glyph for a file browser entry
+func fileIcon(name string, isDir, selected bool) string {
+	if selected {
+		return icons.Selected
+	}
+	if isDir {
+		if glyph, ok := iconOverrides["dir"]; ok {
+			return glyph
+		}
+		return icons.Dir
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	if glyph, ok := iconOverrides[ext]; ok {
+		return glyph
+	}
+	if glyph, ok := icons.ByExt[ext]; ok {
+		return glyph
+	}
+	return icons.File
+}
+
+// withIcon prefixes text with glyph, or returns text alone if glyph is empty
+func withIcon(glyph, text string) string {
+	if glyph == "" {
+		return text
+	}
+	return glyph + " " + text
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// zxignoreName is the ignore file of zx alone, which teams commit to keep
+// generated trees out of everyone's searches whether or not git ignores them
+const zxignoreName = ".zxignore"
+
+// Ignore files consulted in every directory, in order of increasing priority
+var ignoreFileNames = []string{".gitignore", ".ignore", zxignoreName}
+
+// ignoreRule is a single compiled line from an ignore file
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool // Pattern started with '!' and re-includes matches
+	dirOnly bool // Pattern ended with '/' and only matches directories
+}
+
+// ignoreMatcher answers whether paths below a root are excluded by
+// .gitignore/.ignore/.zxignore files. Rules are loaded lazily per directory
+// and cached, and deeper files take precedence over shallower ones.
+type ignoreMatcher struct {
+	base  string                  // Topmost directory whose rules apply
+	names []string                // Ignore files read in each directory
+	rules map[string][]ignoreRule // Directory → rules declared in it
+}
+
+// newIgnoreMatcher creates a matcher for a walk rooted at root. When root is
+// inside a git work tree, ignore files between the repository root and root
+// are honored too, as is .git/info/exclude. Above that, only .zxignore
+// files apply. Without git, .gitignore and .git/info/exclude are left out,
+// so git-ignored files are searched while .ignore and .zxignore still apply.
+func newIgnoreMatcher(root string, git bool) *ignoreMatcher {
+	root, _ = filepath.Abs(root)
+	im := &ignoreMatcher{base: root, names: ignoreFileNames, rules: make(map[string][]ignoreRule)}
+	if !git {
+		im.names = ignoreFileNames[1:] // All but .gitignore
+	}
+
+	if repo := findRepoRoot(root); repo != "" {
+		im.base = repo
+		if git {
+			im.rules[repo] = append(im.rules[repo],
+				parseIgnoreFile(filepath.Join(repo, ".git", "info", "exclude"))...)
+		}
+		im.rules[repo] = append(im.rules[repo], im.loadDir(repo)...)
+	}
+
+	// The rules of every directory above base are cached, so the walk
+	// from a .zxignore found there down to base doesn't read the other
+	// ignore files of those directories
+	for dir := im.base; filepath.Dir(dir) != dir; {
+		dir = filepath.Dir(dir)
+		rules := parseIgnoreFile(filepath.Join(dir, zxignoreName))
+		im.rules[dir] = rules
+		if len(rules) > 0 {
+			im.base = dir
+		}
+	}
+	return im
+}
+
+// findRepoRoot returns the closest ancestor of dir containing .git, or ""
+func findRepoRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadDir parses the ignore files declared directly in dir
+func (im *ignoreMatcher) loadDir(dir string) []ignoreRule {
+	var rules []ignoreRule
+	for _, name := range im.names {
+		rules = append(rules, parseIgnoreFile(filepath.Join(dir, name))...)
+	}
+	return rules
+}
+
+// rulesFor returns the (cached) rules declared in dir
+func (im *ignoreMatcher) rulesFor(dir string) []ignoreRule {
+	if rules, ok := im.rules[dir]; ok {
+		return rules
+	}
+	rules := im.loadDir(dir)
+	im.rules[dir] = rules
+	return rules
+}
+
+// ignored reports whether path is excluded by the applicable ignore rules
+func (im *ignoreMatcher) ignored(path string, isDir bool) bool {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	if isDir && filepath.Base(path) == ".git" {
+		return true
+	}
+
+	rel, err := filepath.Rel(im.base, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+
+	// Collect ancestor directories from base down to the parent of path
+	dirs := []string{im.base}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := 0; i < len(parts)-1; i++ {
+		dirs = append(dirs, filepath.Join(dirs[len(dirs)-1], parts[i]))
+	}
+
+	ignored := false
+	for _, dir := range dirs {
+		relToDir, err := filepath.Rel(dir, path)
+		if err != nil {
+			continue
+		}
+		relToDir = filepath.ToSlash(relToDir)
+		for _, rule := range im.rulesFor(dir) {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.re.MatchString(relToDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// parseIgnoreFile reads gitignore-syntax rules from path; a missing file
+// yields no rules
+func parseIgnoreFile(path string) []ignoreRule {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreLine compiles one gitignore pattern line
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// Patterns with a slash anywhere but the end are relative to the
+	// ignore file's directory; others match a name at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	prefix := "^(?:.*/)?"
+	if anchored {
+		prefix = "^"
+	}
+	re, err := regexp.Compile(prefix + globToRegexp(line) + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globToRegexp translates gitignore glob syntax (*, ?, [...], **) into a
+// regular expression body
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
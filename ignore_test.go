@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseIgnoreLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		path  string
+		isDir bool
+		match bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "deep/dir/a.log", false, true},
+		{"*.log", "a.log.txt", false, false},
+		{"/build", "build", true, true},
+		{"/build", "sub/build", true, false},
+		{"doc/*.txt", "doc/a.txt", false, true},
+		{"doc/*.txt", "doc/sub/a.txt", false, false},
+		{"**/cache", "a/b/cache", true, true},
+		{"**/cache", "cache", true, true},
+		{"logs/**", "logs/a/b.txt", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**/b", "a/b", false, true},
+		{"tmp/", "tmp", true, true},
+		{"tmp/", "tmp", false, false},
+		{"file?.go", "file1.go", false, true},
+		{"file?.go", "file10.go", false, false},
+		{"[abc].txt", "b.txt", false, true},
+		{"[!abc].txt", "b.txt", false, false},
+		{"[!abc].txt", "d.txt", false, true},
+		{`\#hash`, "#hash", false, true},
+		{`\!bang`, "!bang", false, true},
+		{`trailing\ `, "trailing ", false, true},
+		{"spaces   ", "spaces", false, true},
+	}
+	for _, tt := range tests {
+		rule, ok := parseIgnoreLine(tt.line)
+		if !ok {
+			t.Errorf("parseIgnoreLine(%q) gave no rule", tt.line)
+			continue
+		}
+		match := rule.re.MatchString(tt.path) && (!rule.dirOnly || tt.isDir)
+		if match != tt.match {
+			t.Errorf("%q on %q (dir %v) = %v; want %v", tt.line, tt.path, tt.isDir, match, tt.match)
+		}
+	}
+
+	for _, line := range []string{"", "   ", "# comment", "/", "!"} {
+		if _, ok := parseIgnoreLine(line); ok {
+			t.Errorf("parseIgnoreLine(%q) gave a rule", line)
+		}
+	}
+	if rule, _ := parseIgnoreLine("!keep.log"); !rule.negate {
+		t.Errorf("parseIgnoreLine(%q) isn't negated", "!keep.log")
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":         "*.log\n!keep.log\nbuild/\n",
+		".ignore":            "*.tmp\n",
+		".zxignore":          "secret\n",
+		".git/info/exclude":  "*.local\n",
+		"sub/.gitignore":     "!*.log\n/only-here\n",
+		"sub/deep/.zxignore": "*.go\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path       string
+		isDir      bool
+		ignored    bool // With .gitignore
+		gitignored bool // Without it, as IncludeGitignored searches
+	}{
+		{"main.go", false, false, false},
+		{"a.log", false, true, false},
+		{"keep.log", false, false, false},
+		{"build", true, true, false},
+		{"build", false, false, false},
+		{"a.tmp", false, true, true},
+		{"secret", false, true, true},
+		{"x.local", false, true, false},
+		{".git", true, true, true},
+		// Deeper ignore files take precedence
+		{"sub/a.log", false, false, false},
+		{"sub/only-here", false, true, false},
+		{"only-here", false, false, false},
+		{"sub/other/only-here", false, false, false},
+		{"sub/deep/main.go", false, true, true},
+		{"sub/main.go", false, false, false},
+	}
+	for _, git := range []bool{true, false} {
+		im := newIgnoreMatcher(root, git)
+		for _, tt := range tests {
+			want := tt.ignored
+			if !git {
+				want = tt.gitignored
+			}
+			if got := im.ignored(filepath.Join(root, tt.path), tt.isDir); got != want {
+				t.Errorf("git=%v: ignored(%q, %v) = %v; want %v", git, tt.path, tt.isDir, got, want)
+			}
+		}
+	}
+}
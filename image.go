@@ -0,0 +1,450 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Manifest media types accepted from registries
+const (
+	mediaDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	mediaOCIIndex       = "application/vnd.oci.image.index.v1+json"
+)
+
+// dockerHub is where references without a registry host are pulled from
+const dockerHub = "registry-1.docker.io"
+
+// imageRef is a parsed image reference such as ghcr.io/org/app:tag
+type imageRef struct {
+	Registry   string
+	Repository string
+	Reference  string // Tag or digest
+}
+
+// parseImageRef splits ref into registry, repository and tag or digest,
+// applying Docker's defaults (docker.io, library/, :latest)
+func parseImageRef(ref string) (imageRef, error) {
+	r := imageRef{Registry: dockerHub, Reference: "latest"}
+	name := ref
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, r.Reference = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, r.Reference = name[:i], name[i+1:]
+	}
+
+	// The first component is a registry host if it looks like one
+	if i := strings.Index(name, "/"); i >= 0 {
+		host := name[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			r.Registry, name = host, name[i+1:]
+		}
+	}
+	if r.Registry == "docker.io" || r.Registry == "index.docker.io" {
+		r.Registry = dockerHub
+	}
+	if r.Registry == dockerHub && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	if name == "" || r.Reference == "" {
+		return r, fmt.Errorf("invalid image reference %q", ref)
+	}
+	r.Repository = name
+	return r, nil
+}
+
+// registryClient talks to a registry's v2 API, fetching a pull token the
+// first time the registry asks for one
+type registryClient struct {
+	client *http.Client
+	ref    imageRef
+	scheme string
+	token  string
+}
+
+func newRegistryClient(ref imageRef) *registryClient {
+	scheme := "https"
+	if strings.HasPrefix(ref.Registry, "localhost") || strings.HasPrefix(ref.Registry, "127.0.0.1") {
+		scheme = "http"
+	}
+	return &registryClient{client: http.DefaultClient, ref: ref, scheme: scheme}
+}
+
+// get requests a path under /v2/<repository>/, authenticating on a 401
+func (c *registryClient) get(ctx context.Context, kind, reference string, accept ...string) (*http.Response, error) {
+	endpoint := fmt.Sprintf("%s://%s/v2/%s/%s/%s", c.scheme, c.ref.Registry, c.ref.Repository, kind, reference)
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range accept {
+			req.Header.Add("Accept", a)
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if err := c.authenticate(ctx, challenge); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s %s: %s", c.ref.Repository, reference, resp.Status)
+		}
+		return resp, nil
+	}
+}
+
+// authenticate exchanges a Bearer challenge for a pull token, using the
+// credentials from ~/.docker/config.json when there are any
+func (c *registryClient) authenticate(ctx context.Context, challenge string) error {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return fmt.Errorf("%s requires unsupported authentication %q", c.ref.Registry, challenge)
+	}
+	params := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			params[key] = strings.Trim(value, `"`)
+		}
+	}
+	if params["realm"] == "" {
+		return fmt.Errorf("%s sent no token realm", c.ref.Registry)
+	}
+
+	query := url.Values{}
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", c.ref.Repository)
+	}
+	query.Set("scope", scope)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if auth := dockerCredentials(c.ref.Registry); auth != "" {
+		req.Header.Set("Authorization", "Basic "+auth)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to get a pull token for %s: %s", c.ref.Repository, resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("invalid token response: %v", err)
+	}
+	c.token = token.Token
+	if c.token == "" {
+		c.token = token.AccessToken
+	}
+	return nil
+}
+
+// dockerCredentials returns the base64 user:password stored by `docker
+// login` for a registry, if any. Credential helpers aren't consulted.
+func dockerCredentials(registry string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".docker", "config.json"))
+	if err != nil {
+		return ""
+	}
+	var config struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if json.Unmarshal(data, &config) != nil {
+		return ""
+	}
+	keys := []string{registry, "https://" + registry}
+	if registry == dockerHub {
+		keys = append(keys, "https://index.docker.io/v1/", "docker.io")
+	}
+	for _, key := range keys {
+		if auth := config.Auths[key].Auth; auth != "" {
+			if _, err := base64.StdEncoding.DecodeString(auth); err == nil {
+				return auth
+			}
+		}
+	}
+	return ""
+}
+
+// imageManifest lists an image's layers, bottom first
+type imageManifest struct {
+	MediaType string `json:"mediaType"`
+	Layers    []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+		Size      int64  `json:"size"`
+	} `json:"layers"`
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+			Variant      string `json:"variant"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// manifest fetches the image manifest, resolving multi-platform indexes to
+// the entry for platform (os/arch[/variant])
+func (c *registryClient) manifest(ctx context.Context, platform string) (imageManifest, error) {
+	reference := c.ref.Reference
+	for depth := 0; depth < 2; depth++ {
+		resp, err := c.get(ctx, "manifests", reference, mediaOCIIndex, mediaDockerList, mediaOCIManifest, mediaDockerManifest)
+		if err != nil {
+			return imageManifest{}, err
+		}
+		var manifest imageManifest
+		err = json.NewDecoder(resp.Body).Decode(&manifest)
+		resp.Body.Close()
+		if err != nil {
+			return imageManifest{}, fmt.Errorf("invalid manifest for %s: %v", reference, err)
+		}
+		if len(manifest.Manifests) == 0 {
+			return manifest, nil
+		}
+
+		reference = ""
+		var available []string
+		for _, entry := range manifest.Manifests {
+			p := entry.Platform.OS + "/" + entry.Platform.Architecture
+			if entry.Platform.Variant != "" && strings.Count(platform, "/") == 2 {
+				p += "/" + entry.Platform.Variant
+			}
+			if p == platform {
+				reference = entry.Digest
+				break
+			}
+			available = append(available, p)
+		}
+		if reference == "" {
+			return imageManifest{}, fmt.Errorf("no %s image in %s (available: %s)", platform, c.ref.Repository, strings.Join(available, ", "))
+		}
+	}
+	return imageManifest{}, fmt.Errorf("nested image index in %s", c.ref.Repository)
+}
+
+// defaultPlatform is the image platform matching this machine
+func defaultPlatform() string {
+	return "linux/" + runtime.GOARCH
+}
+
+// searchImage pulls each layer of an image and searches the files in it.
+// Results name the image and in-image path, and record the layer they came
+// from, noting files a later layer deleted or replaced.
+func (m *model) searchImage(ctx context.Context, ref, platform string) SearchResults {
+	start := time.Now()
+	results := SearchResults{Pattern: m.searchInput, Target: ref, Inverted: m.searchConfig.InvertMatch}
+	fail := func(err error) SearchResults {
+		results.Errors = append(results.Errors, err.Error())
+		results.SearchTime = time.Since(start)
+		return results
+	}
+
+	parsed, err := parseImageRef(ref)
+	if err != nil {
+		return fail(err)
+	}
+	client := newRegistryClient(parsed)
+	manifest, err := client.manifest(ctx, platform)
+	if err != nil {
+		return fail(err)
+	}
+
+	// Paths each layer adds or deletes, to tell which files survive
+	type layerChanges struct {
+		paths    map[string]bool
+		deleted  map[string]bool // Whiteouts: .wh.<name>
+		opaque   map[string]bool // Directories whose lower contents are hidden
+		firstRes int             // Index of the layer's first result
+	}
+	layers := make([]layerChanges, len(manifest.Layers))
+
+	for i, layer := range manifest.Layers {
+		if ctx.Err() != nil {
+			results.Progress.Cancelled = true
+			break
+		}
+		changes := layerChanges{paths: map[string]bool{}, deleted: map[string]bool{}, opaque: map[string]bool{}, firstRes: len(results.Results)}
+		label := fmt.Sprintf("%d/%d %s", i+1, len(manifest.Layers), shortDigest(layer.Digest))
+		err := m.searchLayer(ctx, client, layer.Digest, func(name string, hdr *tar.Header, r io.Reader) {
+			dir, file := path.Split(name)
+			switch {
+			case file == ".wh..wh..opq":
+				changes.opaque[strings.TrimSuffix(dir, "/")] = true
+				return
+			case strings.HasPrefix(file, ".wh."):
+				changes.deleted[dir+strings.TrimPrefix(file, ".wh.")] = true
+				return
+			}
+			changes.paths[name] = true
+			if hdr.Typeflag != tar.TypeReg {
+				return
+			}
+			results.TotalFiles++
+			if hdr.Size > m.searchConfig.MaxFileSize {
+				return
+			}
+
+			reader := bufio.NewReaderSize(r, BufferSize)
+			sample, _ := reader.Peek(sniffSize)
+			if binary, _ := sniffBinary(sample); binary {
+				return
+			}
+			base := SearchResult{
+				FilePath:     fmt.Sprintf("%s:/%s", ref, name),
+				FileSize:     hdr.Size,
+				LastModified: hdr.ModTime,
+				Layer:        label,
+			}
+			fileResults, err := m.searchReader(ctx, reader, base)
+			if err != nil {
+				results.Errors = append(results.Errors, fmt.Sprintf("%s: %v", base.FilePath, err))
+			}
+			results.Results = append(results.Results, fileResults...)
+		})
+		if err != nil {
+			results.Errors = append(results.Errors, fmt.Sprintf("layer %s: %v", label, err))
+		}
+		layers[i] = changes
+	}
+
+	// Flag results whose file doesn't exist in the final filesystem
+	for i := range layers {
+		end := len(results.Results)
+		if i+1 < len(layers) {
+			end = layers[i+1].firstRes
+		}
+		for j := layers[i].firstRes; j < end; j++ {
+			r := &results.Results[j]
+			name := strings.TrimPrefix(r.FilePath, ref+":/")
+			for _, later := range layers[i+1:] {
+				if later.paths[name] {
+					r.Layer += " (replaced in a later layer)"
+					break
+				}
+				// A whiteout deletes the path and, for a directory, all
+				// the files under it
+				if later.deleted[name] || underDirs(name, later.deleted) || underDirs(name, later.opaque) {
+					r.Layer += " (deleted in a later layer)"
+					break
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(results.Results, func(i, j int) bool {
+		return results.Results[i].FilePath < results.Results[j].FilePath
+	})
+	results.SearchTime = time.Since(start)
+	return results
+}
+
+// searchLayer streams a layer blob and calls visit for each tar entry, with
+// the entry's path relative to the image root
+func (m *model) searchLayer(ctx context.Context, client *registryClient, digest string, visit func(name string, hdr *tar.Header, r io.Reader)) error {
+	resp, err := client.get(ctx, "blobs", digest)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var blob io.Reader = bufio.NewReaderSize(resp.Body, BufferSize)
+	magic, _ := blob.(*bufio.Reader).Peek(magicSize)
+	if kind := detectCompression(magic); kind != "" {
+		blob, err = decompressingReader(blob, kind)
+		if err != nil {
+			return err
+		}
+	}
+
+	archive := tar.NewReader(blob)
+	for {
+		hdr, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unreadable layer (zstd layers aren't supported): %v", err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		visit(name, hdr, archive)
+	}
+}
+
+// underDirs reports whether name lies under one of dirs, such as those made
+// opaque or whited out
+func underDirs(name string, dirs map[string]bool) bool {
+	for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if dirs[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// shortDigest abbreviates sha256:<hex> digests to 12 hex digits
+func shortDigest(digest string) string {
+	algo, hex, ok := strings.Cut(digest, ":")
+	if !ok || len(hex) <= 12 {
+		return digest
+	}
+	return algo + ":" + hex[:12]
+}
+
+// runImageCommand implements `zx image [flags] ref pattern`
+func runImageCommand(args []string, config SearchConfig, platform string) (SearchResults, error) {
+	if len(args) != 2 {
+		return SearchResults{}, fmt.Errorf("usage: zx image [flags] <image> <pattern>")
+	}
+	query, err := compileQuery(args[1])
+	if err != nil {
+		return SearchResults{}, fmt.Errorf("invalid regex pattern: %v", err)
+	}
+	config.Query = query
+	m := &model{searchConfig: config, searchInput: args[1]}
+	return m.searchImage(context.Background(), args[0], platform), nil
+}
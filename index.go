@@ -0,0 +1,644 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// indexVersion is bumped whenever the on-disk index layout changes
+const indexVersion = 1
+
+// trigramIndex maps every three-byte sequence (ASCII-lowercased) found in
+// the text of a directory's files to the files containing it. Searches use
+// it to skip files that can't match before running the regex.
+type trigramIndex struct {
+	Version int
+	Root    string
+	Built   time.Time
+	Files   []indexedFile
+
+	// Posting lists, stored as raw arrays after the gob-encoded header so
+	// the index loads quickly: the files containing trigrams[i] are
+	// ids[offsets[i]:offsets[i+1]], sorted
+	trigrams []uint32
+	offsets  []uint32
+	ids      []uint32 // Indexes into Files
+
+	byPath map[string]uint32
+}
+
+// postings returns the sorted IDs of the files containing trigram t
+func (ix *trigramIndex) postings(t uint32) []uint32 {
+	i := sort.Search(len(ix.trigrams), func(i int) bool { return ix.trigrams[i] >= t })
+	if i == len(ix.trigrams) || ix.trigrams[i] != t {
+		return nil
+	}
+	return ix.ids[ix.offsets[i]:ix.offsets[i+1]]
+}
+
+// indexedFile is the state of a file when it was indexed; files that have
+// changed since are searched normally
+type indexedFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// indexFile returns where the index for root is stored
+func indexFile(root string) (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(root))
+	return filepath.Join(cache, "zx", "index", hex.EncodeToString(sum[:])+".idx"), nil
+}
+
+// buildIndex indexes the searchable files under root, using the model's
+// file selection rules (ignore files, size limit, binary detection)
+func (m *model) buildIndex(ctx context.Context, root string) (*trigramIndex, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	paths, _, _ := m.collectFilesFromDir(ctx, root)
+
+	type indexed struct {
+		id       int
+		file     indexedFile
+		trigrams []uint32
+		err      error
+	}
+
+	jobs := make(chan int)
+	out := make(chan indexed)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				file, trigrams, err := fileTrigrams(ctx, paths[id])
+				out <- indexed{id: id, file: file, trigrams: trigrams, err: err}
+			}
+		}()
+	}
+	go func() {
+		for id := range paths {
+			jobs <- id
+		}
+		close(jobs)
+		wg.Wait()
+		close(out)
+	}()
+
+	ix := &trigramIndex{
+		Version: indexVersion,
+		Root:    root,
+		Built:   time.Now(),
+		Files:   make([]indexedFile, len(paths)),
+	}
+	postings := make(map[uint32][]uint32)
+	for r := range out {
+		if r.err != nil {
+			// Unreadable files stay in Files with no trigrams; a zero size
+			// and time never match the file on disk, so it is searched
+			ix.Files[r.id] = indexedFile{Path: paths[r.id]}
+			continue
+		}
+		ix.Files[r.id] = r.file
+		for _, t := range r.trigrams {
+			postings[t] = append(postings[t], uint32(r.id))
+		}
+	}
+
+	for t := range postings {
+		ix.trigrams = append(ix.trigrams, t)
+	}
+	sort.Slice(ix.trigrams, func(i, j int) bool { return ix.trigrams[i] < ix.trigrams[j] })
+	for _, t := range ix.trigrams {
+		ids := postings[t]
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		ix.offsets = append(ix.offsets, uint32(len(ix.ids)))
+		ix.ids = append(ix.ids, ids...)
+	}
+	ix.offsets = append(ix.offsets, uint32(len(ix.ids)))
+	return ix, ctx.Err()
+}
+
+// fileTrigrams returns the distinct trigrams of a file's searchable text
+func fileTrigrams(ctx context.Context, path string) (indexedFile, []uint32, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return indexedFile{}, nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return indexedFile{}, nil, err
+	}
+
+	var base SearchResult
+	content, err := contentReader(ctx, file, &base)
+	if err != nil {
+		return indexedFile{}, nil, err
+	}
+	reader := bufio.NewReaderSize(content, BufferSize)
+	sample, _ := reader.Peek(sniffSize)
+	text := bufio.NewReaderSize(decodingReader(reader, detectEncoding(sample)), BufferSize)
+
+	seen := make(map[uint32]struct{})
+	var t uint32
+	n := 0
+	for {
+		c, err := text.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return indexedFile{}, nil, err
+		}
+		if c == '\n' {
+			// Lines are matched independently, so trigrams never span them
+			n = 0
+			continue
+		}
+		t = (t<<8 | uint32(lowerASCII(c))) & 0xFFFFFF
+		if n++; n >= 3 {
+			seen[t] = struct{}{}
+		}
+	}
+
+	trigrams := make([]uint32, 0, len(seen))
+	for t := range seen {
+		trigrams = append(trigrams, t)
+	}
+	return indexedFile{Path: path, Size: info.Size(), ModTime: info.ModTime()}, trigrams, nil
+}
+
+func lowerASCII(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// save writes the index to the cache directory
+func (ix *trigramIndex) save() (string, error) {
+	path, err := indexFile(ix.Root)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".idx-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriterSize(tmp, BufferSize)
+	err = gob.NewEncoder(w).Encode(ix)
+	for _, array := range [][]uint32{ix.trigrams, ix.offsets, ix.ids} {
+		if err == nil {
+			err = binary.Write(w, binary.LittleEndian, uint32(len(array)))
+		}
+		if err == nil {
+			err = binary.Write(w, binary.LittleEndian, array)
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp.Name(), path)
+}
+
+// loadIndex reads the index stored for root, if there is one
+func loadIndex(root string) (*trigramIndex, error) {
+	path, err := indexFile(root)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// A bufio.Reader is an io.ByteReader, so gob reads no further than the
+	// header and the arrays can follow from the same reader
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReaderSize(f, BufferSize)
+	var ix trigramIndex
+	if err := gob.NewDecoder(r).Decode(&ix); err != nil {
+		return nil, fmt.Errorf("corrupt index %s: %v", path, err)
+	}
+	if ix.Version != indexVersion {
+		return nil, fmt.Errorf("index %s is from another zx version; rebuild it with 'zx index'", path)
+	}
+	for _, array := range []*[]uint32{&ix.trigrams, &ix.offsets, &ix.ids} {
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, fmt.Errorf("corrupt index %s: %v", path, err)
+		}
+		// A damaged length mustn't allocate more than the file could hold
+		if int64(n)*4 > info.Size() {
+			return nil, fmt.Errorf("corrupt index %s: array of %d entries in a %d byte file", path, n, info.Size())
+		}
+		*array = make([]uint32, n)
+		if err := binary.Read(r, binary.LittleEndian, *array); err != nil {
+			return nil, fmt.Errorf("corrupt index %s: %v", path, err)
+		}
+	}
+	if len(ix.offsets) != len(ix.trigrams)+1 || int(ix.offsets[len(ix.trigrams)]) != len(ix.ids) {
+		return nil, fmt.Errorf("corrupt index %s: inconsistent posting lists", path)
+	}
+
+	ix.byPath = make(map[string]uint32, len(ix.Files))
+	for id, file := range ix.Files {
+		ix.byPath[file.Path] = uint32(id)
+	}
+	return &ix, nil
+}
+
+// findIndex returns the index covering target: the one built for target
+// itself or its nearest indexed ancestor
+func findIndex(target string) *trigramIndex {
+	dir, err := filepath.Abs(target)
+	if err != nil {
+		return nil
+	}
+	for {
+		if ix, err := loadIndex(dir); err == nil {
+			return ix
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// shortlistFiles drops files that an up-to-date index proves can't match
+// the current query. Files missing from the index or changed since it was
+// built are always kept.
+func (m *model) shortlistFiles(targets, files []string) ([]string, int) {
+	shortlist := m.indexShortlist(targets)
+	if len(shortlist) == 0 {
+		return files, 0
+	}
+	kept := files[:0:0]
+	skipped := 0
+	for _, path := range files {
+		if shortlist.rulesOut(path) {
+			skipped++
+		} else {
+			kept = append(kept, path)
+		}
+	}
+	return kept, skipped
+}
+
+// indexShortlist holds the indexes covering a search's targets, with the
+// files of each that can match its query
+type indexShortlist []indexCandidates
+
+// indexCandidates is one covering index and the files it yields
+type indexCandidates struct {
+	ix         *trigramIndex
+	candidates map[uint32]bool
+}
+
+// indexShortlist loads each index covering targets once, or returns nil
+// when no index can rule out a file for the current query
+func (m *model) indexShortlist(targets []string) indexShortlist {
+	if m.searchConfig.NoIndex || m.searchConfig.InvertMatch || m.searchConfig.Query == nil {
+		return nil
+	}
+	plan := m.searchConfig.Query.trigramPlan()
+	if plan.all() {
+		return nil
+	}
+
+	var indexes indexShortlist
+	loaded := make(map[string]bool)
+	for _, target := range targets {
+		ix := findIndex(target)
+		if ix == nil || loaded[ix.Root] {
+			continue
+		}
+		loaded[ix.Root] = true
+		ids, all := plan.eval(ix)
+		if all {
+			continue
+		}
+		candidates := make(map[uint32]bool, len(ids))
+		for _, id := range ids {
+			candidates[id] = true
+		}
+		indexes = append(indexes, indexCandidates{ix: ix, candidates: candidates})
+	}
+	return indexes
+}
+
+// rulesOut reports whether an index proves path can't match; it is safe
+// to call from several goroutines
+func (s indexShortlist) rulesOut(path string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	abs, _ := filepath.Abs(path)
+	for _, c := range s {
+		id, ok := c.ix.byPath[abs]
+		if !ok || c.candidates[id] {
+			continue
+		}
+		entry := c.ix.Files[id]
+		info, err := os.Stat(path)
+		return err == nil && info.Size() == entry.Size && info.ModTime().Equal(entry.ModTime)
+	}
+	return false
+}
+
+// trigramPlan is a boolean combination of trigrams that any file matching a
+// query must contain. A plan with no trigrams and no children matches all
+// files.
+type trigramPlan struct {
+	and      bool // Children combined with AND (true) or OR (false)
+	trigrams []uint32
+	children []trigramPlan
+}
+
+func (p trigramPlan) all() bool { return len(p.trigrams) == 0 && len(p.children) == 0 }
+
+// allFiles is the plan that can't rule any file out
+var allFiles = trigramPlan{and: true}
+
+func andPlan(plans []trigramPlan) trigramPlan {
+	out := trigramPlan{and: true}
+	for _, p := range plans {
+		if !p.all() {
+			out.children = append(out.children, p)
+		}
+	}
+	if len(out.children) == 1 {
+		return out.children[0]
+	}
+	return out
+}
+
+func orPlan(plans []trigramPlan) trigramPlan {
+	out := trigramPlan{}
+	for _, p := range plans {
+		if p.all() {
+			return allFiles
+		}
+		out.children = append(out.children, p)
+	}
+	if len(out.children) == 1 {
+		return out.children[0]
+	}
+	return out
+}
+
+// eval returns the sorted IDs of files that satisfy the plan, or all=true
+func (p trigramPlan) eval(ix *trigramIndex) (ids []uint32, all bool) {
+	if p.all() {
+		return nil, true
+	}
+
+	var sets [][]uint32
+	for _, t := range p.trigrams {
+		sets = append(sets, ix.postings(t))
+	}
+	for _, child := range p.children {
+		ids, all := child.eval(ix)
+		if all {
+			if !p.and {
+				return nil, true
+			}
+			continue
+		}
+		sets = append(sets, ids)
+	}
+
+	if len(sets) == 0 {
+		return nil, true
+	}
+	result := sets[0]
+	for _, set := range sets[1:] {
+		if p.and {
+			result = intersectIDs(result, set)
+		} else {
+			result = unionIDs(result, set)
+		}
+	}
+	return result, false
+}
+
+func intersectIDs(a, b []uint32) []uint32 {
+	var out []uint32
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+func unionIDs(a, b []uint32) []uint32 {
+	out := make([]uint32, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			out = append(out, a[i])
+			i++
+		case a[i] > b[j]:
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	out = append(out, a[i:]...)
+	return append(out, b[j:]...)
+}
+
+// trigramPlan derives the trigrams a matching file must contain from the
+// expression: negated terms can't narrow the search, && and || combine
+func (q *Query) trigramPlan() trigramPlan {
+	var walk func(n queryNode) trigramPlan
+	walk = func(n queryNode) trigramPlan {
+		switch n := n.(type) {
+		case termNode:
+			return regexpPlan(q.Patterns[n])
+		case andNode:
+			plans := make([]trigramPlan, len(n.children))
+			for i, c := range n.children {
+				plans[i] = walk(c)
+			}
+			return andPlan(plans)
+		case orNode:
+			plans := make([]trigramPlan, len(n.children))
+			for i, c := range n.children {
+				plans[i] = walk(c)
+			}
+			return orPlan(plans)
+		}
+		return allFiles
+	}
+	return walk(q.root)
+}
+
+// regexpPlan returns the trigrams required by the literal parts of a regex
+func regexpPlan(re *regexp.Regexp) trigramPlan {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return allFiles
+	}
+	return syntaxPlan(parsed.Simplify())
+}
+
+func syntaxPlan(re *syntax.Regexp) trigramPlan {
+	switch re.Op {
+	case syntax.OpLiteral, syntax.OpConcat:
+		// Adjacent literals form runs whose trigrams must all be present
+		subs := re.Sub
+		if re.Op == syntax.OpLiteral {
+			subs = []*syntax.Regexp{re}
+		}
+		var plans []trigramPlan
+		var run []rune
+		flush := func() {
+			plans = append(plans, literalPlan(string(run)))
+			run = nil
+		}
+		for _, sub := range subs {
+			if sub.Op != syntax.OpLiteral {
+				flush()
+				plans = append(plans, syntaxPlan(sub))
+				continue
+			}
+			fold := sub.Flags&syntax.FoldCase != 0
+			for _, r := range sub.Rune {
+				// The index only folds ASCII case, so a rune that also
+				// matches non-ASCII ones (é, or k and s, which match the
+				// Kelvin sign and long s) can't be part of a run
+				if fold && foldsBeyondASCII(r) {
+					flush()
+					continue
+				}
+				run = append(run, r)
+			}
+		}
+		flush()
+		return andPlan(plans)
+
+	case syntax.OpAlternate:
+		plans := make([]trigramPlan, len(re.Sub))
+		for i, sub := range re.Sub {
+			plans[i] = syntaxPlan(sub)
+		}
+		return orPlan(plans)
+
+	case syntax.OpCapture, syntax.OpPlus:
+		return syntaxPlan(re.Sub[0])
+
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return syntaxPlan(re.Sub[0])
+		}
+	}
+	return allFiles
+}
+
+// foldsBeyondASCII reports whether r matches a non-ASCII rune other than
+// itself under case folding
+func foldsBeyondASCII(r rune) bool {
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f > unicode.MaxASCII {
+			return true
+		}
+	}
+	return r > unicode.MaxASCII && unicode.SimpleFold(r) != r
+}
+
+// literalPlan requires every trigram of s (ASCII-lowercased, like the index)
+func literalPlan(s string) trigramPlan {
+	b := []byte(strings.ToValidUTF8(s, ""))
+	if len(b) < 3 {
+		return allFiles
+	}
+	plan := trigramPlan{and: true}
+	seen := make(map[uint32]bool)
+	for i := 0; i+3 <= len(b); i++ {
+		t := uint32(lowerASCII(b[i]))<<16 | uint32(lowerASCII(b[i+1]))<<8 | uint32(lowerASCII(b[i+2]))
+		if !seen[t] {
+			seen[t] = true
+			plan.trigrams = append(plan.trigrams, t)
+		}
+	}
+	return plan
+}
+
+// runIndexCommand implements `zx index [flags] [dir...]`
+func runIndexCommand(args []string, config SearchConfig) error {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	m := &model{searchConfig: config}
+	for _, dir := range args {
+		start := time.Now()
+		ix, err := m.buildIndex(context.Background(), dir)
+		if err != nil {
+			return err
+		}
+		path, err := ix.save()
+		if err != nil {
+			return fmt.Errorf("unable to save index: %v", err)
+		}
+		fmt.Printf("Indexed %d files (%d trigrams) under %s in %v\n  -> %s\n",
+			len(ix.Files), len(ix.trigrams), ix.Root, time.Since(start).Round(time.Millisecond), path)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// planString renders a plan as its trigrams and children, and(...) or
+// or(...), with "*" for the plan that rules nothing out
+func planString(p trigramPlan) string {
+	if p.all() {
+		return "*"
+	}
+	var parts []string
+	for _, t := range p.trigrams {
+		parts = append(parts, string([]byte{byte(t >> 16), byte(t >> 8), byte(t)}))
+	}
+	for _, child := range p.children {
+		parts = append(parts, planString(child))
+	}
+	op := "or"
+	if p.and {
+		op = "and"
+	}
+	return op + "(" + strings.Join(parts, " ") + ")"
+}
+
+func TestLiteralPlan(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"ab", "*"},
+		{"abc", "and(abc)"},
+		{"Hello", "and(hel ell llo)"},
+		{"aaaa", "and(aaa)"},
+	}
+	for _, tt := range tests {
+		if got := planString(literalPlan(tt.s)); got != tt.want {
+			t.Errorf("literalPlan(%q) = %s; want %s", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestRegexpPlan(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"hello", "and(hel ell llo)"},
+		{"hello.*world", "and(and(hel ell llo) and(wor orl rld))"},
+		{"foo|barx", "or(and(foo) and(bar arx))"},
+		{"foo|ab", "*"},
+		{`\w+`, "*"},
+		{"(abcd)+", "and(abc bcd)"},
+		{"(abcd)*", "*"},
+		// The index folds ASCII case itself
+		{"(?i)hello", "and(hel ell llo)"},
+		// k and s also match the Kelvin sign and the long s
+		{"(?i)kelvin", "and(elv lvi vin)"},
+		{"(?i)latest", "and(lat ate)"},
+		{"tests", "and(tes est sts)"},
+		{"(?i)café", "and(caf)"},
+	}
+	for _, tt := range tests {
+		if got := planString(regexpPlan(regexp.MustCompile(tt.pattern))); got != tt.want {
+			t.Errorf("regexpPlan(%q) = %s; want %s", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestQueryTrigramPlan(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"hello && world", "and(and(hel ell llo) and(wor orl rld))"},
+		{"hello || world", "or(and(hel ell llo) and(wor orl rld))"},
+		// Negated terms can't rule files out
+		{"hello && ! world", "and(hel ell llo)"},
+		{"! world", "*"},
+	}
+	for _, tt := range tests {
+		q, err := compileQuery(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := planString(q.trigramPlan()); got != tt.want {
+			t.Errorf("trigramPlan(%q) = %s; want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestLoadIndex(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	ix := &trigramIndex{
+		Version:  indexVersion,
+		Root:     root,
+		Files:    []indexedFile{{Path: "a"}, {Path: "b"}},
+		trigrams: []uint32{1, 2},
+		offsets:  []uint32{0, 1, 3},
+		ids:      []uint32{1, 0, 1},
+	}
+	path, err := ix.save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadIndex(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.postings(2); !reflect.DeepEqual(got, []uint32{0, 1}) {
+		t.Errorf("postings(2) = %v; want [0 1]", got)
+	}
+
+	// A damaged length of the last array is refused before it's allocated
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint32(data[len(data)-4*len(ix.ids)-4:], 1<<30)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadIndex(root); err == nil || !strings.Contains(err.Error(), "entries in a") {
+		t.Errorf("loadIndex of a damaged index = %v; want the array length refused", err)
+	}
+}
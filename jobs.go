@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// runningAction is what starting a search does to one still running
+type runningAction int
+
+const (
+	runningCancel     runningAction = iota // Stop it; its partial results can be merged later
+	runningBackground                      // Keep it running out of view
+	runningQueue                           // Start the new search once it finishes
+)
+
+// searchJob is a search moved out of view, possibly still running. It's
+// shared by the model's copies, like the render cache.
+type searchJob struct {
+	id      int // searchID of its messages
+	results SearchResults
+	merged  *partialMerge
+	stream  <-chan searchBatchMsg
+	cancel  context.CancelFunc
+	done    bool
+}
+
+// queuedSearch waits for the running search to finish
+type queuedSearch struct {
+	entry historyEntry
+	quick bool
+}
+
+// partialMerge records the results of a stopped search merged into the
+// view, so they can be labeled and kept when the view's search completes
+type partialMerge struct {
+	pattern  string
+	results  []SearchResult
+	progress SearchProgress
+}
+
+// promptRunningSearch asks what to do with the running search before
+// typing a new one
+func (m *model) promptRunningSearch() {
+	m.prompt = &inputPrompt{
+		label:  "A search is running: [c]ancel it, keep it in the [b]ackground, or [q]ueue the new one after it? ",
+		input:  "c",
+		cursor: 1,
+		onSubmit: func(m *model, value string) {
+			switch strings.ToLower(value) {
+			case "c", "cancel":
+				m.cancelSearch()
+				m.runningAction = runningCancel
+				m.statusMsg = fmt.Sprintf("Search stopped with %d results; press P in the next results to merge them", len(m.searchResults.Results))
+			case "b", "background":
+				m.runningAction = runningBackground
+				m.statusMsg = "The running search will continue in the background; J shows it"
+			case "q", "queue":
+				m.runningAction = runningQueue
+				m.statusMsg = "The new search will start when the running one finishes"
+			default:
+				m.statusMsg = fmt.Sprintf("Unknown choice %q (use c, b or q)", value)
+				return
+			}
+			m.mode = SearchInputMode
+			m.searchInput = ""
+		},
+	}
+}
+
+// queueSearch remembers the typed search to run when the current one
+// finishes, and returns to its results
+func (m *model) queueSearch() {
+	targets, _, _, _ := m.searchTargets()
+	m.queued = &queuedSearch{
+		entry: historyEntry{Pattern: m.searchInput, Targets: targets, Config: m.searchConfig},
+		quick: m.quickSearch,
+	}
+	m.quickSearch = false
+	m.mode = SearchResultsMode
+	m.statusMsg = fmt.Sprintf("Queued %q; it starts when the running search finishes", m.searchInput)
+}
+
+// startQueuedSearch runs the queued search, if any, keeping the results
+// just completed in the background
+func (m *model) startQueuedSearch() tea.Cmd {
+	q := m.queued
+	if q == nil {
+		return nil
+	}
+	m.queued = nil
+	m.setBackground(&searchJob{id: m.searchID, results: m.searchResults, merged: m.merged, done: true})
+	m.useSearch(q.entry)
+	m.quickSearch = q.quick
+	return m.performSearch()
+}
+
+// detachSearch moves the running search out of view; it keeps streaming
+// into its job
+func (m *model) detachSearch() {
+	m.setBackground(&searchJob{
+		id:      m.searchID,
+		results: m.searchResults,
+		merged:  m.merged,
+		stream:  m.resultStream,
+		cancel:  m.searchCancel,
+	})
+	m.searching = false
+	m.searchCancel = nil
+}
+
+// setBackground replaces the background job, stopping the old one if it's
+// still running
+func (m *model) setBackground(job *searchJob) {
+	if old := m.background; old != nil && !old.done && old.cancel != nil {
+		old.cancel()
+	}
+	m.background = job
+}
+
+// handleBackgroundBatch adds streamed results to the background job
+func (m *model) handleBackgroundBatch(msg searchBatchMsg) tea.Cmd {
+	job := m.background
+	job.results.Results = append(job.results.Results, msg.results...)
+	job.results.Progress = msg.progress
+	job.results.TotalFiles = int(msg.progress.TotalFiles)
+	return waitForBatch(job.stream)
+}
+
+// handleBackgroundComplete stores the final results of the background job
+func (m *model) handleBackgroundComplete(msg searchCompleteMsg) {
+	job := m.background
+	job.results = msg.results
+	if job.merged != nil {
+		job.results.Results, _ = mergeResults(job.results.Results, job.merged.results)
+	}
+	job.done = true
+	job.cancel = nil
+	m.statusMsg = fmt.Sprintf("Background search %q finished: %d matches in %d files; press J to show it",
+		job.results.Pattern, len(job.results.Results), job.results.TotalFiles)
+}
+
+// swapBackground shows the finished background job's results, putting the
+// current ones in the background
+func (m *model) swapBackground() {
+	job := m.background
+	switch {
+	case job == nil:
+		m.statusMsg = "No background search"
+		return
+	case !job.done:
+		m.statusMsg = fmt.Sprintf("The background search %q is still running (%d results so far)", job.results.Pattern, len(job.results.Results))
+		return
+	case m.searching:
+		m.statusMsg = "Stop the running search or wait for it before switching"
+		return
+	}
+	job.results, m.searchResults = m.searchResults, job.results
+	job.merged, m.merged = m.merged, job.merged
+	m.searchConfig.Query, _ = compileQuery(m.searchResults.Pattern)
+	m.resultIndex = 0
+	m.viewport.offset = 0
+	m.showRefs = false
+	m.applyResultFilters()
+	m.statusMsg = fmt.Sprintf("Showing %q (%d results); J switches back to %q",
+		m.searchResults.Pattern, len(m.searchResults.Results), job.results.Pattern)
+}
+
+// keepPartial remembers the view's results before a new search replaces
+// them if their search was stopped, so they can be merged into the next
+func (m *model) keepPartial() {
+	if m.searchResults.Progress.Cancelled && len(m.searchResults.Results) > 0 {
+		partial := m.searchResults
+		m.partial = &partial
+	}
+	m.merged = nil
+}
+
+// mergePartial adds the stopped search's results to the view, skipping
+// lines it already shows
+func (m *model) mergePartial() {
+	p := m.partial
+	if p == nil {
+		m.statusMsg = "No stopped search to merge"
+		return
+	}
+	m.partial = nil
+
+	var added []SearchResult
+	m.searchResults.Results, added = mergeResults(m.searchResults.Results, p.Results)
+	m.merged = &partialMerge{pattern: p.Pattern, results: added, progress: p.Progress}
+	m.applyResultFilters()
+	m.statusMsg = fmt.Sprintf("Merged %d partial results from %q (%d already shown)",
+		len(added), p.Pattern, len(p.Results)-len(added))
+}
+
+// mergeResults appends the results of from whose lines aren't in into
+func mergeResults(into, from []SearchResult) (merged, added []SearchResult) {
+	seen := make(map[lineKey]bool, len(into))
+	for _, r := range into {
+		seen[lineKey{r.FilePath, r.LineNumber}] = true
+	}
+	for _, r := range from {
+		key := lineKey{r.FilePath, r.LineNumber}
+		if !seen[key] {
+			seen[key] = true
+			added = append(added, r)
+		}
+	}
+	return append(into, added...), added
+}
+
+// renderJobBanners labels merged partial results and reports the
+// background and queued searches
+func (m model) renderJobBanners() string {
+	var b strings.Builder
+	if p := m.searchResults.Progress; p.Cancelled && !m.searching && !m.searchResults.Quick {
+		banner := "PARTIAL: cancelled while collecting files"
+		switch {
+		case p.Collecting && p.TotalFiles > 0:
+			banner = fmt.Sprintf("PARTIAL: cancelled while collecting files, after %d of the %d found so far", p.ProcessedFiles, p.TotalFiles)
+		case p.TotalFiles > 0:
+			banner = fmt.Sprintf("PARTIAL: cancelled after %d of %d files", p.ProcessedFiles, p.TotalFiles)
+		}
+		b.WriteString(warningStyle.Render(banner + "; more matches may exist (. reruns the search)"))
+		b.WriteString("\n")
+	}
+	if p := m.merged; p != nil {
+		banner := fmt.Sprintf("PARTIAL: %d results merged from stopped search %q", len(p.results), p.pattern)
+		if p.progress.TotalFiles > 0 {
+			banner += fmt.Sprintf(" (it searched %d of %d files)", p.progress.ProcessedFiles, p.progress.TotalFiles)
+		}
+		b.WriteString(warningStyle.Render(banner))
+		b.WriteString("\n")
+	}
+	if job := m.background; job != nil {
+		state := fmt.Sprintf("running, %d results so far", len(job.results.Results))
+		if job.done {
+			state = fmt.Sprintf("finished with %d results, J to show", len(job.results.Results))
+		}
+		b.WriteString(statusStyle.Render(fmt.Sprintf("Background: %q %s", job.results.Pattern, state)))
+		b.WriteString("\n")
+	}
+	if q := m.queued; q != nil {
+		b.WriteString(statusStyle.Render(fmt.Sprintf("Queued: %q starts when this search finishes", q.entry.Pattern)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
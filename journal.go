@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// journalSource marks results read from the systemd journal
+const journalSource = "journald"
+
+// journalOptions selects the journal entries to search
+type journalOptions struct {
+	Units    []string // Only these units (-u, repeatable)
+	Priority string   // Maximum priority, e.g. err or 3, or a range like warning..err
+	Since    string   // journalctl time expressions, e.g. "1 hour ago" or 2024-05-01
+	Until    string
+	Boot     string // Only this boot: "" for all, "0" for the current one, -1, ...
+	User     bool   // The user's own journal instead of the system one
+}
+
+// journalEntry is the subset of `journalctl -o json` fields zx uses
+type journalEntry struct {
+	Message    json.RawMessage `json:"MESSAGE"`
+	Unit       string          `json:"_SYSTEMD_UNIT"`
+	UserUnit   string          `json:"_SYSTEMD_USER_UNIT"`
+	Identifier string          `json:"SYSLOG_IDENTIFIER"`
+	Priority   string          `json:"PRIORITY"`
+	Realtime   string          `json:"__REALTIME_TIMESTAMP"`
+}
+
+// journalPriorities names syslog priorities 0-7, as journalctl does
+var journalPriorities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// text returns the entry's message; journalctl encodes non-UTF-8 messages
+// as arrays of byte values
+func (e journalEntry) text() string {
+	var s string
+	if json.Unmarshal(e.Message, &s) == nil {
+		return s
+	}
+	var raw []byte
+	var values []int
+	if json.Unmarshal(e.Message, &values) == nil {
+		for _, v := range values {
+			raw = append(raw, byte(v))
+		}
+	}
+	return string(raw)
+}
+
+// unit returns the unit (or, failing that, the syslog identifier) that
+// logged the entry
+func (e journalEntry) unit() string {
+	switch {
+	case e.Unit != "":
+		return e.Unit
+	case e.UserUnit != "":
+		return e.UserUnit
+	case e.Identifier != "":
+		return e.Identifier
+	}
+	return "journal"
+}
+
+// args returns the journalctl arguments for the options
+func (o journalOptions) args() []string {
+	args := []string{"--output", "json", "--no-pager"}
+	if o.User {
+		args = append(args, "--user")
+	}
+	for _, unit := range o.Units {
+		if o.User {
+			args = append(args, "--user-unit", unit)
+		} else {
+			args = append(args, "--unit", unit)
+		}
+	}
+	if o.Priority != "" {
+		args = append(args, "--priority", o.Priority)
+	}
+	if o.Since != "" {
+		args = append(args, "--since", o.Since)
+	}
+	if o.Until != "" {
+		args = append(args, "--until", o.Until)
+	}
+	if o.Boot != "" {
+		args = append(args, "--boot", o.Boot)
+	}
+	return args
+}
+
+// searchJournal runs the query over the messages of the selected journal
+// entries. Each result is one entry, named after its unit and stamped with
+// when it was logged; LineNumber is the entry's position in the output.
+func (m *model) searchJournal(ctx context.Context, opts journalOptions) SearchResults {
+	start := time.Now()
+	results := SearchResults{Pattern: m.searchInput, Target: "journal", Inverted: m.searchConfig.InvertMatch}
+	fail := func(err error) SearchResults {
+		results.Errors = append(results.Errors, err.Error())
+		results.SearchTime = time.Since(start)
+		return results
+	}
+
+	cmd := exec.CommandContext(ctx, "journalctl", opts.args()...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fail(err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fail(fmt.Errorf("unable to run journalctl: %v", err))
+	}
+
+	// Entries come from many units, so match each with its own base
+	lm := m.newLineMatcher(SearchResult{Source: journalSource})
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, BufferSize), 16*BufferSize)
+	var decodeErr error
+	for n := 1; scanner.Scan(); n++ {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			if decodeErr == nil {
+				decodeErr = fmt.Errorf("entry %d: %v", n, err)
+			}
+			continue
+		}
+		lm.base.FilePath = entry.unit()
+		lm.base.Unit = entry.unit()
+		lm.base.Priority = ""
+		if p, err := strconv.Atoi(entry.Priority); err == nil && p >= 0 && p < len(journalPriorities) {
+			lm.base.Priority = journalPriorities[p]
+		}
+		lm.base.LastModified = time.Time{}
+		if usec, err := strconv.ParseInt(entry.Realtime, 10, 64); err == nil {
+			lm.base.LastModified = time.UnixMicro(usec)
+		}
+
+		// Multi-line messages are matched line by line, like a file
+		for _, line := range strings.Split(entry.text(), "\n") {
+			lm.match(n, 0, []byte(line))
+		}
+		results.TotalFiles++
+	}
+	results.Results = lm.finish()
+	if decodeErr != nil {
+		results.Errors = append(results.Errors, fmt.Sprintf("journalctl output: %v", decodeErr))
+	}
+
+	// An entry too long to scan ends the read; stop journalctl rather than
+	// wait on it blocked writing the rest, or on output its children still
+	// hold open
+	if err := scanner.Err(); err != nil {
+		cmd.WaitDelay = time.Second
+		cmd.Process.Kill()
+		cmd.Wait()
+		results.Errors = append(results.Errors, fmt.Sprintf("journalctl output: %v", err))
+	} else if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		results.Errors = append(results.Errors, "journalctl: "+msg)
+	}
+	results.SearchTime = time.Since(start)
+	return results
+}
+
+// runJournalCommand implements `zx journal [flags] pattern`
+func runJournalCommand(args []string, config SearchConfig, opts journalOptions) (SearchResults, error) {
+	if len(args) != 1 {
+		return SearchResults{}, fmt.Errorf("usage: zx journal [flags] <pattern>")
+	}
+	query, err := compileQuery(args[0])
+	if err != nil {
+		return SearchResults{}, fmt.Errorf("invalid regex pattern: %v", err)
+	}
+	config.Query = query
+	m := &model{searchConfig: config, searchInput: args[0]}
+	return m.searchJournal(context.Background(), opts), nil
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// jumpMaxPaths caps how many paths the jumper indexes under one root
+const jumpMaxPaths = 200000
+
+// jumpRefresh is how often the matches are ranked again while indexing
+const jumpRefresh = 150 * time.Millisecond
+
+// pathJumper is the Ctrl+P overlay of the file browser: it indexes the
+// paths under the directory it was opened in and ranks them against the
+// typed query. The index is filled in the background.
+type pathJumper struct {
+	root    string
+	query   string
+	matches []jumpMatch
+	index   int // Highlighted entry of matches
+
+	mu      sync.Mutex
+	paths   []string // Relative to root; directories end in a separator
+	done    bool
+	limited bool // Stopped at jumpMaxPaths
+	stop    bool // The overlay was closed
+}
+
+// jumpMatch is an indexed path matching the query
+type jumpMatch struct {
+	path  string
+	score int
+	hits  []int // Byte offsets of the matched characters
+}
+
+// jumpTickMsg asks for the matches to be ranked again while indexing
+type jumpTickMsg struct {
+	jumper *pathJumper
+}
+
+// openJumper indexes the current directory and shows the overlay
+func (m *model) openJumper() tea.Cmd {
+	j := &pathJumper{root: m.currentDir}
+	m.jumper = j
+	m.statusMsg = "Type part of a path to jump to it..."
+	config := m.searchConfig
+	go j.walk(config)
+	return j.tick()
+}
+
+// walk indexes the paths under root, skipping what a search skips
+func (j *pathJumper) walk(config SearchConfig) {
+	var ignores *ignoreMatcher
+	if !config.NoIgnore {
+		ignores = newIgnoreMatcher(j.root, !config.IncludeGitignored)
+	}
+	var batch []string
+	flush := func() bool {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.paths = append(j.paths, batch...)
+		batch = batch[:0]
+		if len(j.paths) >= jumpMaxPaths {
+			j.limited = true
+		}
+		return !j.stop && !j.limited
+	}
+	filepath.WalkDir(j.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == j.root {
+			return nil
+		}
+		skip := !config.IncludeHidden && isHidden(path) && !config.alwaysSearches(path) ||
+			ignores != nil && ignores.ignored(path, d.IsDir()) ||
+			d.IsDir() && config.excludesDir(path)
+		if skip {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(j.root, path)
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			rel += string(filepath.Separator)
+		}
+		batch = append(batch, rel)
+		if len(batch) == 1000 && !flush() {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	flush()
+	j.mu.Lock()
+	j.done = true
+	j.mu.Unlock()
+}
+
+// tick schedules the next ranking while the index is filling
+func (j *pathJumper) tick() tea.Cmd {
+	return tea.Tick(jumpRefresh, func(time.Time) tea.Msg {
+		return jumpTickMsg{jumper: j}
+	})
+}
+
+// handleJumpTick ranks the paths indexed so far, until indexing is done
+func (m *model) handleJumpTick(msg jumpTickMsg) tea.Cmd {
+	if m.jumper == nil || m.jumper != msg.jumper {
+		return nil
+	}
+	m.jumper.rank()
+	if done, _, _ := m.jumper.state(); done {
+		return nil
+	}
+	return m.jumper.tick()
+}
+
+// state reports the progress of indexing
+func (j *pathJumper) state() (done bool, count int, limited bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.done, len(j.paths), j.limited
+}
+
+// rank matches the indexed paths against the query, best first; an empty
+// query lists the shallowest paths
+func (j *pathJumper) rank() {
+	j.mu.Lock()
+	paths := j.paths
+	j.mu.Unlock()
+
+	j.matches = j.matches[:0]
+	for _, path := range paths {
+		if score, hits, ok := fuzzyMatch(j.query, path); ok {
+			j.matches = append(j.matches, jumpMatch{path: path, score: score, hits: hits})
+		}
+	}
+	sort.SliceStable(j.matches, func(a, b int) bool {
+		if j.matches[a].score != j.matches[b].score {
+			return j.matches[a].score > j.matches[b].score
+		}
+		return len(j.matches[a].path) < len(j.matches[b].path)
+	})
+	j.index = max(0, min(j.index, len(j.matches)-1))
+}
+
+// fuzzyMatch reports whether the characters of query appear in path in
+// order, ignoring case. Matches are taken from the end of the path, so the
+// file or directory name is preferred; characters that follow each other
+// or start a name or word score higher.
+func fuzzyMatch(query, path string) (score int, hits []int, ok bool) {
+	if query == "" {
+		return -strings.Count(path, string(filepath.Separator)), nil, true
+	}
+	q := []rune(strings.ToLower(query))
+	name := strings.LastIndex(strings.TrimSuffix(path, string(filepath.Separator)), string(filepath.Separator)) + 1
+
+	qi := len(q) - 1
+	prev := -1
+	for i := len(path) - 1; i >= 0 && qi >= 0; i-- {
+		if !utf8.RuneStart(path[i]) {
+			continue // Multi-byte characters are compared at their first byte
+		}
+		r, size := utf8.DecodeRuneInString(path[i:])
+		if unicode.ToLower(r) != q[qi] {
+			continue
+		}
+		hits = append(hits, i)
+		score++
+		if prev == i+size {
+			score += 5
+		}
+		if i == 0 || strings.ContainsRune("/\\_-. ", rune(path[i-1])) {
+			score += 8
+		}
+		if i >= name {
+			score += 2
+		}
+		prev = i
+		qi--
+	}
+	if qi >= 0 {
+		return 0, nil, false
+	}
+	// Hits were found from the end; put them in order
+	for a, b := 0, len(hits)-1; a < b; a, b = a+1, b-1 {
+		hits[a], hits[b] = hits[b], hits[a]
+	}
+	return score, hits, true
+}
+
+// closeJumper hides the overlay and stops indexing
+func (m *model) closeJumper() {
+	if m.jumper != nil {
+		m.jumper.mu.Lock()
+		m.jumper.stop = true
+		m.jumper.mu.Unlock()
+	}
+	m.jumper = nil
+}
+
+// updateJumper handles keys while the jumper is open
+func (m *model) updateJumper(msg tea.KeyMsg) {
+	j := m.jumper
+	switch msg.String() {
+	case "esc", "ctrl+c", "ctrl+p":
+		m.closeJumper()
+		m.statusMsg = "Jump closed"
+
+	case "up", "ctrl+k":
+		if j.index > 0 {
+			j.index--
+		}
+
+	case "down", "ctrl+j":
+		if j.index < len(j.matches)-1 {
+			j.index++
+		}
+
+	case "enter":
+		if len(j.matches) == 0 {
+			m.statusMsg = "No path matches " + j.query
+			return
+		}
+		m.jumpTo(filepath.Join(j.root, j.matches[j.index].path))
+
+	case "backspace":
+		if runes := []rune(j.query); len(runes) > 0 {
+			j.query = string(runes[:len(runes)-1])
+			j.index = 0
+			j.rank()
+		}
+
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			j.query += string(msg.Runes)
+			j.index = 0
+			j.rank()
+		}
+	}
+}
+
+// jumpTo opens a directory, or the directory of a file with the cursor on it
+func (m *model) jumpTo(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		m.statusMsg = fmt.Sprintf("Can't jump: %v", err)
+		return
+	}
+	m.closeJumper()
+	if info.IsDir() {
+		m.currentDir = path
+		m.loadDirectory()
+		m.statusMsg = fmt.Sprintf("Jumped to %s", path)
+		return
+	}
+	m.currentDir = filepath.Dir(path)
+	m.loadDirectory()
+	m.focusPath = path
+	m.focusFile()
+	m.statusMsg = fmt.Sprintf("Jumped to %s", path)
+}
+
+// revealResult shows the selected result's file in the file browser, with
+// the cursor on it and the file selected, to act on it or search around it
+func (m *model) revealResult() {
+	if m.resultIndex >= m.visibleResults.len() {
+		return
+	}
+	result := m.visibleResults.at(m.resultIndex)
+	if unpreviewable(result) != "" {
+		m.statusMsg = "Only files on disk can be shown in the file browser"
+		return
+	}
+	if _, err := os.Stat(result.FilePath); err != nil {
+		m.statusMsg = fmt.Sprintf("Can't show the file: %v", err)
+		return
+	}
+	m.pushUndo("select revealed file")
+	m.mode = FileBrowserMode
+	m.currentDir = filepath.Dir(result.FilePath)
+	m.loadDirectory()
+	m.focusPath = result.FilePath
+	m.focusSelect = true
+	m.focusFile()
+	m.statusMsg = fmt.Sprintf("Showing %s in %s (selected; s searches it)", filepath.Base(result.FilePath), m.currentDir)
+}
+
+// focusFile moves the cursor to focusPath once the directory listing has
+// it, selecting it if asked; a large directory may still be loading
+func (m *model) focusFile() {
+	if m.focusPath == "" {
+		return
+	}
+	for i, file := range m.files {
+		if file.Path == m.focusPath {
+			m.selectedFile = i
+			if m.focusSelect {
+				m.setSelected(i, true)
+			}
+			m.focusPath, m.focusSelect = "", false
+			m.adjustViewport()
+			return
+		}
+	}
+	if m.dirLoad == nil {
+		m.focusPath, m.focusSelect = "", false
+	}
+}
+
+// renderJumper shows the query and the best matches
+func (m model) renderJumper() string {
+	j := m.jumper
+	var b strings.Builder
+	b.WriteString(headerStyle.Render(fmt.Sprintf("Jump to a path under %s (↑↓: choose, Enter: jump, Esc: close):", j.root)))
+	b.WriteString("\n")
+	b.WriteString(searchInputStyle.Render("> " + j.query + "█"))
+	b.WriteString("\n")
+
+	done, count, limited := j.state()
+	switch {
+	case limited:
+		b.WriteString(warningStyle.Render(fmt.Sprintf("%d of %d paths match (indexing stopped at %d paths)", len(j.matches), count, jumpMaxPaths)))
+	case done:
+		b.WriteString(helpStyle.Render(fmt.Sprintf("%d of %d paths match", len(j.matches), count)))
+	default:
+		b.WriteString(progressStyle.Render(fmt.Sprintf("Indexing... %d paths so far, %d match", count, len(j.matches))))
+	}
+	b.WriteString("\n")
+
+	rows := max(1, m.viewport.height-3)
+	start := max(0, j.index-rows+1)
+	end := min(start+rows, len(j.matches))
+	for i := start; i < end; i++ {
+		match := j.matches[i]
+		if i == j.index {
+			b.WriteString(renderSelected("  " + match.path))
+		} else {
+			style := fileStyle
+			if strings.HasSuffix(match.path, string(filepath.Separator)) {
+				style = directoryStyle
+			}
+			b.WriteString("  " + renderHits(match.path, match.hits, style))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// renderHits renders path in style with the characters at hits marked
+func renderHits(path string, hits []int, style lipgloss.Style) string {
+	var b strings.Builder
+	last := 0
+	for _, hit := range hits {
+		_, size := utf8.DecodeRuneInString(path[hit:])
+		b.WriteString(style.Render(path[last:hit]))
+		b.WriteString(matchStyle.Render(path[hit : hit+size]))
+		last = hit + size
+	}
+	b.WriteString(style.Render(path[last:]))
+	return b.String()
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// podLogWorkers caps the kubectl log streams running at once
+const podLogWorkers = 8
+
+// kubeOptions selects the pods and log range to search
+type kubeOptions struct {
+	Context       string   // kubeconfig context, empty for the current one
+	Namespace     string   // Empty for the kubeconfig default
+	AllNamespaces bool     // Search pods in every namespace
+	Selector      string   // Label selector (-l)
+	Container     string   // Only this container, instead of all of them
+	Pods          []string // Only these pods, instead of every selected one
+	Since         string   // Only logs newer than a duration, e.g. 1h
+	Tail          int      // Only the last N lines per container; -1 for all
+	Previous      bool     // Logs of the previous, terminated container instance
+}
+
+// podContainer is one container whose logs are searched
+type podContainer struct {
+	Namespace string
+	Pod       string
+	Container string
+}
+
+// path names a container's log in results
+func (c podContainer) path() string {
+	return fmt.Sprintf("k8s:%s/%s/%s", c.Namespace, c.Pod, c.Container)
+}
+
+// kubectl builds a kubectl command with the context and namespace options
+func (o kubeOptions) kubectl(ctx context.Context, args ...string) *exec.Cmd {
+	var global []string
+	if o.Context != "" {
+		global = append(global, "--context", o.Context)
+	}
+	if o.Namespace != "" && !o.AllNamespaces {
+		global = append(global, "--namespace", o.Namespace)
+	}
+	return exec.CommandContext(ctx, "kubectl", append(global, args...)...)
+}
+
+// runKubectl runs kubectl and returns its standard output
+func (o kubeOptions) runKubectl(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := o.kubectl(ctx, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("kubectl %s: %s", strings.Join(args, " "), msg)
+	}
+	return out, nil
+}
+
+// containers lists the containers of the selected pods
+func (o kubeOptions) containers(ctx context.Context) ([]podContainer, error) {
+	args := []string{"get", "pods", "-o",
+		`jsonpath={range .items[*]}{.metadata.namespace}{"\t"}{.metadata.name}{"\t"}{.spec.containers[*].name}{"\n"}{end}`}
+	if o.AllNamespaces {
+		args = append(args, "--all-namespaces")
+	}
+	if o.Selector != "" {
+		args = append(args, "--selector", o.Selector)
+	}
+	out, err := o.runKubectl(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool)
+	for _, pod := range o.Pods {
+		wanted[pod] = true
+	}
+	var containers []podContainer
+	found := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 || (len(wanted) > 0 && !wanted[fields[1]]) {
+			continue
+		}
+		found[fields[1]] = true
+		for _, name := range strings.Fields(fields[2]) {
+			if o.Container == "" || name == o.Container {
+				containers = append(containers, podContainer{Namespace: fields[0], Pod: fields[1], Container: name})
+			}
+		}
+	}
+	for _, pod := range o.Pods {
+		if !found[pod] {
+			return nil, fmt.Errorf("pod %s not found", pod)
+		}
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("no matching pods or containers")
+	}
+	return containers, nil
+}
+
+// searchPodLogs searches the logs of every selected container. Results are
+// per log line, timestamped with when the line was logged.
+func (m *model) searchPodLogs(ctx context.Context, opts kubeOptions) SearchResults {
+	start := time.Now()
+	results := SearchResults{Pattern: m.searchInput, Target: "pod logs", Inverted: m.searchConfig.InvertMatch}
+
+	containers, err := opts.containers(ctx)
+	if err != nil {
+		results.Errors = append(results.Errors, err.Error())
+		results.SearchTime = time.Since(start)
+		return results
+	}
+	results.TotalFiles = len(containers)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan podContainer)
+	for w := 0; w < min(podLogWorkers, len(containers)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				found, err := m.searchContainerLog(ctx, opts, c)
+				mu.Lock()
+				results.Results = append(results.Results, found...)
+				if err != nil {
+					results.Errors = append(results.Errors, err.Error())
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, c := range containers {
+		jobs <- c
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.SliceStable(results.Results, func(i, j int) bool {
+		if results.Results[i].FilePath == results.Results[j].FilePath {
+			return results.Results[i].LineNumber < results.Results[j].LineNumber
+		}
+		return results.Results[i].FilePath < results.Results[j].FilePath
+	})
+	results.SearchTime = time.Since(start)
+	return results
+}
+
+// searchContainerLog streams one container's log through the query
+func (m *model) searchContainerLog(ctx context.Context, opts kubeOptions, c podContainer) ([]SearchResult, error) {
+	args := []string{"logs", c.Pod, "--container", c.Container, "--timestamps"}
+	if opts.AllNamespaces {
+		args = append(args, "--namespace", c.Namespace)
+	}
+	if opts.Since != "" {
+		args = append(args, "--since", opts.Since)
+	}
+	if opts.Tail >= 0 {
+		args = append(args, "--tail", fmt.Sprint(opts.Tail))
+	}
+	if opts.Previous {
+		args = append(args, "--previous")
+	}
+
+	cmd := opts.kubectl(ctx, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("unable to run kubectl: %v", err)
+	}
+
+	lm := m.newLineMatcher(SearchResult{FilePath: c.path(), Source: "kubectl"})
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, BufferSize), BufferSize)
+	var offset int64
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Bytes()
+		length := int64(len(line)) + 1
+
+		// --timestamps prefixes each line with its RFC 3339 time
+		logged, message := splitLogTimestamp(line)
+		before := len(lm.results)
+		lm.match(lineNum, offset+int64(len(line)-len(message)), message)
+		for i := before; i < len(lm.results); i++ {
+			lm.results[i].LastModified = logged
+		}
+		offset += length
+	}
+
+	// A line too long to scan ends the read; stop kubectl rather than wait
+	// on it blocked writing the rest of the log, or on output its children
+	// still hold open
+	if err := scanner.Err(); err != nil {
+		cmd.WaitDelay = time.Second
+		cmd.Process.Kill()
+		cmd.Wait()
+		return lm.finish(), fmt.Errorf("%s: %v", c.path(), err)
+	}
+	if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return lm.finish(), fmt.Errorf("%s: %s", c.path(), msg)
+	}
+	return lm.finish(), nil
+}
+
+// splitLogTimestamp separates the timestamp kubectl adds to a log line
+func splitLogTimestamp(line []byte) (time.Time, []byte) {
+	stamp, message, ok := bytes.Cut(line, []byte{' '})
+	if !ok {
+		return time.Time{}, line
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(stamp))
+	if err != nil {
+		return time.Time{}, line
+	}
+	return t, message
+}
+
+// runLogsCommand implements `zx k8s [flags] pattern [pod...]`
+func runLogsCommand(args []string, config SearchConfig, opts kubeOptions) (SearchResults, error) {
+	if len(args) == 0 {
+		return SearchResults{}, fmt.Errorf("usage: zx k8s [flags] <pattern> [pod...]")
+	}
+	query, err := compileQuery(args[0])
+	if err != nil {
+		return SearchResults{}, fmt.Errorf("invalid regex pattern: %v", err)
+	}
+	config.Query = query
+	opts.Pods = args[1:]
+	m := &model{searchConfig: config, searchInput: args[0]}
+	return m.searchPodLogs(context.Background(), opts), nil
+}
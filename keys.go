@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// keyBindings maps the keys bound in the [keys] table of config.toml to
+// the built-in keys they act as
+var keyBindings = map[string]tea.KeyMsg{}
+
+// keyTypes names the keys other than single characters, as KeyMsg.String
+// gives them
+var keyTypes = func() map[string]tea.KeyType {
+	types := map[string]tea.KeyType{"space": tea.KeySpace}
+	for t := tea.KeyF20; t <= tea.KeyBackspace; t++ {
+		if name := t.String(); name != "" && t != tea.KeyRunes {
+			types[name] = t
+		}
+	}
+	return types
+}()
+
+// parseKey reads a key name such as "j", "ctrl+j", "alt+enter" or "f5"
+func parseKey(name string) (tea.KeyMsg, error) {
+	alt := false
+	if rest, ok := strings.CutPrefix(name, "alt+"); ok && rest != "" {
+		alt, name = true, rest
+	}
+	if t, ok := keyTypes[strings.ToLower(name)]; ok {
+		return tea.KeyMsg{Type: t, Alt: alt}, nil
+	}
+	if runes := []rune(name); len(runes) == 1 {
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: runes, Alt: alt}, nil
+	}
+	return tea.KeyMsg{}, fmt.Errorf("unknown key %q", name)
+}
+
+// setKeyBindings checks and installs the [keys] table of config.toml
+func setKeyBindings(keys map[string]string) error {
+	for key, builtin := range keys {
+		pressed, err := parseKey(key)
+		if err != nil {
+			return fmt.Errorf("[keys]: %v", err)
+		}
+		target, err := parseKey(builtin)
+		if err != nil {
+			return fmt.Errorf("[keys] %q: %v", key, err)
+		}
+		keyBindings[pressed.String()] = target
+	}
+	return nil
+}
+
+// boundKey returns the built-in key msg is bound to, or msg
+func boundKey(msg tea.KeyMsg) tea.KeyMsg {
+	if target, ok := keyBindings[msg.String()]; ok {
+		return target
+	}
+	return msg
+}
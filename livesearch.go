@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Live count defaults, used for zero LiveFileConfig fields
+const (
+	defaultLiveFiles = 2000
+	defaultLiveMB    = 50
+	defaultLiveDelay = 300 * time.Millisecond
+	liveMaxResults   = 10000 // Counting stops here; the count shows as "10000+"
+)
+
+// LiveConfig controls the hit counts shown while typing a pattern. They are
+// only computed for scopes of at most MaxFiles files and MaxBytes bytes.
+type LiveConfig struct {
+	Disabled bool
+	MaxFiles int
+	MaxBytes int64
+	Delay    time.Duration // Pause in typing before counting
+}
+
+// liveConfig is set from the [live] table of the config file
+var liveConfig = LiveConfig{MaxFiles: defaultLiveFiles, MaxBytes: defaultLiveMB << 20, Delay: defaultLiveDelay}
+
+// liveConfig resolves the [live] table of the config file
+func (f LiveFileConfig) liveConfig() (LiveConfig, error) {
+	if f.MaxFiles < 0 || f.MaxMB < 0 || f.DelayMs < 0 {
+		return LiveConfig{}, fmt.Errorf("live.max_files, live.max_mb and live.delay_ms can't be negative")
+	}
+	c := LiveConfig{Disabled: f.Off, MaxFiles: f.MaxFiles, MaxBytes: int64(f.MaxMB) << 20, Delay: time.Duration(f.DelayMs) * time.Millisecond}
+	if c.MaxFiles == 0 {
+		c.MaxFiles = defaultLiveFiles
+	}
+	if c.MaxBytes == 0 {
+		c.MaxBytes = defaultLiveMB << 20
+	}
+	if c.Delay == 0 {
+		c.Delay = defaultLiveDelay
+	}
+	return c, nil
+}
+
+// liveCount is the outcome of counting a pattern while it's typed
+type liveCount struct {
+	pattern   string
+	matches   int
+	lines     int
+	files     int
+	truncated bool
+	took      time.Duration
+	err       string // Invalid pattern
+	skipped   string // Why the scope is too large to count
+}
+
+// liveTickMsg fires once typing has paused for liveConfig.Delay
+type liveTickMsg struct {
+	id int
+}
+
+// liveCountMsg carries a finished count
+type liveCountMsg struct {
+	id    int
+	count liveCount
+}
+
+// liveKey identifies what a count depends on in search input mode
+func (m *model) liveKey() string {
+	return fmt.Sprintf("%s\x00%t\x00%t", m.searchInput, m.searchConfig.InvertMatch, m.searchConfig.FileLevelMatch)
+}
+
+// scheduleLive restarts the pause before the next count if the pattern or
+// its flags changed since key was taken
+func (m *model) scheduleLive(key string) tea.Cmd {
+	if m.mode != SearchInputMode || m.liveKey() == key {
+		return nil
+	}
+	m.stopLive()
+	if liveConfig.Disabled || m.searchInput == "" {
+		m.live = liveCount{}
+		return nil
+	}
+	id := m.liveID
+	return tea.Tick(liveConfig.Delay, func(time.Time) tea.Msg {
+		return liveTickMsg{id: id}
+	})
+}
+
+// stopLive drops the pending or running count
+func (m *model) stopLive() {
+	m.liveID++
+	if m.liveCancel != nil {
+		m.liveCancel()
+		m.liveCancel = nil
+	}
+}
+
+// startLive counts the pattern's hits in the scope the search would cover,
+// if the scope is small enough
+func (m *model) startLive(msg liveTickMsg) tea.Cmd {
+	if msg.id != m.liveID || m.mode != SearchInputMode || m.searchInput == "" {
+		return nil
+	}
+	if params := templateParams(m.searchInput); len(params) > 0 {
+		m.live = liveCount{pattern: m.searchInput, skipped: "a template; Enter asks for " + strings.Join(params, ", ")}
+		return nil
+	}
+	if _, err := compileQuery(m.searchInput); err != nil {
+		m.live = liveCount{pattern: m.searchInput, err: err.Error()}
+		return nil
+	}
+
+	// Count in a copy, so the search doesn't touch the model's state
+	c := *m
+	targets, selectedCount, fileCount, dirCount := c.searchTargets()
+	c.searchConfig.MaxResults = liveMaxResults
+	c.searchConfig.SearchStashes = false
+	c.searchConfig.Suggest.Disabled = true
+	c.tracker = nil
+	c.checkpoint = nil
+	c.collect = nil
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.liveCancel = cancel
+	id := m.liveID
+	return func() tea.Msg {
+		start := time.Now()
+		count := liveCount{pattern: c.searchInput}
+		if files, _, ok := c.measureScope(ctx, targets, liveConfig); !ok {
+			count.skipped = fmt.Sprintf("more than %s in scope (live.max_mb)", formatSize(liveConfig.MaxBytes))
+			if files > liveConfig.MaxFiles {
+				count.skipped = fmt.Sprintf("more than %d files in scope (live.max_files)", liveConfig.MaxFiles)
+			}
+			return liveCountMsg{id: id, count: count}
+		}
+		results := c.performLargeSearchSync(ctx, targets, fileCount, dirCount, selectedCount, FolderAnalysis{}, nil)
+		seen := make(map[string]bool)
+		for _, r := range results.Results {
+			seen[r.FilePath] = true
+		}
+		count.matches = totalMatches(results.Results)
+		count.lines = len(results.Results)
+		count.files = len(seen)
+		count.truncated = results.Truncated
+		count.took = time.Since(start)
+		return liveCountMsg{id: id, count: count}
+	}
+}
+
+// handleLiveCount shows a count unless the pattern changed meanwhile
+func (m *model) handleLiveCount(msg liveCountMsg) {
+	if msg.id == m.liveID {
+		m.live = msg.count
+		m.liveCancel = nil
+	}
+}
+
+// measureScope counts the files under targets that a search could read,
+// stopping as soon as there are more than config allows. It skips what
+// every search skips (hidden, ignored and excluded paths) but not binary
+// or oversized files, so the count errs on the large side.
+func (m *model) measureScope(ctx context.Context, targets []string, config LiveConfig) (files int, size int64, ok bool) {
+	over := func() bool { return files > config.MaxFiles || size > config.MaxBytes }
+	for _, target := range targets {
+		var ignores *ignoreMatcher
+		if !m.searchConfig.NoIgnore {
+			ignores = newIgnoreMatcher(target, !m.searchConfig.IncludeGitignored)
+		}
+		filepath.WalkDir(target, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || ctx.Err() != nil || over() {
+				return filepath.SkipAll
+			}
+			if path != target {
+				if !m.searchConfig.IncludeHidden && isHidden(path) || ignores != nil && ignores.ignored(path, d.IsDir()) {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if d.IsDir() && m.searchConfig.excludesDir(path) {
+					return filepath.SkipDir
+				}
+			}
+			if m.atMaxDepth(target, path, d.IsDir()) {
+				return filepath.SkipDir
+			}
+			if d.Type().IsRegular() {
+				if info, err := d.Info(); err == nil {
+					files++
+					size += info.Size()
+				}
+			}
+			return nil
+		})
+		if over() || ctx.Err() != nil {
+			return files, size, false
+		}
+	}
+	return files, size, true
+}
+
+// renderLive describes the latest count for the search input view
+func (m model) renderLive() string {
+	l := m.live
+	switch {
+	case liveConfig.Disabled || m.searchInput == "":
+		return ""
+	case m.liveCancel != nil && l.pattern != m.searchInput:
+		return helpStyle.Render("Live: counting...")
+	case l.pattern == "":
+		return ""
+	case l.err != "":
+		return errorStyle.Render("Invalid pattern: " + l.err)
+	case l.skipped != "":
+		return helpStyle.Render("Live counts off: " + l.skipped)
+	}
+	matches := fmt.Sprint(l.matches)
+	if l.truncated {
+		matches = fmt.Sprintf("%d+", l.matches)
+	}
+	stale := ""
+	if l.pattern != m.searchInput {
+		stale = fmt.Sprintf(" for %q", l.pattern)
+	}
+	return progressStyle.Render(fmt.Sprintf("Live%s: %s matches on %d lines in %d files (%v)",
+		stale, matches, l.lines, l.files, l.took.Round(time.Millisecond)))
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxWrapRows bounds the rows a wrapped line takes; the rest of a
+// minified file's line is left out
+const maxWrapRows = 20
+
+// lineIndent is the indentation of result and context lines
+const lineIndent = "    "
+
+// hscroll is how far the selected result's line is scrolled sideways from
+// where clipping centers it on the first match; selecting another result
+// starts it over
+type hscroll struct {
+	key   lineKey
+	runes int
+}
+
+// lineWidth is the columns left for a result line in the result list, or
+// 0 before the terminal size is known
+func (m model) lineWidth() int {
+	width := m.viewport.width
+	if m.splitActive() {
+		width = width * m.listRatio() / 100
+	}
+	if width <= len(lineIndent) {
+		return 0
+	}
+	return width - len(lineIndent)
+}
+
+// runeWidth is the columns a rune takes; tabs and other control runes
+// count as one
+func runeWidth(r rune) int {
+	return max(1, lipgloss.Width(string(r)))
+}
+
+// stepRunes moves a byte offset of text by n runes, stopping at either end
+func stepRunes(text string, pos, n int) int {
+	for ; n < 0 && pos > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:pos])
+		pos -= size
+	}
+	for ; n > 0 && pos < len(text); n-- {
+		_, size := utf8.DecodeRuneInString(text[pos:])
+		pos += size
+	}
+	return pos
+}
+
+// clipLine cuts a line longer than width down to the part around its first
+// match, a third of the width in, moved by scroll runes, with an ellipsis
+// on each side cut off. The matches are moved with the text.
+func clipLine(text string, matches []MatchRange, width, scroll int) (string, []MatchRange) {
+	if width <= 2 || len(text) <= width {
+		return text, matches
+	}
+	anchor := 0
+	if len(matches) > 0 {
+		anchor = max(0, min(matches[0].Start, len(text)))
+	}
+	avail := width - 2 // Room for an ellipsis at each end
+	start := stepRunes(text, anchor, scroll-avail/3)
+	end, used := start, 0
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if used+runeWidth(r) > avail {
+			break
+		}
+		used += runeWidth(r)
+		end += size
+	}
+	// Near the end of the line, show more of what comes before
+	for end == len(text) && start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if used+runeWidth(r) > avail {
+			break
+		}
+		used += runeWidth(r)
+		start -= size
+	}
+	if start == 0 && end == len(text) {
+		return text, matches
+	}
+
+	prefix, suffix := "", ""
+	if start > 0 {
+		prefix = "…"
+	}
+	if end < len(text) {
+		suffix = "…"
+	}
+	return prefix + text[start:end] + suffix, shiftMatches(matches, start, end, len(prefix)-start)
+}
+
+// shiftMatches keeps the parts of matches within [start, end) of a line,
+// moved by shift bytes
+func shiftMatches(matches []MatchRange, start, end, shift int) []MatchRange {
+	var kept []MatchRange
+	for _, match := range matches {
+		if s, e := max(match.Start, start), min(match.End, end); s < e {
+			kept = append(kept, MatchRange{Start: s + shift, End: e + shift, Term: match.Term})
+		}
+	}
+	return kept
+}
+
+// wrapLine breaks a line into rows of at most width columns, highlighting
+// the matches in each, up to maxWrapRows rows
+func (m model) wrapLine(text string, matches []MatchRange, width int) []string {
+	var rows []string
+	start := 0
+	for start < len(text) && len(rows) < maxWrapRows {
+		end, used := start, 0
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if used+runeWidth(r) > width && end > start {
+				break
+			}
+			used += runeWidth(r)
+			end += size
+		}
+		rows = append(rows, highlightMatches(text[start:end], shiftMatches(matches, start, end, -start)))
+		start = end
+	}
+	if start < len(text) {
+		rows = append(rows, helpStyle.Render(fmt.Sprintf("… %d more bytes (W to clip)", len(text)-start)))
+	}
+	if len(rows) == 0 {
+		rows = append(rows, "")
+	}
+	return rows
+}
+
+// renderLine highlights a result's line for the result list: wrapped in
+// wrap mode, or else clipped around its first match, scrolled sideways
+// when it's the selected result
+func (m model) renderLine(result SearchResult, selected bool) string {
+	width := m.lineWidth()
+	if m.wrapLines && width > 0 {
+		return lineIndent + strings.Join(m.wrapLine(result.LineContent, result.Matches, width), "\n"+lineIndent)
+	}
+	scroll := 0
+	if selected && m.hscroll.key == (lineKey{result.FilePath, result.LineNumber}) {
+		scroll = m.hscroll.runes
+	}
+	text, matches := clipLine(result.LineContent, result.Matches, width, scroll)
+	return lineIndent + highlightMatches(text, matches)
+}
+
+// renderContextLine lays out a line of context like result lines, wrapped
+// or clipped from its start
+func (m model) renderContextLine(line string) string {
+	width := m.lineWidth()
+	if m.wrapLines && width > 0 {
+		var rows []string
+		for _, row := range m.wrapLine(line, nil, width) {
+			rows = append(rows, helpStyle.Render(lineIndent+row))
+		}
+		return strings.Join(rows, "\n")
+	}
+	line, _ = clipLine(line, nil, width, 0)
+	return helpStyle.Render(lineIndent + line)
+}
+
+// scrollLine scrolls the selected result's line sideways by a quarter of
+// the width; step is -1 or 1
+func (m *model) scrollLine(step int) {
+	if m.resultIndex >= m.visibleResults.len() {
+		return
+	}
+	if m.wrapLines {
+		m.statusMsg = "Lines are wrapped (W to clip them instead)"
+		return
+	}
+	width := m.lineWidth()
+	result := m.visibleResults.at(m.resultIndex)
+	if width <= 2 || utf8.RuneCountInString(result.LineContent) <= width {
+		m.statusMsg = "The line fits"
+		return
+	}
+	key := lineKey{result.FilePath, result.LineNumber}
+	if m.hscroll.key != key {
+		m.hscroll = hscroll{key: key}
+	}
+
+	// Stop once either end of the line shows
+	text := result.LineContent
+	anchor := 0
+	if len(result.Matches) > 0 {
+		anchor = max(0, min(result.Matches[0].Start, len(text)))
+	}
+	avail := width - 2
+	total := utf8.RuneCountInString(text)
+	first := utf8.RuneCountInString(text[:anchor]) - avail/3
+	runes := m.hscroll.runes + step*max(avail/4, 1)
+	m.hscroll.runes = max(min(runes, max(total-avail-first, 0)), min(-first, 0))
+	m.statusMsg = fmt.Sprintf("Showing the line from character %d of %d", max(first+m.hscroll.runes, 0)+1, total)
+}
+
+// toggleWrap switches long result lines between wrapped and clipped
+func (m *model) toggleWrap() {
+	m.wrapLines = !m.wrapLines
+	if m.wrapLines {
+		m.statusMsg = fmt.Sprintf("Wrapping long lines (up to %d rows each)", maxWrapRows)
+	} else {
+		m.statusMsg = "Clipping long lines around the match (←/→ scroll the selected line)"
+	}
+}
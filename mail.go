@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// mailFormats maps mail file extensions to the extractor name shown in results
+var mailFormats = map[string]string{
+	".mbox": "mbox",
+	".mbx":  "mbox",
+	".eml":  "eml",
+}
+
+// leadingHeaders are listed first, in this order; other headers follow
+// alphabetically
+var leadingHeaders = []string{"From", "To", "Cc", "Subject", "Date"}
+
+// mailFormat returns "mbox" or "eml" for mail files, also when compressed
+// (archive.mbox.gz), or "" for other files
+func mailFormat(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".gz", ".bz2", ".xz":
+		ext = strings.ToLower(filepath.Ext(strings.TrimSuffix(path, filepath.Ext(path))))
+	}
+	return mailFormats[ext]
+}
+
+// mailDecoder decodes RFC 2047 encoded words in any charset x/text knows
+var mailDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// charsetReader converts text in the named charset to UTF-8
+func charsetReader(charset string, r io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii":
+		return r, nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return enc.NewDecoder().Reader(r), nil
+}
+
+// searchMailFile searches the messages of an mbox or .eml file. Headers are
+// decoded and bodies are searched as text after undoing base64 and
+// quoted-printable encodings; line numbers refer to that decoded text, and
+// results have no byte offset. Each result carries its message's subject,
+// and its date as LastModified.
+func (m *model) searchMailFile(ctx context.Context, file *os.File, base SearchResult, format string) ([]SearchResult, error) {
+	reader, err := contentReader(ctx, file, &base)
+	if err != nil {
+		return nil, err
+	}
+	base.Extractor = format
+	modified := base.LastModified
+
+	lm := m.newLineMatcher(base)
+	lineNum := 0
+	var searchErr error
+	search := func(raw []byte) bool {
+		lm.base.Subject = ""
+		lm.base.LastModified = modified
+		lines, err := decodeMessage(raw, &lm.base)
+		if err != nil && searchErr == nil {
+			searchErr = err
+		}
+		for _, line := range lines {
+			lineNum++
+			lm.match(lineNum, 0, []byte(line))
+		}
+		return ctx.Err() == nil
+	}
+
+	if format == "eml" {
+		raw, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		search(raw)
+		return lm.finish(), searchErr
+	}
+
+	if err := splitMbox(reader, search); err != nil {
+		return lm.finish(), err
+	}
+	return lm.finish(), searchErr
+}
+
+// splitMbox calls message with the raw text of each message in an mbox,
+// stopping early when it returns false. "From " lines after a blank line
+// separate messages; quoted >From lines are unescaped.
+func splitMbox(r io.Reader, message func(raw []byte) bool) error {
+	reader := bufio.NewReaderSize(r, BufferSize)
+	var current bytes.Buffer
+	started, blank := false, true
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case blank && bytes.HasPrefix(line, []byte("From ")):
+				if started && !message(current.Bytes()) {
+					return nil
+				}
+				current.Reset()
+				started = true
+			case bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) && line[0] == '>':
+				current.Write(line[1:])
+			default:
+				current.Write(line)
+			}
+			blank = len(bytes.TrimRight(line, "\r\n")) == 0
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if started || current.Len() > 0 {
+		message(current.Bytes())
+	}
+	return nil
+}
+
+// decodeMessage returns the searchable lines of a message: its decoded
+// headers, a blank line and the text of its body parts. The subject and
+// date are recorded in base. Unparseable messages are searched as they are.
+func decodeMessage(raw []byte, base *SearchResult) ([]string, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return strings.Split(strings.TrimRight(string(raw), "\n"), "\n"), nil
+	}
+
+	subject := msg.Header.Get("Subject")
+	if decoded, err := mailDecoder.DecodeHeader(subject); err == nil {
+		subject = decoded
+	}
+	base.Subject = subject
+	if date, err := msg.Header.Date(); err == nil {
+		base.LastModified = date
+	}
+
+	lines := headerLines(textproto.MIMEHeader(msg.Header))
+	lines = append(lines, "")
+	var buf bytes.Buffer
+	partErr := writePart(&buf, textproto.MIMEHeader(msg.Header), msg.Body)
+	body := strings.ReplaceAll(buf.String(), "\r\n", "\n")
+	lines = append(lines, strings.Split(strings.TrimRight(body, "\n"), "\n")...)
+	return lines, partErr
+}
+
+// headerLines renders headers as "Name: value" lines with encoded words
+// decoded, the usual ones first
+func headerLines(header textproto.MIMEHeader) []string {
+	var names []string
+	for name := range header {
+		names = append(names, name)
+	}
+	rank := func(name string) int {
+		for i, h := range leadingHeaders {
+			if h == name {
+				return i
+			}
+		}
+		return len(leadingHeaders)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if ri, rj := rank(names[i]), rank(names[j]); ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+
+	var lines []string
+	for _, name := range names {
+		for _, value := range header[name] {
+			if decoded, err := mailDecoder.DecodeHeader(value); err == nil {
+				value = decoded
+			}
+			lines = append(lines, name+": "+value)
+		}
+	}
+	return lines
+}
+
+// writePart writes the text of a MIME part to w, descending into multipart
+// and attached messages. Other attachments are listed by name only.
+func writePart(w *bytes.Buffer, header textproto.MIMEHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("malformed multipart message: %v", err)
+			}
+			if err := writePart(w, part.Header, part); err != nil {
+				return err
+			}
+		}
+
+	case mediaType == "message/rfc822":
+		msg, err := mail.ReadMessage(body)
+		if err != nil {
+			return nil
+		}
+		for _, line := range headerLines(textproto.MIMEHeader(msg.Header)) {
+			w.WriteString(line + "\n")
+		}
+		w.WriteString("\n")
+		return writePart(w, textproto.MIMEHeader(msg.Header), msg.Body)
+
+	case strings.HasPrefix(mediaType, "text/"):
+		text, err := charsetReader(params["charset"], body)
+		if err != nil {
+			text = body
+		}
+		if _, err := io.Copy(w, text); err != nil {
+			return fmt.Errorf("undecodable %s part: %v", mediaType, err)
+		}
+		if w.Len() > 0 && w.Bytes()[w.Len()-1] != '\n' {
+			w.WriteByte('\n')
+		}
+
+	default:
+		name := params["name"]
+		if _, dispParams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil && dispParams["filename"] != "" {
+			name = dispParams["filename"]
+		}
+		if name != "" {
+			w.WriteString(fmt.Sprintf("[attachment: %s (%s)]\n", name, mediaType))
+		}
+	}
+	return nil
+}
@@ -3,16 +3,18 @@ package main
 import (
 	"bufio"
 	"context"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"regexp"
 	"runtime"
 	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -24,6 +26,7 @@ const (
 	MaxResultsInMemory = 10000     // Maximum results to keep in memory
 	MaxFileSize        = 100 << 20 // 100MB max file size to search
 	BufferSize         = 64 << 10  // 64KB buffer for file reading
+	MmapThreshold      = 64 << 20  // Files at least this large are memory-mapped
 	ProgressUpdateMs   = 100       // Progress update interval in milliseconds
 )
 
@@ -37,6 +40,7 @@ const (
 	SearchProgressMode
 	ConfigMode
 	AnalysisMode
+	PreviewMode
 )
 
 // FileItem represents a file or directory in the browser
@@ -46,43 +50,83 @@ type FileItem struct {
 	IsDir    bool
 	Size     int64
 	ModTime  time.Time
+	Mode     os.FileMode
 	Selected bool
+	Depth    int  // Level below currentDir in the tree view
+	Expanded bool // A directory of the tree view showing its entries
 }
 
-// SearchResult represents a single search match
+// MatchRange is the byte span of one match within a line
+type MatchRange struct {
+	Start, End int
+	Term       int // Index in Query.Patterns of the term that matched
+}
+
+// SearchResult represents a matching line and every match on it
 type SearchResult struct {
 	FilePath     string
 	LineNumber   int
 	LineContent  string
-	MatchStart   int
-	MatchEnd     int
+	Matches      []MatchRange // Match spans, ordered by start; empty for lines without one (e.g. -v)
+	Column       int          // 1-based rune column of the first match within the line
+	ByteOffset   int64        // Offset of the first match in the file; 0 and meaningless unless rawOffsets
 	FileSize     int64
 	LastModified time.Time
+	Encoding     string   // Detected text encoding of the file
+	Compression  string   // Compression format the file was decoded from, if any
+	Extractor    string   // Content extractor the text came from, if any
+	Layer        string   // Container image layer the file came from, if any
+	Source       string   // Command the lines were read from when not a file (e.g. kubectl)
+	Unit         string   // systemd unit of a journal entry, or provider and ID of an event
+	Priority     string   // Syslog priority or event level of a log entry (err, warning, ...)
+	Subject      string   // Subject of the mail message the line came from
+	MoreMatches  int      // Matches beyond the per-file cap, set on a file's last kept result
+	Before       []string // Context lines before the match, from an imported bundle
+	After        []string // Context lines after the match, from an imported bundle
+	RefKind      string   // Definition or usage, when references are classified
 }
 
 // SearchProgress tracks search progress for large operations
 type SearchProgress struct {
 	TotalFiles     int64
 	ProcessedFiles int64
-	CurrentFile    string
+	CurrentFile    string // Path of the file a worker started on last
 	TotalSize      int64
 	ProcessedSize  int64
+	Matches        int64 // Matches found, including any past the result limit
+	Failed         int64 // Files left out so far
 	StartTime      time.Time
-	Errors         []string
 	Cancelled      bool
+	Collecting     bool             // Files are still being found, so the totals are still growing
+	Targets        []TargetProgress // Per search root, in the order given
 }
 
 // SearchResults holds all search results and metadata
 type SearchResults struct {
-	Pattern     string
-	Target      string
-	Results     []SearchResult
-	Suggestions []string
-	Errors      []string
-	TotalFiles  int
-	SearchTime  time.Duration
-	Progress    SearchProgress
-	Truncated   bool // True if results were truncated due to memory limits
+	Pattern      string
+	Target       string
+	Results      []SearchResult
+	Suggestions  []string
+	Errors       []string
+	Failed       []failedFile // Files skipped for their size or content, or that couldn't be read
+	TotalFiles   int
+	SearchTime   time.Duration
+	SuggestTime  time.Duration // Part of SearchTime spent looking for suggestions
+	Progress     SearchProgress
+	Truncated    bool   // True if results were truncated due to memory limits
+	Inverted     bool   // True if results are lines NOT matching the pattern
+	Bundle       string // Bundle file the results were imported from, if any
+	IndexSkipped int    // Files ruled out by a trigram index without reading them
+	Quick        bool   // True if the quick search limits applied
+	Resumed      int    // Files whose results came from a checkpoint
+	Checkpointed bool   // True if a stopped search left a checkpoint to resume
+	NetworkSafe  bool   // True if network safe mode applied
+	Saved        string // Saved search these results are a run of, if any
+
+	// What -stats and the statistics panel report beyond the above
+	BytesRead   int64         // Size of the files searched
+	PeakWorkers int           // Most files searched at once
+	CPUTime     time.Duration // CPU time zx used while searching, if known
 }
 
 // FolderAnalysis holds statistics about a directory
@@ -94,19 +138,51 @@ type FolderAnalysis struct {
 	BinaryFiles     int
 	TextFiles       int
 	HiddenFiles     int
-	LargeFiles      int // Files larger than current threshold
+	MinifiedFiles   int            // Minified or generated web assets
+	MinifiedReasons map[string]int // Why files were classified as minified
+	LargeFiles      int            // Files larger than current threshold
+	IgnoredPaths    int            // Files and directories excluded by ignore rules
+	SkippedDirs     int            // Directories pruned as SkipDirs
+	BinaryReasons   map[string]int // Why files were classified as binary
 	Recommendations SearchConfig
 }
 
 // SearchConfig holds configuration for search operations
 type SearchConfig struct {
-	MaxFileSize     int64
-	MaxResults      int
-	IncludePatterns []string
-	ExcludePatterns []string
-	CaseSensitive   bool
-	MaxConcurrency  int
-	AutoConfigured  bool // Whether this was auto-configured
+	MaxFileSize       int64
+	MaxResults        int
+	MaxPerFile        int // Results kept per file; later matches are only counted (0 = unlimited)
+	IncludePatterns   []string
+	ExcludePatterns   []string
+	SkipDirs          []string // Directory names pruned from every walk (skip_dirs)
+	NoSkipDirs        bool     // Search the SkipDirs too
+	TextExts          []string // Extensions searched as text without sniffing their content
+	BinaryExts        []string // Extensions skipped as binary without reading them
+	CaseSensitive     bool
+	InvertMatch       bool          // Report lines that do NOT match the pattern (grep -v)
+	FileLevelMatch    bool          // Evaluate the expression over whole files instead of lines
+	NoIgnore          bool          // Search paths excluded by .gitignore/.ignore/.zxignore files
+	IncludeGitignored bool          // Search paths excluded only by .gitignore or .git/info/exclude
+	IncludeHidden     bool          // Also search dotfiles such as .env
+	IncludeMinified   bool          // Also search minified assets and source maps
+	SearchStashes     bool          // Also search files recorded in git stashes
+	MaxDepth          int           // Directory levels to descend below each target (0 = unlimited)
+	NoIndex           bool          // Don't consult trigram indexes built with `zx index`
+	MinSize           int64         // Only files at least this large (0 = no minimum)
+	MaxSize           int64         // Only files at most this large (0 = no maximum)
+	ModifiedWithin    time.Duration // Only files modified this recently (0 = any time)
+	AlwaysSearch      []string      // Files searched even if hidden, binary-looking or too large
+	Rules             []FileRule    // Per-pattern skips, size limits and workers from config.toml
+	Network           NetworkMode   // When to use network safe mode
+	NetworkSafe       bool          // Few workers, retries and no memory maps, for network file systems
+	Query             *Query        // Compiled search expression, set when a search starts
+	Suggest           SuggestConfig // "Did you mean" suggestions when nothing matches
+	MaxConcurrency    int
+	AutoConfigured    bool // Whether this was auto-configured
+
+	// Files left out of the search, marked in the results before it; a
+	// rerun skips them again, but the history doesn't keep them
+	SkipFiles map[string]bool `json:"-"`
 }
 
 // Model represents the main application model
@@ -129,67 +205,117 @@ type model struct {
 	statusMsg    string
 	searching    bool
 	searchCancel context.CancelFunc
+	searchID     int                   // ID of the running search's messages, to drop stale ones
+	lastSearchID int                   // Last ID handed out, in any tab
+	resultStream <-chan searchBatchMsg // Batches of the running search
 	progress     SearchProgress
 	analysis     FolderAnalysis // Store current analysis
+
+	resultFilter    ResultFilter       // Display filters applied to searchResults
+	visibleResults  resultList         // searchResults.Results after resultFilter
+	prompt          *inputPrompt       // Active single-line prompt, if any
+	undoStack       []undoEntry        // Snapshots for Ctrl+Z
+	trashBatches    [][]trashEntry     // Deletions this session, for restore
+	showRefs        bool               // Show the definition/usage summary in results mode
+	themeIndex      int                // Index into themes
+	themeSaved      int                // Theme to restore when the picker is closed with Esc
+	pickingTheme    bool               // The theme picker is open in config mode
+	matchModeIndex  int                // Index into matchModes
+	iconIndex       int                // Index into iconThemes
+	notes           map[lineKey]string // Triage notes attached to result lines
+	fileCounts      map[string]int     // Results per visible file when aggregated
+	pickingPreset   bool               // The pattern preset picker is open in search input mode
+	bookmarks       []string           // Bookmarked directories, jumped to with Alt+1-9 or the picker
+	pickingBookmark bool               // The bookmark picker is open in the file browser
+	bookmarkIndex   int                // Highlighted entry of bookmarks
+	jumper          *pathJumper        // The Ctrl+P path jumper, while open in the file browser
+	focusPath       string             // File to put the cursor on once its directory has loaded
+	focusSelect     bool               // Also select the file focusPath names
+	fileOp          *fileOperation     // Copy or move running in the background, if any
+	presetIndex     int                // Highlighted entry of patternLibrary
+	quickSearch     bool               // Apply the quick search limits to the next search
+	quickSaved      *SearchConfig      // Configuration to restore after a quick search
+	scopeTargets    []string           // Roots chosen in the scope wizard, searched when nothing is selected
+
+	history         []historyEntry        // Past searches, oldest first
+	historyPos      int                   // Searches back from the newest while recalling (0 = not recalling)
+	historyDraft    historyEntry          // Pattern and settings from before recalling
+	recalledTargets []string              // Targets of a recalled or saved search, used by the next search
+	savedSearches   []historyEntry        // Named searches, rerun with @1-@9 in the file browser
+	lastSearch      *historyEntry         // The most recent search, for saving
+	searchTemplate  *historyEntry         // Template the search about to run was filled from
+	savedRun        string                // Saved search the search about to run was started from
+	trends          map[string][]trendRun // Match counts of past runs of saved searches, by name
+
+	renderCache  *resultRenderCache  // Styled result entries from earlier frames
+	preview      *filePreview        // File shown in PreviewMode
+	paneCache    *panePreviewCache   // File shown in the results preview pane
+	contextCache *contextCache       // Lines read around selected results
+	contextLines int                 // Lines of context shown around the selected result
+	singlePane   bool                // Show results without the preview pane
+	paneRatio    int                 // Percent of the width for the result list in split view (0 = default)
+	paneScroll   paneScroll          // Lines the preview pane is scrolled away from the selected line
+	dirLoad      *dirLoader          // Background load of a large directory, if any
+	treeView     bool                // Show the file browser as a tree expanding in place
+	treeOpen     map[string]bool     // Directories expanded in the tree view
+	selection    map[string]FileItem // Selected entries of every directory, by path
+	count        int                 // Count typed before a movement key in the file browser or the results list
+	savedPrefix  bool                // @ was pressed in the file browser; a digit reruns that saved search
+	wrapLines    bool                // Wrap long result lines instead of clipping them
+	hscroll      hscroll             // Sideways scroll of the selected result's line
+	showFailures bool                // List the files the search left out, by kind
+	showStats    bool                // Show the statistics of the finished search
+	marked       map[lineKey]bool    // Results marked for a batch action
+	skipNext     map[string]bool     // Files the next search leaves out, from marked results
+	watcher      *dirWatcher         // Reports external changes to currentDir; shared by the model's copies
+
+	live       liveCount          // Hit count of the pattern being typed
+	liveID     int                // Incremented per keystroke to drop stale counts
+	liveCancel context.CancelFunc // Stops the running count
+
+	tracker      *targetTracker    // Per-target accounting of the running search
+	targetIndex  int               // Highlighted target in SearchProgressMode
+	checkpoint   *checkpointer     // Saves the running search's progress now and then
+	resumeFrom   *searchCheckpoint // Checkpoint the next search continues
+	collect      *collectTree      // Directories the running search is collecting files from
+	collectIndex int               // Highlighted directory of the collection tree
+
+	tabs      []searchTab // Every tab, the shown one as of the last switch (empty = one tab)
+	activeTab int         // Index into tabs of the shown tab
+
+	runningAction runningAction  // What the next search does to the running one
+	background    *searchJob     // Search moved out of view, shown with J
+	queued        *queuedSearch  // Search to start when the running one finishes
+	partial       *SearchResults // Results of a stopped search replaced by a newer one, merged with P
+	merged        *partialMerge  // Partial results merged into the view
+	dirLoadID     int            // Incremented per load to drop stale batches
+}
+
+// inputPrompt is a single-line text prompt shown above the status bar
+type inputPrompt struct {
+	label    string
+	input    string
+	cursor   int  // Rune offset of the cursor in input
+	raw      bool // Submit input without trimming surrounding space
+	onSubmit func(m *model, value string)
+	onRun    func(m *model, value string) tea.Cmd // Used instead of onSubmit by prompts that start a command
 }
 
-// Styles for the TUI
+// Styles for the TUI, built from the theme by applyTheme
 var (
-	titleStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("#FAFAFA")).
-			Background(lipgloss.Color("#7D56F4")).
-			Padding(0, 1)
-
-	headerStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("#04B575"))
-
-	directoryStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#7D56F4")).
-			Bold(true)
-
-	fileStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#F8F8F2"))
-
-	selectedStyle = lipgloss.NewStyle().
-			Background(lipgloss.Color("#44475A")).
-			Foreground(lipgloss.Color("#F8F8F2")).
-			Bold(true)
-
-	searchInputStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#50FA7B")).
-				Background(lipgloss.Color("#282A36")).
-				Padding(0, 1)
-
-	matchStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FF5F87")).
-			Bold(true).
-			Background(lipgloss.Color("#3C3C3C"))
-
-	errorStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FF5555")).
-			Bold(true)
-
-	helpStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#6272A4")).
-			Italic(true)
-
-	statusStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FFB86C")).
-			Italic(true)
-
-	suggestionStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FFB86C")).
-			Italic(true)
-
-	progressStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#50FA7B")).
-			Bold(true)
-
-	warningStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#F1FA8C")).
-			Bold(true)
+	titleStyle       lipgloss.Style
+	headerStyle      lipgloss.Style
+	directoryStyle   lipgloss.Style
+	fileStyle        lipgloss.Style
+	selectedStyle    lipgloss.Style
+	searchInputStyle lipgloss.Style
+	matchStyle       lipgloss.Style
+	errorStyle       lipgloss.Style
+	helpStyle        lipgloss.Style
+	statusStyle      lipgloss.Style
+	suggestionStyle  lipgloss.Style
+	progressStyle    lipgloss.Style
+	warningStyle     lipgloss.Style
 )
 
 func initialModel() model {
@@ -201,19 +327,37 @@ func initialModel() model {
 			MaxFileSize:    MaxFileSize,
 			MaxResults:     MaxResultsInMemory,
 			MaxConcurrency: MaxConcurrentFiles,
+			SkipDirs:       defaultSkipDirs,
+			TextExts:       defaultTextExts,
+			BinaryExts:     defaultBinaryExts,
 			CaseSensitive:  false,
 		},
+		renderCache:  newResultRenderCache(),
+		paneCache:    &panePreviewCache{},
+		contextCache: newContextCache(),
+		singlePane:   !splitByDefault,
+		watcher:      newDirWatcher(),
 	}
 	m.loadDirectory()
 	return m
 }
 
+// loadDirectory lists currentDir. The first dirBatchSize entries are shown
+// right away; the rest of a larger directory streams in through dirLoad.
 func (m *model) loadDirectory() {
-	entries, err := os.ReadDir(m.currentDir)
+	m.stopDirLoad()
+	m.watcher.watch(m.currentDir)
+	dir, err := os.Open(m.currentDir)
 	if err != nil {
 		m.statusMsg = fmt.Sprintf("Error reading directory: %v", err)
 		return
 	}
+	entries, err := dir.ReadDir(dirBatchSize)
+	if err != nil && err != io.EOF {
+		dir.Close()
+		m.statusMsg = fmt.Sprintf("Error reading directory: %v", err)
+		return
+	}
 
 	m.files = make([]FileItem, 0, len(entries)+1)
 
@@ -227,59 +371,79 @@ func (m *model) loadDirectory() {
 	}
 
 	// Add directory entries
-	for _, entry := range entries {
-		info, err := entry.Info()
-		if err != nil {
-			continue
-		}
-
-		item := FileItem{
-			Name:    entry.Name(),
-			Path:    filepath.Join(m.currentDir, entry.Name()),
-			IsDir:   entry.IsDir(),
-			Size:    info.Size(),
-			ModTime: info.ModTime(),
-		}
-		m.files = append(m.files, item)
-	}
+	m.files = append(m.files, fileItems(m.currentDir, entries)...)
+	m.pruneSelection()
+	m.markSelected(m.files)
 
 	// Sort: directories first, then files, both alphabetically
-	sort.Slice(m.files, func(i, j int) bool {
-		if m.files[i].IsDir && !m.files[j].IsDir {
-			return true
-		}
-		if !m.files[i].IsDir && m.files[j].IsDir {
-			return false
-		}
-		return m.files[i].Name < m.files[j].Name
-	})
+	sortFileItems(m.files)
 
 	m.selectedFile = 0
-	m.statusMsg = fmt.Sprintf("Loaded %d items", len(m.files))
+	m.viewport.offset = 0
+	if len(entries) < dirBatchSize {
+		dir.Close()
+		m.expandTree()
+		m.statusMsg = fmt.Sprintf("Loaded %d items", len(m.files))
+		return
+	}
+	m.dirLoadID++
+	m.dirLoad = &dirLoader{id: m.dirLoadID, dir: m.currentDir, file: dir}
+	m.statusMsg = fmt.Sprintf("Loading... %d items so far", len(m.files))
 }
 
 func (m model) Init() tea.Cmd {
-	return tea.Tick(time.Millisecond*ProgressUpdateMs, func(t time.Time) tea.Msg {
+	return tea.Batch(tea.Tick(time.Millisecond*ProgressUpdateMs, func(t time.Time) tea.Msg {
 		return progressTickMsg{}
-	})
+	}), m.startDirLoad(), waitForDirChange(m.watcher))
 }
 
 type progressTickMsg struct{}
 
 type searchCompleteMsg struct {
+	id            int // searchID of the search that finished
 	results       SearchResults
 	selectedCount int
 	fileCount     int
 	dirCount      int
 }
 
+// searchBatchMsg delivers results found so far by a running search
+type searchBatchMsg struct {
+	id       int
+	results  []SearchResult
+	progress SearchProgress
+}
+
+// waitForBatch waits for the next batch of a streaming search
+func waitForBatch(stream <-chan searchBatchMsg) tea.Cmd {
+	return func() tea.Msg {
+		batch, ok := <-stream
+		if !ok {
+			return nil
+		}
+		return batch
+	}
+}
+
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	// Start streaming the rest of a large directory loadDirectory opened
+	if nm, ok := next.(model); ok && nm.dirLoad != nil && !nm.dirLoad.started {
+		return nm, tea.Batch(cmd, nm.startDirLoad())
+	}
+	return next, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.viewport.width = msg.Width
 		m.viewport.height = msg.Height - 8 // Reserve space for header, input, and footer
 		return m, nil
 
+	case dirChangedMsg:
+		return m, m.handleDirChanged(msg)
+
 	case progressTickMsg:
 		if m.searching {
 			return m, tea.Tick(time.Millisecond*ProgressUpdateMs, func(t time.Time) tea.Msg {
@@ -288,11 +452,120 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case searchBatchMsg:
+		if m.background != nil && msg.id == m.background.id {
+			return m, m.handleBackgroundBatch(msg)
+		}
+		if i := m.tabFor(msg.id); i >= 0 && msg.id != m.searchID {
+			return m, m.inTab(i, func(m *model) tea.Cmd {
+				if m.background != nil && msg.id == m.background.id {
+					return m.handleBackgroundBatch(msg)
+				}
+				if !m.searching {
+					return nil
+				}
+				m.handleSearchBatch(msg)
+				return waitForBatch(m.resultStream)
+			})
+		}
+		// Ignore batches from cancelled or superseded searches
+		if msg.id != m.searchID || !m.searching {
+			return m, nil
+		}
+		m.handleSearchBatch(msg)
+		return m, waitForBatch(m.resultStream)
+
 	case searchCompleteMsg:
+		if m.background != nil && msg.id == m.background.id {
+			m.handleBackgroundComplete(msg)
+			return m, nil
+		}
+		if i := m.tabFor(msg.id); i >= 0 && msg.id != m.searchID {
+			return m, m.inTab(i, func(m *model) tea.Cmd {
+				if m.background != nil && msg.id == m.background.id {
+					m.handleBackgroundComplete(msg)
+					return nil
+				}
+				m.handleSearchComplete(msg)
+				return m.startQueuedSearch()
+			})
+		}
+		if msg.id != m.searchID {
+			return m, nil
+		}
 		m.handleSearchComplete(msg)
+		return m, m.startQueuedSearch()
+
+	case editorDoneMsg:
+		if m.mode == PreviewMode && m.preview.detail {
+			// Show the edited file as it is now
+			m.openDetail()
+		}
+		if msg.err != nil {
+			m.statusMsg = fmt.Sprintf("Editor: %v", msg.err)
+		} else {
+			m.statusMsg = fmt.Sprintf("Edited %d files", msg.files)
+		}
+		return m, nil
+
+	case retryCompleteMsg:
+		if i := m.tabFor(msg.id); i >= 0 && msg.id != m.searchID {
+			return m, m.inTab(i, func(m *model) tea.Cmd {
+				m.handleRetryComplete(msg)
+				return nil
+			})
+		}
+		// Results replaced by a new search don't take them
+		if msg.id == m.searchID {
+			m.handleRetryComplete(msg)
+		}
+		return m, nil
+
+	case dirBatchMsg:
+		return m, m.handleDirBatch(msg)
+
+	case liveTickMsg:
+		return m, m.startLive(msg)
+
+	case liveCountMsg:
+		m.handleLiveCount(msg)
+		return m, nil
+
+	case jumpTickMsg:
+		return m, m.handleJumpTick(msg)
+
+	case fileOpTickMsg:
+		return m, m.handleFileOpTick(msg)
+
+	case fileOpDoneMsg:
+		m.handleFileOpDone(msg)
 		return m, nil
 
 	case tea.KeyMsg:
+		if m.prompt != nil {
+			return m.updatePrompt(msg)
+		}
+		if !m.pickingPreset && !m.pickingBookmark && !m.pickingTheme && m.jumper == nil {
+			// Text typed into the pattern keeps its keys
+			if m.mode != SearchInputMode {
+				msg = boundKey(msg)
+			}
+			switch msg.String() {
+			case "ctrl+n":
+				m.newTab()
+				return m, nil
+			case "tab": // Ctrl+Tab reaches us as Tab in most terminals
+				m.switchTab(1)
+				return m, nil
+			case "shift+tab":
+				m.switchTab(-1)
+				return m, nil
+			case "ctrl+w":
+				m.closeTab()
+				return m, nil
+			}
+		}
+
 		switch m.mode {
 		case FileBrowserMode:
 			return m.updateFileBrowser(msg)
@@ -306,29 +579,127 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updateConfigMode(msg)
 		case AnalysisMode:
 			return m.updateAnalysisMode(msg)
+		case PreviewMode:
+			return m.updatePreview(msg)
 		}
 	}
 
 	return m, nil
 }
 
+func (m model) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "esc":
+		m.prompt = nil
+		m.statusMsg = "Cancelled"
+
+	case "enter":
+		p := m.prompt
+		m.prompt = nil
+		value := p.input
+		if !p.raw {
+			value = strings.TrimSpace(value)
+		}
+		if p.onRun != nil {
+			return m, p.onRun(&m, value)
+		}
+		p.onSubmit(&m, value)
+
+	default:
+		p := *m.prompt
+		p.edit(msg)
+		m.prompt = &p
+	}
+	return m, nil
+}
+
+// edit applies a cursor movement or text change to the prompt input
+func (p *inputPrompt) edit(msg tea.KeyMsg) {
+	runes := []rune(p.input)
+	p.cursor = max(0, min(p.cursor, len(runes)))
+
+	switch msg.Type {
+	case tea.KeyLeft:
+		p.cursor = max(0, p.cursor-1)
+	case tea.KeyRight:
+		p.cursor = min(len(runes), p.cursor+1)
+	case tea.KeyHome, tea.KeyCtrlA:
+		p.cursor = 0
+	case tea.KeyEnd, tea.KeyCtrlE:
+		p.cursor = len(runes)
+	case tea.KeyBackspace:
+		if p.cursor > 0 {
+			runes = append(runes[:p.cursor-1], runes[p.cursor:]...)
+			p.cursor--
+		}
+	case tea.KeyDelete:
+		if p.cursor < len(runes) {
+			runes = append(runes[:p.cursor], runes[p.cursor+1:]...)
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		insert := msg.Runes
+		if msg.Type == tea.KeySpace {
+			insert = []rune{' '}
+		}
+		runes = append(runes[:p.cursor], append(insert, runes[p.cursor:]...)...)
+		p.cursor += len(insert)
+	}
+	p.input = string(runes)
+}
+
+// render draws the prompt with a block cursor at the cursor position
+func (p *inputPrompt) render() string {
+	runes := []rune(p.input)
+	cursor := max(0, min(p.cursor, len(runes)))
+	if cursor == len(runes) {
+		return p.label + p.input + "█"
+	}
+	under := lipgloss.NewStyle().Reverse(true).Render(string(runes[cursor]))
+	return p.label + string(runes[:cursor]) + under + string(runes[cursor+1:])
+}
+
 func (m model) updateFileBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.pickingBookmark {
+		m.updateBookmarkPicker(msg.String())
+		return m, nil
+	}
+	if m.jumper != nil {
+		m.updateJumper(msg)
+		return m, nil
+	}
+	if m.treeView && m.updateTree(msg.String()) {
+		return m, nil
+	}
+	// @ and a digit rerun a saved search, leaving digits free for counts
+	if m.savedPrefix {
+		m.savedPrefix = false
+		if key := msg.String(); len(key) == 1 && key >= "1" && key <= "9" {
+			return m, m.runSavedSearch(int(key[0] - '0'))
+		}
+		m.statusMsg = ""
+	}
+
+	// A count such as the 10 of 10j repeats the movement that follows
+	count, digit := m.takeCount(msg.String())
+	if digit {
+		return m, nil
+	}
+	if delta, ok := m.pageMove(msg.String()); ok {
+		m.moveCursor(delta * count)
+		return m, nil
+	}
+
 	switch msg.String() {
 	case "ctrl+c", "q":
 		m.quitting = true
+		m.keepCheckpoints()
 		return m, tea.Quit
 
 	case "up", "k":
-		if m.selectedFile > 0 {
-			m.selectedFile--
-			m.adjustViewport()
-		}
+		m.moveCursor(-count)
 
 	case "down", "j":
-		if m.selectedFile < len(m.files)-1 {
-			m.selectedFile++
-			m.adjustViewport()
-		}
+		m.moveCursor(count)
 
 	case "enter":
 		if len(m.files) > 0 {
@@ -345,7 +716,8 @@ func (m model) updateFileBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.loadDirectory()
 			} else {
 				// Toggle file selection
-				m.files[m.selectedFile].Selected = !m.files[m.selectedFile].Selected
+				m.pushUndo(fmt.Sprintf("toggle %s", selected.Name))
+				m.setSelected(m.selectedFile, !m.files[m.selectedFile].Selected)
 				m.statusMsg = fmt.Sprintf("Toggled selection: %s", selected.Name)
 			}
 		}
@@ -355,7 +727,8 @@ func (m model) updateFileBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			selected := m.files[m.selectedFile]
 			if selected.Name != ".." {
 				// Toggle selection for both files and directories (except parent)
-				m.files[m.selectedFile].Selected = !m.files[m.selectedFile].Selected
+				m.pushUndo(fmt.Sprintf("toggle %s", selected.Name))
+				m.setSelected(m.selectedFile, !m.files[m.selectedFile].Selected)
 				if selected.IsDir {
 					m.statusMsg = fmt.Sprintf("Toggled directory selection: %s", selected.Name)
 				} else {
@@ -369,7 +742,8 @@ func (m model) updateFileBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if len(m.files) > 0 {
 			selected := m.files[m.selectedFile]
 			if selected.IsDir && selected.Name != ".." {
-				m.files[m.selectedFile].Selected = !m.files[m.selectedFile].Selected
+				m.pushUndo(fmt.Sprintf("toggle %s", selected.Name))
+				m.setSelected(m.selectedFile, !m.files[m.selectedFile].Selected)
 				m.statusMsg = fmt.Sprintf("Toggled directory selection: %s", selected.Name)
 			}
 		}
@@ -379,12 +753,64 @@ func (m model) updateFileBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.searchInput = ""
 		m.statusMsg = "Enter search pattern..."
 
+	case "w":
+		// Build the search scope step by step
+		m.startScopeWizard()
+
+	case "v":
+		// Preview the file under the cursor
+		m.previewSelectedFile()
+
+	case "@":
+		// Rerun a saved search with the digit that follows
+		if len(m.savedSearches) == 0 {
+			m.statusMsg = "No saved searches (S saves one from the results)"
+			break
+		}
+		m.savedPrefix = true
+		m.statusMsg = "Saved search: press 1"
+		if n := min(len(m.savedSearches), 9); n > 1 {
+			m.statusMsg = fmt.Sprintf("Saved search: press 1-%d", n)
+		}
+
+	case ".":
+		// Repeat the last search
+		return m, m.rerunLastSearch()
+
+	case "alt+1", "alt+2", "alt+3", "alt+4", "alt+5", "alt+6", "alt+7", "alt+8", "alt+9":
+		// Jump to a bookmarked directory
+		m.jumpToBookmark(int(msg.String()[4] - '0'))
+
+	case "ctrl+p":
+		return m, m.openJumper()
+
+	case "t":
+		// Switch between the listing and the tree view
+		m.toggleTreeView()
+
+	case "o":
+		// Sort by the next column
+		m.cycleSort(false)
+
+	case "O":
+		// Reverse the sort
+		m.cycleSort(true)
+
+	case "b":
+		// Bookmark the current directory
+		m.toggleBookmark()
+
+	case "B":
+		// List the bookmarks
+		m.openBookmarkPicker()
+
 	case "a":
 		// Select all files and directories (except parent)
+		m.pushUndo("select all")
 		count := 0
 		for i := range m.files {
 			if m.files[i].Name != ".." {
-				m.files[i].Selected = true
+				m.setSelected(i, true)
 				count++
 			}
 		}
@@ -392,10 +818,11 @@ func (m model) updateFileBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case "f":
 		// Select all files only
+		m.pushUndo("select all files")
 		count := 0
 		for i := range m.files {
 			if !m.files[i].IsDir {
-				m.files[i].Selected = true
+				m.setSelected(i, true)
 				count++
 			}
 		}
@@ -406,7 +833,8 @@ func (m model) updateFileBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if len(m.files) > 0 {
 			selected := m.files[m.selectedFile]
 			if selected.IsDir && selected.Name != ".." {
-				m.files[m.selectedFile].Selected = !m.files[m.selectedFile].Selected
+				m.pushUndo(fmt.Sprintf("toggle %s", selected.Name))
+				m.setSelected(m.selectedFile, !m.files[m.selectedFile].Selected)
 				if m.files[m.selectedFile].Selected {
 					m.statusMsg = fmt.Sprintf("Selected directory: %s", selected.Name)
 				} else {
@@ -418,11 +846,9 @@ func (m model) updateFileBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 
 	case "A":
-		// Deselect all
-		for i := range m.files {
-			m.files[i].Selected = false
-		}
-		m.statusMsg = "Deselected all files"
+		// Deselect all, in every directory
+		m.pushUndo("deselect all")
+		m.statusMsg = fmt.Sprintf("Deselected %d items", m.clearSelection())
 
 	case "c":
 		// Configuration mode
@@ -435,8 +861,24 @@ func (m model) updateFileBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		analysis := m.analyzeFolderStructure(targets)
 		m.showFolderAnalysis(analysis)
 
+	case "I":
+		// Import a result bundle
+		m.promptImportBundle()
+
+	case "ctrl+r":
+		m.refreshDirectory()
+
 	case "r":
-		m.loadDirectory()
+		m.promptRename()
+
+	case "C":
+		m.promptFileOp(false)
+
+	case "M":
+		m.promptFileOp(true)
+
+	case "esc":
+		m.stopFileOp()
 
 	case "h", "?":
 		m.showHelp = !m.showHelp
@@ -449,77 +891,328 @@ func (m model) updateFileBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.selectedFile = len(m.files) - 1
 		m.adjustViewport()
 
-	case "ctrl+d":
+	case "F":
 		// Select all directories only (except parent)
+		m.pushUndo("select all directories")
 		count := 0
 		for i := range m.files {
 			if m.files[i].IsDir && m.files[i].Name != ".." {
-				m.files[i].Selected = true
+				m.setSelected(i, true)
 				count++
 			}
 		}
 		m.statusMsg = fmt.Sprintf("Selected %d directories", count)
+
+	case "ctrl+z":
+		m.undo()
+
+	case "D":
+		m.confirmTrash()
+
+	case "U":
+		m.restoreLastTrash()
 	}
 
 	return m, nil
 }
 
 func (m model) updateSearchInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := m.liveKey()
+	if m.pickingPreset {
+		m.updatePresetPicker(msg.String())
+		return m, m.scheduleLive(key)
+	}
+
 	switch msg.String() {
 	case "ctrl+c", "esc":
+		m.stopLive()
+		m.recallHistory(-m.historyPos)
+		m.skipNext = nil
+		m.runningAction = runningCancel
 		m.mode = FileBrowserMode
 		m.statusMsg = "Search cancelled"
 
 	case "enter":
+		if params := templateParams(m.searchInput); len(params) > 0 {
+			// Ask for the placeholders' values first
+			m.stopLive()
+			m.promptTemplate(historyEntry{Template: m.searchInput, Targets: m.recalledTargets, Config: m.searchConfig})
+			return m, nil
+		}
 		if m.searchInput != "" {
+			m.stopLive()
+			if ck := m.resumableCheckpoint(); ck != nil {
+				m.promptResume(ck)
+				return m, nil
+			}
 			return m, m.performSearch()
 		}
 
+	case "up":
+		// Recall an older search
+		m.recallHistory(1)
+
+	case "down":
+		// Recall a newer search
+		m.recallHistory(-1)
+
+	case "ctrl+t":
+		// Time-boxed rough search with aggressive limits
+		if m.searchInput != "" {
+			m.stopLive()
+			return m, m.performQuickSearch()
+		}
+
 	case "backspace":
 		if len(m.searchInput) > 0 {
 			m.searchInput = m.searchInput[:len(m.searchInput)-1]
 		}
 
+	case "ctrl+v":
+		m.pushUndo("toggle inverted match")
+		m.searchConfig.InvertMatch = !m.searchConfig.InvertMatch
+		if m.searchConfig.InvertMatch {
+			m.statusMsg = "Inverted match: showing lines NOT matching the pattern"
+		} else {
+			m.statusMsg = "Normal match: showing lines matching the pattern"
+		}
+
+	case "ctrl+a":
+		// Anchor the terms at the whole line, its start or its end
+		m.cycleAnchor()
+
+	case "ctrl+p":
+		// Pick a named pattern from the library
+		m.pickingPreset = true
+		m.statusMsg = "Choose a pattern preset..."
+
+	case "ctrl+f":
+		m.pushUndo("toggle file-level matching")
+		m.searchConfig.FileLevelMatch = !m.searchConfig.FileLevelMatch
+		if m.searchConfig.FileLevelMatch {
+			m.statusMsg = "File-level matching: expression is evaluated over whole files"
+		} else {
+			m.statusMsg = "Line-level matching: expression is evaluated per line"
+		}
+
 	default:
 		if len(msg.String()) == 1 {
 			m.searchInput += msg.String()
 		}
 	}
 
-	return m, nil
+	return m, m.scheduleLive(key)
 }
 
 func (m model) updateSearchResults(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// A count such as the 10 of 10j repeats the movement that follows
+	count, digit := m.takeCount(msg.String())
+	if digit {
+		return m, nil
+	}
+	if delta, ok := m.pageMove(msg.String()); ok {
+		m.moveCursor(delta * count)
+		return m, nil
+	}
+
 	switch msg.String() {
 	case "ctrl+c", "q", "esc":
+		// The first press stops a running search, keeping partial results
+		if m.cancelSearch() {
+			m.statusMsg = fmt.Sprintf("Search stopped: showing %d partial results", len(m.searchResults.Results))
+			return m, nil
+		}
 		m.mode = FileBrowserMode
 		m.statusMsg = "Returned to file browser"
 
 	case "up", "k":
-		if m.resultIndex > 0 {
-			m.resultIndex--
-			m.adjustViewport()
-		}
+		m.moveCursor(-count)
 
 	case "down", "j":
-		if m.resultIndex < len(m.searchResults.Results)-1 {
-			m.resultIndex++
-			m.adjustViewport()
-		}
+		m.moveCursor(count)
 
 	case "home", "g":
 		m.resultIndex = 0
 		m.viewport.offset = 0
 
 	case "end", "G":
-		m.resultIndex = len(m.searchResults.Results) - 1
+		m.resultIndex = m.visibleResults.len() - 1
 		m.adjustViewport()
 
 	case "s", "/":
+		if m.searching {
+			// Ask whether to cancel, background or queue behind it
+			m.promptRunningSearch()
+			break
+		}
 		m.mode = SearchInputMode
 		m.searchInput = ""
 		m.statusMsg = "Enter new search pattern..."
 
+	case "P":
+		// Merge a stopped search's partial results into this one
+		m.mergePartial()
+
+	case "J":
+		// Switch to the background search's results
+		m.swapBackground()
+
+	case ".":
+		// Repeat the last search, picking up edits made since
+		return m, m.rerunLastSearch()
+
+	case "T":
+		// Progress of the search, per selected target to stop a slow one
+		m.showTargets()
+
+	case "m":
+		m.promptThreshold("Only files with at least N matches (empty to clear): ", func(f *ResultFilter, n int) {
+			f.MinFileMatches = n
+		})
+
+	case "M":
+		m.promptThreshold("Only lines with at least N occurrences (empty to clear): ", func(f *ResultFilter, n int) {
+			f.MinLineMatches = n
+		})
+
+	case "l":
+		// Cycle matches → files with matches → match counts
+		m.pushUndo("change result aggregation")
+		m.resultFilter.Aggregate = (m.resultFilter.Aggregate + 1) % 3
+		m.applyResultFilters()
+		m.statusMsg = fmt.Sprintf("Showing %s (%d rows)", m.resultFilter.Aggregate, m.visibleResults.len())
+
+	case "p":
+		// Cycle all → first → last match per file
+		m.pushUndo("change per-file presentation")
+		m.resultFilter.PerFile = (m.resultFilter.PerFile + 1) % 3
+		m.applyResultFilters()
+		m.statusMsg = fmt.Sprintf("Showing %s (%d results)", m.resultFilter.PerFile, m.visibleResults.len())
+
+	case "enter":
+		// The selected result with the lines around it
+		m.openDetail()
+
+	case "e":
+		// Edit the selected line in place
+		m.editSelectedResult()
+
+	case "n":
+		// Attach a note to the selected line
+		m.promptNote()
+
+	case "w":
+		// Write the annotated report
+		m.promptReport()
+
+	case "b":
+		// Export a shareable result bundle
+		m.promptExportBundle()
+
+	case "y":
+		// Copy a web permalink to the selected line
+		m.copyPermalink()
+
+	case "c":
+		// Copy the path, location, match or line, or every visible result
+		m.promptCopy()
+
+	case "F":
+		// Rerun a quick search without its limits
+		return m, m.escalateSearch()
+
+	case "S":
+		// Name and save this search
+		m.promptSaveSearch()
+
+	case "v":
+		// Preview the result's file around its line
+		m.previewSelectedResult()
+
+	case "|":
+		// Show or hide the preview pane
+		m.toggleSplit()
+
+	case "<", ">":
+		// Resize the result list against the preview pane
+		step := paneRatioStep
+		if msg.String() == "<" {
+			step = -step
+		}
+		m.resizePanes(step)
+
+	case "shift+up", "shift+down":
+		// Scroll the preview pane around the selected line
+		step := 1
+		if msg.String() == "shift+up" {
+			step = -1
+		}
+		m.scrollPane(step)
+
+	case "C":
+		// Export the values of the pattern's capture groups
+		m.promptExportCaptures()
+
+	case "o":
+		// Export the visible results in the format of the file name
+		m.promptExportResults()
+
+	case "x":
+		// Toggle definition vs usage summary
+		m.showRefs = !m.showRefs
+		if m.showRefs {
+			m.classifyReferences()
+			m.statusMsg = "Classified matches as definitions or usages"
+		} else {
+			m.statusMsg = "Returned to result list"
+		}
+
+	case "ctrl+z":
+		m.undo()
+
+	case "+", "=":
+		// Read more lines around the selected result
+		m.widenContext(2)
+
+	case "-":
+		m.widenContext(-2)
+
+	case "left", "right":
+		// Scroll the selected line sideways
+		step := 1
+		if msg.String() == "left" {
+			step = -1
+		}
+		m.scrollLine(step)
+
+	case "W":
+		// Wrap long lines instead of clipping them
+		m.toggleWrap()
+
+	case "E":
+		// List or hide the files the search skipped or couldn't read
+		m.toggleFailures()
+
+	case "i":
+		// Show or hide what the search read and how fast
+		m.toggleStats()
+
+	case "r":
+		// Show the result's file in the file browser
+		m.revealResult()
+
+	case " ", "space":
+		// Mark the result for a batch action
+		m.toggleMark()
+
+	case "a":
+		// Act on the marked results
+		m.promptBatch()
+
+	case "R":
+		// Search the skipped files again
+		m.promptRetry()
+
 	case "h", "?":
 		m.showHelp = !m.showHelp
 	}
@@ -529,18 +1222,53 @@ func (m model) updateSearchResults(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 func (m model) updateSearchProgress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
-	case "ctrl+c", "q", "esc":
-		if m.searchCancel != nil {
-			m.searchCancel()
+	case "ctrl+c", "q":
+		// Stop the search and browse what it found so far
+		m.cancelSearch()
+		m.mode = SearchResultsMode
+		m.statusMsg = fmt.Sprintf("Search cancelled: showing %d partial results", len(m.searchResults.Results))
+
+	case "esc", "T":
+		m.mode = SearchResultsMode
+		m.statusMsg = "Returned to results"
+
+	case "up", "k", "down", "j", "enter", "right", "l", "left", "h", "x", "delete":
+		if m.collect.collecting() {
+			// Prune directories while files are being collected
+			m.updateCollectTree(msg.String())
+			return m, nil
 		}
-		m.mode = FileBrowserMode
-		m.searching = false
-		m.statusMsg = "Search cancelled"
+		return m.updateTargets(msg.String())
+	}
+	return m, nil
+}
+
+// updateTargets handles the per-target keys of SearchProgressMode
+func (m model) updateTargets(key string) (tea.Model, tea.Cmd) {
+	switch key {
+	case "up", "k":
+		if m.targetIndex > 0 {
+			m.targetIndex--
+		}
+
+	case "down", "j":
+		if m.targetIndex < len(m.searchResults.Progress.Targets)-1 {
+			m.targetIndex++
+		}
+
+	case "x", "delete":
+		// Stop one target, letting the others finish
+		m.stopTarget()
 	}
 	return m, nil
 }
 
 func (m model) updateConfigMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.pickingTheme {
+		m.updateThemePicker(msg.String())
+		return m, nil
+	}
+
 	switch msg.String() {
 	case "ctrl+c", "q", "esc":
 		m.mode = FileBrowserMode
@@ -548,6 +1276,7 @@ func (m model) updateConfigMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case "1":
 		// Toggle max file size
+		m.pushUndo("change max file size")
 		if m.searchConfig.MaxFileSize == MaxFileSize {
 			m.searchConfig.MaxFileSize = 1 << 30 // 1GB
 			m.statusMsg = "Max file size set to 1GB"
@@ -558,6 +1287,7 @@ func (m model) updateConfigMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case "2":
 		// Adjust max results
+		m.pushUndo("change max results")
 		if m.searchConfig.MaxResults == MaxResultsInMemory {
 			m.searchConfig.MaxResults = 50000
 			m.statusMsg = "Max results set to 50,000"
@@ -568,6 +1298,7 @@ func (m model) updateConfigMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case "3":
 		// Adjust concurrency
+		m.pushUndo("change concurrency")
 		maxCPU := runtime.NumCPU()
 		if m.searchConfig.MaxConcurrency == MaxConcurrentFiles {
 			m.searchConfig.MaxConcurrency = maxCPU * 2
@@ -577,6 +1308,115 @@ func (m model) updateConfigMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.statusMsg = fmt.Sprintf("Concurrency set to %d (default)", MaxConcurrentFiles)
 		}
 
+	case "4":
+		// Toggle git-ignored files; .ignore and .zxignore still apply
+		m.pushUndo("toggle git-ignored files")
+		m.searchConfig.IncludeGitignored = !m.searchConfig.IncludeGitignored
+		if m.searchConfig.IncludeGitignored {
+			m.statusMsg = "Including git-ignored files (.ignore and .zxignore still apply)"
+		} else {
+			m.statusMsg = "Respecting .gitignore, .ignore and .zxignore files"
+		}
+
+	case "5":
+		// Toggle git stash search
+		m.pushUndo("toggle stash search")
+		m.searchConfig.SearchStashes = !m.searchConfig.SearchStashes
+		if m.searchConfig.SearchStashes {
+			m.statusMsg = "Searching git stash contents"
+		} else {
+			m.statusMsg = "Not searching git stashes"
+		}
+
+	case "6":
+		// Pick a theme
+		m.openThemePicker()
+
+	case "7":
+		// Cycle match highlight style
+		m.matchModeIndex = (m.matchModeIndex + 1) % len(matchModes)
+		m.applyThemeSelection()
+		m.statusMsg = fmt.Sprintf("Match highlight set to %s", matchModes[m.matchModeIndex])
+
+	case "8":
+		// Cycle icon theme
+		m.iconIndex = (m.iconIndex + 1) % len(iconThemes)
+		icons = iconThemes[m.iconIndex]
+		m.statusMsg = fmt.Sprintf("Icon theme set to %s", icons.Name)
+
+	case "9":
+		// Cycle max recursion depth
+		m.pushUndo("change max depth")
+		m.searchConfig.MaxDepth = nextDepth(m.searchConfig.MaxDepth)
+		m.statusMsg = fmt.Sprintf("Max depth set to %s", depthLabel(m.searchConfig.MaxDepth))
+
+	case "x":
+		// Edit the extensions searched as text without sniffing
+		m.promptExtensions(true)
+
+	case "b":
+		// Edit the extensions skipped as binary without reading
+		m.promptExtensions(false)
+
+	case "d":
+		// Toggle pruning node_modules, .git and the other skip_dirs
+		m.pushUndo("toggle skipped directories")
+		m.searchConfig.NoSkipDirs = !m.searchConfig.NoSkipDirs
+		if m.searchConfig.NoSkipDirs {
+			m.statusMsg = "Searching " + strings.Join(m.searchConfig.SkipDirs, ", ") + " too"
+		} else {
+			m.statusMsg = "Skipping " + strings.Join(m.searchConfig.SkipDirs, ", ")
+		}
+
+	case "0":
+		// Toggle searching hidden files
+		m.pushUndo("toggle hidden files")
+		m.searchConfig.IncludeHidden = !m.searchConfig.IncludeHidden
+		if m.searchConfig.IncludeHidden {
+			m.statusMsg = "Including hidden files (dotfiles)"
+		} else {
+			m.statusMsg = "Skipping hidden files (dotfiles)"
+		}
+
+	case "f":
+		// Cycle the per-file result cap
+		m.pushUndo("change per-file cap")
+		m.searchConfig.MaxPerFile = nextPerFileCap(m.searchConfig.MaxPerFile)
+		m.statusMsg = fmt.Sprintf("Results per file: %s", perFileCapLabel(m.searchConfig.MaxPerFile))
+
+	case "m":
+		// Toggle searching minified assets
+		m.pushUndo("toggle minified files")
+		m.searchConfig.IncludeMinified = !m.searchConfig.IncludeMinified
+		if m.searchConfig.IncludeMinified {
+			m.statusMsg = "Including minified and generated assets"
+		} else {
+			m.statusMsg = "Skipping minified and generated assets"
+		}
+
+	case "s":
+		// Cycle the suggestions shown when nothing matches
+		m.pushUndo("change suggestions")
+		m.searchConfig.Suggest = m.searchConfig.Suggest.nextMode()
+		m.statusMsg = fmt.Sprintf("Suggestions: %s", m.searchConfig.Suggest.describe())
+
+	case "z":
+		// Set the file size range
+		m.promptSizeRange()
+
+	case "t":
+		// Set the modification time window
+		m.promptModifiedWithin()
+
+	case "n":
+		// Cycle when network safe mode applies
+		m.pushUndo("change network mode")
+		m.searchConfig.Network = (m.searchConfig.Network + 1) % 3
+		m.statusMsg = fmt.Sprintf("Network safe mode: %s", networkLabel(m.searchConfig.Network))
+
+	case "ctrl+z":
+		m.undo()
+
 	case "h", "?":
 		m.showHelp = !m.showHelp
 	}
@@ -614,209 +1454,595 @@ func (m *model) adjustViewport() {
 }
 
 func (m *model) performSearch() tea.Cmd {
+	saved := m.savedRun
+	m.savedRun = ""
+	m.searchConfig.SkipFiles = m.skipNext
+	m.skipNext = nil
+
+	// A search still running is stopped unless the user chose to keep it
+	action := m.runningAction
+	m.runningAction = runningCancel
+	if m.searching {
+		switch action {
+		case runningQueue:
+			m.queueSearch()
+			return nil
+		case runningBackground:
+			m.detachSearch()
+		}
+	}
+	m.cancelSearch()
+	m.keepPartial()
+
+	m.restoreQuickLimits()
+	quick := m.quickSearch
+	m.quickSearch = false
 	m.searching = true
-	m.mode = SearchProgressMode
 	m.statusMsg = "Analyzing folder structure..."
 
-	// Get selected files and directories
-	var targets []string
-	selectedCount := 0
-	fileCount := 0
-	dirCount := 0
-
-	for _, file := range m.files {
-		if file.Selected && file.Name != ".." {
-			targets = append(targets, file.Path)
-			selectedCount++
-			if file.IsDir {
-				dirCount++
-			} else {
-				fileCount++
-			}
-		}
-	}
+	targets, selectedCount, fileCount, dirCount := m.searchTargets()
+	m.recordSearch(targets)
 
-	// If no files or directories selected, search current directory
-	if selectedCount == 0 {
-		targets = append(targets, m.currentDir)
+	// Checkpoint long searches, picking up where an earlier run stopped
+	m.checkpoint = nil
+	if !quick {
+		m.checkpoint = newCheckpointer(checkpointKey(m.searchInput, targets, m.searchConfig), m.searchInput, targets, m.resumeFrom)
 	}
+	m.resumeFrom = nil
 
 	// Analyze folder structure and apply dynamic configuration
 	analysis := m.analyzeFolderStructure(targets)
 	m.applyDynamicConfig(analysis)
+	if quick {
+		m.applyQuickLimits()
+	}
+	m.searchConfig.resolveNetwork(targets)
 
-	// Create context for cancellation
+	// Create context for cancellation; a quick search also stops at its budget
 	ctx, cancel := context.WithCancel(context.Background())
+	if quick {
+		ctx, cancel = context.WithTimeout(context.Background(), m.searchConfig.networkBudget(quickSearchBudget))
+	}
 	m.searchCancel = cancel
+	m.tracker = newTargetTracker(ctx, targets)
+	m.targetIndex = 0
+	m.collect = newCollectTree(targets)
+	m.collectIndex = 0
+
+	// Show the results view right away; it fills in as batches arrive
+	id := m.nextSearchID()
+	m.searchID = id
+	stream := make(chan searchBatchMsg, 16)
+	m.resultStream = stream
+	m.searchConfig.Query, _ = compileQuery(m.searchInput)
+	m.searchResults = SearchResults{
+		Pattern:  m.searchInput,
+		Target:   strings.Join(targets, ", "),
+		Inverted: m.searchConfig.InvertMatch,
+		Progress: SearchProgress{StartTime: time.Now()},
+		Quick:    quick,
+		Saved:    saved,
+	}
+	m.resultIndex = 0
+	m.viewport.offset = 0
+	m.showRefs = false
+	m.marked = nil
+	m.mode = SearchResultsMode
+	m.applyResultFilters()
+	m.statusMsg = "Searching..."
 
 	// Return command that will perform search and send completion message
-	return func() tea.Msg {
-		results := m.performLargeSearchSync(ctx, targets, fileCount, dirCount, selectedCount, analysis)
+	search := func() tea.Msg {
+		defer close(stream)
+		emit := func(batch []SearchResult, progress SearchProgress) {
+			select {
+			case stream <- searchBatchMsg{id: id, results: batch, progress: progress}:
+			case <-ctx.Done():
+			}
+		}
+		results := m.performLargeSearchSync(ctx, targets, fileCount, dirCount, selectedCount, analysis, emit)
+		results.Quick = quick
+		results.Saved = saved
+		if ctx.Err() == context.DeadlineExceeded {
+			results.Progress.Cancelled = true
+		}
 		return searchCompleteMsg{
+			id:            id,
 			results:       results,
 			selectedCount: selectedCount,
 			fileCount:     fileCount,
 			dirCount:      dirCount,
 		}
 	}
+	return tea.Batch(search, waitForBatch(stream))
 }
 
-func (m *model) performLargeSearchSync(ctx context.Context, targets []string, fileCount, dirCount, selectedCount int, analysis FolderAnalysis) SearchResults {
+// searchTargets returns what the next search covers: a recalled search's
+// targets, the selection, or else the wizard's roots or the current
+// directory, with counts of the selected files and directories
+func (m *model) searchTargets() (targets []string, selectedCount, fileCount, dirCount int) {
+	if len(m.recalledTargets) > 0 {
+		for _, target := range m.recalledTargets {
+			targets = append(targets, target)
+			selectedCount++
+			if info, err := os.Stat(target); err == nil && info.IsDir() {
+				dirCount++
+			} else {
+				fileCount++
+			}
+		}
+		m.recalledTargets = nil
+	} else {
+		var dirs []string
+		for _, file := range m.selectedItems() {
+			// Entries inside a selected directory can be selected too
+			if !underAny(file.Path, dirs) {
+				if file.IsDir {
+					dirs = append(dirs, file.Path)
+				}
+				targets = append(targets, file.Path)
+				selectedCount++
+				if file.IsDir {
+					dirCount++
+				} else {
+					fileCount++
+				}
+			}
+		}
+	}
+
+	// If no files or directories selected, search the wizard's roots or the
+	// current directory
+	if selectedCount == 0 {
+		if len(m.scopeTargets) > 0 {
+			targets = append(targets, m.scopeTargets...)
+		} else {
+			targets = append(targets, m.currentDir)
+		}
+	}
+	return targets, selectedCount, fileCount, dirCount
+}
+
+// cancelSearch stops the running search, if any, keeping the results found
+// so far. Messages still in flight from it are ignored.
+func (m *model) cancelSearch() bool {
+	if !m.searching {
+		return false
+	}
+	if m.searchCancel != nil {
+		m.searchCancel()
+		m.searchCancel = nil
+	}
+	m.searchID = m.nextSearchID()
+	m.searching = false
+	m.searchResults.Progress.Cancelled = true
+	m.searchResults.SearchTime = time.Since(m.searchResults.Progress.StartTime)
+	return true
+}
+
+// performLargeSearchSync searches targets in parallel. If emit is non-nil it
+// is called periodically with the results accepted since the last call and
+// a progress snapshot.
+func (m *model) performLargeSearchSync(ctx context.Context, targets []string, fileCount, dirCount, selectedCount int, analysis FolderAnalysis, emit func([]SearchResult, SearchProgress)) SearchResults {
 	startTime := time.Now()
 
 	results := SearchResults{
-		Pattern: m.searchInput,
-		Target:  strings.Join(targets, ", "),
+		Pattern:     m.searchInput,
+		Target:      strings.Join(targets, ", "),
+		Inverted:    m.searchConfig.InvertMatch,
+		NetworkSafe: m.searchConfig.NetworkSafe,
 		Progress: SearchProgress{
 			StartTime: startTime,
 		},
 	}
 
 	// Validate pattern
-	re, err := regexp.Compile(m.searchInput)
+	query, err := compileQuery(m.searchInput)
 	if err != nil {
 		results.Errors = append(results.Errors, fmt.Sprintf("Invalid regex pattern: %s", err))
 		results.SearchTime = time.Since(startTime)
 		return results
 	}
+	m.searchConfig.Query = query
 
-	// Collect all files to search
-	var allFiles []string
-	var totalSize int64
-
-	for _, target := range targets {
-		if fileInfo, err := os.Stat(target); err == nil {
-			if fileInfo.IsDir() {
-				files, size := m.collectFilesFromDir(ctx, target)
-				allFiles = append(allFiles, files...)
-				totalSize += size
-			} else {
-				if m.shouldSearchFile(target, fileInfo) {
-					allFiles = append(allFiles, target)
-					totalSize += fileInfo.Size()
-				}
-			}
-		}
+	// Account for each target on its own, so one can be stopped
+	tracker := m.tracker
+	if tracker == nil {
+		tracker = newTargetTracker(ctx, targets)
 	}
 
-	results.Progress.TotalFiles = int64(len(allFiles))
-	results.Progress.TotalSize = totalSize
-	results.TotalFiles = len(allFiles)
-
-	// If no files to search, return early
-	if len(allFiles) == 0 {
-		results.Errors = append(results.Errors, "No searchable files found (all files may be binary, hidden, or too large)")
-		results.SearchTime = time.Since(startTime)
-		return results
-	}
+	// Counted by the workers, for the progress streamed with each batch
+	meter := newProgressMeter(startTime)
+	startCPU := processCPUTime()
 
 	// Parallel search with worker pool
 	resultsChan := make(chan SearchResult, 1000)
-	errorsChan := make(chan string, 100)
+	workersDone := make(chan struct{})
 
-	// Worker pool
+	// Files that couldn't be read, from the workers
+	var failedMu sync.Mutex
+	var failed []failedFile
+
+	// A fixed pool of workers takes the files from a queue per lane, so a
+	// giant tree doesn't start a goroutine per file. Files waiting on a
+	// rule's worker limit only hold their own lane's workers, and the
+	// semaphore keeps the files searched at once within MaxConcurrency.
 	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, m.searchConfig.MaxConcurrency)
+	laneWorkers, ruleLane := m.searchConfig.workerLanes()
+	semaphore := make(chan struct{}, laneWorkers[0])
+	lanes := make([]chan string, len(laneWorkers))
+	for i, n := range laneWorkers {
+		lanes[i] = make(chan string, n)
+	}
 
-	// Progress tracking
-	var processedFiles int64
-	var processedSize int64
+	// Collect results
+	var allResults resultPages
+
+	// Results not yet streamed to the UI
+	var pending []SearchResult
+
+	// Take the files a checkpoint has results for instead of searching them
+	ck := m.checkpoint
+	var skip map[string]bool
+	priorMatches := make(map[string]int64)
+	if ck != nil {
+		var prior []SearchResult
+		skip, prior = ck.prepare()
+		if len(prior) > m.searchConfig.MaxResults {
+			prior = prior[:m.searchConfig.MaxResults]
+			results.Truncated = true
+		}
+		allResults.add(prior...)
+		pending = append(pending, prior...)
+		atomic.AddInt64(&meter.matches, int64(totalMatches(prior)))
+		for _, r := range prior {
+			priorMatches[r.FilePath] += int64(r.matchCount() + r.MoreMatches)
+		}
+	}
 
-	// Start workers
-	for _, filePath := range allFiles {
-		select {
-		case <-ctx.Done():
-			results.Progress.Cancelled = true
-			break
-		default:
+	// search searches one file and sends its results
+	tree := m.collect
+	search := func(path string) {
+		semaphore <- struct{}{}        // Acquire
+		defer func() { <-semaphore }() // Release
+
+		meter.begin(path)
+		defer meter.end()
+
+		// Skip the rest of a stopped target, and files queued before
+		// their directory was pruned
+		fileCtx := ctx
+		target := tracker.of(path)
+		if target != nil {
+			defer atomic.AddInt64(&target.done, 1)
+			if fileCtx = target.ctx; fileCtx.Err() != nil {
+				meter.passed(false)
+				return
+			}
+			if tree != nil && tree.isPruned(target.root, filepath.Dir(path)) {
+				meter.passed(false)
+				return
+			}
 		}
 
-		wg.Add(1)
-		go func(path string) {
-			defer wg.Done()
+		// Note the file's size and time before reading it, so a checkpoint
+		// doesn't keep results from before a change made mid-search
+		var before os.FileInfo
+		if ck != nil {
+			before, _ = os.Stat(path)
+		}
 
-			semaphore <- struct{}{}        // Acquire
-			defer func() { <-semaphore }() // Release
+		// Search file
+		fileResults, fileSize, err := m.searchFileOptimized(fileCtx, path)
+		if target != nil && err == nil {
+			atomic.AddInt64(&target.matches, int64(totalMatches(fileResults)))
+		}
+		if err != nil {
+			failedMu.Lock()
+			failed = append(failed, failureOf(path, err))
+			failedMu.Unlock()
+			meter.passed(true)
+			return
+		}
 
-			// Update progress
-			atomic.AddInt64(&processedFiles, 1)
-			results.Progress.ProcessedFiles = atomic.LoadInt64(&processedFiles)
-			results.Progress.CurrentFile = filepath.Base(path)
+		meter.searched(fileSize, totalMatches(fileResults))
+		if before != nil && fileCtx.Err() == nil {
+			ck.finished(searchedFile{Path: path, Size: before.Size(), ModTime: before.ModTime()}, fileResults)
+		}
 
-			// Search file
-			fileResults, fileSize, err := m.searchFileOptimized(ctx, re, path)
-			if err != nil {
-				select {
-				case errorsChan <- err.Error():
-				default:
-				}
+		// Send results
+		for _, result := range fileResults {
+			select {
+			case resultsChan <- result:
+			case <-ctx.Done():
 				return
 			}
+		}
+	}
 
-			atomic.AddInt64(&processedSize, fileSize)
-			results.Progress.ProcessedSize = atomic.LoadInt64(&processedSize)
+	// Start workers
+	for i, n := range laneWorkers {
+		for w := 0; w < n; w++ {
+			wg.Add(1)
+			go func(queue <-chan string) {
+				defer wg.Done()
+				for path := range queue {
+					search(path)
+				}
+			}(lanes[i])
+		}
+	}
 
-			// Send results
-			for _, result := range fileResults {
+	// Queue the files until the search is stopped or has all the results
+	// it keeps. Each lane holds the files found for it until its workers
+	// take them, so a full lane doesn't hold up the walk or the others;
+	// the workers finish the files they have and exit once their queue is
+	// closed.
+	feedCtx, stopFeeding := context.WithCancel(ctx)
+	defer stopFeeding()
+	var cancelled int32
+	inboxes := make([]chan string, len(lanes))
+	for i := range lanes {
+		inboxes[i] = make(chan string)
+		wg.Add(1)
+		go func(found <-chan string, queue chan<- string) {
+			defer wg.Done()
+			defer close(queue)
+			var waiting []string
+			for found != nil || len(waiting) > 0 {
+				var next chan<- string
+				var first string
+				if len(waiting) > 0 {
+					next, first = queue, waiting[0]
+				}
 				select {
-				case resultsChan <- result:
-				case <-ctx.Done():
+				case filePath, ok := <-found:
+					if !ok {
+						found = nil
+						continue
+					}
+					waiting = append(waiting, filePath)
+				case next <- first:
+					waiting = waiting[1:]
+				case <-feedCtx.Done():
+					if ctx.Err() != nil {
+						atomic.StoreInt32(&cancelled, 1)
+					}
 					return
 				}
 			}
-		}(filePath)
+		}(inboxes[i], lanes[i])
 	}
 
+	// Walk the targets while the workers search what is found so far, so
+	// matching starts at once; the totals grow as files are found. Only
+	// the walk touches these until it is done.
+	var allFiles []string
+	var walkFailed []failedFile
+	var indexSkipped, resumed int
+	shortlist := m.indexShortlist(targets)
+	walkDone := make(chan struct{})
+	go func() {
+		defer close(walkDone)
+		found := func(root int, filePath string, size int64) {
+			// Skip files a trigram index proves can't match
+			if shortlist.rulesOut(filePath) {
+				indexSkipped++
+				return
+			}
+			target := tracker.add(root, filePath)
+			allFiles = append(allFiles, filePath)
+			meter.found(size)
+			if skip[filePath] {
+				resumed++
+				meter.passed(false)
+				atomic.AddInt64(&target.done, 1)
+				atomic.AddInt64(&target.matches, priorMatches[filePath])
+				return
+			}
+			lane := 0
+			if rule := m.searchConfig.ruleFor(filePath); rule >= 0 {
+				lane = ruleLane[rule]
+			}
+			select {
+			case inboxes[lane] <- filePath:
+			case <-feedCtx.Done():
+			}
+		}
+
+		for i, target := range targets {
+			if feedCtx.Err() != nil {
+				break
+			}
+			var left []failedFile
+			if fileInfo, err := os.Stat(target); err == nil {
+				if fileInfo.IsDir() {
+					left = m.walkFiles(feedCtx, target, func(filePath string, size int64) {
+						found(i, filePath, size)
+					})
+				} else if ok, skipped := m.checkFile(target, fileInfo); ok {
+					found(i, target, fileInfo.Size())
+				} else if skipped != nil {
+					left = append(left, *skipped)
+				}
+			} else if !os.IsNotExist(err) {
+				left = append(left, failureOf(target, err))
+			}
+			walkFailed = append(walkFailed, left...)
+			atomic.AddInt64(&meter.failed, int64(len(left)))
+		}
+		if feedCtx.Err() != nil && ctx.Err() != nil {
+			atomic.StoreInt32(&cancelled, 1)
+		}
+
+		// Leave the files of pruned directories out of the suggestions
+		if tree != nil {
+			allFiles = tree.finish(allFiles)
+		}
+		meter.walked()
+		for _, inbox := range inboxes {
+			close(inbox)
+		}
+	}()
+
 	// Close channels when done
 	go func() {
 		wg.Wait()
 		close(resultsChan)
-		close(errorsChan)
+		close(workersDone)
 	}()
 
-	// Collect results
-	var allResults []SearchResult
+	flush := func() {
+		if emit == nil {
+			return
+		}
+		progress := meter.snapshot()
+		progress.Targets = tracker.snapshot()
+		emit(pending, progress)
+		pending = nil
+	}
+	ticker := time.NewTicker(time.Millisecond * ProgressUpdateMs)
+	defer ticker.Stop()
 
 	// Collect results with memory limit
-	for result := range resultsChan {
-		if len(allResults) < m.searchConfig.MaxResults {
-			allResults = append(allResults, result)
-		} else {
-			results.Truncated = true
-			// Continue draining the channel to prevent goroutine leaks
-			go func() {
-				for range resultsChan {
-					// Drain remaining results
-				}
-			}()
-			break
+collect:
+	for {
+		select {
+		case result, ok := <-resultsChan:
+			if !ok {
+				break collect
+			}
+			if allResults.len() < m.searchConfig.MaxResults {
+				allResults.add(result)
+				pending = append(pending, result)
+			} else {
+				results.Truncated = true
+				stopFeeding()
+				// Continue draining the channel to prevent goroutine leaks
+				go func() {
+					for range resultsChan {
+						// Drain remaining results
+					}
+				}()
+				break collect
+			}
+		case <-ticker.C:
+			flush()
+			if ck != nil {
+				ck.tick()
+			}
 		}
 	}
 
-	// Collect errors
-	for err := range errorsChan {
-		results.Errors = append(results.Errors, err)
+	// Collect the files that couldn't be read once every worker is done,
+	// still reporting progress when the result limit stopped collecting
+wait:
+	for {
+		select {
+		case <-workersDone:
+			break wait
+		case <-ticker.C:
+			flush()
+		}
+	}
+	<-walkDone
+	results.TotalFiles = len(allFiles)
+	results.IndexSkipped = indexSkipped
+	results.Resumed = resumed
+	results.Failed = append(walkFailed, failed...)
+	sortFailures(results.Failed)
+	results.Progress.Cancelled = atomic.LoadInt32(&cancelled) == 1
+
+	// If there were no files to search, say why, unless the walk was stopped
+	// before it found any
+	if len(allFiles) == 0 {
+		if !results.Progress.Cancelled {
+			results.Errors = append(results.Errors, "No searchable files found (all files may be binary, hidden, or too large)")
+		}
+		progress := meter.snapshot()
+		progress.Cancelled = results.Progress.Cancelled
+		progress.Targets = tracker.snapshot()
+		results.Progress = progress
+		results.SearchTime = time.Since(startTime)
+		return results
 	}
 
-	// Sort results
-	sort.Slice(allResults, func(i, j int) bool {
-		if allResults[i].FilePath == allResults[j].FilePath {
-			return allResults[i].LineNumber < allResults[j].LineNumber
+	// Keep a checkpoint of a stopped search; one cut short by the result
+	// limit can't find more by resuming
+	if ck != nil {
+		stopped := ctx.Err() != nil && !results.Truncated
+		if err := ck.finish(stopped); err != nil {
+			results.Errors = append(results.Errors, fmt.Sprintf("Checkpoint: %v", err))
+		} else {
+			results.Checkpointed = stopped && ck.kept()
 		}
-		return allResults[i].FilePath < allResults[j].FilePath
-	})
+	}
 
-	results.Results = allResults
+	// Search stashed versions of files
+	if m.searchConfig.SearchStashes {
+		stashResults, stashErrs := m.searchStashes(ctx, targets)
+		for _, result := range stashResults {
+			if allResults.len() >= m.searchConfig.MaxResults {
+				results.Truncated = true
+				break
+			}
+			allResults.add(result)
+			pending = append(pending, result)
+		}
+		results.Errors = append(results.Errors, stashErrs...)
+	}
+	flush()
+
+	results.Results = allResults.sorted()
+	progress := meter.snapshot()
+	progress.Cancelled = results.Progress.Cancelled
+	progress.Targets = tracker.snapshot()
+	results.Progress = progress
+	results.BytesRead = progress.ProcessedSize
+	results.PeakWorkers = int(atomic.LoadInt64(&meter.peak))
+	if !results.Truncated {
+		addSuggestions(ctx, &results, allFiles, m.searchConfig)
+	}
 	results.SearchTime = time.Since(startTime)
+	if cpu := processCPUTime(); cpu > 0 {
+		results.CPUTime = cpu - startCPU
+	}
 
 	return results
 }
 
-func (m *model) collectFilesFromDir(ctx context.Context, dirPath string) ([]string, int64) {
+func (m *model) collectFilesFromDir(ctx context.Context, dirPath string) ([]string, int64, []failedFile) {
 	var files []string
 	var totalSize int64
+	failed := m.walkFiles(ctx, dirPath, func(path string, size int64) {
+		files = append(files, path)
+		totalSize += size
+	})
+	return files, totalSize, failed
+}
+
+// walkFiles calls found with each file of dirPath to search as the walk
+// comes to it, returning the files left out
+func (m *model) walkFiles(ctx context.Context, dirPath string, found func(path string, size int64)) []failedFile {
+	var failed []failedFile
+
+	var ignores *ignoreMatcher
+	if !m.searchConfig.NoIgnore {
+		ignores = newIgnoreMatcher(dirPath, !m.searchConfig.IncludeGitignored)
+	}
+	tree := m.collect
+	if !tree.collecting() {
+		tree = nil
+	}
+
+	// skipDir also tells the walk which directories to read ahead, so the
+	// ones left out are never read
+	skipDir := func(path string) bool {
+		return m.skipsWalkDir(dirPath, path, ignores) || tree != nil && tree.isPruned(dirPath, path)
+	}
+	descend := func(path string, _ os.FileInfo) bool { return !skipDir(path) }
 
-	filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+	parallelWalk(dirPath, m.searchConfig.walkWorkers(), descend, func(path string, info os.FileInfo, err error) error {
 		select {
 		case <-ctx.Done():
 			return filepath.SkipDir
@@ -824,131 +2050,406 @@ func (m *model) collectFilesFromDir(ctx context.Context, dirPath string) ([]stri
 		}
 
 		if err != nil {
+			failed = append(failed, failureOf(path, err))
 			return nil
 		}
 
-		if !info.IsDir() && m.shouldSearchFile(path, info) {
-			files = append(files, path)
-			totalSize += info.Size()
+		if info.IsDir() {
+			if skipDir(path) {
+				return filepath.SkipDir
+			}
+			if tree != nil {
+				tree.addDir(dirPath, path)
+			}
+			return nil
 		}
 
+		// Skip files excluded by .gitignore/.ignore/.zxignore files, and
+		// those of directories pruned from the collection tree
+		if ignores != nil && ignores.ignored(path, false) {
+			return nil
+		}
+		if tree != nil && tree.isPruned(dirPath, filepath.Dir(path)) {
+			return nil
+		}
+
+		if ok, skipped := m.checkFile(path, info); ok {
+			if tree != nil {
+				tree.addFile(dirPath, path)
+			}
+			found(path, info.Size())
+		} else if skipped != nil {
+			failed = append(failed, *skipped)
+		}
 		return nil
 	})
 
-	return files, totalSize
+	return failed
 }
 
-func (m *model) shouldSearchFile(filePath string, info os.FileInfo) bool {
-	// Skip hidden files
-	if strings.HasPrefix(filepath.Base(filePath), ".") {
+// skipsWalkDir reports whether a walk of root leaves out the directory at
+// path, for ignore files, the depth limit or the excluded and skipped
+// directories
+func (m *model) skipsWalkDir(root, path string, ignores *ignoreMatcher) bool {
+	if path == root {
 		return false
 	}
+	return ignores != nil && ignores.ignored(path, true) ||
+		m.atMaxDepth(root, path, true) ||
+		m.searchConfig.excludesDir(path)
+}
 
-	// Skip large files
-	if info.Size() > m.searchConfig.MaxFileSize {
+// atMaxDepth reports whether path is a directory whose contents lie beyond
+// MaxDepth. Entries directly inside root are at depth 1.
+func (m *model) atMaxDepth(root, path string, isDir bool) bool {
+	if m.searchConfig.MaxDepth <= 0 || !isDir || path == root {
 		return false
 	}
-
-	// Skip binary files (basic check) - but be more permissive
-	if m.isBinaryFile(filePath) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
 		return false
 	}
+	return strings.Count(filepath.ToSlash(rel), "/")+1 >= m.searchConfig.MaxDepth
+}
+
+func (m *model) shouldSearchFile(filePath string, info os.FileInfo) bool {
+	ok, _ := m.checkFile(filePath, info)
+	return ok
+}
+
+// checkFile reports whether a file passes the search's checks, and for a
+// file left out for its size or content, why; the error panel lists those
+func (m *model) checkFile(filePath string, info os.FileInfo) (bool, *failedFile) {
+	// Files of results marked to be left out
+	if m.searchConfig.SkipFiles[filePath] {
+		return false, nil
+	}
 
-	// For debugging - let's be more permissive with text files
-	ext := strings.ToLower(filepath.Ext(filePath))
+	// Files the user always wants searched skip every check
+	if m.searchConfig.alwaysSearches(filePath) {
+		return true, nil
+	}
 
-	// Allow common text file extensions and files without extensions
-	textExts := []string{
-		"", ".txt", ".md", ".go", ".js", ".ts", ".py", ".java", ".c", ".cpp", ".h", ".hpp",
-		".rs", ".rb", ".php", ".html", ".css", ".json", ".xml", ".yaml", ".yml", ".toml",
-		".sh", ".bash", ".zsh", ".fish", ".ps1", ".bat", ".cmd", ".sql", ".log", ".conf",
-		".cfg", ".ini", ".env", ".gitignore", ".dockerfile", ".makefile", ".cmake",
+	// Files a rule skips entirely
+	rule := m.searchConfig.ruleFor(filePath)
+	if rule >= 0 && m.searchConfig.Rules[rule].Skip {
+		return false, nil
 	}
 
-	for _, textExt := range textExts {
-		if ext == textExt {
-			return true
-		}
+	// Skip hidden files unless asked to include them
+	if !m.searchConfig.IncludeHidden && isHidden(filePath) {
+		return false, nil
 	}
 
-	// If no extension or unknown extension, try to detect if it's text
-	// For now, allow it and let the search handle it
-	return true
-}
+	// Only file types chosen in the scope wizard, if any
+	if !m.searchConfig.includesFile(filePath) {
+		return false, nil
+	}
 
-func (m *model) isBinaryFile(filePath string) bool {
-	// Simple binary file detection
-	ext := strings.ToLower(filepath.Ext(filePath))
-	binaryExts := []string{
-		".exe", ".bin", ".so", ".dll", ".dylib", ".a", ".o",
-		".jpg", ".jpeg", ".png", ".gif", ".bmp", ".ico",
-		".mp3", ".mp4", ".avi", ".mov", ".wav", ".flac",
-		".zip", ".tar", ".gz", ".bz2", ".xz", ".7z",
-		".pdf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx",
+	// Skip large files, unless a rule sets another limit
+	if m.searchConfig.overSizeLimit(rule, info.Size()) {
+		return false, &failedFile{Path: filePath, Kind: failTooLarge,
+			Reason: fmt.Sprintf("%s, over the %s limit", formatSize(info.Size()), formatSize(m.searchConfig.sizeLimit(rule)))}
 	}
 
-	for _, binaryExt := range binaryExts {
-		if ext == binaryExt {
-			return true
+	// Size range and modification time filters
+	if !m.searchConfig.matchesMetadata(info) {
+		return false, nil
+	}
+
+	// Skip binary files (by extension or content sniffing)
+	if binary, reason := m.searchConfig.classifyBinary(filePath); binary {
+		return false, &failedFile{Path: filePath, Kind: failBinary, Reason: reason}
+	}
+
+	// Skip minified and generated web assets unless asked to include them
+	if !m.searchConfig.IncludeMinified {
+		if minified, _ := classifyMinified(filePath); minified {
+			return false, nil
 		}
 	}
 
-	return false
+	return true, nil
+}
+
+// isHidden reports whether a file is a dotfile
+func isHidden(filePath string) bool {
+	return strings.HasPrefix(filepath.Base(filePath), ".")
 }
 
-func (m *model) searchFileOptimized(ctx context.Context, re *regexp.Regexp, filePath string) ([]SearchResult, int64, error) {
+// searchFileOnce searches a file; searchFileOptimized retries it
+func (m *model) searchFileOnce(ctx context.Context, filePath string) ([]SearchResult, int64, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, 0, fmt.Errorf("unable to open file %s: %v", filePath, err)
+		return nil, 0, fmt.Errorf("unable to open file %s: %w", filePath, err)
 	}
 	defer file.Close()
 
 	fileInfo, err := file.Stat()
 	if err != nil {
-		return nil, 0, fmt.Errorf("unable to get file info %s: %v", filePath, err)
+		return nil, 0, fmt.Errorf("unable to get file info %s: %w", filePath, err)
+	}
+
+	base := SearchResult{
+		FilePath:     filePath,
+		FileSize:     fileInfo.Size(),
+		LastModified: fileInfo.ModTime(),
+	}
+
+	// Mailboxes are split into messages and decoded
+	if format := mailFormat(filePath); format != "" {
+		results, err := m.searchMailFile(ctx, file, base, format)
+		if err != nil {
+			return results, fileInfo.Size(), fmt.Errorf("error reading mail %s: %w", filePath, err)
+		}
+		return results, fileInfo.Size(), nil
+	}
+
+	// Very large plain-text files are searched in place through a memory
+	// map, except on network file systems where a failed read faults
+	if fileInfo.Size() >= MmapThreshold && extractorFor(filePath) == nil && !m.searchConfig.NetworkSafe {
+		if results, ok := m.searchMappedFile(ctx, file, base); ok {
+			return results, fileInfo.Size(), nil
+		}
+	}
+
+	reader, err := contentReader(ctx, file, &base)
+	if err != nil {
+		return nil, fileInfo.Size(), err
 	}
 
-	var results []SearchResult
-	scanner := bufio.NewScanner(file)
+	results, err := m.searchReader(ctx, reader, base)
+	if err != nil {
+		return results, fileInfo.Size(), fmt.Errorf("error reading file %s: %w", filePath, err)
+	}
+	return results, fileInfo.Size(), nil
+}
+
+// contentReader returns the searchable content of an open file: document
+// text for files with an extractor, the decompressed stream for gzip, bzip2
+// and xz files, or the file itself. base.Extractor and base.Compression
+// record which applied.
+func contentReader(ctx context.Context, file *os.File, base *SearchResult) (io.Reader, error) {
+	// Documents (docx, xlsx, pdf, ...) are converted to text first
+	if extractor := extractorFor(file.Name()); extractor != nil {
+		text, err := extractor.Extract(ctx, file.Name())
+		if err != nil {
+			return nil, fmt.Errorf("unable to extract text from %s: %v", file.Name(), err)
+		}
+		base.Extractor = extractor.Name()
+		return text, nil
+	}
+
+	// Stream-decompress gzip/bzip2/xz files (e.g. rotated logs)
+	buffered := bufio.NewReaderSize(file, BufferSize)
+	magic, _ := buffered.Peek(magicSize)
+	if kind := detectCompression(magic); kind != "" {
+		reader, err := decompressingReader(buffered, kind)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress %s: %v", file.Name(), err)
+		}
+		base.Compression = kind
+		return reader, nil
+	}
+	return buffered, nil
+}
+
+// searchReader matches the current query against every line of r. Fields of
+// base (path, size, timestamps) are copied into each result.
+func (m *model) searchReader(ctx context.Context, r io.Reader, base SearchResult) ([]SearchResult, error) {
+	// Detect the text encoding and transcode to UTF-8 on the fly
+	reader := bufio.NewReaderSize(r, BufferSize)
+	sample, _ := reader.Peek(sniffSize)
+	base.Encoding = detectEncoding(sample)
+
+	scanner := bufio.NewScanner(decodingReader(reader, base.Encoding))
 
 	// Use larger buffer for better performance
 	buf := make([]byte, 0, BufferSize)
 	scanner.Buffer(buf, BufferSize)
 
-	lineNum := 1
+	// Track where each line starts; a skipped BOM still counts. The counts
+	// are of the UTF-8 text, so results only keep them when that is the
+	// file's own bytes (see rawOffsets).
+	var consumed, lineStart int64
+	if base.Encoding == EncodingUTF8BOM {
+		consumed = int64(len(bomUTF8))
+	}
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if token != nil {
+			lineStart = consumed
+		}
+		consumed += int64(advance)
+		return advance, token, err
+	})
 
-	for scanner.Scan() {
+	lm := m.newLineMatcher(base)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
 		select {
 		case <-ctx.Done():
-			return results, fileInfo.Size(), nil
+			return lm.results, nil
 		default:
 		}
+		lm.match(lineNum, lineStart, scanner.Bytes())
+	}
+	return lm.finish(), scanner.Err()
+}
 
-		line := scanner.Text()
+// lineMatcher applies the current query to a file one line at a time,
+// collecting its results
+type lineMatcher struct {
+	m        *model
+	query    *Query
+	base     SearchResult
+	hits     []bool
+	fileHits []bool // Terms seen anywhere in the file, for file-level matching
+	results  []SearchResult
+	kept     map[string]int // Results kept per path, when MaxPerFile is set
+	more     map[string]int // Matches per path beyond MaxPerFile
+}
 
-		// Check for exact match
-		if matches := re.FindAllStringIndex(line, -1); len(matches) > 0 {
-			for _, match := range matches {
-				result := SearchResult{
-					FilePath:     filePath,
-					LineNumber:   lineNum,
-					LineContent:  line,
-					MatchStart:   match[0],
-					MatchEnd:     match[1],
-					FileSize:     fileInfo.Size(),
-					LastModified: fileInfo.ModTime(),
-				}
-				results = append(results, result)
+func (m *model) newLineMatcher(base SearchResult) *lineMatcher {
+	query := m.searchConfig.Query
+	return &lineMatcher{
+		m:        m,
+		query:    query,
+		base:     base,
+		hits:     make([]bool, len(query.Patterns)),
+		fileHits: make([]bool, len(query.Patterns)),
+	}
+}
+
+// match checks one line. The bytes are only copied into a string when the
+// line produces a result, so they may be reused by the caller afterwards.
+func (lm *lineMatcher) match(lineNum int, offset int64, line []byte) {
+	m, query := lm.m, lm.query
+	query.Hits(line, lm.hits)
+
+	if m.searchConfig.FileLevelMatch {
+		// Defer the decision until the whole file has been seen
+		for i, hit := range lm.hits {
+			lm.fileHits[i] = lm.fileHits[i] || hit
+		}
+		if query.anyPositive(lm.hits) != m.searchConfig.InvertMatch && !lm.overCap(line) {
+			lm.results = append(lm.results, m.lineResult(query, lm.base, lineNum, offset, string(line)))
+		}
+		return
+	}
+
+	matched := query.Eval(lm.hits)
+
+	// Inverted match: report whole lines that do not satisfy the expression
+	if m.searchConfig.InvertMatch {
+		if !matched && !lm.overCap(line) {
+			result := lm.base
+			result.LineNumber = lineNum
+			result.LineContent = string(line)
+			result.Column = 1
+			if result.rawOffsets() {
+				result.ByteOffset = offset
 			}
+			lm.results = append(lm.results, result)
 		}
-		lineNum++
+		return
 	}
 
-	if err := scanner.Err(); err != nil {
-		return results, fileInfo.Size(), fmt.Errorf("error reading file %s: %v", filePath, err)
+	if matched && !lm.overCap(line) {
+		lm.results = append(lm.results, m.lineResult(query, lm.base, lineNum, offset, string(line)))
 	}
+}
 
-	return results, fileInfo.Size(), nil
+// overCap counts a reportable line against the per-file cap. Lines past the
+// cap only add their matches to the file's overflow count.
+func (lm *lineMatcher) overCap(line []byte) bool {
+	limit := lm.m.searchConfig.MaxPerFile
+	if limit <= 0 {
+		return false
+	}
+	if lm.kept == nil {
+		lm.kept, lm.more = make(map[string]int), make(map[string]int)
+	}
+	path := lm.base.FilePath
+	if lm.kept[path] < limit {
+		lm.kept[path]++
+		return false
+	}
+	if lm.m.searchConfig.InvertMatch {
+		lm.more[path]++
+	} else {
+		lm.more[path] += lm.query.countMatches(line)
+	}
+	return true
+}
+
+// finish returns the file's results once every line has been matched
+func (lm *lineMatcher) finish() []SearchResult {
+	if lm.m.searchConfig.FileLevelMatch && !lm.query.Eval(lm.fileHits) {
+		return nil
+	}
+	// Record matches beyond the cap on each path's last kept result
+	for i := len(lm.results) - 1; i >= 0 && len(lm.more) > 0; i-- {
+		path := lm.results[i].FilePath
+		if n, ok := lm.more[path]; ok {
+			lm.results[i].MoreMatches = n
+			delete(lm.more, path)
+		}
+	}
+	return lm.results
+}
+
+// lineResult builds the result for a matching line, with the spans of all
+// contributing matches. Lines that satisfy the expression without a positive
+// match (e.g. "! test") have no spans.
+func (m *model) lineResult(query *Query, base SearchResult, lineNum int, offset int64, line string) SearchResult {
+	base.LineNumber = lineNum
+	base.LineContent = line
+	base.Column = 1
+	base.ByteOffset = 0
+	base.Matches = nil
+
+	base.Matches = query.MatchRanges(line)
+	if len(base.Matches) > 0 {
+		first := base.Matches[0].Start
+		base.Column = utf8.RuneCountInString(line[:first]) + 1
+		offset += int64(first)
+	}
+	if base.rawOffsets() {
+		base.ByteOffset = offset
+	}
+	return base
+}
+
+// matchCount returns the number of matches on a result's line; lines
+// reported without a span (inverted or negation-only matches) count once
+func (r SearchResult) matchCount() int {
+	return max(len(r.Matches), 1)
+}
+
+// rawOffsets reports whether the searched text is the file's own bytes, so
+// offsets into it are offsets into the file. Transcoded, decompressed and
+// extracted text, and command output, has no such mapping and its results
+// keep ByteOffset 0.
+func (r SearchResult) rawOffsets() bool {
+	if r.Compression != "" || r.Extractor != "" || r.Source != "" {
+		return false
+	}
+	return r.Encoding != EncodingUTF16LE && r.Encoding != EncodingUTF16BE && r.Encoding != EncodingLatin1
+}
+
+// logEntry reports whether the result is a journal or event log entry,
+// shown by its unit and time rather than a file position
+func (r SearchResult) logEntry() bool {
+	return r.Source == journalSource || r.Source == eventLogSource
+}
+
+// totalMatches sums the matches of results
+func totalMatches(results []SearchResult) int {
+	total := 0
+	for _, r := range results {
+		total += r.matchCount() + r.MoreMatches
+	}
+	return total
 }
 
 func (m *model) finishSearch(results SearchResults, selectedCount, fileCount, dirCount int) {
@@ -961,7 +2462,7 @@ func (m *model) finishSearch(results SearchResults, selectedCount, fileCount, di
 
 	// Enhanced status message
 	statusParts := []string{
-		fmt.Sprintf("Found %d matches", len(results.Results)),
+		fmt.Sprintf("Found %d matches on %d lines", totalMatches(results.Results), len(results.Results)),
 	}
 
 	if results.Truncated {
@@ -1005,18 +2506,35 @@ func (m model) View() string {
 	switch m.mode {
 	case FileBrowserMode:
 		title := fmt.Sprintf(" ZX - %s ", m.currentDir)
+		if browserSort.field != sortByName || browserSort.reverse {
+			title += fmt.Sprintf("[sorted by %s] ", sortLabel())
+		}
 		b.WriteString(titleStyle.Render(title))
 	case SearchInputMode:
 		title := " ZX Search Input "
 		b.WriteString(titleStyle.Render(title))
 	case SearchResultsMode:
 		title := fmt.Sprintf(" ZX Search Results - '%s' ", m.searchResults.Pattern)
+		if m.searchResults.Inverted {
+			title = fmt.Sprintf(" ZX Search Results - NOT '%s' (inverted) ", m.searchResults.Pattern)
+		}
+		if m.searchResults.Bundle != "" {
+			title += fmt.Sprintf("[bundle: %s] ", filepath.Base(m.searchResults.Bundle))
+		}
 		b.WriteString(titleStyle.Render(title))
 	case SearchProgressMode:
 		title := " ZX Search Progress "
 		b.WriteString(titleStyle.Render(title))
+	case PreviewMode:
+		title := fmt.Sprintf(" ZX Preview - %s ", m.preview.path)
+		if m.preview.detail {
+			title = fmt.Sprintf(" ZX Result - %s ", filepath.Base(m.preview.path))
+		}
+		b.WriteString(titleStyle.Render(title))
 	}
-	b.WriteString("\n\n")
+	b.WriteString("\n")
+	b.WriteString(m.renderTabBar())
+	b.WriteString("\n")
 
 	// Show help if requested
 	if m.showHelp {
@@ -1031,13 +2549,26 @@ func (m model) View() string {
 	case SearchInputMode:
 		b.WriteString(m.renderSearchInput())
 	case SearchResultsMode:
-		b.WriteString(m.renderSearchResults())
+		if m.showRefs {
+			b.WriteString(m.renderReferenceSummary())
+		} else {
+			b.WriteString(m.renderSearchResults())
+		}
 	case SearchProgressMode:
 		b.WriteString(m.renderSearchProgress())
 	case ConfigMode:
 		b.WriteString(m.renderConfig())
 	case AnalysisMode:
 		b.WriteString(m.renderAnalysis())
+	case PreviewMode:
+		b.WriteString(m.renderPreview())
+	}
+
+	// Active prompt
+	if m.prompt != nil {
+		b.WriteString("\n")
+		b.WriteString(searchInputStyle.Render(m.prompt.render()))
+		b.WriteString("\n")
 	}
 
 	// Status bar
@@ -1061,6 +2592,29 @@ func (m model) renderFileBrowser() string {
 		return b.String()
 	}
 
+	if m.pickingBookmark {
+		b.WriteString(m.renderBookmarkPicker())
+		return b.String()
+	}
+
+	if m.jumper != nil {
+		b.WriteString(m.renderJumper())
+		return b.String()
+	}
+
+	if m.dirLoad != nil {
+		b.WriteString(progressStyle.Render(fmt.Sprintf("Loading directory... %d entries so far", len(m.files))))
+		b.WriteString("\n")
+	}
+	if m.fileOp != nil {
+		b.WriteString(m.renderFileOp())
+		b.WriteString("\n")
+	}
+	if selection := m.renderSelection(); selection != "" {
+		b.WriteString(selection)
+		b.WriteString("\n")
+	}
+
 	start := m.viewport.offset
 	end := min(start+m.viewport.height, len(m.files))
 
@@ -1068,165 +2622,390 @@ func (m model) renderFileBrowser() string {
 		file := m.files[i]
 
 		// File icon and name
-		icon := "📄"
-		if file.IsDir {
-			icon = "📁"
-		}
-		if file.Selected {
-			icon = "✅"
-		}
+		icon := fileIcon(file.Name, file.IsDir, file.Selected)
 
 		// File info
-		var fileInfo string
-		if file.IsDir {
-			fileInfo = fmt.Sprintf("%s %s", icon, file.Name)
-		} else {
-			fileInfo = fmt.Sprintf("%s %s (%s)", icon, file.Name, formatSize(file.Size))
+		fileInfo := withIcon(icon, file.Name)
+		if m.treeView {
+			fileInfo = treePrefix(file) + fileInfo
 		}
+		fileInfo = m.browserRow(file, fileInfo)
 
 		// Apply styling
 		if i == m.selectedFile {
-			b.WriteString(selectedStyle.Render(fileInfo))
+			b.WriteString(renderSelected(fileInfo))
 		} else if file.IsDir {
 			b.WriteString(directoryStyle.Render(fileInfo))
 		} else {
-			b.WriteString(fileStyle.Render(fileInfo))
+			b.WriteString(fileStyle.Render(fileInfo))
+		}
+		b.WriteString("\n")
+	}
+
+	// Navigation info
+	if len(m.files) > m.viewport.height {
+		navInfo := fmt.Sprintf("Showing %d-%d of %d items", start+1, end, len(m.files))
+		b.WriteString("\n")
+		b.WriteString(helpStyle.Render(navInfo))
+	}
+
+	return b.String()
+}
+
+func (m model) renderSearchInput() string {
+	var b strings.Builder
+
+	b.WriteString(headerStyle.Render("Enter search pattern (regex supported, combine with && || !):"))
+	b.WriteString("\n\n")
+
+	// Search input box
+	inputText := fmt.Sprintf("Search: %s█", m.searchInput)
+	b.WriteString(searchInputStyle.Render(inputText))
+	b.WriteString("\n")
+	if m.searchConfig.InvertMatch {
+		b.WriteString(warningStyle.Render("Inverted match: lines NOT matching the pattern will be shown"))
+		b.WriteString("\n")
+	}
+	if m.searchConfig.FileLevelMatch {
+		b.WriteString(warningStyle.Render("File-level matching: && and ! apply to whole files"))
+		b.WriteString("\n")
+	}
+	if anchor := patternAnchor(m.searchInput); anchor != AnchorNone {
+		b.WriteString(statusStyle.Render("Anchor: " + anchor.String() + " (Ctrl+A for the next)"))
+		b.WriteString("\n")
+	}
+	if live := m.renderLive(); live != "" {
+		b.WriteString(live)
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	if m.pickingPreset {
+		b.WriteString(m.renderPresetPicker())
+		b.WriteString("\n")
+	} else if saved := m.renderSavedSearches(); saved != "" {
+		b.WriteString(saved)
+		b.WriteString("\n")
+	}
+
+	if len(m.skipNext) > 0 {
+		b.WriteString(statusStyle.Render(fmt.Sprintf("Skipping the %d files of the marked results (Esc drops them)", len(m.skipNext))))
+		b.WriteString("\n")
+	}
+
+	// Selected files and directories info
+	selectedFiles, selectedDirs, _ := m.selectionCounts()
+
+	if len(m.recalledTargets) > 0 {
+		b.WriteString(headerStyle.Render("Will search in: " + m.targetsLabel(m.recalledTargets)))
+	} else if selectedFiles > 0 || selectedDirs > 0 {
+		var targetInfo string
+		if selectedFiles > 0 && selectedDirs > 0 {
+			targetInfo = fmt.Sprintf("Will search in %d selected files and %d selected directories", selectedFiles, selectedDirs)
+		} else if selectedFiles > 0 {
+			targetInfo = fmt.Sprintf("Will search in %d selected files", selectedFiles)
+		} else {
+			targetInfo = fmt.Sprintf("Will search in %d selected directories", selectedDirs)
+		}
+		b.WriteString(headerStyle.Render(targetInfo))
+	} else if len(m.scopeTargets) > 0 {
+		b.WriteString(headerStyle.Render("Will search in scope: " + m.scopeLabel()))
+	} else {
+		b.WriteString(headerStyle.Render(fmt.Sprintf("Will search in current directory: %s", m.currentDir)))
+	}
+
+	return b.String()
+}
+
+func (m model) renderSearchResults() string {
+	var b strings.Builder
+
+	// Summary
+	matchNoun := fmt.Sprintf("matches on %d lines", len(m.searchResults.Results))
+	matches := m.cachedTotalMatches()
+	if m.searchResults.Inverted {
+		matchNoun = "non-matching lines"
+	}
+	if m.searching && m.collect.collecting() {
+		progress := m.searchResults.Progress
+		_, dirs := m.collect.totals()
+		summary := fmt.Sprintf("Searching... %d %s so far (%d of %d files found so far in %d directories, %v elapsed, T: prune directories)",
+			matches,
+			matchNoun,
+			progress.ProcessedFiles,
+			progress.TotalFiles,
+			dirs,
+			time.Since(progress.StartTime).Round(time.Second))
+		b.WriteString(progressStyle.Render(summary))
+	} else if m.searching && m.searchResults.Progress.Collecting {
+		progress := m.searchResults.Progress
+		summary := fmt.Sprintf("Searching... %d %s so far (%d of %d files found so far, %v elapsed)",
+			matches,
+			matchNoun,
+			progress.ProcessedFiles,
+			progress.TotalFiles,
+			time.Since(progress.StartTime).Round(time.Second))
+		b.WriteString(progressStyle.Render(summary))
+	} else if m.searching {
+		progress := m.searchResults.Progress
+		summary := fmt.Sprintf("Searching... %d %s so far (%d/%d files, %v elapsed)",
+			matches,
+			matchNoun,
+			progress.ProcessedFiles,
+			progress.TotalFiles,
+			time.Since(progress.StartTime).Round(time.Second))
+		b.WriteString(progressStyle.Render(summary))
+	} else {
+		summary := fmt.Sprintf("Found %d %s in %d files (searched in %v)",
+			matches,
+			matchNoun,
+			m.searchResults.TotalFiles,
+			m.searchResults.SearchTime)
+		if m.searchResults.IndexSkipped > 0 {
+			summary += fmt.Sprintf(" [index skipped %d files]", m.searchResults.IndexSkipped)
+		}
+		if m.searchResults.Resumed > 0 {
+			summary += fmt.Sprintf(" [resumed %d files from checkpoint]", m.searchResults.Resumed)
+		}
+		if m.searchResults.Progress.Cancelled {
+			summary += " [stopped]"
+		}
+		if m.searchResults.NetworkSafe {
+			summary += " [network safe mode]"
+		}
+		if m.searchResults.Checkpointed {
+			summary += " [checkpoint saved: Enter resumes]"
+		}
+		if m.searchResults.Quick {
+			summary += " " + quickSummary()
+		}
+		b.WriteString(headerStyle.Render(summary))
+	}
+	b.WriteString("\n")
+	b.WriteString(m.renderTrend())
+	b.WriteString(m.renderMarks())
+	if m.resultFilter.active() {
+		b.WriteString(warningStyle.Render(fmt.Sprintf("Filtered: showing %d results (%s)",
+			m.visibleResults.len(), m.resultFilter.describe())))
+		b.WriteString("\n")
+	}
+	b.WriteString(m.renderJobBanners())
+	b.WriteString("\n")
+
+	// Results
+	if m.visibleResults.len() == 0 && m.resultFilter.active() {
+		b.WriteString(errorStyle.Render("No results pass the current filters."))
+		b.WriteString("\n")
+	} else if m.visibleResults.len() == 0 && m.searching {
+		b.WriteString(statusStyle.Render("No matches yet, still searching..."))
+		b.WriteString("\n")
+	} else if m.visibleResults.len() == 0 {
+		b.WriteString(errorStyle.Render("No matches found."))
+		b.WriteString("\n\n")
+
+		// Show suggestions if available
+		if len(m.searchResults.Suggestions) > 0 {
+			b.WriteString(headerStyle.Render(fmt.Sprintf("Suggestions (took %v):", m.searchResults.SuggestTime.Round(time.Millisecond))))
+			b.WriteString("\n")
+			for _, suggestion := range m.searchResults.Suggestions {
+				b.WriteString("  ")
+				b.WriteString(suggestionStyle.Render(suggestion))
+				b.WriteString("\n")
+			}
+		}
+	} else if m.splitActive() {
+		b.WriteString(m.renderSplit(m.renderResultList()))
+	} else {
+		b.WriteString(m.renderResultList())
+	}
+
+	// Errors, and the files left out
+	b.WriteString(m.renderFailures())
+	b.WriteString(m.renderStats())
+
+	return b.String()
+}
+
+// renderResultList renders the visible page of results, one entry or one
+// file row each
+func (m model) renderResultList() string {
+	if m.resultFilter.Aggregate != AggregateNone {
+		return m.renderAggregated()
+	}
+
+	var b strings.Builder
+	start := m.viewport.offset
+	end := min(start+m.viewport.height, m.visibleResults.len())
+
+	for i := start; i < end; i++ {
+		if i == m.resultIndex {
+			b.WriteString(m.renderResultEntry(i, true))
+		} else {
+			b.WriteString(m.cachedResultEntry(i))
 		}
-		b.WriteString("\n")
 	}
 
 	// Navigation info
-	if len(m.files) > m.viewport.height {
-		navInfo := fmt.Sprintf("Showing %d-%d of %d items", start+1, end, len(m.files))
-		b.WriteString("\n")
+	if m.visibleResults.len() > m.viewport.height {
+		navInfo := fmt.Sprintf("Showing %d-%d of %d results",
+			start+1, end, m.visibleResults.len())
 		b.WriteString(helpStyle.Render(navInfo))
+		b.WriteString("\n")
 	}
-
 	return b.String()
 }
 
-func (m model) renderSearchInput() string {
+// renderResultEntry renders one result: its header, the line, and for the
+// selected result the surrounding context and captures
+func (m model) renderResultEntry(i int, selected bool) string {
 	var b strings.Builder
+	result := m.visibleResults.at(i)
 
-	b.WriteString(headerStyle.Render("Enter search pattern (regex supported):"))
-	b.WriteString("\n\n")
+	// File header; log entries show their unit and time instead
+	icon := icons.Result
+	if m.isMarked(result) {
+		icon = icons.Selected
+	}
+	var fileHeader string
+	if result.logEntry() {
+		fileHeader = withIcon(icon, fmt.Sprintf("%s (%s)",
+			result.Unit,
+			result.LastModified.Format("2006-01-02 15:04:05")))
+		if result.Priority != "" {
+			fileHeader += fmt.Sprintf(" [%s]", result.Priority)
+		}
+	} else {
+		fileHeader = withIcon(icon, fmt.Sprintf("%s:%d:%d (%s)",
+			result.FilePath,
+			result.LineNumber,
+			result.Column,
+			result.LastModified.Format("2006-01-02 15:04")))
+		if selected && result.rawOffsets() {
+			fileHeader += fmt.Sprintf(" [byte %d]", result.ByteOffset)
+		}
+	}
+	if result.RefKind == RefDefinition {
+		fileHeader += " [def]"
+	}
+	if result.Compression != "" {
+		fileHeader += fmt.Sprintf(" [%s]", result.Compression)
+	}
+	if result.Extractor != "" {
+		fileHeader += fmt.Sprintf(" [%s]", result.Extractor)
+	}
+	if result.Subject != "" {
+		fileHeader += fmt.Sprintf(" %q", result.Subject)
+	}
+	if result.Layer != "" {
+		fileHeader += fmt.Sprintf(" [layer %s]", result.Layer)
+	}
+	if result.Encoding != "" && result.Encoding != EncodingUTF8 {
+		fileHeader += fmt.Sprintf(" [%s]", result.Encoding)
+	}
+	if m.resultFilter.PerFile != PerFileAll {
+		fileHeader += fmt.Sprintf(" [%s of %d]", m.resultFilter.PerFile, m.fileMatchCount(result.FilePath))
+	}
+	if result.MoreMatches > 0 {
+		fileHeader += fmt.Sprintf(" [%d more matches in this file]", result.MoreMatches)
+	}
 
-	// Search input box
-	inputText := fmt.Sprintf("Search: %s█", m.searchInput)
-	b.WriteString(searchInputStyle.Render(inputText))
-	b.WriteString("\n\n")
+	if selected {
+		b.WriteString(renderSelected(fileHeader))
+	} else {
+		b.WriteString(directoryStyle.Render(fileHeader))
+	}
+	b.WriteString("\n")
 
-	// Selected files and directories info
-	selectedFiles := 0
-	selectedDirs := 0
-	for _, file := range m.files {
-		if file.Selected && file.Name != ".." {
-			if file.IsDir {
-				selectedDirs++
-			} else {
-				selectedFiles++
-			}
+	// Context around the selected match, bundled or read on demand
+	var after []string
+	if selected {
+		var before []string
+		var warning string
+		before, after, warning = m.resultContext(result)
+		if warning != "" {
+			b.WriteString(warningStyle.Render("    " + warning))
+			b.WriteString("\n")
+		}
+		for _, line := range before {
+			b.WriteString(m.renderContextLine(line))
+			b.WriteString("\n")
 		}
 	}
 
-	if selectedFiles > 0 || selectedDirs > 0 {
-		var targetInfo string
-		if selectedFiles > 0 && selectedDirs > 0 {
-			targetInfo = fmt.Sprintf("Will search in %d selected files and %d selected directories", selectedFiles, selectedDirs)
-		} else if selectedFiles > 0 {
-			targetInfo = fmt.Sprintf("Will search in %d selected files", selectedFiles)
-		} else {
-			targetInfo = fmt.Sprintf("Will search in %d selected directories", selectedDirs)
-		}
-		b.WriteString(headerStyle.Render(targetInfo))
+	// Line content with highlighting, clipped or wrapped to the width
+	lineContent := m.renderLine(result, selected)
+	if selected {
+		b.WriteString(selectedStyle.Render(lineContent))
 	} else {
-		b.WriteString(headerStyle.Render(fmt.Sprintf("Will search in current directory: %s", m.currentDir)))
+		b.WriteString(lineContent)
 	}
-
+	b.WriteString("\n")
+	if selected {
+		for _, line := range after {
+			b.WriteString(m.renderContextLine(line))
+			b.WriteString("\n")
+		}
+		b.WriteString(m.renderCaptures(result))
+	}
+	if note := m.noteFor(result); note != "" {
+		b.WriteString(statusStyle.Render("    " + withIcon(icons.Note, note)))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
 	return b.String()
 }
 
-func (m model) renderSearchResults() string {
+// renderAggregated lists the visible files, one row each, with their match
+// counts; count mode also totals them
+func (m model) renderAggregated() string {
 	var b strings.Builder
+	start := m.viewport.offset
+	end := min(start+m.viewport.height, m.visibleResults.len())
 
-	// Summary
-	summary := fmt.Sprintf("Found %d matches in %d files (searched in %v)",
-		len(m.searchResults.Results),
-		m.searchResults.TotalFiles,
-		m.searchResults.SearchTime)
-	b.WriteString(headerStyle.Render(summary))
-	b.WriteString("\n\n")
-
-	// Results
-	if len(m.searchResults.Results) == 0 {
-		b.WriteString(errorStyle.Render("No matches found."))
-		b.WriteString("\n\n")
-
-		// Show suggestions if available
-		if len(m.searchResults.Suggestions) > 0 {
-			b.WriteString(headerStyle.Render("Suggestions:"))
-			b.WriteString("\n")
-			for _, suggestion := range m.searchResults.Suggestions {
-				b.WriteString("  ")
-				b.WriteString(suggestionStyle.Render(suggestion))
-				b.WriteString("\n")
-			}
+	if m.resultFilter.Aggregate == AggregateCounts {
+		total := 0
+		for _, n := range m.fileCounts {
+			total += n
 		}
-	} else {
-		start := m.viewport.offset
-		end := min(start+m.viewport.height, len(m.searchResults.Results))
-
-		for i := start; i < end; i++ {
-			result := m.searchResults.Results[i]
-
-			// File header
-			fileHeader := fmt.Sprintf("📁 %s:%d (%s)",
-				result.FilePath,
-				result.LineNumber,
-				result.LastModified.Format("2006-01-02 15:04"))
+		b.WriteString(headerStyle.Render(fmt.Sprintf("Total: %d in %d files", total, len(m.fileCounts))))
+		b.WriteString("\n\n")
+	}
 
-			if i == m.resultIndex {
-				b.WriteString(selectedStyle.Render(fileHeader))
-			} else {
-				b.WriteString(directoryStyle.Render(fileHeader))
-			}
-			b.WriteString("\n")
+	for i := start; i < end; i++ {
+		result := m.visibleResults.at(i)
+		count := m.fileCounts[result.FilePath]
 
-			// Line content with highlighting
-			lineContent := m.highlightMatch(result.LineContent, result.MatchStart, result.MatchEnd)
-			if i == m.resultIndex {
-				b.WriteString(selectedStyle.Render("    " + lineContent))
-			} else {
-				b.WriteString("    " + lineContent)
+		var row string
+		if m.resultFilter.Aggregate == AggregateCounts {
+			row = fmt.Sprintf("%8d  %s", count, result.FilePath)
+		} else {
+			noun := "matches"
+			if count == 1 {
+				noun = "match"
 			}
-			b.WriteString("\n\n")
+			row = withIcon(fileIcon(result.FilePath, false, false), fmt.Sprintf("%s (%d %s, %s)",
+				result.FilePath, count, noun, result.LastModified.Format("2006-01-02 15:04")))
 		}
 
-		// Navigation info
-		if len(m.searchResults.Results) > m.viewport.height {
-			navInfo := fmt.Sprintf("Showing %d-%d of %d results",
-				start+1, end, len(m.searchResults.Results))
-			b.WriteString(helpStyle.Render(navInfo))
-			b.WriteString("\n")
+		if i == m.resultIndex {
+			b.WriteString(renderSelected(row))
+		} else {
+			b.WriteString(directoryStyle.Render(row))
 		}
+		b.WriteString("\n")
 	}
 
-	// Show errors if any
-	if len(m.searchResults.Errors) > 0 {
+	if m.visibleResults.len() > m.viewport.height {
+		b.WriteString(helpStyle.Render(fmt.Sprintf("Showing %d-%d of %d files", start+1, end, m.visibleResults.len())))
 		b.WriteString("\n")
-		b.WriteString(errorStyle.Render("Errors encountered:"))
-		b.WriteString("\n")
-		for _, err := range m.searchResults.Errors {
-			b.WriteString("  ")
-			b.WriteString(errorStyle.Render(err))
-			b.WriteString("\n")
-		}
 	}
-
 	return b.String()
 }
 
 func (m model) renderSearchProgress() string {
+	if m.collect.collecting() {
+		return m.renderCollectTree()
+	}
+
 	var b strings.Builder
 
 	progress := m.searchResults.Progress
@@ -1237,12 +3016,21 @@ func (m model) renderSearchProgress() string {
 
 	// Current file being processed
 	if progress.CurrentFile != "" {
-		b.WriteString(fmt.Sprintf("Processing: %s", progress.CurrentFile))
+		current := fmt.Sprintf("Processing: %s", m.relPath(progress.CurrentFile))
+		if m.viewport.width > 0 {
+			current = truncateWidth(current, m.viewport.width)
+		}
+		b.WriteString(current)
 		b.WriteString("\n\n")
 	}
 
-	// Progress bars
-	if progress.TotalFiles > 0 {
+	// Progress bars, once every file to search has been found
+	if progress.Collecting {
+		b.WriteString(fmt.Sprintf("Files: %d searched of %d found so far", progress.ProcessedFiles, progress.TotalFiles))
+		b.WriteString("\n")
+		b.WriteString(fmt.Sprintf("Data: %s searched of %s found so far", formatSize(progress.ProcessedSize), formatSize(progress.TotalSize)))
+		b.WriteString("\n\n")
+	} else if progress.TotalFiles > 0 {
 		fileProgress := float64(progress.ProcessedFiles) / float64(progress.TotalFiles) * 100
 		b.WriteString(fmt.Sprintf("Files: %d/%d (%.1f%%)",
 			progress.ProcessedFiles, progress.TotalFiles, fileProgress))
@@ -1265,23 +3053,37 @@ func (m model) renderSearchProgress() string {
 	b.WriteString(fmt.Sprintf("Elapsed: %v", elapsed.Round(time.Second)))
 	b.WriteString("\n")
 
-	// ETA calculation
-	if progress.ProcessedFiles > 0 && progress.TotalFiles > 0 {
-		rate := float64(progress.ProcessedFiles) / elapsed.Seconds()
-		remaining := float64(progress.TotalFiles - progress.ProcessedFiles)
-		eta := time.Duration(remaining/rate) * time.Second
+	if seconds := elapsed.Seconds(); progress.ProcessedFiles > 0 && seconds > 0 {
+		b.WriteString(fmt.Sprintf("Rate: %.0f files/s, %s/s",
+			float64(progress.ProcessedFiles)/seconds, formatSize(int64(float64(progress.ProcessedSize)/seconds))))
+		b.WriteString("\n")
+	}
+
+	// ETA calculation; bytes tell how far along a search is better than
+	// files, which vary in size
+	done, total := float64(progress.ProcessedSize), float64(progress.TotalSize)
+	if total == 0 {
+		done, total = float64(progress.ProcessedFiles), float64(progress.TotalFiles)
+	}
+	if done > 0 && total > done && !progress.Collecting {
+		eta := time.Duration(float64(elapsed) * (total - done) / done)
 		b.WriteString(fmt.Sprintf("ETA: %v", eta.Round(time.Second)))
 		b.WriteString("\n")
 	}
 
+	if len(progress.Targets) > 1 {
+		b.WriteString("\n")
+		b.WriteString(m.renderTargets(progress.Targets))
+	}
+
 	// Current results count
 	b.WriteString("\n")
-	b.WriteString(fmt.Sprintf("Matches found so far: %d", len(m.searchResults.Results)))
+	b.WriteString(fmt.Sprintf("Matches found so far: %d", progress.Matches))
 
-	// Errors
-	if len(progress.Errors) > 0 {
+	// Files skipped or unreadable, listed once the search finishes
+	if progress.Failed > 0 {
 		b.WriteString("\n\n")
-		b.WriteString(errorStyle.Render(fmt.Sprintf("Errors: %d", len(progress.Errors))))
+		b.WriteString(warningStyle.Render(fmt.Sprintf("Files left out so far: %d (E lists them once the search finishes)", progress.Failed)))
 	}
 
 	return b.String()
@@ -1307,15 +3109,39 @@ File Browser Mode:
   Ctrl+Enter    Toggle directory selection (without entering)
   d             Toggle directory selection (multiple allowed)
   s//           Start search
+  v             Preview the file under the cursor
+  w             Scope wizard: choose roots, file types, hidden, vendored, size
+  @1-@9         Rerun a saved search (a template asks for its {{values}})
+  .             Repeat the last search with the same pattern, targets and settings
+  b             Bookmark the current directory (again to remove it)
+  B             List bookmarks (1-9 or Enter to jump, x to remove)
+  Ctrl+P        Jump to a path under this directory by typing part of it
+  t             Tree view: →/Enter expands a directory in place, ← collapses it
+  o/O           Sort by name, size, modified or extension / reverse the sort
+  Alt+1-9       Jump to one of the first nine bookmarks
   a             Select all files and directories
   f             Select all files only
-  Ctrl+D        Select all directories only
-  A             Deselect all files and directories
+  F             Select all directories (folders) only
+  A             Deselect all files and directories, in every directory
+  Ctrl+Z        Undo last selection or config change
+  D             Move selected items (or current item) to trash
+  U             Restore the last trashed items
+  r             Rename the current item
+  C             Copy selected items (or current item) to a directory
+  M             Move selected items (or current item) to a directory
+  Esc           Stop a running copy or move
   c             Configuration (performance settings)
   i             Analyze folder (show statistics)
-  r             Refresh directory
+  I             Import a result bundle for review
+  Ctrl+R        Refresh directory
   g/Home        Go to first item
   G/End         Go to last item
+  PgUp/PgDn     Move a page up / down
+  Ctrl+U/Ctrl+D Move half a page up / down
+  10j, 5PgDn    Type a count first to repeat a movement
+  Ctrl+N        Open a new tab (searches keep running in their own tab)
+  Tab/Shift+Tab Switch to the next / previous tab
+  Ctrl+W        Close the tab, stopping its search
   h/?           Toggle this help
   q/Ctrl+C      Quit
 
@@ -1323,7 +3149,7 @@ Navigation: Use arrow keys or vim-style keys (j/k)
 Selection: Select files and/or directories to search within
 Directory Selection: Use Space to select, Enter to navigate, Ctrl+Enter to select without entering
 Multiple Directories: Use 'd' to toggle directory selection (allows multiple)
-All Directories: Use Ctrl+D to select all directories
+All Directories: Use F to select all directories
 Configuration: Press 'c' to adjust settings for large datasets
 Analysis: Press 'i' to see why searches might fail
 `
@@ -1331,14 +3157,26 @@ Analysis: Press 'i' to see why searches might fail
 		help = `
 Search Input Mode:
   Type          Enter search pattern (regex supported)
-  Enter         Start search
+  Enter         Start search (offers to resume it if it left a checkpoint)
   Esc/Ctrl+C    Cancel search
   Backspace     Delete character
+  ↑/↓           Recall past searches with their targets and settings
+  Ctrl+V        Toggle inverted match (show non-matching lines)
+  Ctrl+F        Toggle file-level matching (evaluate && and ! per file)
+  Ctrl+A        Anchor the pattern: whole line ^…$, start ^…, end …$, anywhere
+  Ctrl+P        Pick a named pattern (Enter replaces the input, & or | adds it)
+  Ctrl+T        Quick search: small files, first match per file, 5s budget
+  {{name}}      Placeholder of a template; Enter asks for its value
 
 Examples:
   func.*main     - Find function definitions containing 'main'
   TODO|FIXME     - Find TODO or FIXME comments
   error.*return  - Find error handling patterns
+
+Combinators (surround operators with spaces, && binds tighter than ||):
+  error && ! test - Lines containing 'error' but not 'test'
+  TODO || FIXME   - Lines containing either marker
+  ! debug         - Lines not containing 'debug' (!debug is a regex)
 `
 	case SearchResultsMode:
 		help = `
@@ -1347,8 +3185,47 @@ Search Results Mode:
   ↓/j           Move down through results
   g/Home        Go to first result
   G/End         Go to last result
-  s/            Start new search
-  Esc/q         Return to file browser
+  PgUp/PgDn     Move a page up / down
+  Ctrl+U/Ctrl+D Move half a page up / down
+  10j, 5PgDn    Type a count first to repeat a movement
+  s/            Start new search (while one runs: cancel, background or queue it)
+  P             Merge the partial results of a stopped search into these
+  J             Switch to the background search's results
+  T             Show the search's progress, per target to stop a slow one
+  m             Only show files with at least N matches
+  M             Only show lines with at least N occurrences
+  p             Cycle all / first / last match per file
+  l             Cycle matches / files with matches / match counts
+  e             Edit the selected line in place (original is backed up)
+  n             Add, change or remove a note on the selected line
+  w             Write results and notes as a Markdown report
+  b             Export results, settings and notes as a shareable bundle
+  C             Export capture group values as CSV or JSON
+  o             Export results as JSON, JSONL, CSV, TSV, Markdown, SARIF or plain text
+  y             Copy a GitHub/GitLab permalink to the selected line
+  c             Copy the path, path:line, match or line, or all visible results
+  x             Toggle definition vs usage summary per file
+  F             Rerun a quick search as a full search
+  S             Save this search under a name (rerun with @1-@9 in the file browser)
+  .             Repeat the search, picking up edits made since
+  v             Preview the file with the selected line centered
+  |             Show or hide the preview pane next to the results
+  </>           Narrow or widen the result list in split view
+  Shift+↑/↓     Scroll the preview pane around the selected line
+  +/-           Show more or fewer lines of context around the selected result
+  ←/→           Scroll the selected result's line sideways when it's clipped
+  W             Wrap long lines instead of clipping them around the match
+  r             Show the result's file in the file browser, selected in its directory
+  Space         Mark or unmark the result for a batch action, moving to the next
+  a             Act on the marked results: open in the editor, copy paths, export, skip their files next search
+  E             List or hide the files left out: permission denied, too large, binary, read errors
+  R             Search files left out again, all or one kind, with the current settings
+  i             Show or hide the search's statistics: files, bytes, time, workers, throughput
+  Ctrl+Z        Undo last filter change
+  Ctrl+N        Open a new tab, leaving this search running here
+  Tab/Shift+Tab Switch to the next / previous tab
+  Ctrl+W        Close the tab, stopping its search
+  Esc/q         Stop a running search, or return to file browser
   h/?           Toggle this help
 
 Navigation: Browse through search matches with context
@@ -1356,14 +3233,42 @@ Navigation: Browse through search matches with context
 	case SearchProgressMode:
 		help = `
 Search Progress Mode:
-  Shows progress of ongoing search
+  Shows files and data searched, the file being searched, the rate, an
+  ETA and the matches so far, with a row per selected target
+  ↑/k ↓/j       Choose a target
+  x             Stop the chosen target; the others go on
+
+  While files are still being collected, and searched as they are found,
+  it shows the directories found so far with their file counts instead
+  ↑/k ↓/j       Choose a directory
+  Enter/→ ←     Expand or collapse it
+  x             Prune it (again to keep it); nothing more in it is searched
+  Esc/T         Return to the results
+  q/Ctrl+C      Stop the whole search and browse the results it found
 `
 	case ConfigMode:
 		help = `
 Configuration Mode:
   1             Toggle max file size (100MB ↔ 1GB)
   2             Toggle max results (10K ↔ 50K)
+  f             Cycle results kept per file (unlimited, 10, 100, 1000)
   3             Toggle concurrency (50 ↔ 2x CPU cores)
+  4             Toggle including git-ignored files (.ignore/.zxignore still apply)
+  5             Toggle searching git stash contents
+  6             Pick a theme (↑↓ previews, Enter keeps, Esc goes back)
+  7             Cycle match highlight (color, underline, reverse, bold)
+  8             Cycle icon theme (emoji, nerd-font, ascii, none)
+  9             Cycle max directory depth (unlimited, 1, 2, 3, 5, 10)
+  0             Toggle searching hidden files (dotfiles)
+  d             Toggle searching .git, node_modules, vendor and the other skip_dirs
+  x             Edit the extensions searched as text without looking at their content
+  b             Edit the extensions skipped as binary without reading them
+  m             Toggle searching minified assets and source maps
+  z             Set the file size range, e.g. >1KB <5MB
+  t             Set the modification time window, e.g. 7d
+  s             Cycle suggestions when nothing matches (tokens, lines, off)
+  n             Cycle network safe mode (auto, on, off)
+  Ctrl+Z        Undo last setting change
   h/?           Toggle this help
   Esc/q         Return to file browser
 
@@ -1376,6 +3281,31 @@ Adjust these settings based on your dataset size:
 		help = `
 Analysis Mode:
   Shows folder analysis and recommendations
+`
+	case PreviewMode:
+		if m.preview.detail {
+			help = `
+Result Detail:
+  Shows the result's line with 20 lines on each side, and its file
+  ↑/k ↓/j       Scroll one line
+  n/→ N/←       Go to the next / previous result in this file
+  e             Open the file in the editor (config editor, $VISUAL or $EDITOR) at the line
+  c             Copy the path, path:line, match or line
+  v             Show the whole file as the preview does
+  Esc/q/Enter   Back to the results
+  ?             Toggle this help
+`
+			break
+		}
+		help = `
+Preview Mode:
+  ↑/k ↓/j       Scroll one line
+  PgUp/PgDn     Scroll one page (also Ctrl+U/Ctrl+D, Space)
+  g/G           Go to the top / bottom
+  n/N           Jump to the next / previous result line in this file (marked ▶)
+  c             Center the highlighted line
+  Esc/q/v       Close the preview
+  h/?           Toggle this help
 `
 	}
 
@@ -1387,17 +3317,46 @@ func (m model) renderFooter() string {
 
 	switch m.mode {
 	case FileBrowserMode:
-		shortcuts = "s:search | Enter:navigate/select | Space:toggle | d:multiple dirs | a:all | f:files | Ctrl+D:all dirs | A:none | c:config | i:analyze | h:help | q:quit"
+		shortcuts = m.rerunHint() + "s:search | v:preview | w:scope wizard | b/B:bookmark/list | Ctrl+P:jump | t:tree | o/O:sort | Enter:navigate/select | Space:toggle | d:multiple dirs | a:all | f:files | F:all dirs | @1-9:saved | A:none | Ctrl+Z:undo | D:trash | U:restore | r:rename | C/M:copy/move | c:config | i:analyze | Ctrl+N:new tab | h:help | q:quit"
 	case SearchInputMode:
-		shortcuts = "Enter:search | Esc:cancel"
+		shortcuts = "Enter:search | ↑↓:history | Ctrl+T:quick | Ctrl+P:presets | Ctrl+V:invert | Ctrl+F:file-level | Ctrl+A:anchor | Esc:cancel"
 	case SearchResultsMode:
-		shortcuts = "↑↓:navigate | s:new search | Esc:back | h:help"
+		shortcuts = m.rerunHint() + "↑↓:navigate | PgUp/PgDn:page | Ctrl+U/D:half page | s:new search | m/M:min matches | p:per file | e:edit | n:note | w:report | b:bundle | o:export | C:captures | y:permalink | c:copy | S:save | v:preview | |:split | </>:resize | Shift+↑↓:scroll pane | +/-:context | ←→:scroll line | W:wrap | r:reveal | Space:mark | a:actions | E/R:left out files | i:stats | x:refs | Esc:back | h:help"
+		if m.searchResults.Quick {
+			shortcuts = "F:full search | " + shortcuts
+		}
+		if m.searching {
+			shortcuts = "↑↓:navigate | s:new search | m/M:min matches | p:per file | e:edit | Esc:stop search | h:help"
+			if m.collect.collecting() {
+				shortcuts = "T:prune dirs | " + shortcuts
+			} else if len(m.searchResults.Progress.Targets) > 1 {
+				shortcuts = "T:targets | " + shortcuts
+			}
+		}
+		if m.background != nil {
+			shortcuts = "J:background | " + shortcuts
+		}
+		if m.partial != nil {
+			shortcuts = "P:merge partial | " + shortcuts
+		}
 	case SearchProgressMode:
-		shortcuts = "Esc:cancel"
+		shortcuts = "↑↓:choose target | x:stop target | Esc:results | q:stop search"
+		if len(m.searchResults.Progress.Targets) < 2 {
+			shortcuts = "Esc:results | q:stop search"
+		}
+		if m.collect.collecting() {
+			shortcuts = "↑↓:choose dir | Enter/→←:expand | x:prune | Esc:results | q:stop search"
+		}
 	case ConfigMode:
-		shortcuts = "1:file size | 2:max results | 3:concurrency | h:help | Esc:back"
+		shortcuts = "1:file size | 2:max results | f:per file | 3:concurrency | 4:git-ignored | 5:stashes | 6:theme | 7:highlight | 0:hidden | d:skip dirs | x/b:text/binary exts | m:minified | z:size | t:modified | s:suggest | n:network | h:help | Esc:back"
 	case AnalysisMode:
 		shortcuts = "h:help | Esc:back"
+	case PreviewMode:
+		if m.preview.detail {
+			shortcuts = "↑↓:scroll | n/N:next/prev result in file | e:editor | c:copy | v:whole file | Esc:back | ?:help"
+			break
+		}
+		shortcuts = "↑↓:scroll | PgUp/PgDn:page | g/G:top/bottom | n/N:next/prev result | c:center | Esc:close | h:help"
 	}
 
 	return helpStyle.Render(shortcuts)
@@ -1409,6 +3368,15 @@ func (m model) renderConfig() string {
 	b.WriteString(headerStyle.Render("Performance Configuration"))
 	b.WriteString("\n\n")
 
+	if m.pickingTheme {
+		b.WriteString(m.renderThemePicker())
+		b.WriteString("\n")
+		b.WriteString(renderSelected("  selected line") + "  " + fileStyle.Render("main.go") + "  " + directoryStyle.Render("src/") + "  " +
+			matchStyle.Render("match") + "  " + errorStyle.Render("error") + "  " + warningStyle.Render("warning") + "  " + helpStyle.Render("help"))
+		b.WriteString("\n")
+		return b.String()
+	}
+
 	// Current settings
 	b.WriteString("Current Settings:\n\n")
 
@@ -1419,11 +3387,100 @@ func (m model) renderConfig() string {
 	// Max results
 	b.WriteString(fmt.Sprintf("2. Max Results: %d\n", m.searchConfig.MaxResults))
 	b.WriteString("   Maximum search results to keep in memory\n\n")
+	b.WriteString(fmt.Sprintf("f. Results Per File: %s\n", perFileCapLabel(m.searchConfig.MaxPerFile)))
+	b.WriteString("   Further matches in a file are counted on its header instead\n\n")
 
 	// Concurrency
 	b.WriteString(fmt.Sprintf("3. Concurrency: %d workers\n", m.searchConfig.MaxConcurrency))
 	b.WriteString(fmt.Sprintf("   CPU cores available: %d\n\n", runtime.NumCPU()))
 
+	// Ignore files
+	ignoreState := "skipped"
+	switch {
+	case m.searchConfig.NoIgnore:
+		ignoreState = "included (-no-ignore turns off every ignore file)"
+	case m.searchConfig.IncludeGitignored:
+		ignoreState = "included"
+	}
+	b.WriteString(fmt.Sprintf("4. Git-ignored Files: %s\n", ignoreState))
+	b.WriteString("   Paths listed in .gitignore and .git/info/exclude; .ignore and .zxignore still apply\n\n")
+
+	// Git stashes
+	stashState := "off"
+	if m.searchConfig.SearchStashes {
+		stashState = "on"
+	}
+	b.WriteString(fmt.Sprintf("5. Search Git Stashes: %s\n", stashState))
+	b.WriteString("   Also search files saved in git stash entries\n\n")
+
+	// Appearance
+	b.WriteString(fmt.Sprintf("6. Theme: %s\n", themes[m.themeIndex].Name))
+	if colors <= colorMono {
+		b.WriteString(fmt.Sprintf("   Not shown: colors are %s (set with -color)\n\n", colors))
+	} else {
+		b.WriteString(fmt.Sprintf("   Presets for dark and light terminals and colorblind-safe variants (colors: %s)\n\n", colors))
+	}
+	b.WriteString(fmt.Sprintf("7. Match Highlight: %s %s\n", matchModes[m.matchModeIndex], matchStyle.Render("example")))
+	b.WriteString("   Underline or reverse video work without color\n\n")
+	b.WriteString(fmt.Sprintf("8. Icons: %s %s\n", icons.Name, fileIcon("", true, false)))
+	b.WriteString("   Use ascii or none if emoji misalign rows in your terminal\n\n")
+
+	// Depth
+	b.WriteString(fmt.Sprintf("9. Max Depth: %s\n", depthLabel(m.searchConfig.MaxDepth)))
+	b.WriteString("   Directory levels to descend below each target\n\n")
+
+	// Hidden files
+	hiddenState := "skipped"
+	if m.searchConfig.IncludeHidden {
+		hiddenState = "included"
+	}
+	b.WriteString(fmt.Sprintf("0. Hidden Files: %s\n", hiddenState))
+	b.WriteString("   Dotfiles such as .env and .eslintrc\n\n")
+
+	// Skipped directories
+	skipState := "skipped"
+	if m.searchConfig.NoSkipDirs {
+		skipState = "searched"
+	}
+	b.WriteString(fmt.Sprintf("d. Skipped Directories: %s\n", skipState))
+	b.WriteString(fmt.Sprintf("   %s (skip_dirs)\n\n", strings.Join(m.searchConfig.SkipDirs, ", ")))
+
+	// Extension lists
+	b.WriteString(fmt.Sprintf("x. Text Extensions: %s\n", extensionsLabel(m.searchConfig.TextExts)))
+	b.WriteString("   Searched as text without looking at their content (text_exts)\n\n")
+	b.WriteString(fmt.Sprintf("b. Binary Extensions: %s\n", extensionsLabel(m.searchConfig.BinaryExts)))
+	b.WriteString("   Skipped as binary without reading them (binary_exts)\n\n")
+
+	// Minified assets
+	minifiedState := "skipped"
+	if m.searchConfig.IncludeMinified {
+		minifiedState = "included"
+	}
+	b.WriteString(fmt.Sprintf("m. Minified Assets: %s\n", minifiedState))
+	b.WriteString("   *.min.js, source maps and other generated web assets\n\n")
+
+	// File metadata
+	b.WriteString(fmt.Sprintf("z. Size Range: %s\n", sizeRangeLabel(m.searchConfig.MinSize, m.searchConfig.MaxSize)))
+	b.WriteString("   Only search files within this size range\n\n")
+	b.WriteString(fmt.Sprintf("t. Modified: %s\n", ageLabel(m.searchConfig.ModifiedWithin)))
+	b.WriteString("   Only search files changed within this window\n\n")
+	b.WriteString(fmt.Sprintf("s. Suggestions: %s\n", m.searchConfig.Suggest.describe()))
+	b.WriteString("   Near misses of the pattern when nothing matches; off saves a rescan on big trees\n\n")
+	b.WriteString(fmt.Sprintf("n. Network Safe Mode: %s\n", networkLabel(m.searchConfig.Network)))
+	b.WriteString("   Few workers, retries after read errors and no memory maps, for NFS and SMB mounts\n\n")
+	if len(m.searchConfig.AlwaysSearch) > 0 {
+		b.WriteString(fmt.Sprintf("   Always Searched: %s\n", strings.Join(m.searchConfig.AlwaysSearch, ", ")))
+		b.WriteString("   Searched even if hidden, binary-looking or over the size limit (always_search)\n\n")
+	}
+	if len(m.searchConfig.Rules) > 0 {
+		var rules []string
+		for _, rule := range m.searchConfig.Rules {
+			rules = append(rules, rule.describe())
+		}
+		b.WriteString(fmt.Sprintf("   File Rules: %s\n", strings.Join(rules, "; ")))
+		b.WriteString("   Per-pattern skips, size limits and workers; the first matching rule applies ([[rules]])\n\n")
+	}
+
 	// Performance tips
 	b.WriteString(warningStyle.Render("Performance Tips for Large Datasets:"))
 	b.WriteString("\n\n")
@@ -1431,7 +3488,7 @@ func (m model) renderConfig() string {
 	b.WriteString("• Increase max results if you need more matches\n")
 	b.WriteString("• Increase concurrency for faster searching\n")
 	b.WriteString("• Use file/directory selection to limit scope\n")
-	b.WriteString("• Binary files are automatically skipped\n")
+	b.WriteString("• Binary files are detected by content and skipped\n")
 
 	return b.String()
 }
@@ -1450,8 +3507,39 @@ func (m model) renderAnalysis() string {
 	b.WriteString(fmt.Sprintf("Total Files: %d\n", analysis.TotalFiles))
 	b.WriteString(fmt.Sprintf("Text Files: %d\n", analysis.TextFiles))
 	b.WriteString(fmt.Sprintf("Binary Files: %d (skipped)\n", analysis.BinaryFiles))
-	b.WriteString(fmt.Sprintf("Hidden Files: %d (skipped)\n", analysis.HiddenFiles))
+	reasons := make([]string, 0, len(analysis.BinaryReasons))
+	for reason := range analysis.BinaryReasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		b.WriteString(fmt.Sprintf("  • %s: %d\n", reason, analysis.BinaryReasons[reason]))
+	}
+	if m.searchConfig.IncludeHidden {
+		b.WriteString(fmt.Sprintf("Hidden Files: %d (included)\n", analysis.HiddenFiles))
+	} else {
+		b.WriteString(fmt.Sprintf("Hidden Files: %d (skipped)\n", analysis.HiddenFiles))
+	}
+	minifiedState := "skipped"
+	if m.searchConfig.IncludeMinified {
+		minifiedState = "included"
+	}
+	b.WriteString(fmt.Sprintf("Minified/Generated Files: %d (%s)\n", analysis.MinifiedFiles, minifiedState))
+	reasons = reasons[:0]
+	for reason := range analysis.MinifiedReasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		b.WriteString(fmt.Sprintf("  • %s: %d\n", reason, analysis.MinifiedReasons[reason]))
+	}
 	b.WriteString(fmt.Sprintf("Large Files: %d (may be skipped)\n", analysis.LargeFiles))
+	if !m.searchConfig.NoIgnore {
+		b.WriteString(fmt.Sprintf("Ignored Paths: %d (.gitignore/.ignore/.zxignore)\n", analysis.IgnoredPaths))
+	}
+	if analysis.SkippedDirs > 0 {
+		b.WriteString(fmt.Sprintf("Skipped Directories: %d (%s)\n", analysis.SkippedDirs, strings.Join(m.searchConfig.SkipDirs, ", ")))
+	}
 	b.WriteString("\n")
 
 	// Size statistics
@@ -1473,175 +3561,940 @@ func (m model) renderAnalysis() string {
 	} else {
 		b.WriteString(statusStyle.Render("(Manual configuration)"))
 	}
-	b.WriteString("\n\n")
+	b.WriteString("\n\n")
+
+	// Recommendations
+	if analysis.LargeFiles > 0 {
+		b.WriteString(warningStyle.Render(withIcon(icons.Warning, "Potential Issues:")))
+		b.WriteString("\n")
+		b.WriteString(fmt.Sprintf("• %d files are larger than the current limit (%s)\n",
+			analysis.LargeFiles, formatSize(m.searchConfig.MaxFileSize)))
+		b.WriteString("• These files will be skipped during search\n")
+		b.WriteString("• Consider increasing max file size in configuration\n\n")
+	}
+
+	// Search scope
+	searchableFiles := analysis.TextFiles - analysis.LargeFiles
+	if !m.searchConfig.IncludeMinified {
+		searchableFiles -= analysis.MinifiedFiles
+	}
+	if searchableFiles <= 0 {
+		b.WriteString(errorStyle.Render(withIcon(icons.Error, "No files will be searched!")))
+		b.WriteString("\n")
+		b.WriteString("All text files are either hidden or too large.\n")
+		b.WriteString("Adjust configuration to include more files.\n")
+	} else {
+		b.WriteString(progressStyle.Render(withIcon(icons.OK, fmt.Sprintf("%d files will be searched", searchableFiles))))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// highlightMatches renders every match span of a line, merging spans that
+// overlap (several terms can match the same text)
+func highlightMatches(text string, matches []MatchRange) string {
+	var b strings.Builder
+	pos := 0
+	for i := 0; i < len(matches); i++ {
+		start, end := matches[i].Start, matches[i].End
+		for i+1 < len(matches) && matches[i+1].Start <= end {
+			i++
+			end = max(end, matches[i].End)
+		}
+		start = max(start, pos)
+		if start >= end || end > len(text) {
+			continue
+		}
+		b.WriteString(text[pos:start])
+		b.WriteString(matchStyle.Render(text[start:end]))
+		pos = end
+	}
+	b.WriteString(text[pos:])
+	return b.String()
+}
+
+// Helper functions
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// depthSteps are the MaxDepth values cycled through in configuration mode
+var depthSteps = []int{0, 1, 2, 3, 5, 10}
+
+// nextDepth returns the depth step following depth, wrapping to unlimited
+func nextDepth(depth int) int {
+	for _, step := range depthSteps {
+		if step > depth {
+			return step
+		}
+	}
+	return depthSteps[0]
+}
+
+// perFileCaps are the MaxPerFile values cycled through in config mode
+var perFileCaps = []int{0, 10, 100, 1000}
+
+// nextPerFileCap returns the cap following the given one
+func nextPerFileCap(limit int) int {
+	for _, step := range perFileCaps {
+		if step > limit {
+			return step
+		}
+	}
+	return perFileCaps[0]
+}
+
+// perFileCapLabel formats a MaxPerFile value for display
+func perFileCapLabel(limit int) string {
+	if limit <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d", limit)
+}
+
+// depthLabel formats a MaxDepth value for display
+func depthLabel(depth int) string {
+	if depth <= 0 {
+		return "unlimited"
+	}
+	if depth == 1 {
+		return "1 level"
+	}
+	return fmt.Sprintf("%d levels", depth)
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func main() {
+	// -config is read before the commands, whose flags default to the config
+	configFile, rest, err := configArg(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	os.Args = append(os.Args[:1], rest...)
+	fileConfig, err := loadConfig(configFile)
+	if err == nil {
+		err = registerCommandExtractors(fileConfig.Extractors)
+	}
+	if err == nil {
+		err = setKeyBindings(fileConfig.Keys)
+	}
+	configEditor = fileConfig.Editor
+	if err == nil {
+		err = setNameOrder(fileConfig.Sort, fileConfig.SortLocale)
+	}
+	if fileConfig.SplitView != nil {
+		splitByDefault = *fileConfig.SplitView
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	// Build the styles for what the terminal can show; -color overrides it
+	level, err := resolveColorLevel(fileConfig.Color)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	setColorLevel(level)
+	if err := setUserThemes(fileConfig.Themes); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	themeIndex, err := findTheme(fileConfig.Theme)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	applyTheme(themes[themeIndex], matchModes[0])
+
+	suggest, err := fileConfig.Suggest.suggestConfig()
+	if err == nil {
+		liveConfig, err = fileConfig.Live.liveConfig()
+	}
+	var always []string
+	if err == nil {
+		always, err = alwaysSearchPatterns(fileConfig.AlwaysSearch)
+	}
+	var network NetworkMode
+	if err == nil {
+		network, err = parseNetworkMode(fileConfig.Network)
+	}
+	var rules []FileRule
+	if err == nil {
+		rules, err = fileRules(fileConfig.Rules)
+	}
+	var skipDirs []string
+	if err == nil {
+		skipDirs, err = skipDirPatterns(fileConfig.SkipDirs)
+	}
+	var textExts, binaryExts []string
+	if err == nil {
+		textExts, binaryExts, err = extensionLists(fileConfig.TextExts, fileConfig.BinaryExts)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	// `zx index` builds trigram indexes; use `zx -- index dir` to search for "index"
+	if len(os.Args) > 1 && os.Args[1] == "index" {
+		indexFlags := flag.NewFlagSet("index", flag.ExitOnError)
+		noIgnore := indexFlags.Bool("no-ignore", false, "Also index paths listed in .gitignore/.ignore/.zxignore")
+		hidden := indexFlags.Bool("hidden", false, "Also index hidden files (dotfiles)")
+		minified := indexFlags.Bool("minified", false, "Also index minified assets and source maps")
+		maxDepth := indexFlags.Int("max-depth", 0, "Descend at most this many directory levels (0 = unlimited)")
+		indexFlags.Parse(os.Args[2:])
+
+		config := SearchConfig{
+			MaxFileSize:     MaxFileSize,
+			NoIgnore:        *noIgnore,
+			IncludeHidden:   *hidden,
+			IncludeMinified: *minified,
+			MaxDepth:        *maxDepth,
+		}
+		if err := runIndexCommand(indexFlags.Args(), config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Recommendations
-	if analysis.LargeFiles > 0 {
-		b.WriteString(warningStyle.Render("⚠️  Potential Issues:"))
-		b.WriteString("\n")
-		b.WriteString(fmt.Sprintf("• %d files are larger than the current limit (%s)\n",
-			analysis.LargeFiles, formatSize(m.searchConfig.MaxFileSize)))
-		b.WriteString("• These files will be skipped during search\n")
-		b.WriteString("• Consider increasing max file size in configuration\n\n")
+	// Settings from the config file, which the flags of each command override
+	defaults := SearchConfig{
+		MaxFileSize:    MaxFileSize,
+		MaxConcurrency: 1, // Printed searches read one file at a time, keeping every result
+		AlwaysSearch:   always,
+		SkipDirs:       skipDirs,
+		TextExts:       textExts,
+		BinaryExts:     binaryExts,
+		Rules:          rules,
+		Network:        network,
+		Suggest:        suggest,
 	}
 
-	// Search scope
-	searchableFiles := analysis.TextFiles - analysis.LargeFiles
-	if searchableFiles <= 0 {
-		b.WriteString(errorStyle.Render("❌ No files will be searched!"))
-		b.WriteString("\n")
-		b.WriteString("All text files are either hidden or too large.\n")
-		b.WriteString("Adjust configuration to include more files.\n")
-	} else {
-		b.WriteString(progressStyle.Render(fmt.Sprintf("✅ %d files will be searched", searchableFiles)))
-		b.WriteString("\n")
+	if len(os.Args) > 1 && os.Args[1] == "help" {
+		fmt.Print(usage)
+		return
 	}
 
-	return b.String()
-}
+	// `zx search pattern [path...]` or `zx search -workspace name pattern`
+	// searches several roots at once
+	if len(os.Args) > 1 && os.Args[1] == "search" {
+		searchFlags := flag.NewFlagSet("search", flag.ExitOnError)
+		sf := fileConfig.Search.flags()
+		var ui uiFlags
+		sf.register(searchFlags)
+		ui.register(searchFlags)
+		workspace := searchFlags.String("workspace", "", "Search the roots of this workspace from the config file")
+		jsonOut := searchFlags.Bool("json", false, "Print a JSON report with per-root summaries and matches")
+		formatName := searchFlags.String("format", "", "Print the matches of all roots in this format: "+strings.Join(formatterNames(), ", "))
+		templateText := searchFlags.String("template", "", "Print each match through a template, e.g. '{path}:{line}:{col} {text}' or Go text/template syntax")
+		sarifFlag := searchFlags.String("sarif-level", sarifLevel, "Level of -format sarif findings: "+strings.Join(sarifLevels, ", "))
+		listFiles := searchFlags.Bool("l", false, "Only list files containing matches")
+		countOnly := searchFlags.Bool("c", false, "Only print match counts per file")
+		progressFlag := searchFlags.String("progress", "", "Report progress on stderr while searching: json, none (default none)")
+		var layoutSet bool
+		registerLayout(searchFlags, &layoutSet)
+		statsFlag := searchFlags.Bool("stats", false, "Print statistics on stderr after the search: files, bytes, matches, time, workers and throughput")
+		args := parseInterspersed(searchFlags, os.Args[2:])
+		config, err := sf.apply(defaults)
+		if err == nil {
+			_, err = ui.apply(fileConfig)
+		}
+		var progress bool
+		if err == nil {
+			progress, err = progressMode(*progressFlag)
+		}
+		if err == nil {
+			err = setSARIFLevel(*sarifFlag)
+		}
+		var formatter Formatter
+		if err == nil && *formatName != "" {
+			formatter, err = formatterFor(*formatName)
+		}
+		if err == nil && *templateText != "" {
+			formatter, err = templateOutput(*templateText, *formatName != "" || *jsonOut || *listFiles || *countOnly)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitFailed)
+		}
 
-func (m model) highlightMatch(text string, start, end int) string {
-	if start < 0 || end > len(text) || start >= end {
-		return text
+		if progress {
+			cliProgress = startProgress(os.Stderr)
+		}
+		code, err := runSearchCommand(args, *workspace, fileConfig.Workspaces, config, *jsonOut, formatter, aggregateFlag(*listFiles, *countOnly), *statsFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(code)
 	}
 
-	before := text[:start]
-	match := text[start:end]
-	after := text[end:]
+	// `zx browse [dir]` opens the interface on a directory
+	if len(os.Args) > 1 && os.Args[1] == "browse" {
+		browseFlags := flag.NewFlagSet("browse", flag.ExitOnError)
+		sf := fileConfig.Search.flags()
+		var ui uiFlags
+		sf.register(browseFlags)
+		ui.register(browseFlags)
+		importPath := browseFlags.String("import", "", "Open a result bundle exported with 'b' first")
+		args := parseInterspersed(browseFlags, os.Args[2:])
+		if len(args) > 1 {
+			fmt.Fprintln(os.Stderr, "Error: usage: zx browse [flags] [dir]")
+			os.Exit(2)
+		}
+		defaults.MaxResults, defaults.MaxConcurrency = MaxResultsInMemory, MaxConcurrentFiles
+		config, err := sf.apply(defaults)
+		var settings uiSettings
+		if err == nil {
+			settings, err = ui.apply(fileConfig)
+		}
+		dir := ""
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		if err == nil {
+			err = runInteractive(dir, config, settings, *importPath)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		return
+	}
 
-	return before + matchStyle.Render(match) + after
-}
+	// `zx analyze [dir]` prints the folder analysis of the interface
+	if len(os.Args) > 1 && os.Args[1] == "analyze" {
+		analyzeFlags := flag.NewFlagSet("analyze", flag.ExitOnError)
+		sf := fileConfig.Search.flags()
+		var ui uiFlags
+		sf.register(analyzeFlags)
+		ui.register(analyzeFlags)
+		jsonOut := analyzeFlags.Bool("json", false, "Print the analysis as JSON")
+		args := parseInterspersed(analyzeFlags, os.Args[2:])
+		defaults.MaxResults, defaults.MaxConcurrency = MaxResultsInMemory, MaxConcurrentFiles
+		config, err := sf.apply(defaults)
+		if err == nil {
+			_, err = ui.apply(fileConfig)
+		}
+		if err == nil {
+			err = runAnalyzeCommand(args, config, *jsonOut, os.Stdout)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		return
+	}
 
-// Helper functions
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
+	// `zx image ref pattern` searches the layers of a container image
+	if len(os.Args) > 1 && os.Args[1] == "image" {
+		imageFlags := flag.NewFlagSet("image", flag.ExitOnError)
+		invertMatch := imageFlags.Bool("v", false, "Invert match: show lines that do NOT match the pattern")
+		fileLevel := imageFlags.Bool("file-level", false, "Evaluate && / ! combinators over whole files instead of lines")
+		listFiles := imageFlags.Bool("l", false, "Only list files containing matches")
+		countOnly := imageFlags.Bool("c", false, "Only print match counts per file")
+		platform := imageFlags.String("platform", defaultPlatform(), "Image platform to pull from multi-platform images (os/arch[/variant])")
+		imageFlags.Parse(os.Args[2:])
+
+		config := SearchConfig{MaxFileSize: MaxFileSize, InvertMatch: *invertMatch, FileLevelMatch: *fileLevel}
+		results, err := runImageCommand(imageFlags.Args(), config, *platform)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
 		}
+		showResults(results, aggregateFlag(*listFiles, *countOnly), false, false)
+		return
 	}
-	return false
-}
 
-func min(a, b int) int {
-	if a < b {
-		return a
+	// `zx k8s pattern [pod...]` searches Kubernetes pod logs through kubectl
+	if len(os.Args) > 1 && os.Args[1] == "k8s" {
+		k8sFlags := flag.NewFlagSet("k8s", flag.ExitOnError)
+		invertMatch := k8sFlags.Bool("v", false, "Invert match: show lines that do NOT match the pattern")
+		var opts kubeOptions
+		k8sFlags.StringVar(&opts.Context, "context", "", "kubeconfig context to use")
+		k8sFlags.StringVar(&opts.Namespace, "n", "", "Namespace of the pods (default from kubeconfig)")
+		k8sFlags.BoolVar(&opts.AllNamespaces, "A", false, "Search pods in all namespaces")
+		k8sFlags.StringVar(&opts.Selector, "l", "", "Label selector, e.g. app=web")
+		k8sFlags.StringVar(&opts.Container, "c", "", "Only this container (default all containers)")
+		k8sFlags.StringVar(&opts.Since, "since", "", "Only logs newer than a duration, e.g. 30m or 2h")
+		k8sFlags.IntVar(&opts.Tail, "tail", -1, "Only the last N lines of each container's log (-1 = all)")
+		k8sFlags.BoolVar(&opts.Previous, "previous", false, "Search the previous instance of restarted containers")
+		k8sFlags.Parse(os.Args[2:])
+
+		config := SearchConfig{InvertMatch: *invertMatch}
+		results, err := runLogsCommand(k8sFlags.Args(), config, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		showResults(results, AggregateNone, false, false)
+		return
 	}
-	return b
-}
 
-func formatSize(size int64) string {
-	const unit = 1024
-	if size < unit {
-		return fmt.Sprintf("%d B", size)
+	// `zx journal pattern` searches the systemd journal through journalctl
+	if len(os.Args) > 1 && os.Args[1] == "journal" {
+		journalFlags := flag.NewFlagSet("journal", flag.ExitOnError)
+		invertMatch := journalFlags.Bool("v", false, "Invert match: show entries that do NOT match the pattern")
+		var opts journalOptions
+		journalFlags.Func("u", "Only entries of this unit (repeatable, globs allowed)", func(unit string) error {
+			opts.Units = append(opts.Units, unit)
+			return nil
+		})
+		journalFlags.StringVar(&opts.Priority, "p", "", "Maximum priority (emerg..debug or 0-7), or a range like warning..err")
+		journalFlags.StringVar(&opts.Since, "since", "", `Only entries since a time, e.g. "1 hour ago" or 2024-05-01`)
+		journalFlags.StringVar(&opts.Until, "until", "", "Only entries until a time")
+		journalFlags.StringVar(&opts.Boot, "b", "", "Only entries of a boot: 0 for the current one, -1 for the previous, ...")
+		journalFlags.BoolVar(&opts.User, "user", false, "Search the user journal instead of the system one")
+		journalFlags.Parse(os.Args[2:])
+
+		config := SearchConfig{InvertMatch: *invertMatch}
+		results, err := runJournalCommand(journalFlags.Args(), config, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		showResults(results, AggregateNone, false, false)
+		return
 	}
-	div, exp := int64(unit), 0
-	for n := size / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+
+	// `zx eventlog pattern` searches the Windows Event Log through wevtutil
+	if len(os.Args) > 1 && os.Args[1] == "eventlog" {
+		eventFlags := flag.NewFlagSet("eventlog", flag.ExitOnError)
+		invertMatch := eventFlags.Bool("v", false, "Invert match: show events that do NOT match the pattern")
+		var opts eventLogOptions
+		eventFlags.Func("channel", "Only events of this log, e.g. System (repeatable; default System and Application)", func(channel string) error {
+			opts.Channels = append(opts.Channels, channel)
+			return nil
+		})
+		eventFlags.IntVar(&opts.MaxLevel, "level", 0, "Maximum level: 1 critical, 2 error, 3 warning, 4 information, 5 verbose")
+		eventFlags.DurationVar(&opts.Since, "since", 0, "Only events newer than a duration, e.g. 24h")
+		eventFlags.IntVar(&opts.Max, "max", 0, "Only the newest N events per log (0 = all)")
+		eventFlags.Parse(os.Args[2:])
+
+		config := SearchConfig{InvertMatch: *invertMatch}
+		results, err := runEventLogCommand(eventFlags.Args(), config, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		showResults(results, AggregateNone, false, false)
+		return
 	}
-	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
-}
 
-func main() {
-	// If arguments provided, use legacy command-line mode
-	if len(os.Args) >= 3 {
-		pattern := os.Args[1]
-		target := os.Args[2]
-
-		// Perform search and show results in TUI
-		results := performLegacySearch(pattern, target)
-		p := tea.NewProgram(legacyResultsModel(results), tea.WithAltScreen())
-		if _, err := p.Run(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error running program: %v\n", err)
-			os.Exit(1)
+	sf := fileConfig.Search.flags()
+	var ui uiFlags
+	sf.register(flag.CommandLine)
+	ui.register(flag.CommandLine)
+	listFiles := flag.Bool("l", false, "Only list files containing matches")
+	countOnly := flag.Bool("c", false, "Only print match counts per file")
+	formatName := flag.String("format", "", "Print results in this format instead of opening the TUI: "+strings.Join(formatterNames(), ", "))
+	templateText := flag.String("template", "", "Print each match through a template instead of opening the TUI, e.g. '{path}:{line}:{col} {text}' or Go text/template syntax")
+	sarifFlag := flag.String("sarif-level", sarifLevel, "Level of -format sarif findings and exports: "+strings.Join(sarifLevels, ", "))
+	var plain, layoutSet bool
+	registerLayout(flag.CommandLine, &layoutSet)
+	statsFlag := flag.Bool("stats", false, "With a pattern and target, print statistics on stderr after the search: files, bytes, matches, time, workers and throughput")
+	flag.BoolVar(&plain, "plain", false, "With a pattern and target, print highlighted path:line:col:text lines instead of opening the TUI (the default when stdout isn't a terminal)")
+	flag.BoolVar(&plain, "no-tui", false, "Same as -plain")
+	progressFlag := flag.String("progress", "", "With a pattern and target, report progress on stderr while searching: json, none (default none)")
+	importPath := flag.String("import", "", "Open a result bundle exported with 'b' instead of searching")
+	patternsFile := flag.String("f", "", "Read patterns from this file (- for stdin), one per line, matching lines with any of them")
+	filesFrom := flag.String("files-from", "", "Search the files (and directories) listed in this file (- for stdin), one per line or NUL-separated, instead of a target")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+	args := parseInterspersed(flag.CommandLine, os.Args[1:])
+
+	// With a pattern and a target, search and print the results; -f
+	// stands in for the pattern and -files-from for the target
+	if len(args) >= 2 || *patternsFile != "" || *filesFrom != "" {
+		pattern, target, files, err := legacyInputs(args, *patternsFile, *filesFrom)
+		var config SearchConfig
+		if err == nil {
+			config, err = sf.apply(defaults)
+		}
+		if err == nil {
+			_, err = ui.apply(fileConfig)
+		}
+		var progress bool
+		if err == nil {
+			progress, err = progressMode(*progressFlag)
+		}
+		if err == nil {
+			err = setSARIFLevel(*sarifFlag)
+		}
+		var formatter Formatter
+		if err == nil && *formatName != "" {
+			if formatter, err = formatterFor(*formatName); err == nil && (*listFiles || *countOnly) {
+				err = fmt.Errorf("-format can't be combined with -l or -c")
+			}
+		}
+		if err == nil && *templateText != "" {
+			formatter, err = templateOutput(*templateText, *formatName != "" || *listFiles || *countOnly)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+
+		if progress {
+			cliProgress = startProgress(os.Stderr)
 		}
+		if _, ok := formatter.(jsonlFormatter); ok {
+			cliStream = newJSONLStream(os.Stdout)
+		}
+		var results SearchResults
+		if *filesFrom != "" {
+			results = performFileListSearch(pattern, target, files, config)
+		} else {
+			results = performLegacySearch(pattern, target, config)
+		}
+		cliProgress.finish()
+		// The search is all this process did
+		results.CPUTime = processCPUTime()
+		if formatter != nil {
+			if err := writeFormatted(os.Stdout, os.Stderr, formatter, results, &config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(2)
+			}
+			if *statsFlag {
+				writeStats(os.Stderr, results)
+			}
+			if len(results.Results) == 0 {
+				os.Exit(1)
+			}
+			return
+		}
+		showResults(results, aggregateFlag(*listFiles, *countOnly), plain || layoutSet, *statsFlag)
 		return
 	}
 
 	// Interactive TUI mode
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error running program: %v\n", err)
-		os.Exit(1)
+	defaults.MaxResults, defaults.MaxConcurrency = MaxResultsInMemory, MaxConcurrentFiles
+	config, err := sf.apply(defaults)
+	var settings uiSettings
+	if err == nil {
+		settings, err = ui.apply(fileConfig)
+	}
+	if err == nil {
+		err = runInteractive("", config, settings, *importPath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
 	}
 }
 
-// Legacy functions for backward compatibility
-func performLegacySearch(pattern, target string) SearchResults {
-	startTime := time.Now()
+// stdinTarget is the target that searches standard input, as in
+// `journalctl | zx error -`
+const stdinTarget = "-"
+
+// stdinName stands for standard input in results, as in grep
+const stdinName = "(standard input)"
 
+// startLegacySearch compiles the pattern of a command-line search over
+// targets and prepares the model that runs it; the model is nil if the
+// pattern is invalid, with the error in the results
+func startLegacySearch(pattern, target string, targets []string, config SearchConfig) (*model, SearchResults) {
 	results := SearchResults{
-		Pattern: pattern,
-		Target:  target,
+		Pattern:  pattern,
+		Target:   target,
+		Inverted: config.InvertMatch,
 	}
 
 	// Validate pattern
-	re, err := regexp.Compile(pattern)
+	query, err := compileQuery(pattern)
 	if err != nil {
 		results.Errors = append(results.Errors, fmt.Sprintf("Invalid regex pattern: %s", err))
-		results.SearchTime = time.Since(startTime)
-		return results
+		return nil, results
+	}
+
+	// Create a temporary model for search methods
+	config.Query = query
+	config.resolveNetwork(targets)
+	results.NetworkSafe = config.NetworkSafe
+	return &model{searchConfig: config}, results
+}
+
+// Legacy functions for backward compatibility
+func performLegacySearch(pattern, target string, config SearchConfig) SearchResults {
+	startTime := time.Now()
+	if target == stdinTarget {
+		return performStdinSearch(pattern, config)
 	}
 
 	// Check if target exists
 	fileInfo, err := os.Stat(target)
 	if err != nil {
+		_, results := startLegacySearch(pattern, target, nil, config)
 		results.Errors = append(results.Errors, fmt.Sprintf("File or folder not found: %s", target))
 		results.SearchTime = time.Since(startTime)
 		return results
 	}
 
-	// Create a temporary model for search methods
-	m := &model{
-		searchConfig: SearchConfig{
-			MaxFileSize:    MaxFileSize,
-			MaxResults:     MaxResultsInMemory,
-			MaxConcurrency: 1, // Single-threaded for legacy mode
-		},
+	m, results := startLegacySearch(pattern, target, []string{target}, config)
+	if m == nil {
+		results.SearchTime = time.Since(startTime)
+		return results
 	}
+	config = m.searchConfig
 
 	ctx := context.Background()
 
+	searched := []string{target}
 	if fileInfo.IsDir() {
-		files, _ := m.collectFilesFromDir(ctx, target)
-		results.TotalFiles = len(files)
-
-		for _, filePath := range files {
-			fileResults, _, err := m.searchFileOptimized(ctx, re, filePath)
-			if err != nil {
-				results.Errors = append(results.Errors, err.Error())
-				continue
+		// Search each file as the walk finds it, until the result limit
+		searched = nil
+		shortlist := m.indexShortlist([]string{target})
+		walkCtx, stopWalk := context.WithCancel(ctx)
+		cliProgress.collect()
+		failed := m.walkFiles(walkCtx, target, func(path string, size int64) {
+			if shortlist.rulesOut(path) {
+				results.IndexSkipped++
+				return
 			}
-			results.Results = append(results.Results, fileResults...)
-		}
+			cliProgress.found(size)
+			results.TotalFiles++
+			searched = append(searched, path)
+			if !m.searchListedFile(ctx, path, &results) {
+				stopWalk()
+			}
+		})
+		stopWalk()
+		cliProgress.walked()
+		results.Failed = append(results.Failed, failed...)
 	} else {
 		results.TotalFiles = 1
-		fileResults, _, err := m.searchFileOptimized(ctx, re, target)
+		cliProgress.collect()
+		cliProgress.collected(1, fileInfo.Size())
+		fileResults, fileSize, err := m.searchFileOptimized(ctx, target)
+		cliProgress.searched(fileSize, totalMatches(fileResults))
+		results.PeakWorkers = 1
 		if err != nil {
 			results.Errors = append(results.Errors, err.Error())
+			results.Failed = append(results.Failed, failureOf(target, err))
 		} else {
+			results.BytesRead = fileSize
 			results.Results = fileResults
+			cliStream.write(fileResults)
 		}
 	}
 
+	if config.SearchStashes {
+		stashResults, stashErrs := m.searchStashes(ctx, []string{target})
+		cliStream.write(stashResults)
+		results.Results = append(results.Results, stashResults...)
+		results.Errors = append(results.Errors, stashErrs...)
+	}
+
 	// Sort results by file path and line number
-	sort.Slice(results.Results, func(i, j int) bool {
-		if results.Results[i].FilePath == results.Results[j].FilePath {
-			return results.Results[i].LineNumber < results.Results[j].LineNumber
+	results.Results = sortResults(results.Results)
+
+	addSuggestions(ctx, &results, searched, config)
+	results.SearchTime = time.Since(startTime)
+	return results
+}
+
+// searchFileList searches files one after another into results, up to
+// the result limit
+func (m *model) searchFileList(ctx context.Context, files []string, results *SearchResults) {
+	for _, filePath := range files {
+		if !m.searchListedFile(ctx, filePath, results) {
+			return
 		}
-		return results.Results[i].FilePath < results.Results[j].FilePath
-	})
+	}
+}
+
+// searchListedFile searches one file of a sequential search into results;
+// it reports false once the result limit is reached
+func (m *model) searchListedFile(ctx context.Context, filePath string, results *SearchResults) bool {
+	limit := m.searchConfig.MaxResults
+	results.PeakWorkers = 1
+	fileResults, fileSize, err := m.searchFileOptimized(ctx, filePath)
+	cliProgress.searched(fileSize, totalMatches(fileResults))
+	if err != nil {
+		results.Errors = append(results.Errors, err.Error())
+		results.Failed = append(results.Failed, failureOf(filePath, err))
+		return true
+	}
+	results.BytesRead += fileSize
+	kept := len(results.Results)
+	results.Results = append(results.Results, fileResults...)
+	full := limit > 0 && len(results.Results) >= limit
+	if full {
+		results.Results = results.Results[:limit]
+	}
+	cliStream.write(results.Results[kept:])
+	if full {
+		results.Truncated = true
+		results.Errors = append(results.Errors, fmt.Sprintf("Stopped at %d results (-max-results raises the limit)", limit))
+		return false
+	}
+	return true
+}
+
+// performFileListSearch searches the files of a list, as given by
+// -files-from, and the contents of its directories. The files are searched
+// even if the filters of a directory search would leave them out.
+func performFileListSearch(pattern, source string, paths []string, config SearchConfig) SearchResults {
+	startTime := time.Now()
+	m, results := startLegacySearch(pattern, "files from "+source, paths, config)
+	if m == nil {
+		results.SearchTime = time.Since(startTime)
+		return results
+	}
+
+	ctx := context.Background()
+	cliProgress.collect()
+	var files []string
+	var size int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			results.Errors = append(results.Errors, fmt.Sprintf("File or folder not found: %s", path))
+		case info.IsDir():
+			dirFiles, dirSize, failed := m.collectFilesFromDir(ctx, path)
+			results.Failed = append(results.Failed, failed...)
+			files = append(files, dirFiles...)
+			size += dirSize
+		default:
+			files = append(files, path)
+			size += info.Size()
+		}
+	}
+	cliProgress.collected(len(files), size)
+	results.TotalFiles = len(files)
+	m.searchFileList(ctx, files, &results)
+
+	results.Results = sortResults(results.Results)
+	addSuggestions(ctx, &results, files, m.searchConfig)
+	results.SearchTime = time.Since(startTime)
+	return results
+}
+
+// performStdinSearch searches the lines of standard input
+func performStdinSearch(pattern string, config SearchConfig) SearchResults {
+	startTime := time.Now()
+	m, results := startLegacySearch(pattern, stdinName, nil, config)
+	if m == nil {
+		results.SearchTime = time.Since(startTime)
+		return results
+	}
 
+	results.TotalFiles = 1
+	cliProgress.collect()
+	cliProgress.collected(1, 0)
+	counted := &countingReader{r: os.Stdin}
+	fileResults, err := m.searchReader(context.Background(), counted, SearchResult{
+		FilePath:     stdinName,
+		Source:       "stdin",
+		LastModified: startTime,
+	})
+	cliProgress.searched(counted.n, totalMatches(fileResults))
+	results.BytesRead, results.PeakWorkers = counted.n, 1
+	if err != nil {
+		results.Errors = append(results.Errors, fmt.Sprintf("%s: %v", stdinName, err))
+	}
+	if limit := config.MaxResults; limit > 0 && len(fileResults) > limit {
+		fileResults = fileResults[:limit]
+		results.Truncated = true
+		results.Errors = append(results.Errors, fmt.Sprintf("Stopped at %d results (-max-results raises the limit)", limit))
+	}
+	for i := range fileResults {
+		fileResults[i].FileSize = counted.n
+	}
+	cliStream.write(fileResults)
+	results.Results = fileResults
 	results.SearchTime = time.Since(startTime)
 	return results
 }
 
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// aggregateFlag maps the -l and -c flags to a result aggregation mode
+func aggregateFlag(listFiles, countOnly bool) AggregateMode {
+	switch {
+	case countOnly:
+		return AggregateCounts
+	case listFiles:
+		return AggregateFiles
+	}
+	return AggregateNone
+}
+
+// showResults presents the results of a command-line search: in the
+// results view, or as plain path:line:col: lines for editors and scripts
+// when stdout isn't a terminal or plain is set (-plain). With stats, the
+// statistics follow on stderr, or open in the results view.
+func showResults(results SearchResults, aggregate AggregateMode, plain, stats bool) {
+	if plain || !isTerminal(os.Stdout) {
+		writePlainResults(os.Stdout, os.Stderr, results, aggregate)
+		if stats {
+			writeStats(os.Stderr, results)
+		}
+		if len(results.Results) == 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	m := legacyResultsModel(results)
+	m.showStats = stats
+	m.resultFilter.Aggregate = aggregate
+	m.applyResultFilters()
+	options := []tea.ProgramOption{tea.WithAltScreen()}
+	if !isTerminal(os.Stdin) {
+		// Standard input was the search's list or text; keys come from the terminal
+		options = append(options, tea.WithInputTTY())
+	}
+	p := tea.NewProgram(m, options...)
+	if _, err := p.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running program: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// highlightOutput reports whether printed results should be colored: when
+// they go to a terminal, or CLICOLOR_FORCE asks for it when piping into
+// `less -R`, unless -color none turned escape sequences off
+func highlightOutput(out io.Writer) bool {
+	if colors == colorNone {
+		return false
+	}
+	if forced := os.Getenv("CLICOLOR_FORCE"); forced != "" && forced != "0" {
+		return true
+	}
+	f, ok := out.(*os.File)
+	return ok && isTerminal(f)
+}
+
+// writePlainResults prints one path:line:col: line per result, the format
+// vim's quickfix list and VS Code's terminal links understand, or one line
+// per file when aggregating (path for -l, path:count for -c, with the total
+// on errOut), laid out as outputLayout says. Errors go to errOut. Paths
+// and matches are highlighted when printing to a terminal.
+func writePlainResults(out, errOut io.Writer, results SearchResults, aggregate AggregateMode) {
+	highlight := highlightOutput(out)
+	layout := outputLayout
+	if aggregate == AggregateNone {
+		writeFormatted(out, errOut, plainFormatter{highlight: highlight, layout: layout}, results, nil)
+		return
+	}
+
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+	files, counts := aggregateByFile(results.Results)
+	for _, r := range files {
+		path := r.FilePath
+		if highlight {
+			path = fileStyle.Render(path)
+		}
+		switch {
+		case aggregate == AggregateCounts && layout.noPath:
+			fmt.Fprintf(w, "%d\n", counts[r.FilePath])
+		case aggregate == AggregateCounts:
+			fmt.Fprintf(w, "%s%s%d\n", path, layout.pathEnd(":"), counts[r.FilePath])
+		default:
+			fmt.Fprintf(w, "%s%s", path, layout.pathEnd("\n"))
+		}
+	}
+	if aggregate == AggregateCounts {
+		fmt.Fprintf(errOut, "%d matches in %d files\n", totalMatches(results.Results), len(files))
+	}
+	for _, e := range results.Errors {
+		fmt.Fprintln(errOut, e)
+	}
+	writeSuggestions(errOut, results)
+}
+
+// writeFormatted prints results with a formatter, and the settings they
+// were found with if config is set; truncated files and errors are
+// reported on errOut. JSON lines streamed during the search (cliStream)
+// aren't printed again.
+func writeFormatted(out, errOut io.Writer, f Formatter, results SearchResults, config *SearchConfig) error {
+	var err error
+	if _, ok := f.(jsonlFormatter); ok && cliStream != nil {
+		err = cliStream.finish()
+	} else {
+		err = f.Format(out, resultReport{
+			Pattern:   results.Pattern,
+			Target:    results.Target,
+			Results:   results.Results,
+			Errors:    results.Errors,
+			Generated: time.Now(),
+			Config:    config,
+			Stats:     searchStats(results),
+		})
+	}
+	for _, r := range results.Results {
+		if r.MoreMatches > 0 {
+			fmt.Fprintf(errOut, "%s: %d more matches not shown\n", r.FilePath, r.MoreMatches)
+		}
+	}
+	for _, e := range results.Errors {
+		fmt.Fprintln(errOut, e)
+	}
+	writeSuggestions(errOut, results)
+	return err
+}
+
 func legacyResultsModel(results SearchResults) model {
 	m := model{
 		mode:          SearchResultsMode,
 		searchResults: results,
 		resultIndex:   0,
+		renderCache:   newResultRenderCache(),
+		paneCache:     &panePreviewCache{},
+		contextCache:  newContextCache(),
+		singlePane:    !splitByDefault,
 	}
+	m.applyResultFilters()
 	return m
 }
 
@@ -1670,11 +4523,36 @@ func (m *model) analyzeFolderStructure(targets []string) FolderAnalysis {
 }
 
 func (m *model) analyzeDirectory(dirPath string, analysis *FolderAnalysis) {
-	filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+	var ignores *ignoreMatcher
+	if !m.searchConfig.NoIgnore {
+		ignores = newIgnoreMatcher(dirPath, !m.searchConfig.IncludeGitignored)
+	}
+
+	descend := func(path string, _ os.FileInfo) bool { return !m.skipsWalkDir(dirPath, path, ignores) }
+	parallelWalk(dirPath, m.searchConfig.walkWorkers(), descend, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
 		}
 
+		if ignores != nil && path != dirPath && ignores.ignored(path, info.IsDir()) {
+			analysis.IgnoredPaths++
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if m.atMaxDepth(dirPath, path, info.IsDir()) {
+			return filepath.SkipDir
+		}
+
+		if info.IsDir() && path != dirPath && m.searchConfig.excludesDir(path) {
+			if m.searchConfig.skipsDir(info.Name()) {
+				analysis.SkippedDirs++
+			}
+			return filepath.SkipDir
+		}
+
 		if !info.IsDir() {
 			m.analyzeFile(path, info, analysis)
 		}
@@ -1690,30 +4568,43 @@ func (m *model) analyzeFile(filePath string, info os.FileInfo, analysis *FolderA
 		analysis.LargestFile = info.Size()
 	}
 
-	// Check if hidden
-	if strings.HasPrefix(filepath.Base(filePath), ".") {
+	// Check if hidden; skipped hidden files aren't counted in other categories
+	if isHidden(filePath) {
 		analysis.HiddenFiles++
-		return // Don't count hidden files in other categories
+		if !m.searchConfig.IncludeHidden {
+			return
+		}
 	}
 
 	// Check if binary
-	if m.isBinaryFile(filePath) {
+	if binary, reason := m.searchConfig.classifyBinary(filePath); binary {
 		analysis.BinaryFiles++
+		if analysis.BinaryReasons == nil {
+			analysis.BinaryReasons = make(map[string]int)
+		}
+		analysis.BinaryReasons[reason]++
 	} else {
 		analysis.TextFiles++
+		if minified, reason := classifyMinified(filePath); minified {
+			analysis.MinifiedFiles++
+			if analysis.MinifiedReasons == nil {
+				analysis.MinifiedReasons = make(map[string]int)
+			}
+			analysis.MinifiedReasons[reason]++
+		}
 	}
 
 	// Check if larger than current threshold
-	if info.Size() > m.searchConfig.MaxFileSize {
+	if m.searchConfig.overSizeLimit(m.searchConfig.ruleFor(filePath), info.Size()) {
 		analysis.LargeFiles++
 	}
 }
 
 func (m *model) generateRecommendations(analysis FolderAnalysis) SearchConfig {
-	config := SearchConfig{
-		MaxConcurrency: runtime.NumCPU(),
-		AutoConfigured: true,
-	}
+	// Start from the current config so user options survive auto-configuration
+	config := m.searchConfig
+	config.MaxConcurrency = runtime.NumCPU()
+	config.AutoConfigured = true
 
 	// Dynamic max file size based on largest files
 	if analysis.LargestFile > 0 {
@@ -1751,6 +4642,17 @@ func (m *model) generateRecommendations(analysis FolderAnalysis) SearchConfig {
 		config.MaxConcurrency = min(cpuCount*3, 100)
 	}
 
+	// Limits given on the command line stay as they are
+	if pinned.maxFileSize {
+		config.MaxFileSize = m.searchConfig.MaxFileSize
+	}
+	if pinned.maxResults {
+		config.MaxResults = m.searchConfig.MaxResults
+	}
+	if pinned.workers {
+		config.MaxConcurrency = m.searchConfig.MaxConcurrency
+	}
+
 	return config
 }
 
@@ -1786,13 +4688,41 @@ func (m *model) showFolderAnalysis(analysis FolderAnalysis) {
 	m.statusMsg = fmt.Sprintf("Analysis complete: %d files, %s total", analysis.TotalFiles, formatSize(analysis.TotalSize))
 }
 
+// handleSearchBatch appends streamed results to the results view
+func (m *model) handleSearchBatch(msg searchBatchMsg) {
+	m.searchResults.Progress = msg.progress
+	m.searchResults.TotalFiles = int(msg.progress.TotalFiles)
+	m.addResults(msg.results)
+}
+
 func (m *model) handleSearchComplete(msg searchCompleteMsg) {
-	// Update the model with results
+	// Keep the cursor on the result the user was looking at while streaming
+	var current *SearchResult
+	if m.resultIndex > 0 && m.resultIndex < m.visibleResults.len() {
+		r := m.visibleResults.at(m.resultIndex)
+		current = &r
+	}
+
+	// Update the model with results, keeping merged partial ones
 	m.searchResults = msg.results
+	if m.merged != nil {
+		m.searchResults.Results, _ = mergeResults(m.searchResults.Results, m.merged.results)
+	}
 	m.resultIndex = 0
 	m.searching = false
 	m.mode = SearchResultsMode
 	m.searchCancel = nil
+	m.applyResultFilters()
+	trendErr := m.recordTrend(m.searchResults)
+	if current != nil {
+		for i := 0; i < m.visibleResults.len(); i++ {
+			if r := m.visibleResults.at(i); r.FilePath == current.FilePath && r.LineNumber == current.LineNumber {
+				m.resultIndex = i
+				break
+			}
+		}
+		m.adjustViewport()
+	}
 
 	// Enhanced status message
 	statusParts := []string{
@@ -1825,6 +4755,15 @@ func (m *model) handleSearchComplete(msg searchCompleteMsg) {
 	if len(msg.results.Errors) > 0 {
 		statusParts = append(statusParts, fmt.Sprintf("(%d errors)", len(msg.results.Errors)))
 	}
+	if len(msg.results.Failed) > 0 {
+		statusParts = append(statusParts, fmt.Sprintf("(%d files left out, E lists them)", len(msg.results.Failed)))
+	}
+	if stopped := stoppedTargets(msg.results.Progress.Targets); len(stopped) > 0 {
+		statusParts = append(statusParts, fmt.Sprintf("(stopped: %s)", strings.Join(stopped, ", ")))
+	}
+	if trendErr != nil {
+		statusParts = append(statusParts, fmt.Sprintf("(error saving trends: %v)", trendErr))
+	}
 
 	m.statusMsg = strings.Join(statusParts, " ")
 }
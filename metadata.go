@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// sizeUnits are the suffixes parseSize accepts, binary like formatSize
+var sizeUnits = map[string]int64{
+	"": 1, "B": 1,
+	"K": 1 << 10, "KB": 1 << 10,
+	"M": 1 << 20, "MB": 1 << 20,
+	"G": 1 << 30, "GB": 1 << 30,
+}
+
+// parseSize parses sizes such as 500, 1KB, 1.5M or 2GB
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	unit, ok := sizeUnits[strings.ToUpper(strings.TrimSpace(s[i:]))]
+	if err != nil || !ok || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (use e.g. 500, 1KB, 5MB)", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// parseAge parses an age such as 30m, 12h, 7d or 2w
+func parseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(strings.TrimRight(s, "dw")); err == nil && n >= 0 && s != "" {
+		switch s[len(s)-1] {
+		case 'd':
+			return time.Duration(n) * 24 * time.Hour, nil
+		case 'w':
+			return time.Duration(n) * 7 * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 12h, 7d, 2w)", s)
+	}
+	return d, nil
+}
+
+// parseSizeRange parses a size range written as bounds (">1KB <5MB",
+// "<100K") or as min..max ("1KB..5MB", "1MB.."); 0 means unbounded
+func parseSizeRange(s string) (minSize, maxSize int64, err error) {
+	if lo, hi, ok := strings.Cut(s, ".."); ok {
+		if strings.TrimSpace(lo) != "" {
+			if minSize, err = parseSize(lo); err != nil {
+				return 0, 0, err
+			}
+		}
+		if strings.TrimSpace(hi) != "" {
+			if maxSize, err = parseSize(hi); err != nil {
+				return 0, 0, err
+			}
+		}
+	} else {
+		for _, bound := range strings.FieldsFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == ',' }) {
+			var size int64
+			switch {
+			case strings.HasPrefix(bound, ">"):
+				if size, err = parseSize(strings.TrimLeft(bound, ">=")); err != nil {
+					return 0, 0, err
+				}
+				minSize = size
+			case strings.HasPrefix(bound, "<"):
+				if size, err = parseSize(strings.TrimLeft(bound, "<=")); err != nil {
+					return 0, 0, err
+				}
+				maxSize = size
+			default:
+				return 0, 0, fmt.Errorf("invalid size bound %q (use e.g. >1KB <5MB)", bound)
+			}
+		}
+	}
+	if maxSize > 0 && minSize > maxSize {
+		return 0, 0, fmt.Errorf("minimum size %s is above the maximum %s", formatSize(minSize), formatSize(maxSize))
+	}
+	return minSize, maxSize, nil
+}
+
+// matchesMetadata reports whether a file passes the size range and
+// modification time filters
+func (c SearchConfig) matchesMetadata(info os.FileInfo) bool {
+	if info.Size() < c.MinSize || (c.MaxSize > 0 && info.Size() > c.MaxSize) {
+		return false
+	}
+	if c.ModifiedWithin > 0 && time.Since(info.ModTime()) > c.ModifiedWithin {
+		return false
+	}
+	return true
+}
+
+// sizeRangeLabel formats the size range filter for display
+func sizeRangeLabel(minSize, maxSize int64) string {
+	switch {
+	case minSize > 0 && maxSize > 0:
+		return fmt.Sprintf("%s to %s", formatSize(minSize), formatSize(maxSize))
+	case minSize > 0:
+		return "at least " + formatSize(minSize)
+	case maxSize > 0:
+		return "at most " + formatSize(maxSize)
+	}
+	return "any"
+}
+
+// ageLabel formats the modification time filter for display
+func ageLabel(age time.Duration) string {
+	switch {
+	case age <= 0:
+		return "any time"
+	case age%(24*time.Hour) == 0:
+		return fmt.Sprintf("last %d days", age/(24*time.Hour))
+	}
+	return "last " + age.String()
+}
+
+// promptSizeRange asks for the range of file sizes to search
+func (m *model) promptSizeRange() {
+	m.prompt = &inputPrompt{
+		label: "Only files sized (e.g. >1KB <5MB or 1KB..5MB, empty for any): ",
+		onSubmit: func(m *model, value string) {
+			minSize, maxSize, err := parseSizeRange(value)
+			if err != nil {
+				m.statusMsg = fmt.Sprintf("Error: %v", err)
+				return
+			}
+			m.pushUndo("change size range")
+			m.searchConfig.MinSize, m.searchConfig.MaxSize = minSize, maxSize
+			m.statusMsg = fmt.Sprintf("Size range set to %s", sizeRangeLabel(minSize, maxSize))
+		},
+	}
+}
+
+// promptModifiedWithin asks how recently searched files must have changed
+func (m *model) promptModifiedWithin() {
+	m.prompt = &inputPrompt{
+		label: "Only files modified within (e.g. 12h, 7d, empty for any time): ",
+		onSubmit: func(m *model, value string) {
+			var age time.Duration
+			if value != "" {
+				var err error
+				if age, err = parseAge(value); err != nil {
+					m.statusMsg = fmt.Sprintf("Error: %v", err)
+					return
+				}
+			}
+			m.pushUndo("change modification time filter")
+			m.searchConfig.ModifiedWithin = age
+			m.statusMsg = fmt.Sprintf("Searching files modified %s", ageLabel(age))
+		},
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// minifiedLineLength is the line length beyond which a web asset is
+// considered minified; hand-written code rarely comes close
+const minifiedLineLength = 1000
+
+// sourceMapTailSize is how much of the end of a web asset is checked for a
+// source map comment, which bundlers write last
+const sourceMapTailSize = 4 << 10
+
+// sourceMapMarker starts the source map comment of a generated asset
+var sourceMapMarker = []byte("sourceMappingURL=")
+
+// Reasons reported by classifyMinified
+const (
+	minifiedReasonName      = "minified name (.min.)"
+	minifiedReasonSourceMap = "source map"
+	minifiedReasonLines     = "very long lines"
+	minifiedReasonDerived   = "generated (has a source map)"
+)
+
+// webAssetExts are checked for long lines and source map references
+var webAssetExts = []string{".js", ".mjs", ".cjs", ".css"}
+
+// classifyMinified reports whether a file is a minified or generated web
+// asset, along with the reason. Such files are mostly single huge lines
+// that slow scanning and match almost anything.
+func classifyMinified(filePath string) (bool, string) {
+	name := strings.ToLower(filepath.Base(filePath))
+	ext := filepath.Ext(name)
+	switch {
+	case ext == ".map":
+		return true, minifiedReasonSourceMap
+	case strings.Contains(name, ".min."):
+		return true, minifiedReasonName
+	}
+
+	isAsset := false
+	for _, assetExt := range webAssetExts {
+		if ext == assetExt {
+			isAsset = true
+			break
+		}
+	}
+	if !isAsset {
+		return false, ""
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, ""
+	}
+	defer file.Close()
+	buf := make([]byte, sniffSize)
+	n, _ := io.ReadFull(file, buf)
+	if minified, reason := sniffMinified(buf[:n]); minified || n < len(buf) {
+		return minified, reason
+	}
+
+	// A large bundle with short lines still ends with its source map
+	info, err := file.Stat()
+	if err != nil {
+		return false, ""
+	}
+	offset := max(info.Size()-sourceMapTailSize, int64(n))
+	tail := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(tail, offset); err == nil && bytes.Contains(tail, sourceMapMarker) {
+		return true, minifiedReasonDerived
+	}
+	return false, ""
+}
+
+// sniffMinified classifies a web asset from a content sample
+func sniffMinified(data []byte) (bool, string) {
+	longest := 0
+	for rest := data; len(rest) > 0; {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line, rest = rest[:i], rest[i+1:]
+		} else {
+			rest = nil
+		}
+		longest = max(longest, len(line))
+	}
+	switch {
+	case longest >= minifiedLineLength:
+		return true, minifiedReasonLines
+	case bytes.Contains(data, sourceMapMarker):
+		return true, minifiedReasonDerived
+	}
+	return false, ""
+}
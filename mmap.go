@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"os"
+)
+
+// searchMappedFile searches a large file through a read-only memory map,
+// avoiding the copies and the line length limit of buffered scanning. It
+// reports false for content that needs decoding (compressed files, non-UTF-8
+// text) or when the file can't be mapped, so the caller can stream it instead.
+func (m *model) searchMappedFile(ctx context.Context, file *os.File, base SearchResult) ([]SearchResult, bool) {
+	data, unmap, err := mmapFile(file, base.FileSize)
+	if err != nil {
+		return nil, false
+	}
+	defer unmap()
+
+	if detectCompression(data[:min(len(data), magicSize)]) != "" {
+		return nil, false
+	}
+	base.Encoding = detectEncoding(data[:min(len(data), sniffSize)])
+	var skipped int64
+	switch base.Encoding {
+	case EncodingUTF8:
+	case EncodingUTF8BOM:
+		data = data[len(bomUTF8):]
+		skipped = int64(len(bomUTF8))
+	default:
+		return nil, false
+	}
+	return m.searchMapped(ctx, data, skipped, base), true
+}
+
+// searchMapped matches the current query against the lines of data, which
+// starts skipped bytes into the file. When every matching line must contain
+// one of a few literals, it jumps between their occurrences and counts
+// newlines only up to each candidate line.
+func (m *model) searchMapped(ctx context.Context, data []byte, skipped int64, base SearchResult) []SearchResult {
+	lm := m.newLineMatcher(base)
+	literals := lm.query.lineLiterals()
+	if literals == nil || m.searchConfig.InvertMatch || m.searchConfig.FileLevelMatch {
+		lineNum, offset := 1, skipped
+		for len(data) > 0 {
+			if lineNum%4096 == 0 && ctx.Err() != nil {
+				return lm.results
+			}
+			line := data
+			if end := bytes.IndexByte(data, '\n'); end >= 0 {
+				line = data[:end+1]
+			}
+			data = data[len(line):]
+			lm.match(lineNum, offset, dropCR(bytes.TrimSuffix(line, []byte{'\n'})))
+			offset += int64(len(line))
+			lineNum++
+		}
+		return lm.finish()
+	}
+
+	// next[i] caches the next occurrence of literals[i] at or after pos, so
+	// rare literals aren't rescanned for every candidate line
+	next := make([]int, len(literals))
+	for i := range next {
+		next[i] = -1
+	}
+	pos, counted, lineNum := 0, 0, 1
+	for pos < len(data) {
+		if ctx.Err() != nil {
+			return lm.results
+		}
+		at := math.MaxInt
+		for i, lit := range literals {
+			if next[i] < pos {
+				if j := bytes.Index(data[pos:], lit); j >= 0 {
+					next[i] = pos + j
+				} else {
+					next[i] = math.MaxInt
+				}
+			}
+			at = min(at, next[i])
+		}
+		if at == math.MaxInt {
+			break
+		}
+
+		start := bytes.LastIndexByte(data[:at], '\n') + 1
+		end := len(data)
+		if j := bytes.IndexByte(data[at:], '\n'); j >= 0 {
+			end = at + j
+		}
+		lineNum += bytes.Count(data[counted:start], []byte{'\n'})
+		counted = start
+		lm.match(lineNum, skipped+int64(start), dropCR(data[start:end]))
+		pos = end + 1
+	}
+	return lm.finish()
+}
+
+// dropCR removes the \r of a CRLF line ending, as bufio.ScanLines does
+func dropCR(line []byte) []byte {
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		return line[:len(line)-1]
+	}
+	return line
+}
@@ -0,0 +1,68 @@
+package main
+
+import "fmt"
+
+// maxCount bounds a count typed before a movement key
+const maxCount = 999999
+
+// cursor returns the index of the entry under the cursor in the file
+// browser or the results list, and how many entries there are
+func (m *model) cursor() (*int, int) {
+	if m.mode == SearchResultsMode {
+		return &m.resultIndex, m.visibleResults.len()
+	}
+	return &m.selectedFile, len(m.files)
+}
+
+// moveCursor moves the cursor by delta entries, stopping at either end
+func (m *model) moveCursor(delta int) {
+	index, n := m.cursor()
+	if n == 0 {
+		return
+	}
+	*index = max(0, min(*index+delta, n-1))
+	m.adjustViewport()
+}
+
+// pageSize is how many entries a page key moves over: the visible rows,
+// or half of them
+func (m model) pageSize(half bool) int {
+	rows := max(m.viewport.height, 1)
+	if half {
+		return max(rows/2, 1)
+	}
+	return rows
+}
+
+// pageMove returns how far a paging key moves the cursor, or false for
+// other keys
+func (m model) pageMove(key string) (int, bool) {
+	switch key {
+	case "pgup":
+		return -m.pageSize(false), true
+	case "pgdown":
+		return m.pageSize(false), true
+	case "ctrl+u":
+		return -m.pageSize(true), true
+	case "ctrl+d":
+		return m.pageSize(true), true
+	}
+	return 0, false
+}
+
+// takeCount reads a digit of a count typed before a movement key, such as
+// the 10 of 10j. It returns the count for any other key, 1 when none was
+// typed, and clears it.
+func (m *model) takeCount(key string) (count int, digit bool) {
+	if len(key) == 1 && key[0] >= '0' && key[0] <= '9' && (key != "0" || m.count > 0) {
+		m.count = min(m.count*10+int(key[0]-'0'), maxCount)
+		m.statusMsg = fmt.Sprintf("Count: %d (a movement key uses it)", m.count)
+		return 0, true
+	}
+	if m.count > 0 {
+		m.statusMsg = ""
+	}
+	count = max(m.count, 1)
+	m.count = 0
+	return count, false
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Network safe mode settings, for targets on NFS, SMB and similar mounts
+// where the defaults tuned for local disks flood the server
+const (
+	networkConcurrency = 4                      // Workers at most
+	networkRetries     = 3                      // Retries of a file after a transient error
+	networkBackoff     = 250 * time.Millisecond // Pause before the first retry, doubled after each
+	networkSlowdown    = 3                      // Factor applied to time budgets such as the quick search's
+)
+
+// NetworkMode chooses when network safe mode applies
+type NetworkMode int
+
+const (
+	NetworkAuto NetworkMode = iota // When a target is on a network file system
+	NetworkOn                      // Always
+	NetworkOff                     // Never
+)
+
+// networkLabel describes a mode for the config view
+func networkLabel(n NetworkMode) string {
+	switch n {
+	case NetworkOn:
+		return "on"
+	case NetworkOff:
+		return "off"
+	}
+	return "auto (on for network file systems)"
+}
+
+// parseNetworkMode reads the -network flag and the network config key
+func parseNetworkMode(s string) (NetworkMode, error) {
+	switch strings.ToLower(s) {
+	case "", "auto":
+		return NetworkAuto, nil
+	case "on", "true":
+		return NetworkOn, nil
+	case "off", "false":
+		return NetworkOff, nil
+	}
+	return NetworkAuto, fmt.Errorf("unknown network mode %q (use auto, on or off)", s)
+}
+
+// resolveNetwork turns network safe mode on or off for a search of targets
+// and tunes the configuration for it. It returns the network file system
+// found, if the mode was detected.
+func (c *SearchConfig) resolveNetwork(targets []string) string {
+	c.NetworkSafe = c.Network == NetworkOn
+	fsType := ""
+	if c.Network == NetworkAuto {
+		for _, target := range targets {
+			if name, ok := networkFS(target); ok {
+				c.NetworkSafe, fsType = true, name
+				break
+			}
+		}
+	}
+	if c.NetworkSafe && (c.MaxConcurrency == 0 || c.MaxConcurrency > networkConcurrency) {
+		c.MaxConcurrency = networkConcurrency
+	}
+	return fsType
+}
+
+// networkBudget lengthens a time budget in network safe mode
+func (c SearchConfig) networkBudget(d time.Duration) time.Duration {
+	if c.NetworkSafe {
+		return d * networkSlowdown
+	}
+	return d
+}
+
+// transientError reports whether err may go away when the file is read
+// again, as network file systems fail reads while a server is slow or
+// a connection is re-established
+func transientError(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.EIO, syscall.ETIMEDOUT, syscall.EAGAIN, syscall.EINTR, syscall.ESTALE, syscall.ECONNRESET} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// searchFileOptimized searches a file, retrying with backoff after
+// transient errors in network safe mode
+func (m *model) searchFileOptimized(ctx context.Context, filePath string) ([]SearchResult, int64, error) {
+	results, size, err := m.searchFileOnce(ctx, filePath)
+	backoff := networkBackoff
+	for retry := 0; retry < networkRetries && err != nil && m.searchConfig.NetworkSafe && transientError(err); retry++ {
+		select {
+		case <-ctx.Done():
+			return results, size, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		results, size, err = m.searchFileOnce(ctx, filePath)
+	}
+	return results, size, err
+}
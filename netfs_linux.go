@@ -0,0 +1,28 @@
+//go:build linux
+
+package main
+
+import "syscall"
+
+// networkMagic names the statfs types of network file systems
+var networkMagic = map[uint32]string{
+	0x6969:     "nfs",
+	0x517B:     "smb",
+	0xFF534D42: "cifs",
+	0xFE534D42: "smb2",
+	0x5346414F: "afs",
+	0x00C36400: "ceph",
+	0x0BD00BD0: "lustre",
+	0x47504653: "gpfs",
+	0x01021997: "9p",
+}
+
+// networkFS reports whether path is on a network file system, and which
+func networkFS(path string) (string, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return "", false
+	}
+	name, ok := networkMagic[uint32(st.Type)]
+	return name, ok
+}
@@ -0,0 +1,35 @@
+//go:build windows
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// driveRemote is what GetDriveTypeW returns for mapped network drives
+const driveRemote = 4
+
+var getDriveType = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDriveTypeW")
+
+// networkFS reports whether path is on a network share, through a UNC
+// path or a mapped drive
+func networkFS(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	if strings.HasPrefix(abs, `\\`) && !strings.HasPrefix(abs, `\\?\`) {
+		return "smb", true
+	}
+	root, err := syscall.UTF16PtrFromString(filepath.VolumeName(abs) + `\`)
+	if err != nil {
+		return "", false
+	}
+	if kind, _, _ := getDriveType.Call(uintptr(unsafe.Pointer(root))); kind != driveRemote {
+		return "", false
+	}
+	return "smb", true
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// noteFor returns the note attached to a result's line, if any
+func (m *model) noteFor(result SearchResult) string {
+	return m.notes[lineKey{result.FilePath, result.LineNumber}]
+}
+
+// promptNote edits the note attached to the selected result's line. Notes
+// are kept for the session, so they reappear when later searches hit the
+// same line.
+func (m *model) promptNote() {
+	if m.resultIndex >= m.visibleResults.len() {
+		return
+	}
+	result := m.visibleResults.at(m.resultIndex)
+	key := lineKey{result.FilePath, result.LineNumber}
+	current := m.notes[key]
+
+	m.prompt = &inputPrompt{
+		label:  fmt.Sprintf("Note for line %d (empty to remove): ", result.LineNumber),
+		input:  current,
+		cursor: len([]rune(current)),
+		onSubmit: func(m *model, value string) {
+			m.renderCache.reset()
+			if value == "" {
+				delete(m.notes, key)
+				m.statusMsg = "Note removed"
+				return
+			}
+			if m.notes == nil {
+				m.notes = make(map[lineKey]string)
+			}
+			m.notes[key] = value
+			m.statusMsg = fmt.Sprintf("Note saved (%d notes)", len(m.notes))
+		},
+	}
+}
+
+// promptReport asks for a file name and writes the annotated report to it
+func (m *model) promptReport() {
+	m.prompt = &inputPrompt{
+		label:  "Write report to: ",
+		input:  "zx-report.md",
+		cursor: len("zx-report.md"),
+		onSubmit: func(m *model, value string) {
+			if value == "" {
+				m.statusMsg = "Cancelled"
+				return
+			}
+			if err := m.writeReport(value, formatters["markdown"]); err != nil {
+				m.statusMsg = fmt.Sprintf("Error: %v", err)
+				return
+			}
+			m.statusMsg = fmt.Sprintf("Report written to %s", value)
+		},
+	}
+}
+
+// report collects the visible results and their notes for a Formatter
+func (m *model) report() resultReport {
+	config := m.searchConfig
+	r := resultReport{
+		Pattern:   m.searchResults.Pattern,
+		Target:    m.searchResults.Target,
+		Results:   m.visibleResults.slice(),
+		Notes:     m.notes,
+		Errors:    m.searchResults.errorMessages(),
+		Generated: time.Now(),
+		Config:    &config,
+		Stats:     searchStats(m.searchResults),
+	}
+	if m.resultFilter.active() {
+		r.Filters = m.resultFilter.describe()
+	}
+	return r
+}
+
+// writeReport writes the visible results to a file in a format
+func (m *model) writeReport(path string, f Formatter) error {
+	return writeReportTo(path, f, m.report())
+}
+
+// writeReportTo writes a report to a file in a format
+func writeReportTo(path string, f Formatter, r resultReport) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := f.Format(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// promptExportResults asks for a file and writes the visible results to
+// it in the format its extension names
+func (m *model) promptExportResults() {
+	m.prompt = &inputPrompt{
+		label:  "Export results to (.json, .jsonl, .csv, .tsv, .md, .sarif, else plain): ",
+		input:  "zx-results.json",
+		cursor: len("zx-results.json"),
+		onSubmit: func(m *model, value string) {
+			if value == "" {
+				m.statusMsg = "Cancelled"
+				return
+			}
+			f := formatterForPath(value)
+			if err := m.writeReport(value, f); err != nil {
+				m.statusMsg = fmt.Sprintf("Error: %v", err)
+				return
+			}
+			m.statusMsg = fmt.Sprintf("Exported %d results as %s to %s", m.visibleResults.len(), f.Name(), value)
+		},
+	}
+}
+
+// markdownCode wraps s in a code span, using a backtick fence longer than
+// any run of backticks inside it
+func markdownCode(s string) string {
+	longest, run := 0, 0
+	for _, c := range s {
+		if c == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", longest+1)
+	if longest > 0 {
+		return fence + " " + s + " " + fence
+	}
+	return fence + s + fence
+}
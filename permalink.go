@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// webRemote is a repository on a recognized web host
+type webRemote struct {
+	host   string // e.g. github.com or gitlab.example.com
+	path   string // owner/repo, or group/subgroup/repo on GitLab
+	gitlab bool
+}
+
+// parseRemote recognizes GitHub and GitLab remotes in SSH (git@host:path,
+// ssh://git@host/path) and HTTPS form
+func parseRemote(remote string) (webRemote, bool) {
+	remote = strings.TrimSpace(remote)
+	var host, path string
+	if u, err := url.Parse(remote); err == nil && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if at, rest, ok := strings.Cut(remote, "@"); ok && !strings.Contains(at, "/") {
+		host, path, _ = strings.Cut(rest, ":")
+	} else {
+		return webRemote{}, false
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+
+	lower := strings.ToLower(host)
+	r := webRemote{host: host, path: path, gitlab: strings.Contains(lower, "gitlab")}
+	if path == "" || (!r.gitlab && !strings.Contains(lower, "github")) {
+		return webRemote{}, false
+	}
+	return r, true
+}
+
+// fileURL returns the web URL of a line of a file at a commit
+func (r webRemote) fileURL(commit, file string, line int) string {
+	var escaped []string
+	for _, part := range strings.Split(file, "/") {
+		escaped = append(escaped, url.PathEscape(part))
+	}
+	blob := "blob"
+	if r.gitlab {
+		blob = "-/blob"
+	}
+	return fmt.Sprintf("https://%s/%s/%s/%s/%s#L%d", r.host, r.path, blob, commit, strings.Join(escaped, "/"), line)
+}
+
+// permalink builds the URL of a result's line at the repository's current
+// commit. changed reports whether the file differs from that commit, in
+// which case the line number may point elsewhere.
+func permalink(ctx context.Context, result SearchResult) (link string, changed bool, err error) {
+	switch {
+	case result.Source != "", result.Layer != "", strings.HasPrefix(result.FilePath, "stash@{"):
+		return "", false, fmt.Errorf("only files in a git checkout have permalinks")
+	case result.Compression != "" || result.Extractor != "":
+		return "", false, fmt.Errorf("line numbers of %s text don't match the file", result.Compression+result.Extractor)
+	}
+
+	abs, err := filepath.Abs(result.FilePath)
+	if err != nil {
+		return "", false, err
+	}
+	repo := findRepoRoot(filepath.Dir(abs))
+	if repo == "" {
+		return "", false, fmt.Errorf("%s is not in a git repository", result.FilePath)
+	}
+
+	remotes, err := runGit(ctx, repo, "remote")
+	if err != nil {
+		return "", false, err
+	}
+	var remote webRemote
+	found := false
+	// Prefer origin, then upstream, then any other remote on a known host
+	names := append([]string{"origin", "upstream"}, splitLines(remotes)...)
+	for _, name := range names {
+		out, err := runGit(ctx, repo, "remote", "get-url", name)
+		if err != nil {
+			continue
+		}
+		if remote, found = parseRemote(string(out)); found {
+			break
+		}
+	}
+	if !found {
+		return "", false, fmt.Errorf("no GitHub or GitLab remote configured")
+	}
+
+	commit, err := runGit(ctx, repo, "rev-parse", "HEAD")
+	if err != nil {
+		return "", false, err
+	}
+	rel, err := filepath.Rel(repo, abs)
+	if err != nil {
+		return "", false, err
+	}
+	rel = filepath.ToSlash(rel)
+	if status, err := runGit(ctx, repo, "status", "--porcelain", "--", rel); err == nil {
+		changed = len(strings.TrimSpace(string(status))) > 0
+	}
+	return remote.fileURL(strings.TrimSpace(string(commit)), rel, result.LineNumber), changed, nil
+}
+
+// copyPermalink copies the permalink of the selected result
+func (m *model) copyPermalink() {
+	if m.resultIndex >= m.visibleResults.len() {
+		return
+	}
+	link, changed, err := permalink(context.Background(), m.visibleResults.at(m.resultIndex))
+	if err != nil {
+		m.statusMsg = fmt.Sprintf("Error: %v", err)
+		return
+	}
+	copyToClipboard(link)
+	m.statusMsg = "Copied " + link
+	if changed {
+		m.statusMsg += " (file has uncommitted changes; the line may differ)"
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"regexp/syntax"
+	"unicode"
+)
+
+// maxPrefilterLiterals caps the alternatives tried per line; beyond it the
+// substring checks cost more than they save
+const maxPrefilterLiterals = 16
+
+// prefilter is a cheap test run on raw line bytes before a term's regex.
+// Every match of the regex contains at least one of literals, so lines
+// containing none can be rejected without running the regex engine.
+type prefilter struct {
+	literals [][]byte // nil when no required literal could be derived
+	exact    bool     // The regex is the literal itself; containing it is a match
+}
+
+// newPrefilter derives the required literals of a compiled regex
+func newPrefilter(re *regexp.Regexp) prefilter {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return prefilter{}
+	}
+	parsed = parsed.Simplify()
+
+	var f prefilter
+	for _, lit := range requiredLiterals(parsed) {
+		f.literals = append(f.literals, []byte(lit))
+	}
+	f.exact = parsed.Op == syntax.OpLiteral && parsed.Flags&syntax.FoldCase == 0
+	return f
+}
+
+// match reports whether line can match (possible) and, for exact filters,
+// whether it does (certain)
+func (f prefilter) match(line []byte) (possible, certain bool) {
+	if f.literals == nil {
+		return true, false
+	}
+	for _, lit := range f.literals {
+		if bytes.Contains(line, lit) {
+			return true, f.exact
+		}
+	}
+	return false, false
+}
+
+// requiredLiterals returns a set of strings at least one of which occurs in
+// any text the regex matches, or nil if there is no such set
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral, syntax.OpConcat:
+		subs := re.Sub
+		if re.Op == syntax.OpLiteral {
+			subs = []*syntax.Regexp{re}
+		}
+
+		// Consecutive literal runes form runs that every match contains;
+		// of those and the children's sets, keep the most selective
+		var best []string
+		var run []rune
+		consider := func(set []string) {
+			if set != nil && (best == nil || shortestLiteral(set) > shortestLiteral(best)) {
+				best = set
+			}
+		}
+		flush := func() {
+			if len(run) > 0 {
+				consider([]string{string(run)})
+			}
+			run = nil
+		}
+		for _, sub := range subs {
+			if sub.Op != syntax.OpLiteral {
+				flush()
+				consider(requiredLiterals(sub))
+				continue
+			}
+			fold := sub.Flags&syntax.FoldCase != 0
+			for _, r := range sub.Rune {
+				// Case-folded letters have several spellings; break the run there
+				if fold && unicode.SimpleFold(r) != r {
+					flush()
+					continue
+				}
+				run = append(run, r)
+			}
+		}
+		flush()
+		return best
+
+	case syntax.OpAlternate:
+		var set []string
+		for _, sub := range re.Sub {
+			alt := requiredLiterals(sub)
+			if alt == nil {
+				return nil
+			}
+			set = append(set, alt...)
+		}
+		if len(set) > maxPrefilterLiterals {
+			return nil
+		}
+		return set
+
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+	}
+	return nil
+}
+
+// shortestLiteral returns the byte length of the shortest string in set
+func shortestLiteral(set []string) int {
+	shortest := len(set[0])
+	for _, s := range set[1:] {
+		shortest = min(shortest, len(s))
+	}
+	return shortest
+}
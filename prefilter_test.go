@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestPrefilterLiterals(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		literals []string // nil: no prefilter
+		exact    bool
+	}{
+		{"hello", []string{"hello"}, true},
+		{"foo.*barbaz", []string{"barbaz"}, false},
+		// The set whose shortest literal is longest wins
+		{"(error|warn)ing", []string{"error", "warn"}, false},
+		{"(a|b)ing", []string{"ing"}, false},
+		{"error|warning", []string{"error", "warning"}, false},
+		{"(abc)+", []string{"abc"}, false},
+		{"(abc){2,}", []string{"abc"}, false},
+		{"(abc)*", nil, false},
+		{"(abc)?x", []string{"x"}, false},
+		{"a|.*", nil, false},
+		{`\d+`, nil, false},
+		// Case-folded letters break the run; the rest remains
+		{"(?i)x-1234", []string{"-1234"}, false},
+		{"(?i)hello", nil, false},
+		{"a|b|c|d|e|f|g|h|i|j|k|l|m|n|o|p|q", nil, false},
+	}
+	for _, tt := range tests {
+		f := newPrefilter(regexp.MustCompile(tt.pattern))
+		var literals []string
+		for _, lit := range f.literals {
+			literals = append(literals, string(lit))
+		}
+		if !reflect.DeepEqual(literals, tt.literals) || f.exact != tt.exact {
+			t.Errorf("newPrefilter(%q) = %q exact=%v; want %q exact=%v", tt.pattern, literals, f.exact, tt.literals, tt.exact)
+		}
+	}
+}
+
+func TestPrefilterMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		line     string
+		possible bool
+		certain  bool
+	}{
+		{"hello", "say hello", true, true},
+		{"hello", "say hi", false, false},
+		{"hel+o", "say hello", true, false},
+		{"error|warning", "a warning", true, false},
+		{"error|warning", "a notice", false, false},
+		{`\d+`, "anything", true, false},
+	}
+	for _, tt := range tests {
+		possible, certain := newPrefilter(regexp.MustCompile(tt.pattern)).match([]byte(tt.line))
+		if possible != tt.possible || certain != tt.certain {
+			t.Errorf("prefilter %q on %q = %v, %v; want %v, %v", tt.pattern, tt.line, possible, certain, tt.possible, tt.certain)
+		}
+	}
+}
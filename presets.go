@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// namedPattern is a reusable search pattern offered by the preset picker
+type namedPattern struct {
+	Name        string
+	Pattern     string
+	Description string
+}
+
+// builtinPatterns ship with zx, sorted by name; user patterns from the
+// config file with the same name replace them
+var builtinPatterns = []namedPattern{
+	{"email", `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`, "Email addresses"},
+	{"go-func", `^func (?:\([^)]*\) )?\w+`, "Go function and method definitions"},
+	{"ipv4", `\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`, "IPv4 addresses"},
+	{"ipv6", `(?i)\b(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}\b|\b(?:[0-9a-f]{1,4}:){1,7}:(?:[0-9a-f]{1,4}(?::[0-9a-f]{1,4}){0,6})?`, "IPv6 addresses, full or :: compressed"},
+	{"stack-trace", `^goroutine \d+ \[|^panic: |^\s+at [\w$.]+\(|^Traceback \(most recent call last\)|^\s+File "[^"]+", line \d+`, "Go, Java and Python stack traces"},
+	{"todo", `\b(?:TODO|FIXME|XXX|HACK)\b`, "TODO, FIXME, XXX and HACK markers"},
+	{"uuid", `(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`, "UUIDs"},
+}
+
+// patternLibrary is the active set of presets, sorted by name
+var patternLibrary = builtinPatterns
+
+// setUserPatterns adds the patterns defined in the config file to the
+// library, replacing built-in ones of the same name
+func setUserPatterns(patterns map[string]string) error {
+	byName := make(map[string]namedPattern)
+	for _, p := range builtinPatterns {
+		byName[p.Name] = p
+	}
+	for name, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid pattern %q in config: %v", name, err)
+		}
+		byName[name] = namedPattern{Name: name, Pattern: pattern, Description: "From config"}
+	}
+
+	library := make([]namedPattern, 0, len(byName))
+	for _, p := range byName {
+		library = append(library, p)
+	}
+	sort.Slice(library, func(i, j int) bool { return library[i].Name < library[j].Name })
+	patternLibrary = library
+	return nil
+}
+
+// composePattern combines the current input with a preset: op is "" to
+// replace the input, or && / || to add the preset as another term
+func composePattern(input, preset, op string) string {
+	if op == "" || strings.TrimSpace(input) == "" {
+		return preset
+	}
+	return strings.TrimSpace(input) + " " + op + " " + preset
+}
+
+// updatePresetPicker handles keys while the preset picker is open
+func (m *model) updatePresetPicker(key string) {
+	switch key {
+	case "esc", "ctrl+p":
+		m.pickingPreset = false
+		m.statusMsg = "Preset picker closed"
+
+	case "up", "k":
+		if m.presetIndex > 0 {
+			m.presetIndex--
+		}
+
+	case "down", "j":
+		if m.presetIndex < len(patternLibrary)-1 {
+			m.presetIndex++
+		}
+
+	case "enter", "&", "|":
+		op := map[string]string{"enter": "", "&": "&&", "|": "||"}[key]
+		preset := patternLibrary[m.presetIndex]
+		m.searchInput = composePattern(m.searchInput, preset.Pattern, op)
+		m.pickingPreset = false
+		m.statusMsg = fmt.Sprintf("Inserted preset %s", preset.Name)
+	}
+}
+
+// renderPresetPicker lists the pattern library with the cursor on the
+// highlighted preset
+func (m model) renderPresetPicker() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Pattern presets (Enter: use, &: add with &&, |: add with ||, Esc: close):"))
+	b.WriteString("\n")
+	for i, p := range patternLibrary {
+		line := fmt.Sprintf("  %-12s %s", p.Name, p.Description)
+		if i == m.presetIndex {
+			b.WriteString(renderSelected(line))
+			b.WriteString("\n")
+			b.WriteString(helpStyle.Render("      " + p.Pattern))
+		} else {
+			b.WriteString(line)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
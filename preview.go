@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// previewMaxBytes caps how much of a file the preview loads
+const previewMaxBytes = 8 << 20 // 8MB
+
+// Syntax highlighting styles, rebuilt by applyTheme
+var (
+	keywordStyle lipgloss.Style
+	stringStyle  lipgloss.Style
+	commentStyle lipgloss.Style
+	numberStyle  lipgloss.Style
+)
+
+// syntaxSpec describes enough of a language's lexical structure to color
+// keywords, strings, comments and numbers line by line
+type syntaxSpec struct {
+	lineComments []string
+	blockStart   string
+	blockEnd     string
+	quotes       string
+	keywords     []string
+	longQuotes   []string // Delimiters of strings that may span lines, ending with the same delimiter
+}
+
+// syntaxSpecs is keyed like definitionTemplates, via languageByExt
+var syntaxSpecs = map[string]syntaxSpec{
+	"go": {[]string{"//"}, "/*", "*/", "\"'", []string{
+		"break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough", "for",
+		"func", "go", "goto", "if", "import", "interface", "map", "package", "range", "return", "select",
+		"struct", "switch", "type", "var", "nil", "true", "false", "iota"}, []string{"`"}},
+	"python": {[]string{"#"}, "", "", "\"'", []string{
+		"and", "as", "assert", "async", "await", "break", "class", "continue", "def", "del", "elif", "else",
+		"except", "finally", "for", "from", "global", "if", "import", "in", "is", "lambda", "nonlocal", "not",
+		"or", "pass", "raise", "return", "try", "while", "with", "yield", "None", "True", "False", "self"},
+		[]string{`"""`, "'''"}},
+	"javascript": {[]string{"//"}, "/*", "*/", "\"'", []string{
+		"async", "await", "break", "case", "catch", "class", "const", "continue", "default", "delete", "do",
+		"else", "enum", "export", "extends", "finally", "for", "function", "if", "implements", "import", "in",
+		"instanceof", "interface", "let", "new", "of", "return", "static", "super", "switch", "this", "throw",
+		"try", "type", "typeof", "var", "void", "while", "yield", "null", "undefined", "true", "false"}, []string{"`"}},
+	"java": {[]string{"//"}, "/*", "*/", "\"'", []string{
+		"abstract", "break", "case", "catch", "class", "const", "continue", "default", "do", "else", "enum",
+		"extends", "final", "finally", "for", "fun", "if", "implements", "import", "interface", "namespace",
+		"new", "override", "package", "private", "protected", "public", "record", "return", "static", "super",
+		"switch", "this", "throw", "throws", "try", "using", "val", "var", "void", "while", "null", "true", "false"},
+		[]string{`"""`}},
+	"c": {[]string{"//"}, "/*", "*/", "\"'", []string{
+		"auto", "break", "case", "char", "class", "const", "continue", "default", "delete", "do", "double",
+		"else", "enum", "extern", "float", "for", "goto", "if", "inline", "int", "long", "namespace", "new",
+		"nullptr", "private", "public", "return", "short", "signed", "sizeof", "static", "struct", "switch",
+		"template", "this", "typedef", "union", "unsigned", "using", "virtual", "void", "volatile", "while",
+		"true", "false", "NULL"}, nil},
+	"rust": {[]string{"//"}, "/*", "*/", "", []string{
+		"as", "async", "await", "break", "const", "continue", "crate", "dyn", "else", "enum", "extern", "fn",
+		"for", "if", "impl", "in", "let", "loop", "match", "mod", "move", "mut", "pub", "ref", "return",
+		"self", "Self", "static", "struct", "super", "trait", "type", "unsafe", "use", "where", "while",
+		"true", "false"}, []string{`"`}},
+	"ruby": {[]string{"#"}, "", "", "\"'", []string{
+		"alias", "and", "begin", "break", "case", "class", "def", "do", "else", "elsif", "end", "ensure",
+		"for", "if", "in", "module", "next", "nil", "not", "or", "redo", "rescue", "retry", "return", "self",
+		"super", "then", "unless", "until", "when", "while", "yield", "true", "false"}, nil},
+	"php": {[]string{"//", "#"}, "/*", "*/", "\"'", []string{
+		"abstract", "as", "break", "case", "catch", "class", "const", "continue", "default", "do", "echo",
+		"else", "elseif", "extends", "final", "finally", "fn", "for", "foreach", "function", "if", "implements",
+		"interface", "namespace", "new", "private", "protected", "public", "return", "static", "switch",
+		"throw", "trait", "try", "use", "while", "null", "true", "false"}, nil},
+	"shell": {[]string{"#"}, "", "", "\"'", []string{
+		"case", "do", "done", "elif", "else", "esac", "export", "fi", "for", "function", "if", "in", "local",
+		"return", "select", "then", "until", "while"}, nil},
+}
+
+// highlighter colors lines of one language, carrying block comments and
+// multi-line strings from line to line
+type highlighter struct {
+	spec     syntaxSpec
+	keywords map[string]bool
+	inBlock  bool
+	inString string // Delimiter of the multi-line string the last line left open
+}
+
+func newHighlighter(filePath string) *highlighter {
+	spec, ok := syntaxSpecs[languageByExt[strings.ToLower(filepath.Ext(filePath))]]
+	if !ok {
+		return nil
+	}
+	h := &highlighter{spec: spec, keywords: make(map[string]bool)}
+	for _, keyword := range spec.keywords {
+		h.keywords[keyword] = true
+	}
+	return h
+}
+
+// line returns the styled text of the next line
+func (h *highlighter) line(text string) string {
+	var b strings.Builder
+	i := 0
+	for i < len(text) {
+		rest := text[i:]
+		if h.inString != "" {
+			end := longStringEnd(rest, h.inString)
+			if end < 0 {
+				b.WriteString(stringStyle.Render(rest))
+				return b.String()
+			}
+			b.WriteString(stringStyle.Render(rest[:end]))
+			h.inString = ""
+			i += end
+			continue
+		}
+		if h.inBlock {
+			end := strings.Index(rest, h.spec.blockEnd)
+			if end < 0 {
+				b.WriteString(commentStyle.Render(rest))
+				return b.String()
+			}
+			end += len(h.spec.blockEnd)
+			b.WriteString(commentStyle.Render(rest[:end]))
+			h.inBlock = false
+			i += end
+			continue
+		}
+
+		if quote := h.longQuote(rest); quote != "" {
+			h.inString = quote
+			b.WriteString(stringStyle.Render(quote))
+			i += len(quote)
+			continue
+		}
+		if h.spec.blockStart != "" && strings.HasPrefix(rest, h.spec.blockStart) {
+			h.inBlock = true
+			b.WriteString(commentStyle.Render(h.spec.blockStart))
+			i += len(h.spec.blockStart)
+			continue
+		}
+		comment := false
+		for _, marker := range h.spec.lineComments {
+			if strings.HasPrefix(rest, marker) {
+				comment = true
+			}
+		}
+		if comment {
+			b.WriteString(commentStyle.Render(rest))
+			return b.String()
+		}
+
+		c := text[i]
+		switch {
+		case strings.IndexByte(h.spec.quotes, c) >= 0:
+			end := i + 1
+			for end < len(text) && text[end] != c {
+				if text[end] == '\\' && c != '`' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(text))
+			b.WriteString(stringStyle.Render(text[i:end]))
+			i = end
+
+		case isWordByte(c):
+			end := i
+			for end < len(text) && isWordByte(text[end]) {
+				end++
+			}
+			word := text[i:end]
+			switch {
+			case h.keywords[word]:
+				b.WriteString(keywordStyle.Render(word))
+			case c >= '0' && c <= '9':
+				b.WriteString(numberStyle.Render(word))
+			default:
+				b.WriteString(word)
+			}
+			i = end
+
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// longQuote returns the multi-line string delimiter text starts with, if any
+func (h *highlighter) longQuote(text string) string {
+	for _, quote := range h.spec.longQuotes {
+		if strings.HasPrefix(text, quote) {
+			return quote
+		}
+	}
+	return ""
+}
+
+// longStringEnd returns where the multi-line string delimited by quote ends
+// in text, just past the closing delimiter, or -1 if it continues on the
+// next line. Backslashes escape the next byte except in back-quoted
+// strings, which Go keeps raw.
+func longStringEnd(text, quote string) int {
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && quote != "`":
+			i++
+		case strings.HasPrefix(text[i:], quote):
+			return i + len(quote)
+		}
+	}
+	return -1
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// filePreview is a file opened in PreviewMode
+type filePreview struct {
+	path      string
+	lines     []string // Styled lines
+	raw       []string // Lines as read, for the highlighted match line
+	truncated bool     // The file is larger than previewMaxBytes
+	target    int      // Index into lines of the line to center and highlight, or -1
+	matches   []MatchRange
+	hits      []int // Indices of lines with results, for n/N
+	offset    int
+	back      AppMode // Mode to return to
+	detail    bool    // Shown as a result's detail view, around its line
+}
+
+// loadPreview reads a file's text the way searches with config do, so line
+// numbers agree with results: extracted or decompressed, then decoded to
+// UTF-8
+func loadPreview(filePath string, config SearchConfig) (*filePreview, error) {
+	if binary, reason := config.classifyBinary(filePath); binary {
+		return nil, fmt.Errorf("%s is a binary file (%s)", filepath.Base(filePath), reason)
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var base SearchResult
+	r, err := contentReader(context.Background(), file, &base)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReaderSize(io.LimitReader(r, previewMaxBytes+1), BufferSize)
+	sample, _ := reader.Peek(sniffSize)
+
+	// Lines are read whole, however long, as the limit above bounds them
+	p := &filePreview{path: filePath, target: -1}
+	text := bufio.NewReaderSize(decodingReader(reader, detectEncoding(sample)), BufferSize)
+	size := 0
+	for {
+		line, err := text.ReadString('\n')
+		if line != "" {
+			size += len(line)
+			if size > previewMaxBytes {
+				p.truncated = true
+				break
+			}
+			p.raw = append(p.raw, strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Lines too long to be code (minified bundles, data) stay plain
+	h := newHighlighter(filePath)
+	p.lines = make([]string, len(p.raw))
+	for i, line := range p.raw {
+		if h != nil && len(line) <= BufferSize {
+			p.lines[i] = h.line(line)
+		} else {
+			p.lines[i] = line
+		}
+	}
+	return p, nil
+}
+
+// focus sets the line to highlight (from 1; 0 for none), its matches and
+// the lines with results
+func (p *filePreview) focus(line int, matches []MatchRange, hits []int) {
+	p.target = -1
+	if line >= 1 && line <= len(p.lines) {
+		p.target = line - 1
+	}
+	p.matches = matches
+	p.hits = nil
+	for _, hit := range hits {
+		if hit >= 1 && hit <= len(p.lines) {
+			p.hits = append(p.hits, hit-1)
+		}
+	}
+	sort.Ints(p.hits)
+}
+
+// openPreview shows a file in PreviewMode, centered on line (from 1; 0 for
+// the top) with matches highlighted
+func (m *model) openPreview(filePath string, line int, matches []MatchRange, hits []int) {
+	p, err := loadPreview(filePath, m.searchConfig)
+	if err != nil {
+		m.statusMsg = fmt.Sprintf("Error: %v", err)
+		return
+	}
+	p.back = m.mode
+	p.focus(line, matches, hits)
+	m.preview = p
+	m.mode = PreviewMode
+	m.centerPreview()
+
+	m.statusMsg = fmt.Sprintf("Previewing %s (%d lines)", filePath, len(p.lines))
+	if p.truncated {
+		m.statusMsg += fmt.Sprintf(", first %s only", formatSize(previewMaxBytes))
+	}
+}
+
+// previewSelectedFile previews the file under the cursor in the browser
+func (m *model) previewSelectedFile() {
+	if m.selectedFile >= len(m.files) {
+		return
+	}
+	file := m.files[m.selectedFile]
+	if file.IsDir {
+		m.statusMsg = "Select a file to preview"
+		return
+	}
+	m.openPreview(file.Path, 0, nil, nil)
+}
+
+// previewSelectedResult previews the file of the selected result, centered
+// on its line, with the file's other result lines reachable with n/N
+func (m *model) previewSelectedResult() {
+	if m.resultIndex >= m.visibleResults.len() {
+		return
+	}
+	result := m.visibleResults.at(m.resultIndex)
+	if reason := unpreviewable(result); reason != "" {
+		m.statusMsg = reason
+		return
+	}
+	m.openPreview(result.FilePath, result.LineNumber, result.Matches, m.resultLines(result.FilePath))
+}
+
+// unpreviewable says why a result's file can't be previewed, or returns ""
+func unpreviewable(result SearchResult) string {
+	switch {
+	case result.Source != "", result.Layer != "", strings.HasPrefix(result.FilePath, "stash@{"):
+		return "Only files on disk can be previewed"
+	case mailFormat(result.FilePath) != "":
+		return "Mail files can't be previewed"
+	}
+	return ""
+}
+
+// resultLines returns the line numbers of the visible results in a file
+func (m model) resultLines(filePath string) []int {
+	var lines []int
+	for _, r := range m.visibleResults.slice() {
+		if r.FilePath == filePath {
+			lines = append(lines, r.LineNumber)
+		}
+	}
+	return lines
+}
+
+// centerPreview scrolls so the target line is in the middle of the view
+func (m *model) centerPreview() {
+	p := m.preview
+	if p.target >= 0 {
+		p.offset = p.target - m.previewHeight()/2
+	}
+	m.clampPreview()
+}
+
+// clampPreview keeps the preview offset within the file
+func (m *model) clampPreview() {
+	p := m.preview
+	p.offset = max(0, min(p.offset, len(p.lines)-m.previewHeight()))
+}
+
+// previewHeight is the number of file lines shown at once; the detail view
+// shows the context around the result, below its header
+func (m model) previewHeight() int {
+	if m.preview != nil && m.preview.detail {
+		return max(min(2*detailContext+1, m.viewport.height-3), 5)
+	}
+	return max(m.viewport.height, 5)
+}
+
+func (m model) updatePreview(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.preview
+	if p.detail {
+		if next, cmd, ok := m.updateDetail(msg); ok {
+			return next, cmd
+		}
+	}
+	switch msg.String() {
+	case "esc", "q", "v":
+		m.mode = p.back
+		m.preview = nil
+		m.statusMsg = "Closed preview"
+		return m, nil
+
+	case "up", "k":
+		p.offset--
+	case "down", "j":
+		p.offset++
+	case "pgup", "ctrl+u":
+		p.offset -= m.previewHeight()
+	case "pgdown", "ctrl+d", " ":
+		p.offset += m.previewHeight()
+	case "home", "g":
+		p.offset = 0
+	case "end", "G":
+		p.offset = len(p.lines)
+	case "c":
+		m.centerPreview()
+
+	case "n", "N":
+		// Jump to the next or previous result line in this file
+		if len(p.hits) == 0 {
+			m.statusMsg = "No results in this file"
+			break
+		}
+		i := sort.SearchInts(p.hits, p.target+1)
+		if msg.String() == "N" {
+			i = sort.SearchInts(p.hits, p.target) - 1
+		}
+		i = (i + len(p.hits)) % len(p.hits)
+		p.target = p.hits[i]
+		p.matches = nil
+		for _, r := range m.visibleResults.slice() {
+			if r.FilePath == p.path && r.LineNumber == p.target+1 {
+				p.matches = r.Matches
+				break
+			}
+		}
+		m.centerPreview()
+		m.statusMsg = fmt.Sprintf("Result %d/%d in this file, line %d", i+1, len(p.hits), p.target+1)
+
+	case "h", "?":
+		m.showHelp = !m.showHelp
+	}
+	m.clampPreview()
+	return m, nil
+}
+
+// renderPreview draws the visible part of the previewed file with line
+// numbers; the target line is highlighted and result lines are marked
+func (m model) renderPreview() string {
+	p := m.preview
+	if p.detail {
+		return m.renderDetail()
+	}
+	var b strings.Builder
+	end := min(p.offset+m.previewHeight(), len(p.lines))
+	b.WriteString(m.renderPreviewLines(p, end))
+
+	info := fmt.Sprintf("Lines %d-%d of %d", min(p.offset+1, end), end, len(p.lines))
+	if p.truncated {
+		info += fmt.Sprintf(" (first %s)", formatSize(previewMaxBytes))
+	}
+	b.WriteString(helpStyle.Render(info))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// renderPreviewLines draws the lines of p from its offset up to end
+func (m model) renderPreviewLines(p *filePreview, end int) string {
+	var b strings.Builder
+	hits := make(map[int]bool, len(p.hits))
+	for _, hit := range p.hits {
+		hits[hit] = true
+	}
+	width := len(fmt.Sprint(len(p.lines)))
+	for i := p.offset; i < end; i++ {
+		marker := " "
+		if hits[i] {
+			marker = "▶"
+		}
+		gutter := fmt.Sprintf("%s%*d │ ", marker, width, i+1)
+		if i == p.target {
+			b.WriteString(renderSelected(gutter + highlightMatches(p.raw[i], p.matches)))
+		} else {
+			b.WriteString(helpStyle.Render(gutter))
+			b.WriteString(p.lines[i])
+		}
+		b.WriteString("\n")
+	}
+	if len(p.lines) == 0 {
+		b.WriteString(helpStyle.Render("(empty file)"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is how often -progress=json reports on a headless run
+const progressInterval = time.Second
+
+// cliProgress counts the progress of a headless search for -progress=json;
+// nil when not asked for. Every root of a workspace search adds to it.
+var cliProgress *progressMeter
+
+// progressMeter accumulates files and bytes searched by a search's workers.
+// A headless search writes it as JSON lines; the interface takes a snapshot
+// of it for each batch of results it streams.
+type progressMeter struct {
+	w     io.Writer
+	start time.Time
+	stop  chan struct{}
+	done  sync.WaitGroup
+
+	collecting int64 // Roots still collecting files
+	totalFiles int64
+	totalBytes int64
+	files      int64
+	bytes      int64
+	matches    int64
+	failed     int64        // Files left out
+	current    atomic.Value // Path of the file a worker started on last
+	active     int64        // Files being searched now
+	peak       int64        // Most files searched at once
+}
+
+// progressLine is one object written by -progress=json
+type progressLine struct {
+	Type       string  `json:"type"`  // progress, then done
+	Phase      string  `json:"phase"` // collecting, searching or done
+	Files      int64   `json:"files"`
+	TotalFiles int64   `json:"total_files"`
+	Bytes      int64   `json:"bytes"`
+	TotalBytes int64   `json:"total_bytes"`
+	Matches    int64   `json:"matches"`
+	Percent    float64 `json:"percent"`
+	ElapsedMs  int64   `json:"elapsed_ms"`
+	ETAMs      *int64  `json:"eta_ms,omitempty"` // Unknown until something is searched
+}
+
+// progressMode checks the value of -progress
+func progressMode(value string) (bool, error) {
+	switch value {
+	case "", "none":
+		return false, nil
+	case "json":
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown progress format %q (use json or none)", value)
+}
+
+// newProgressMeter starts counting a search from start, collecting its
+// files until collected is called
+func newProgressMeter(start time.Time) *progressMeter {
+	return &progressMeter{start: start, collecting: 1}
+}
+
+// startProgress reports to w every progressInterval until finish
+func startProgress(w io.Writer) *progressMeter {
+	p := &progressMeter{w: w, start: time.Now(), stop: make(chan struct{})}
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.write("progress")
+			}
+		}
+	}()
+	return p
+}
+
+// collect marks a root as collecting files until collected is called
+func (p *progressMeter) collect() {
+	if p != nil {
+		atomic.AddInt64(&p.collecting, 1)
+	}
+}
+
+// collected adds the files a root is going to search
+func (p *progressMeter) collected(files int, size int64) {
+	if p != nil {
+		atomic.AddInt64(&p.totalFiles, int64(files))
+		atomic.AddInt64(&p.totalBytes, size)
+		atomic.AddInt64(&p.collecting, -1)
+	}
+}
+
+// found adds one file to search as a root's walk comes to it, so the
+// totals grow until walked is called
+func (p *progressMeter) found(size int64) {
+	if p != nil {
+		atomic.AddInt64(&p.totalFiles, 1)
+		atomic.AddInt64(&p.totalBytes, size)
+	}
+}
+
+// walked marks the walk of a root whose files found counted as over
+func (p *progressMeter) walked() {
+	if p != nil {
+		atomic.AddInt64(&p.collecting, -1)
+	}
+}
+
+// begin records the file a worker starts on, until end
+func (p *progressMeter) begin(path string) {
+	if p == nil {
+		return
+	}
+	p.current.Store(path)
+	active := atomic.AddInt64(&p.active, 1)
+	for peak := atomic.LoadInt64(&p.peak); active > peak; peak = atomic.LoadInt64(&p.peak) {
+		if atomic.CompareAndSwapInt64(&p.peak, peak, active) {
+			break
+		}
+	}
+}
+
+// end records that a worker is done with the file it began
+func (p *progressMeter) end() {
+	if p != nil {
+		atomic.AddInt64(&p.active, -1)
+	}
+}
+
+// searched counts one file searched
+func (p *progressMeter) searched(size int64, matches int) {
+	if p != nil {
+		atomic.AddInt64(&p.files, 1)
+		atomic.AddInt64(&p.bytes, size)
+		atomic.AddInt64(&p.matches, int64(matches))
+	}
+}
+
+// passed counts one file done without searching it: its results were
+// restored, its target was stopped, or it failed to be read
+func (p *progressMeter) passed(failed bool) {
+	if p == nil {
+		return
+	}
+	atomic.AddInt64(&p.files, 1)
+	if failed {
+		atomic.AddInt64(&p.failed, 1)
+	}
+}
+
+// snapshot returns the progress so far for the interface
+func (p *progressMeter) snapshot() SearchProgress {
+	current, _ := p.current.Load().(string)
+	return SearchProgress{
+		TotalFiles:     atomic.LoadInt64(&p.totalFiles),
+		ProcessedFiles: atomic.LoadInt64(&p.files),
+		CurrentFile:    current,
+		TotalSize:      atomic.LoadInt64(&p.totalBytes),
+		ProcessedSize:  atomic.LoadInt64(&p.bytes),
+		Matches:        atomic.LoadInt64(&p.matches),
+		Failed:         atomic.LoadInt64(&p.failed),
+		StartTime:      p.start,
+		Collecting:     atomic.LoadInt64(&p.collecting) > 0,
+	}
+}
+
+// finish stops the reports and writes the last one
+func (p *progressMeter) finish() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	p.done.Wait()
+	p.write("done")
+}
+
+// write prints the current progress as one JSON line
+func (p *progressMeter) write(kind string) {
+	line := progressLine{
+		Type:       kind,
+		Phase:      "searching",
+		Files:      atomic.LoadInt64(&p.files),
+		TotalFiles: atomic.LoadInt64(&p.totalFiles),
+		Bytes:      atomic.LoadInt64(&p.bytes),
+		TotalBytes: atomic.LoadInt64(&p.totalBytes),
+		Matches:    atomic.LoadInt64(&p.matches),
+		ElapsedMs:  time.Since(p.start).Milliseconds(),
+	}
+	switch {
+	case kind == "done":
+		line.Phase = "done"
+	case atomic.LoadInt64(&p.collecting) > 0:
+		line.Phase = "collecting"
+	}
+
+	// Bytes tell how far along a search is better than files, which vary
+	// in size; they are only a fraction once every root has collected, as
+	// the totals grow while files are found
+	done, total := float64(line.Bytes), float64(line.TotalBytes)
+	if total == 0 {
+		done, total = float64(line.Files), float64(line.TotalFiles)
+	}
+	if total > 0 && line.Phase != "collecting" {
+		line.Percent = math.Min(100, math.Round(done/total*1000)/10)
+	}
+	if line.Phase == "searching" && done > 0 && total > done {
+		eta := int64(float64(line.ElapsedMs) * (total - done) / done)
+		line.ETAMs = &eta
+	}
+	if line.Phase == "done" {
+		line.Percent = 100
+	}
+
+	data, _ := json.Marshal(line)
+	fmt.Fprintf(p.w, "%s\n", data)
+}
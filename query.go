@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Query is a compiled search expression made of one or more regex terms
+// combined with boolean operators:
+//
+//	error && ! test     lines containing "error" but not "test"
+//	TODO || FIXME       lines containing either marker
+//	! debug             lines not containing "debug"
+//
+// Operators must be separated from terms by whitespace so that regex
+// alternation (a|b) and groups are left untouched, and a "!" with no space
+// after it, as in "!important", is part of the regex. && binds tighter
+// than ||. A pattern with no operators is used as typed, spaces and all.
+type Query struct {
+	Source   string
+	Patterns []*regexp.Regexp // Compiled regex for each term, in input order
+	Positive []bool           // Whether each term contributes matches (not negated)
+	filters  []prefilter      // Literal prefilter for each term
+	root     queryNode
+}
+
+// queryNode is a node of the boolean expression tree. hits[i] reports
+// whether Patterns[i] matched.
+type queryNode interface {
+	eval(hits []bool) bool
+}
+
+type termNode int
+
+type notNode struct{ child queryNode }
+
+type andNode struct{ children []queryNode }
+
+type orNode struct{ children []queryNode }
+
+func (n termNode) eval(hits []bool) bool { return hits[n] }
+
+func (n notNode) eval(hits []bool) bool { return !n.child.eval(hits) }
+
+func (n andNode) eval(hits []bool) bool {
+	for _, c := range n.children {
+		if !c.eval(hits) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n orNode) eval(hits []bool) bool {
+	for _, c := range n.children {
+		if c.eval(hits) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileQuery parses and compiles a search expression
+func compileQuery(input string) (*Query, error) {
+	q := &Query{Source: input}
+
+	// Split into OR groups, then AND terms
+	orGroups := splitOperator(input, "||")
+	var ors []queryNode
+	for g, group := range orGroups {
+		andTerms := splitOperator(group, "&&")
+		var ands []queryNode
+		for t, term := range andTerms {
+			afterOp, beforeOp := termSides(g, len(orGroups), t, len(andTerms))
+			node, err := q.parseTerm(trimTerm(term, afterOp, beforeOp))
+			if err != nil {
+				return nil, err
+			}
+			ands = append(ands, node)
+		}
+		if len(ands) == 1 {
+			ors = append(ors, ands[0])
+		} else {
+			ors = append(ors, andNode{children: ands})
+		}
+	}
+
+	if len(ors) == 1 {
+		q.root = ors[0]
+	} else {
+		q.root = orNode{children: ors}
+	}
+	return q, nil
+}
+
+// parseTerm compiles a single (optionally negated) regex term
+func (q *Query) parseTerm(term string) (queryNode, error) {
+	negated := false
+	for rest, ok := cutNegation(term); ok; rest, ok = cutNegation(term) {
+		negated = !negated
+		term = rest
+	}
+	if term == "" {
+		return nil, fmt.Errorf("empty term in expression %q", q.Source)
+	}
+
+	re, err := regexp.Compile(term)
+	if err != nil {
+		return nil, err
+	}
+
+	var node queryNode = termNode(len(q.Patterns))
+	q.Patterns = append(q.Patterns, re)
+	q.Positive = append(q.Positive, !negated)
+	q.filters = append(q.filters, newPrefilter(re))
+	if negated {
+		node = notNode{child: node}
+	}
+	return node, nil
+}
+
+// termSides reports whether term t of OR group g has an operator before
+// and after it
+func termSides(g, groups, t, terms int) (afterOp, beforeOp bool) {
+	return g > 0 || t > 0, g < groups-1 || t < terms-1
+}
+
+// trimTerm drops the whitespace between a term and the operators beside
+// it, which belongs to them; a term with no operator beside it is kept as
+// typed
+func trimTerm(term string, afterOp, beforeOp bool) string {
+	if afterOp {
+		term = strings.TrimLeft(term, " \t")
+	}
+	if beforeOp {
+		term = strings.TrimRight(term, " \t")
+	}
+	return term
+}
+
+// cutNegation strips a leading "! " from term. A "!" not followed by
+// whitespace, or followed by nothing else, is part of the regex.
+func cutNegation(term string) (string, bool) {
+	if len(term) < 2 || term[0] != '!' || (term[1] != ' ' && term[1] != '\t') {
+		return term, false
+	}
+	rest := strings.TrimLeft(term[1:], " \t")
+	if rest == "" {
+		return term, false
+	}
+	return rest, true
+}
+
+// splitOperator splits s on op where op is surrounded by whitespace
+func splitOperator(s, op string) []string {
+	var parts []string
+	sep := " " + op + " "
+	for {
+		idx := strings.Index(s, sep)
+		if idx < 0 {
+			break
+		}
+		parts = append(parts, s[:idx])
+		s = s[idx+len(sep):]
+	}
+	return append(parts, s)
+}
+
+// Hits records in hits which terms match the given line. Each term's
+// prefilter runs first, so most non-matching lines never reach the regex.
+func (q *Query) Hits(line []byte, hits []bool) {
+	for i, re := range q.Patterns {
+		possible, certain := q.filters[i].match(line)
+		hits[i] = certain || (possible && re.Match(line))
+	}
+}
+
+// Eval evaluates the expression for the given term hits
+func (q *Query) Eval(hits []bool) bool {
+	return q.root.eval(hits)
+}
+
+// anyPositive reports whether any contributing (non-negated) term hit
+func (q *Query) anyPositive(hits []bool) bool {
+	for i, hit := range hits {
+		if hit && q.Positive[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchRanges returns the match spans of all contributing (non-negated)
+// terms on the line, ordered by start offset
+func (q *Query) MatchRanges(line string) []MatchRange {
+	var ranges []MatchRange
+	for i, re := range q.Patterns {
+		if !q.Positive[i] {
+			continue
+		}
+		for _, loc := range re.FindAllStringIndex(line, -1) {
+			ranges = append(ranges, MatchRange{Start: loc[0], End: loc[1], Term: i})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+	return ranges
+}
+
+// countMatches returns the number of contributing matches on a line, at
+// least 1 for lines that satisfy the expression without one
+func (q *Query) countMatches(line []byte) int {
+	count := 0
+	for i, re := range q.Patterns {
+		if q.Positive[i] {
+			count += len(re.FindAllIndex(line, -1))
+		}
+	}
+	return max(count, 1)
+}
+
+// lineLiterals returns literals at least one of which occurs on every line
+// the expression matches, or nil if the expression has no such literals
+// (e.g. when it can match through a negated term)
+func (q *Query) lineLiterals() [][]byte {
+	switch n := q.root.(type) {
+	case termNode:
+		return q.filters[n].literals
+	case andNode:
+		// Any positive term's literals will do, since all terms must hold
+		for _, c := range n.children {
+			if t, ok := c.(termNode); ok && q.filters[t].literals != nil {
+				return q.filters[t].literals
+			}
+		}
+	case orNode:
+		var literals [][]byte
+		for _, c := range n.children {
+			t, ok := c.(termNode)
+			if !ok || q.filters[t].literals == nil {
+				return nil
+			}
+			literals = append(literals, q.filters[t].literals...)
+		}
+		return literals
+	}
+	return nil
+}
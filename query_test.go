@@ -0,0 +1,162 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCutNegation(t *testing.T) {
+	tests := []struct {
+		term string
+		rest string
+		ok   bool
+	}{
+		{"! debug", "debug", true},
+		{"!\tdebug", "debug", true},
+		{"!   debug", "debug", true},
+		{"!important", "!important", false},
+		{"!", "!", false},
+		{"! ", "! ", false},
+		{"debug", "debug", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		rest, ok := cutNegation(tt.term)
+		if rest != tt.rest || ok != tt.ok {
+			t.Errorf("cutNegation(%q) = %q, %v; want %q, %v", tt.term, rest, ok, tt.rest, tt.ok)
+		}
+	}
+}
+
+func TestCompileQuery(t *testing.T) {
+	tests := []struct {
+		input    string
+		patterns []string
+		positive []bool
+		match    []string
+		noMatch  []string
+	}{
+		{
+			input:    "error",
+			patterns: []string{"error"},
+			positive: []bool{true},
+			match:    []string{"an error"},
+			noMatch:  []string{"fine"},
+		},
+		{
+			// Regex alternation is not an operator
+			input:    "a|b",
+			patterns: []string{"a|b"},
+			positive: []bool{true},
+			match:    []string{"a", "b"},
+			noMatch:  []string{"c"},
+		},
+		{
+			input:    "TODO || FIXME",
+			patterns: []string{"TODO", "FIXME"},
+			positive: []bool{true, true},
+			match:    []string{"TODO: x", "FIXME: y"},
+			noMatch:  []string{"XXX"},
+		},
+		{
+			input:    "error && ! test",
+			patterns: []string{"error", "test"},
+			positive: []bool{true, false},
+			match:    []string{"error in main"},
+			noMatch:  []string{"error in test", "fine"},
+		},
+		{
+			// && binds tighter than ||
+			input:    "a && b || c",
+			patterns: []string{"a", "b", "c"},
+			positive: []bool{true, true, true},
+			match:    []string{"ab", "c"},
+			noMatch:  []string{"a", "b"},
+		},
+		{
+			input:    "! debug",
+			patterns: []string{"debug"},
+			positive: []bool{false},
+			match:    []string{"release"},
+			noMatch:  []string{"debug build"},
+		},
+		{
+			input:    "! ! debug",
+			patterns: []string{"debug"},
+			positive: []bool{true},
+			match:    []string{"debug build"},
+			noMatch:  []string{"release"},
+		},
+		{
+			// A "!" run into its term is part of the regex
+			input:    "!important",
+			patterns: []string{"!important"},
+			positive: []bool{true},
+			match:    []string{"color: red !important"},
+			noMatch:  []string{"important"},
+		},
+		{
+			// A lone pattern keeps its whitespace
+			input:    " x ",
+			patterns: []string{" x "},
+			positive: []bool{true},
+			match:    []string{"a x b"},
+			noMatch:  []string{"ax b"},
+		},
+		{
+			// Operators need whitespace on both sides
+			input:    "a&&b",
+			patterns: []string{"a&&b"},
+			positive: []bool{true},
+			match:    []string{"a&&b"},
+			noMatch:  []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		q, err := compileQuery(tt.input)
+		if err != nil {
+			t.Errorf("compileQuery(%q): %v", tt.input, err)
+			continue
+		}
+		var patterns []string
+		for _, re := range q.Patterns {
+			patterns = append(patterns, re.String())
+		}
+		if !reflect.DeepEqual(patterns, tt.patterns) || !reflect.DeepEqual(q.Positive, tt.positive) {
+			t.Errorf("compileQuery(%q) = %q %v; want %q %v", tt.input, patterns, q.Positive, tt.patterns, tt.positive)
+		}
+		for _, want := range []bool{true, false} {
+			lines := tt.match
+			if !want {
+				lines = tt.noMatch
+			}
+			for _, line := range lines {
+				hits := make([]bool, len(q.Patterns))
+				q.Hits([]byte(line), hits)
+				if got := q.Eval(hits); got != want {
+					t.Errorf("compileQuery(%q) on %q = %v; want %v", tt.input, line, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestCompileQueryErrors(t *testing.T) {
+	for _, input := range []string{"a && ", " || b", "(", "a && ! ("} {
+		if _, err := compileQuery(input); err == nil {
+			t.Errorf("compileQuery(%q) succeeded; want an error", input)
+		}
+	}
+}
+
+func TestMatchRanges(t *testing.T) {
+	q, err := compileQuery("b+ || a && ! z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := q.MatchRanges("abba")
+	want := []MatchRange{{Start: 0, End: 1, Term: 1}, {Start: 1, End: 3, Term: 0}, {Start: 3, End: 4, Term: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MatchRanges = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Limits applied by a quick search
+const (
+	quickSearchBudget = 5 * time.Second
+	quickMaxFileSize  = 1 << 20 // 1MB
+	quickPerFile      = 1       // First match per file; the rest are counted
+)
+
+// performQuickSearch runs a rough, time-boxed search: small files only,
+// ignore files respected, one result per file and at most
+// quickSearchBudget of searching. The limits are lifted again by the next
+// search, e.g. escalateSearch.
+func (m *model) performQuickSearch() tea.Cmd {
+	m.quickSearch = true
+	return m.performSearch()
+}
+
+// applyQuickLimits tightens the configuration for a quick search, saving
+// the full configuration for restoreQuickLimits
+func (m *model) applyQuickLimits() {
+	saved := m.searchConfig
+	m.quickSaved = &saved
+	if m.searchConfig.MaxFileSize > quickMaxFileSize {
+		m.searchConfig.MaxFileSize = quickMaxFileSize
+	}
+	m.searchConfig.NoIgnore, m.searchConfig.IncludeGitignored = false, false
+	m.searchConfig.MaxPerFile = quickPerFile
+}
+
+// restoreQuickLimits undoes applyQuickLimits after a quick search
+func (m *model) restoreQuickLimits() {
+	if m.quickSaved != nil {
+		m.searchConfig = *m.quickSaved
+		m.quickSaved = nil
+	}
+}
+
+// escalateSearch reruns a quick search's pattern as a full search
+func (m *model) escalateSearch() tea.Cmd {
+	if !m.searchResults.Quick || m.searchResults.Pattern == "" {
+		m.statusMsg = "Not a quick search; press s to start a new one"
+		return nil
+	}
+	m.searchInput = m.searchResults.Pattern
+	return m.performSearch()
+}
+
+// quickSummary describes the limits of a quick search for the results view
+func quickSummary() string {
+	return fmt.Sprintf("[quick: first match per file, files ≤ %s, %v budget; F for a full search]",
+		formatSize(quickMaxFileSize), quickSearchBudget)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Reference kinds assigned by classifyReference
+const (
+	RefDefinition = "definition"
+	RefUsage      = "usage"
+)
+
+// definitionTemplates holds per-language patterns recognizing a definition
+// of NAME. NAME is replaced by the quoted matched text before compiling.
+var definitionTemplates = map[string][]string{
+	"go": {
+		`\bfunc\s+(?:\([^)]*\)\s*)?NAME\s*[\[(]`,
+		`\b(?:type|var|const)\s+NAME\b`,
+		`^\s*NAME\s*(?:,\s*\w+\s*)*:=`,
+	},
+	"python": {
+		`^\s*(?:async\s+)?def\s+NAME\b`,
+		`^\s*class\s+NAME\b`,
+		`^\s*NAME\s*(?::[^=]*)?=[^=]`,
+	},
+	"javascript": {
+		`\bfunction\s*\*?\s*NAME\b`,
+		`\b(?:class|interface|type|enum)\s+NAME\b`,
+		`\b(?:const|let|var)\s+NAME\b`,
+		`^\s*(?:async\s+)?NAME\s*\([^)]*\)\s*\{`,
+	},
+	"java": {
+		`\b(?:class|interface|enum|record)\s+NAME\b`,
+		`^\s*(?:(?:public|private|protected|static|final|abstract|synchronized|override)\s+)*[\w<>\[\],.?]+\s+NAME\s*\([^;]*$`,
+	},
+	"c": {
+		`^\s*(?:[\w*&:<>]+\s+)+\**NAME\s*\([^;]*$`,
+		`#\s*define\s+NAME\b`,
+		`\b(?:struct|class|enum|union|typedef)\s+NAME\b`,
+	},
+	"rust": {
+		`\bfn\s+NAME\b`,
+		`\b(?:struct|enum|trait|type|mod|const|static)\s+NAME\b`,
+		`\blet\s+(?:mut\s+)?NAME\b`,
+	},
+	"ruby": {
+		`^\s*def\s+(?:self\.)?NAME\b`,
+		`^\s*(?:class|module)\s+NAME\b`,
+	},
+	"php": {
+		`\bfunction\s+NAME\b`,
+		`\b(?:class|interface|trait)\s+NAME\b`,
+	},
+	"shell": {
+		`^\s*(?:function\s+)?NAME\s*\(\)`,
+		`^\s*(?:export\s+|local\s+)?NAME=`,
+	},
+}
+
+// languageByExt maps file extensions to definitionTemplates keys
+var languageByExt = map[string]string{
+	".go": "go",
+	".py": "python",
+	".js": "javascript", ".jsx": "javascript", ".ts": "javascript", ".tsx": "javascript", ".mjs": "javascript",
+	".java": "java", ".kt": "java", ".cs": "java", ".scala": "java",
+	".c": "c", ".h": "c", ".cpp": "c", ".hpp": "c", ".cc": "c",
+	".rs":  "rust",
+	".rb":  "ruby",
+	".php": "php",
+	".sh":  "shell", ".bash": "shell", ".zsh": "shell",
+}
+
+// refClassifier caches compiled definition patterns per language and symbol
+type refClassifier struct {
+	cache map[string][]*regexp.Regexp
+}
+
+func newRefClassifier() *refClassifier {
+	return &refClassifier{cache: make(map[string][]*regexp.Regexp)}
+}
+
+// classify reports whether a result is a definition or a usage of the text
+// it matched, based on simple per-language patterns
+func (c *refClassifier) classify(result SearchResult) string {
+	lang := languageByExt[strings.ToLower(filepath.Ext(result.FilePath))]
+	if lang == "" || len(result.Matches) == 0 {
+		return RefUsage
+	}
+	first := result.Matches[0]
+	if first.End <= first.Start || first.End > len(result.LineContent) {
+		return RefUsage
+	}
+	symbol := result.LineContent[first.Start:first.End]
+
+	key := lang + "\x00" + symbol
+	patterns, ok := c.cache[key]
+	if !ok {
+		for _, tmpl := range definitionTemplates[lang] {
+			re, err := regexp.Compile(strings.ReplaceAll(tmpl, "NAME", regexp.QuoteMeta(symbol)))
+			if err == nil {
+				patterns = append(patterns, re)
+			}
+		}
+		c.cache[key] = patterns
+	}
+
+	for _, re := range patterns {
+		if re.MatchString(result.LineContent) {
+			return RefDefinition
+		}
+	}
+	return RefUsage
+}
+
+// fileRefCount is the per-file row of the reference summary
+type fileRefCount struct {
+	Path        string
+	Definitions int
+	Usages      int
+}
+
+// classifyReferences tags every result with its reference kind
+func (m *model) classifyReferences() {
+	classifier := newRefClassifier()
+	for i := range m.searchResults.Results {
+		m.searchResults.Results[i].RefKind = classifier.classify(m.searchResults.Results[i])
+	}
+	m.applyResultFilters()
+}
+
+// referenceSummary aggregates definition/usage counts per file, files with
+// definitions first
+func (m *model) referenceSummary() []fileRefCount {
+	byFile := make(map[string]*fileRefCount)
+	var order []string
+	for _, r := range m.visibleResults.slice() {
+		counts, ok := byFile[r.FilePath]
+		if !ok {
+			counts = &fileRefCount{Path: r.FilePath}
+			byFile[r.FilePath] = counts
+			order = append(order, r.FilePath)
+		}
+		if r.RefKind == RefDefinition {
+			counts.Definitions++
+		} else {
+			counts.Usages++
+		}
+	}
+
+	summary := make([]fileRefCount, 0, len(order))
+	for _, path := range order {
+		summary = append(summary, *byFile[path])
+	}
+	sort.SliceStable(summary, func(i, j int) bool {
+		if summary[i].Definitions != summary[j].Definitions {
+			return summary[i].Definitions > summary[j].Definitions
+		}
+		return summary[i].Path < summary[j].Path
+	})
+	return summary
+}
+
+func (m model) renderReferenceSummary() string {
+	var b strings.Builder
+
+	summary := m.referenceSummary()
+	totalDefs, totalUses := 0, 0
+	for _, row := range summary {
+		totalDefs += row.Definitions
+		totalUses += row.Usages
+	}
+
+	b.WriteString(headerStyle.Render(fmt.Sprintf("References: %d definitions, %d usages in %d files",
+		totalDefs, totalUses, len(summary))))
+	b.WriteString("\n\n")
+
+	b.WriteString(fmt.Sprintf("%6s %6s  %s\n", "defs", "uses", "file"))
+	end := min(len(summary), max(m.viewport.height, 1))
+	for _, row := range summary[:end] {
+		line := fmt.Sprintf("%6d %6d  %s", row.Definitions, row.Usages, row.Path)
+		if row.Definitions > 0 {
+			b.WriteString(progressStyle.Render(line))
+		} else {
+			b.WriteString(fileStyle.Render(line))
+		}
+		b.WriteString("\n")
+	}
+	if end < len(summary) {
+		b.WriteString(helpStyle.Render(fmt.Sprintf("... and %d more files", len(summary)-end)))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render("Heuristic: definitions are recognized by per-language patterns"))
+	return b.String()
+}
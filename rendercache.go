@@ -0,0 +1,75 @@
+package main
+
+// maxCachedEntries bounds the render cache; scrolling far through a large
+// result set starts it over rather than keeping every entry
+const maxCachedEntries = 4096
+
+// styleKey identifies the styles and the line layout a cached entry was
+// rendered with
+type styleKey struct {
+	theme, matchMode, icons int
+	width                   int
+	wrap                    bool
+}
+
+// resultRenderCache keeps the styled text of unselected result entries
+// and the match total between frames, so moving through large result sets
+// only restyles the entry under the cursor. It's shared by the model's
+// copies and must be reset whenever the results or the notes change.
+type resultRenderCache struct {
+	styles  styleKey
+	entries map[int]string // By index into visibleResults.base
+	matches int            // totalMatches of searchResults.Results, or -1
+}
+
+func newResultRenderCache() *resultRenderCache {
+	return &resultRenderCache{entries: make(map[int]string), matches: -1}
+}
+
+// reset drops all cached entries
+func (c *resultRenderCache) reset() {
+	if c != nil {
+		clear(c.entries)
+		c.matches = -1
+	}
+}
+
+// forget drops the cached entry of a result whose rendering changed
+func (c *resultRenderCache) forget(key int) {
+	if c != nil {
+		delete(c.entries, key)
+	}
+}
+
+// cachedTotalMatches returns totalMatches of all results
+func (m model) cachedTotalMatches() int {
+	c := m.renderCache
+	if c == nil {
+		return totalMatches(m.searchResults.Results)
+	}
+	if c.matches < 0 {
+		c.matches = totalMatches(m.searchResults.Results)
+	}
+	return c.matches
+}
+
+// cachedResultEntry renders an unselected result entry, reusing the text
+// from an earlier frame when it's still valid
+func (m model) cachedResultEntry(i int) string {
+	c := m.renderCache
+	if c == nil {
+		return m.renderResultEntry(i, false)
+	}
+	styles := styleKey{m.themeIndex, m.matchModeIndex, m.iconIndex, m.lineWidth(), m.wrapLines}
+	if c.styles != styles || len(c.entries) >= maxCachedEntries {
+		c.styles = styles
+		clear(c.entries)
+	}
+	key := m.visibleResults.index(i)
+	if entry, ok := c.entries[key]; ok {
+		return entry
+	}
+	entry := m.renderResultEntry(i, false)
+	c.entries[key] = entry
+	return entry
+}
@@ -0,0 +1,193 @@
+package main
+
+import "sort"
+
+// resultPageSize is the number of results in each page of a resultPages
+const resultPageSize = 4096
+
+// resultPages collects a search's results in pages of a fixed size, so
+// adding to hundreds of thousands of them never copies the ones collected
+// before
+type resultPages struct {
+	pages [][]SearchResult
+	n     int
+}
+
+// add appends results to the last page, starting a new one when it's full
+func (p *resultPages) add(results ...SearchResult) {
+	for _, r := range results {
+		if p.n%resultPageSize == 0 {
+			p.pages = append(p.pages, make([]SearchResult, 0, resultPageSize))
+		}
+		last := len(p.pages) - 1
+		p.pages[last] = append(p.pages[last], r)
+		p.n++
+	}
+}
+
+// len returns the number of results collected
+func (p *resultPages) len() int {
+	return p.n
+}
+
+// at returns the i'th result collected
+func (p *resultPages) at(i int) *SearchResult {
+	return &p.pages[i/resultPageSize][i%resultPageSize]
+}
+
+// sorted returns the results ordered by path and line number
+func (p *resultPages) sorted() []SearchResult {
+	return orderByFile(p.n, p.at)
+}
+
+// sortResults orders results by path and line number
+func sortResults(results []SearchResult) []SearchResult {
+	return orderByFile(len(results), func(i int) *SearchResult { return &results[i] })
+}
+
+// orderByFile groups n results by file, in path order, and orders each
+// file's results by line number. Workers send a file's results in line
+// order, interleaved with other files', so only the files are sorted as a
+// whole; the results are copied once, into place.
+func orderByFile(n int, at func(i int) *SearchResult) []SearchResult {
+	byFile := make(map[string][]int)
+	var paths []string
+	for i := 0; i < n; i++ {
+		path := at(i).FilePath
+		if _, ok := byFile[path]; !ok {
+			paths = append(paths, path)
+		}
+		byFile[path] = append(byFile[path], i)
+	}
+	sort.Strings(paths)
+
+	ordered := make([]SearchResult, 0, n)
+	for _, path := range paths {
+		rows := byFile[path]
+		if !sort.SliceIsSorted(rows, func(a, b int) bool { return at(rows[a]).LineNumber < at(rows[b]).LineNumber }) {
+			sort.SliceStable(rows, func(a, b int) bool { return at(rows[a]).LineNumber < at(rows[b]).LineNumber })
+		}
+		for _, i := range rows {
+			ordered = append(ordered, *at(i))
+		}
+	}
+	return ordered
+}
+
+// resultList is what the results view lists: all of the results, the
+// ones the filters keep, or one row per file. Rows refer to results by
+// their index in base, which stays the same as a search streams in more
+// of them, so rows styled for one frame are still valid in the next and
+// a batch only adds rows instead of rebuilding the list.
+type resultList struct {
+	base   []SearchResult // searchResults.Results, or the per-file rows
+	rows   []int          // Indices into base of the rows, or nil for all of base
+	counts *resultCounts  // Tallies of searchResults.Results, when filtering
+}
+
+// len returns the number of rows
+func (l resultList) len() int {
+	if l.rows == nil {
+		return len(l.base)
+	}
+	return len(l.rows)
+}
+
+// index returns the index in base of row i
+func (l resultList) index(i int) int {
+	if l.rows == nil {
+		return i
+	}
+	return l.rows[i]
+}
+
+// at returns row i
+func (l resultList) at(i int) SearchResult {
+	return l.base[l.index(i)]
+}
+
+// slice returns the rows as results of their own, for exports and scans
+// through all of them
+func (l resultList) slice() []SearchResult {
+	if l.rows == nil {
+		return l.base
+	}
+	results := make([]SearchResult, len(l.rows))
+	for i, row := range l.rows {
+		results[i] = l.base[row]
+	}
+	return results
+}
+
+// resultCounts tallies the matches in each file and line of the results,
+// and where each file's results are, so filtering more results doesn't
+// start the count over
+type resultCounts struct {
+	files map[string]int   // Matches per file
+	lines map[lineKey]int  // Matches per line
+	rows  map[string][]int // Indices of each file's results
+	n     int              // Results counted so far
+}
+
+func newResultCounts() *resultCounts {
+	return &resultCounts{
+		files: make(map[string]int),
+		lines: make(map[lineKey]int),
+		rows:  make(map[string][]int),
+	}
+}
+
+// add counts the results past the ones counted before. It returns the
+// files whose earlier results the thresholds of f now let through, and
+// whether a line's did, which needs the rows rebuilt.
+func (c *resultCounts) add(results []SearchResult, f ResultFilter) (files []string, lines bool) {
+	from := c.n
+	for i := from; i < len(results); i++ {
+		r := results[i]
+		key := lineKey{r.FilePath, r.LineNumber}
+		before := c.files[r.FilePath]
+		c.files[r.FilePath] += r.matchCount() + r.MoreMatches
+		if before > 0 && before < f.MinFileMatches && c.files[r.FilePath] >= f.MinFileMatches && c.rows[r.FilePath][0] < from {
+			files = append(files, r.FilePath)
+		}
+		if c.lines[key] > 0 && c.lines[key] < f.MinLineMatches && c.lines[key]+r.matchCount() >= f.MinLineMatches {
+			lines = true
+		}
+		c.lines[key] += r.matchCount()
+		c.rows[r.FilePath] = append(c.rows[r.FilePath], i)
+	}
+	c.n = len(results)
+	return files, lines
+}
+
+// passes reports whether a result meets the thresholds of f
+func (c *resultCounts) passes(r SearchResult, f ResultFilter) bool {
+	if f.MinFileMatches > 1 && c.files[r.FilePath] < f.MinFileMatches {
+		return false
+	}
+	return f.MinLineMatches <= 1 || c.lines[lineKey{r.FilePath, r.LineNumber}] >= f.MinLineMatches
+}
+
+// filter returns the indices of results[from:] that meet the thresholds
+func (c *resultCounts) filter(results []SearchResult, from int, f ResultFilter) []int {
+	rows := make([]int, 0, len(results)-from)
+	for i := from; i < len(results); i++ {
+		if c.passes(results[i], f) {
+			rows = append(rows, i)
+		}
+	}
+	return rows
+}
+
+// mergeRows merges two ascending lists of indices
+func mergeRows(a, b []int) []int {
+	merged := make([]int, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if a[0] < b[0] {
+			merged, a = append(merged, a[0]), a[1:]
+		} else {
+			merged, b = append(merged, b[0]), b[1:]
+		}
+	}
+	return append(append(merged, a...), b...)
+}
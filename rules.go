@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// noSizeLimit is the FileRule.MaxFileSize of files searched however large
+const noSizeLimit = -1
+
+// RuleFileConfig is one [[rules]] table of config.toml, e.g.
+//
+//	[[rules]]
+//	pattern = "*.log"
+//	workers = 4         # Search at most 4 of these files at once
+//	max_size = "none"   # No size limit; or a size such as "1GB"
+//
+//	[[rules]]
+//	pattern = "*.min.js"
+//	skip = true
+type RuleFileConfig struct {
+	Pattern string `toml:"pattern"`
+	Skip    bool   `toml:"skip"`
+	MaxSize string `toml:"max_size"`
+	Workers int    `toml:"workers"`
+}
+
+// FileRule overrides the skips, size limit and concurrency of the files
+// whose names match Pattern
+type FileRule struct {
+	Pattern     string
+	Skip        bool  // Never search these files
+	MaxFileSize int64 // Size limit instead of MaxFileSize (0 = MaxFileSize, noSizeLimit = none)
+	Workers     int   // Files of the rule searched at once (0 = MaxConcurrency)
+}
+
+// fileRules checks the [[rules]] tables of config.toml
+func fileRules(tables []RuleFileConfig) ([]FileRule, error) {
+	var rules []FileRule
+	for _, t := range tables {
+		pattern := strings.TrimSpace(t.Pattern)
+		if pattern == "" {
+			return nil, fmt.Errorf("a rule needs a pattern such as \"*.log\"")
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid rule pattern %q: %v", pattern, err)
+		}
+		if t.Workers < 0 {
+			return nil, fmt.Errorf("rule %q: workers can't be negative", pattern)
+		}
+		rule := FileRule{Pattern: pattern, Skip: t.Skip, Workers: t.Workers}
+		switch strings.ToLower(strings.TrimSpace(t.MaxSize)) {
+		case "":
+		case "none", "unlimited":
+			rule.MaxFileSize = noSizeLimit
+		default:
+			size, err := parseSize(t.MaxSize)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %v", pattern, err)
+			}
+			rule.MaxFileSize = size
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// ruleFor returns the index of the first rule matching a file's name, or
+// -1 when none does
+func (c SearchConfig) ruleFor(filePath string) int {
+	if len(c.Rules) == 0 {
+		return -1
+	}
+	name := filepath.Base(filePath)
+	for i, rule := range c.Rules {
+		if ok, _ := filepath.Match(rule.Pattern, name); ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// sizeLimit returns the largest file searched under rule i, or -1 for no
+// limit
+func (c SearchConfig) sizeLimit(i int) int64 {
+	if i >= 0 && c.Rules[i].MaxFileSize != 0 {
+		return c.Rules[i].MaxFileSize
+	}
+	return c.MaxFileSize
+}
+
+// overSizeLimit reports whether a file is too large to search
+func (c SearchConfig) overSizeLimit(rule int, size int64) bool {
+	limit := c.sizeLimit(rule)
+	return limit != noSizeLimit && size > limit
+}
+
+// workerLanes divides the workers of a search into lanes, each with a
+// queue of its own: lane 0 has MaxConcurrency workers for most files, and
+// each rule with a worker limit has a lane of that many (at most
+// MaxConcurrency). It returns the workers of each lane and the lane of each
+// rule, 0 for rules without a limit.
+func (c SearchConfig) workerLanes() (workers []int, ruleLane []int) {
+	shared := max(1, c.MaxConcurrency)
+	workers = []int{shared}
+	ruleLane = make([]int, len(c.Rules))
+	for i, rule := range c.Rules {
+		if rule.Workers > 0 {
+			ruleLane[i] = len(workers)
+			workers = append(workers, min(rule.Workers, shared))
+		}
+	}
+	return workers, ruleLane
+}
+
+// describe summarizes a rule for the config view
+func (r FileRule) describe() string {
+	if r.Skip {
+		return r.Pattern + ": skipped"
+	}
+	var parts []string
+	switch {
+	case r.MaxFileSize == noSizeLimit:
+		parts = append(parts, "no size limit")
+	case r.MaxFileSize > 0:
+		parts = append(parts, "up to "+formatSize(r.MaxFileSize))
+	}
+	if r.Workers > 0 {
+		parts = append(parts, fmt.Sprintf("%d workers", r.Workers))
+	}
+	if len(parts) == 0 {
+		return r.Pattern
+	}
+	return r.Pattern + ": " + strings.Join(parts, ", ")
+}
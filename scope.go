@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// vendoredDirs hold third-party code that the scope wizard can leave out
+var vendoredDirs = []string{"vendor", "node_modules", "third_party", "bower_components", "Pods", ".venv", "venv"}
+
+// defaultSkipDirs are the directories every walk prunes unless skip_dirs
+// in config.toml replaces them: version control data, dependencies and
+// build output, which rarely hold what a search is after
+var defaultSkipDirs = []string{".git", ".hg", ".svn", "node_modules", "bower_components", "vendor", "target", "__pycache__", ".venv", ".tox"}
+
+// includesFile reports whether a file's name matches IncludePatterns, which
+// allow everything when empty, and none of ExcludePatterns
+func (c SearchConfig) includesFile(filePath string) bool {
+	name := filepath.Base(filePath)
+	for _, pattern := range c.ExcludePatterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(c.IncludePatterns) == 0 {
+		return true
+	}
+	for _, pattern := range c.IncludePatterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// excludesDir reports whether a directory's name matches ExcludePatterns,
+// or SkipDirs unless NoSkipDirs is set
+func (c SearchConfig) excludesDir(dirPath string) bool {
+	name := filepath.Base(dirPath)
+	for _, pattern := range c.ExcludePatterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return c.skipsDir(name)
+}
+
+// skipsDir reports whether a directory name is one of SkipDirs, which
+// NoSkipDirs searches anyway
+func (c SearchConfig) skipsDir(name string) bool {
+	if c.NoSkipDirs {
+		return false
+	}
+	for _, pattern := range c.SkipDirs {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// skipDirPatterns checks the skip_dirs of config.toml, which replace the
+// built-in list when set
+func skipDirPatterns(names []string) ([]string, error) {
+	if names == nil {
+		return defaultSkipDirs, nil
+	}
+	for _, name := range names {
+		if _, err := filepath.Match(name, ""); err != nil || strings.ContainsRune(name, '/') {
+			return nil, fmt.Errorf("invalid skip_dirs entry %q: directory names or globs of them", name)
+		}
+	}
+	return names, nil
+}
+
+// alwaysSearches reports whether a file matches AlwaysSearch. Patterns
+// with a slash match the absolute path, or name a directory whose files all
+// match; others match the file name.
+func (c SearchConfig) alwaysSearches(filePath string) bool {
+	if len(c.AlwaysSearch) == 0 {
+		return false
+	}
+	name := filepath.Base(filePath)
+	abs, _ := filepath.Abs(filePath)
+	abs = filepath.ToSlash(abs)
+	for _, pattern := range c.AlwaysSearch {
+		if !strings.ContainsRune(pattern, '/') {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, abs); ok || strings.HasPrefix(abs, strings.TrimSuffix(pattern, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// alwaysSearchPatterns checks and resolves always_search patterns: ~ is
+// the home directory and relative paths start at the working directory
+func alwaysSearchPatterns(patterns []string) ([]string, error) {
+	var resolved []string
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid always_search pattern %q: %v", pattern, err)
+		}
+		if strings.HasPrefix(pattern, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				pattern = filepath.ToSlash(filepath.Join(home, pattern[2:]))
+			}
+		}
+		if strings.ContainsRune(pattern, '/') && !filepath.IsAbs(pattern) {
+			if abs, err := filepath.Abs(pattern); err == nil {
+				pattern = filepath.ToSlash(abs)
+			}
+		}
+		resolved = append(resolved, pattern)
+	}
+	return resolved, nil
+}
+
+// typePatterns turns the file types given to the wizard into globs: bare
+// words and .ext are extensions, anything else is used as a glob
+func typePatterns(value string) []string {
+	var patterns []string
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		switch {
+		case strings.ContainsAny(field, "*?["):
+			patterns = append(patterns, field)
+		case strings.HasPrefix(field, "."):
+			patterns = append(patterns, "*"+field)
+		case !strings.Contains(field, "."):
+			patterns = append(patterns, "*."+field)
+		default:
+			patterns = append(patterns, field)
+		}
+	}
+	return patterns
+}
+
+// yesNo interprets a [y/N] style answer, with def for an empty one
+func yesNo(answer string, def bool) bool {
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return def
+}
+
+// scopeWizard collects answers while the scope wizard runs
+type scopeWizard struct {
+	roots  []string
+	config SearchConfig
+}
+
+// startScopeWizard asks which roots, file types, hidden files, vendored
+// code and file sizes a search should cover, one prompt at a time, then
+// opens search input with the resulting scope
+func (m *model) startScopeWizard() {
+	wizard := &scopeWizard{config: m.searchConfig}
+	current := "."
+	if len(m.scopeTargets) > 0 {
+		current = strings.ReplaceAll(m.targetsLabel(m.scopeTargets), ", ", " ")
+	}
+
+	m.prompt = &inputPrompt{
+		label:  "Scope 1/5: search in (space-separated paths): ",
+		input:  current,
+		cursor: len([]rune(current)),
+		onSubmit: func(m *model, value string) {
+			for _, root := range strings.Fields(value) {
+				if strings.HasPrefix(root, "~") {
+					if home, err := os.UserHomeDir(); err == nil {
+						root = home + root[1:]
+					}
+				}
+				if !filepath.IsAbs(root) {
+					root = filepath.Join(m.currentDir, root)
+				}
+				if _, err := os.Stat(root); err != nil {
+					m.statusMsg = fmt.Sprintf("Error: %v", err)
+					return
+				}
+				wizard.roots = append(wizard.roots, filepath.Clean(root))
+			}
+			m.scopeTypes(wizard)
+		},
+	}
+}
+
+func (m *model) scopeTypes(wizard *scopeWizard) {
+	current := strings.Join(wizard.config.IncludePatterns, " ")
+	m.prompt = &inputPrompt{
+		label:  "Scope 2/5: file types (e.g. go md or *_test.go, empty for all): ",
+		input:  current,
+		cursor: len([]rune(current)),
+		onSubmit: func(m *model, value string) {
+			wizard.config.IncludePatterns = typePatterns(value)
+			m.scopeHidden(wizard)
+		},
+	}
+}
+
+func (m *model) scopeHidden(wizard *scopeWizard) {
+	m.prompt = &inputPrompt{
+		label: "Scope 3/5: include hidden files such as .env? [y/N] ",
+		onSubmit: func(m *model, answer string) {
+			wizard.config.IncludeHidden = yesNo(answer, false)
+			m.scopeVendored(wizard)
+		},
+	}
+}
+
+func (m *model) scopeVendored(wizard *scopeWizard) {
+	m.prompt = &inputPrompt{
+		label: fmt.Sprintf("Scope 4/5: include vendored code (%s)? [y/N] ", strings.Join(vendoredDirs, ", ")),
+		onSubmit: func(m *model, answer string) {
+			wizard.config.ExcludePatterns = nil
+			if !yesNo(answer, false) {
+				wizard.config.ExcludePatterns = vendoredDirs
+			}
+			m.scopeSize(wizard)
+		},
+	}
+}
+
+func (m *model) scopeSize(wizard *scopeWizard) {
+	m.prompt = &inputPrompt{
+		label: "Scope 5/5: skip files larger than (e.g. 10MB, empty for no limit): ",
+		onSubmit: func(m *model, value string) {
+			wizard.config.MaxSize = 0
+			if value != "" {
+				size, err := parseSize(value)
+				if err != nil {
+					m.statusMsg = fmt.Sprintf("Error: %v", err)
+					return
+				}
+				wizard.config.MaxSize = size
+			}
+			m.applyScope(wizard)
+		},
+	}
+}
+
+// applyScope makes the wizard's answers the search scope. The roots replace
+// the selection; no roots means the current directory again.
+func (m *model) applyScope(wizard *scopeWizard) {
+	m.pushUndo("scope wizard")
+	m.searchConfig = wizard.config
+	m.scopeTargets = wizard.roots
+	m.clearSelection()
+
+	m.mode = SearchInputMode
+	m.searchInput = ""
+	m.statusMsg = "Scope: " + m.scopeLabel() + ". Enter search pattern..."
+}
+
+// scopeLabel summarizes the wizard's scope
+func (m model) scopeLabel() string {
+	var parts []string
+	if len(m.scopeTargets) == 0 {
+		parts = append(parts, "current directory")
+	} else {
+		parts = append(parts, m.targetsLabel(m.scopeTargets))
+	}
+	if len(m.searchConfig.IncludePatterns) > 0 {
+		parts = append(parts, strings.Join(m.searchConfig.IncludePatterns, " "))
+	}
+	if m.searchConfig.IncludeHidden {
+		parts = append(parts, "hidden files included")
+	}
+	if len(m.searchConfig.ExcludePatterns) > 0 {
+		parts = append(parts, "vendored code skipped")
+	}
+	if label := sizeRangeLabel(m.searchConfig.MinSize, m.searchConfig.MaxSize); label != "any" {
+		parts = append(parts, "files "+label)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// targetsLabel lists targets relative to the current directory
+func (m model) targetsLabel(targets []string) string {
+	var rels []string
+	for _, target := range targets {
+		rels = append(rels, m.relPath(target))
+	}
+	return strings.Join(rels, ", ")
+}
+
+// relPath shows a path relative to the current directory when it's inside it
+func (m model) relPath(path string) string {
+	if rel, err := filepath.Rel(m.currentDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
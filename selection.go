@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// setSelected selects or deselects entry i of the listing. The selection
+// is kept by path, so it outlives the listing: entries picked in other
+// directories stay selected and are searched with the rest.
+func (m *model) setSelected(i int, on bool) {
+	file := m.files[i]
+	m.files[i].Selected = on
+	if !on {
+		delete(m.selection, file.Path)
+		return
+	}
+	if m.selection == nil {
+		m.selection = make(map[string]FileItem)
+	}
+	file.Selected, file.Depth, file.Expanded = true, 0, false
+	m.selection[file.Path] = file
+}
+
+// clearSelection deselects every entry, in any directory
+func (m *model) clearSelection() int {
+	count := len(m.selection)
+	m.selection = nil
+	for i := range m.files {
+		m.files[i].Selected = false
+	}
+	return count
+}
+
+// markSelected flags the listed entries that are selected, taking their
+// current size and time into the selection
+func (m *model) markSelected(items []FileItem) {
+	if len(m.selection) == 0 {
+		return
+	}
+	for i := range items {
+		if sel, ok := m.selection[items[i].Path]; ok {
+			items[i].Selected = true
+			sel.Size, sel.ModTime, sel.Mode = items[i].Size, items[i].ModTime, items[i].Mode
+			m.selection[items[i].Path] = sel
+		}
+	}
+}
+
+// pruneSelection drops selected entries that no longer exist, after they
+// were trashed, moved or deleted by another program
+func (m *model) pruneSelection() {
+	for path := range m.selection {
+		if _, err := os.Lstat(path); err != nil {
+			delete(m.selection, path)
+		}
+	}
+}
+
+// moveSelection keeps a renamed entry selected under its new path
+func (m *model) moveSelection(from, to string) {
+	if sel, ok := m.selection[from]; ok {
+		delete(m.selection, from)
+		sel.Path = to
+		m.selection[to] = sel
+	}
+}
+
+// selectedItems returns the selected entries of every directory, ordered
+// by path so a directory comes before the entries inside it
+func (m model) selectedItems() []FileItem {
+	items := make([]FileItem, 0, len(m.selection))
+	for _, item := range m.selection {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Path < items[j].Path })
+	return items
+}
+
+// selectionCounts counts the selected files and directories and totals the
+// size of the files
+func (m model) selectionCounts() (files, dirs int, size int64) {
+	for _, item := range m.selection {
+		if item.IsDir {
+			dirs++
+		} else {
+			files++
+			size += item.Size
+		}
+	}
+	return files, dirs, size
+}
+
+// renderSelection summarizes the selection above the listing, counting
+// what was selected outside the current directory
+func (m model) renderSelection() string {
+	if len(m.selection) == 0 {
+		return ""
+	}
+	files, dirs, size := m.selectionCounts()
+	summary := "Selected:"
+	if files > 0 {
+		summary += fmt.Sprintf(" %d files (%s)", files, formatSize(size))
+		if dirs > 0 {
+			summary += " and"
+		}
+	}
+	if dirs > 0 {
+		summary += fmt.Sprintf(" %d directories", dirs)
+	}
+
+	visible := 0
+	for _, file := range m.files {
+		if file.Selected {
+			visible++
+		}
+	}
+	if elsewhere := len(m.selection) - visible; elsewhere > 0 {
+		summary += fmt.Sprintf(", %d of them outside this listing", elsewhere)
+	}
+	return headerStyle.Render(summary + " (A to clear)")
+}
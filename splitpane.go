@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Split view layout: the result list on the left and the selected
+// result's file on the right
+const (
+	splitMinWidth    = 100 // Narrower terminals show the list alone
+	defaultPaneRatio = 50  // Percent of the width given to the list
+	minPaneRatio     = 20
+	maxPaneRatio     = 80
+	paneRatioStep    = 10
+)
+
+// panePreviewCache keeps the file shown in the preview pane, so moving
+// between results in one file doesn't reread it. Like resultRenderCache
+// it's shared by the model's copies.
+type panePreviewCache struct {
+	path    string
+	preview *filePreview
+	err     error
+}
+
+// reset drops the cached file, after it was changed on disk
+func (c *panePreviewCache) reset() {
+	if c != nil {
+		*c = panePreviewCache{}
+	}
+}
+
+// splitByDefault is whether result views start with the preview pane;
+// split_view = false in the config file turns it off
+var splitByDefault = true
+
+// paneScroll offsets the preview pane of one result; selecting another
+// result centers its line again
+type paneScroll struct {
+	key   lineKey
+	lines int
+}
+
+// splitActive reports whether results are shown next to a preview pane
+func (m model) splitActive() bool {
+	return !m.singlePane && m.viewport.width >= splitMinWidth
+}
+
+// listRatio is the percentage of the width used by the result list
+func (m model) listRatio() int {
+	if m.paneRatio == 0 {
+		return defaultPaneRatio
+	}
+	return m.paneRatio
+}
+
+// toggleSplit switches between the split view and the list alone
+func (m *model) toggleSplit() {
+	m.singlePane = !m.singlePane
+	switch {
+	case m.singlePane:
+		m.statusMsg = "Split view off"
+	case !m.splitActive():
+		m.statusMsg = fmt.Sprintf("Split view needs %d columns, the terminal has %d", splitMinWidth, m.viewport.width)
+	default:
+		m.statusMsg = "Split view on"
+	}
+}
+
+// resizePanes widens the result list by step percent, or narrows it
+func (m *model) resizePanes(step int) {
+	if !m.splitActive() {
+		m.statusMsg = "Split view is off"
+		return
+	}
+	m.paneRatio = max(minPaneRatio, min(m.listRatio()+step, maxPaneRatio))
+	m.statusMsg = fmt.Sprintf("Result list uses %d%% of the width", m.paneRatio)
+}
+
+// scrollPane moves the preview pane by step lines without changing the
+// selected result
+func (m *model) scrollPane(step int) {
+	if !m.splitActive() || m.resultIndex >= m.visibleResults.len() {
+		m.statusMsg = "Split view is off"
+		return
+	}
+	result := m.visibleResults.at(m.resultIndex)
+	key := lineKey{result.FilePath, result.LineNumber}
+	if m.paneScroll.key != key {
+		m.paneScroll = paneScroll{key: key}
+	}
+	p, err := m.panePreview(result)
+	if err != nil {
+		return
+	}
+
+	// Stop where the pane reaches the top or bottom of the file
+	base := m.paneOffset(result.LineNumber-1, len(p.lines), 0)
+	m.paneScroll.lines = m.paneOffset(result.LineNumber-1, len(p.lines), m.paneScroll.lines+step) - base
+}
+
+// paneOffset is the first file line the pane shows: the target line is
+// centered, then moved by scroll, staying within the file
+func (m model) paneOffset(target, lines, scroll int) int {
+	height := m.previewHeight()
+	limit := max(0, lines-height)
+	centered := max(0, min(target-height/2, limit))
+	return max(0, min(centered+scroll, limit))
+}
+
+// panePreview returns the preview of a result's file, reading it when the
+// selection moved to another file
+func (m model) panePreview(result SearchResult) (*filePreview, error) {
+	c := m.paneCache
+	if c == nil {
+		return loadPreview(result.FilePath, m.searchConfig)
+	}
+	if c.path != result.FilePath || c.preview == nil && c.err == nil {
+		c.path = result.FilePath
+		c.preview, c.err = loadPreview(result.FilePath, m.searchConfig)
+	}
+	return c.preview, c.err
+}
+
+// renderSplit lays out the rendered result list next to the preview pane
+func (m model) renderSplit(list string) string {
+	listWidth := m.viewport.width * m.listRatio() / 100
+	paneWidth := m.viewport.width - listWidth - 3
+
+	left := lipgloss.NewStyle().MaxWidth(listWidth).Render(strings.TrimSuffix(list, "\n"))
+	left = lipgloss.PlaceHorizontal(listWidth, lipgloss.Left, left)
+	right := lipgloss.NewStyle().MaxWidth(paneWidth).Render(strings.TrimSuffix(m.renderPane(), "\n"))
+
+	height := max(lipgloss.Height(left), lipgloss.Height(right))
+	separator := helpStyle.Render(strings.TrimSuffix(strings.Repeat(" │ \n", height), "\n"))
+	return lipgloss.JoinHorizontal(lipgloss.Top, left, separator, right) + "\n"
+}
+
+// renderPane shows the selected result's file around its line, with the
+// line highlighted and the file's other result lines marked
+func (m model) renderPane() string {
+	if m.resultIndex >= m.visibleResults.len() {
+		return ""
+	}
+	result := m.visibleResults.at(m.resultIndex)
+	if reason := unpreviewable(result); reason != "" {
+		return helpStyle.Render(reason) + "\n"
+	}
+	p, err := m.panePreview(result)
+	if err != nil {
+		return errorStyle.Render(fmt.Sprintf("Error: %v", err)) + "\n"
+	}
+
+	view := *p
+	view.focus(result.LineNumber, result.Matches, m.resultLines(result.FilePath))
+	scroll := 0
+	if m.paneScroll.key == (lineKey{result.FilePath, result.LineNumber}) {
+		scroll = m.paneScroll.lines
+	}
+	view.offset = m.paneOffset(view.target, len(view.lines), scroll)
+	end := min(view.offset+m.previewHeight(), len(view.lines))
+
+	var b strings.Builder
+	b.WriteString(headerStyle.Render(fmt.Sprintf("%s:%d", result.FilePath, result.LineNumber)))
+	b.WriteString("\n")
+	b.WriteString(m.renderPreviewLines(&view, end))
+	return b.String()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// statLine is one figure of a finished search's statistics
+type statLine struct {
+	label, value string
+}
+
+// searchStatLines describes a finished search for -stats and the stats
+// panel: what it read and left out, what it found, and how fast
+func searchStatLines(r SearchResults) []statLine {
+	files, _ := aggregateByFile(r.Results)
+	searched := fmt.Sprintf("%d", r.TotalFiles)
+	var notes []string
+	if len(r.Failed) > 0 {
+		notes = append(notes, fmt.Sprintf("%d left out: %s", len(r.Failed), failureSummary(r.Failed)))
+	}
+	if r.IndexSkipped > 0 {
+		notes = append(notes, fmt.Sprintf("%d ruled out by the index", r.IndexSkipped))
+	}
+	if r.Resumed > 0 {
+		notes = append(notes, fmt.Sprintf("%d from a checkpoint", r.Resumed))
+	}
+	if len(notes) > 0 {
+		searched += " (" + strings.Join(notes, "; ") + ")"
+	}
+
+	found := fmt.Sprintf("%d on %d lines in %d files", totalMatches(r.Results), len(r.Results), len(files))
+	if r.Truncated {
+		found += " (stopped at the result limit)"
+	}
+
+	elapsed := r.SearchTime.Round(time.Millisecond).String() + " wall"
+	if r.CPUTime > 0 {
+		elapsed += ", " + r.CPUTime.Round(time.Millisecond).String() + " CPU"
+	}
+	if suggest := r.SuggestTime.Round(time.Millisecond); suggest > 0 {
+		elapsed += fmt.Sprintf(" (%v looking for suggestions)", suggest)
+	}
+
+	throughput := "-"
+	if seconds := r.SearchTime.Seconds(); seconds > 0 {
+		throughput = fmt.Sprintf("%.1f MB/s, %.0f files/s", float64(r.BytesRead)/seconds/(1024*1024), float64(r.TotalFiles)/seconds)
+	}
+
+	return []statLine{
+		{"Files", searched},
+		{"Read", formatSize(r.BytesRead)},
+		{"Matches", found},
+		{"Time", elapsed},
+		{"Workers", fmt.Sprintf("%d at most", r.PeakWorkers)},
+		{"Throughput", throughput},
+	}
+}
+
+// writeStats prints the statistics block of -stats
+func writeStats(w io.Writer, r SearchResults) {
+	fmt.Fprintln(w, "Statistics:")
+	for _, line := range searchStatLines(r) {
+		fmt.Fprintf(w, "  %-11s %s\n", line.label+":", line.value)
+	}
+}
+
+// renderStats shows the statistics of the finished search, in a panel i
+// toggles below the results
+func (m model) renderStats() string {
+	if !m.showStats || m.searching {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n")
+	b.WriteString(headerStyle.Render("Search statistics"))
+	b.WriteString(helpStyle.Render(" (i to hide)"))
+	b.WriteString("\n")
+	for _, line := range searchStatLines(m.searchResults) {
+		b.WriteString(helpStyle.Render(fmt.Sprintf("  %-11s %s", line.label+":", line.value)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// toggleStats shows or hides the statistics panel
+func (m *model) toggleStats() {
+	if m.searching {
+		m.statusMsg = "Statistics are shown once the search finishes"
+		return
+	}
+	m.showStats = !m.showStats
+}
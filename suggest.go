@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Suggestion defaults, used for zero SuggestConfig fields
+const (
+	defaultSuggestDistance = 2
+	defaultSuggestions     = 5
+	suggestScanLimit       = 64 << 20 // Bytes read looking for near misses
+	suggestMinLiteral      = 3        // Shorter literals match too much by accident
+)
+
+// SuggestGranularity is what a search's literals are compared against
+type SuggestGranularity int
+
+const (
+	SuggestTokens SuggestGranularity = iota // Words in the searched files
+	SuggestLines                            // Whole trimmed lines, for pasted log lines
+)
+
+func (g SuggestGranularity) String() string {
+	if g == SuggestLines {
+		return "lines"
+	}
+	return "tokens"
+}
+
+// SuggestConfig controls the "did you mean" suggestions computed when a
+// search finds nothing. The zero value suggests up to defaultSuggestions
+// tokens within defaultSuggestDistance edits.
+type SuggestConfig struct {
+	Disabled       bool
+	MaxDistance    int // Edits allowed between a literal and a suggestion
+	MaxSuggestions int
+	Granularity    SuggestGranularity
+}
+
+func (c SuggestConfig) distance() int {
+	if c.MaxDistance > 0 {
+		return c.MaxDistance
+	}
+	return defaultSuggestDistance
+}
+
+func (c SuggestConfig) limit() int {
+	if c.MaxSuggestions > 0 {
+		return c.MaxSuggestions
+	}
+	return defaultSuggestions
+}
+
+// describe summarizes the settings for the config view
+func (c SuggestConfig) describe() string {
+	if c.Disabled {
+		return "off"
+	}
+	return fmt.Sprintf("%s, up to %d within %d edits", c.Granularity, c.limit(), c.distance())
+}
+
+// suggestModes are the values of -suggest and the config's suggest.mode
+var suggestModes = []string{"tokens", "lines", "off"}
+
+// withMode returns the config with suggestions compared against tokens or
+// lines, or turned off; an empty mode changes nothing
+func (c SuggestConfig) withMode(mode string) (SuggestConfig, error) {
+	switch strings.ToLower(mode) {
+	case "":
+	case "tokens":
+		c.Disabled, c.Granularity = false, SuggestTokens
+	case "lines":
+		c.Disabled, c.Granularity = false, SuggestLines
+	case "off":
+		c.Disabled = true
+	default:
+		return c, fmt.Errorf("unknown suggestion mode %q (use %s)", mode, strings.Join(suggestModes, ", "))
+	}
+	return c, nil
+}
+
+// mode is the name withMode takes for the current settings
+func (c SuggestConfig) mode() string {
+	if c.Disabled {
+		return "off"
+	}
+	return c.Granularity.String()
+}
+
+// nextMode cycles tokens -> lines -> off, for the config view
+func (c SuggestConfig) nextMode() SuggestConfig {
+	for i, mode := range suggestModes {
+		if mode == c.mode() {
+			c, _ = c.withMode(suggestModes[(i+1)%len(suggestModes)])
+			break
+		}
+	}
+	return c
+}
+
+// suggestConfig resolves the [suggest] table of the config file
+func (f SuggestFileConfig) suggestConfig() (SuggestConfig, error) {
+	if f.MaxDistance < 0 || f.MaxSuggestions < 0 {
+		return SuggestConfig{}, fmt.Errorf("suggest.max_distance and suggest.max_suggestions can't be negative")
+	}
+	return SuggestConfig{MaxDistance: f.MaxDistance, MaxSuggestions: f.MaxSuggestions}.withMode(f.Mode)
+}
+
+// suggestion is a near miss of one of the pattern's literals
+type suggestion struct {
+	text     string
+	distance int
+	count    int
+	file     string // First occurrence
+	line     int
+}
+
+// addSuggestions fills in results.Suggestions when a search found nothing,
+// timing the scan in SuggestTime
+func addSuggestions(ctx context.Context, results *SearchResults, files []string, config SearchConfig) {
+	if config.Suggest.Disabled || len(results.Results) > 0 || config.InvertMatch || config.Query == nil || ctx.Err() != nil {
+		return
+	}
+	start := time.Now()
+	results.Suggestions = suggestPatterns(ctx, files, config)
+	results.SuggestTime = time.Since(start)
+}
+
+// suggestPatterns looks through files for text close to the query's
+// required literals, skipping the files config takes for binary
+func suggestPatterns(ctx context.Context, files []string, search SearchConfig) []string {
+	q, config := search.Query, search.Suggest
+	var literals []string
+	for _, lit := range q.lineLiterals() {
+		if utf8.RuneCount(lit) >= suggestMinLiteral {
+			literals = append(literals, strings.ToLower(string(lit)))
+		}
+	}
+	if len(literals) == 0 {
+		return nil
+	}
+
+	found := make(map[string]*suggestion)
+	consider := func(text, file string, line int) {
+		if s, ok := found[text]; ok {
+			s.count++
+			return
+		}
+		lower := strings.ToLower(text)
+		for _, lit := range literals {
+			// Allow fewer edits for short literals, so "foo" doesn't suggest "for"
+			limit := min(config.distance(), max(1, utf8.RuneCountInString(lit)/3))
+			if d := editDistance(lit, lower, limit); d <= limit {
+				found[text] = &suggestion{text: text, distance: d, count: 1, file: file, line: line}
+				return
+			}
+		}
+	}
+
+	read := 0
+	for _, file := range files {
+		if ctx.Err() != nil || read >= suggestScanLimit {
+			break
+		}
+		if binary, _ := search.classifyBinary(file); binary {
+			continue
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, BufferSize), BufferSize)
+		for n := 1; scanner.Scan(); n++ {
+			line := scanner.Text()
+			read += len(line) + 1
+			if config.Granularity == SuggestLines {
+				if trimmed := strings.TrimSpace(line); trimmed != "" {
+					consider(trimmed, file, n)
+				}
+				continue
+			}
+			for _, token := range splitTokens(line) {
+				consider(token, file, n)
+			}
+		}
+		f.Close()
+	}
+
+	var ranked []*suggestion
+	for _, s := range found {
+		ranked = append(ranked, s)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		if a.count != b.count {
+			return a.count > b.count
+		}
+		return a.text < b.text
+	})
+
+	var suggestions []string
+	for _, s := range ranked[:min(len(ranked), config.limit())] {
+		suggestions = append(suggestions, fmt.Sprintf("Did you mean %q? (%d occurrences, first at %s:%d)", s.text, s.count, s.file, s.line))
+	}
+	return suggestions
+}
+
+// writeSuggestions prints the suggestions of a search that found nothing,
+// with the time spent finding them
+func writeSuggestions(w io.Writer, results SearchResults) {
+	if len(results.Results) > 0 || len(results.Suggestions) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "No matches. Suggestions (took %v):\n", results.SuggestTime.Round(time.Millisecond))
+	for _, s := range results.Suggestions {
+		fmt.Fprintf(w, "  %s\n", s)
+	}
+}
+
+// splitTokens returns the words of a line
+func splitTokens(line string) []string {
+	var tokens []string
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		if i < len(line) && (isWordByte(line[i]) || line[i] >= utf8.RuneSelf) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, line[start:i])
+			start = -1
+		}
+	}
+	return tokens
+}
+
+// editDistance returns the Levenshtein distance between a and b, or
+// limit+1 once it's known to exceed limit
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra)-len(rb) > limit || len(rb)-len(ra) > limit {
+		return limit + 1
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		best := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(min(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+			best = min(best, cur[j])
+		}
+		if best > limit {
+			return limit + 1
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
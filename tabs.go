@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// searchTab is the state of one tab while another is shown: its view, its
+// search and that search's results. The browser's directory, selection,
+// history and appearance are shared by all tabs.
+type searchTab struct {
+	mode           AppMode
+	searchInput    string
+	searchConfig   SearchConfig
+	searchResults  SearchResults
+	resultIndex    int
+	offset         int
+	statusMsg      string
+	searching      bool
+	searchCancel   func()
+	searchID       int
+	resultStream   <-chan searchBatchMsg
+	progress       SearchProgress
+	resultFilter   ResultFilter
+	visibleResults resultList
+	fileCounts     map[string]int
+	showRefs       bool
+	marked         map[lineKey]bool
+	preview        *filePreview
+	paneScroll     paneScroll
+	quickSearch    bool
+	quickSaved     *SearchConfig
+	runningAction  runningAction
+	background     *searchJob
+	queued         *queuedSearch
+	partial        *SearchResults
+	merged         *partialMerge
+	tracker        *targetTracker
+	targetIndex    int
+	checkpoint     *checkpointer
+	collect        *collectTree
+	collectIndex   int
+}
+
+// nextSearchID returns a new ID for a search's messages; IDs are unique
+// across tabs, so a message finds the tab whose search sent it
+func (m *model) nextSearchID() int {
+	m.lastSearchID++
+	return m.lastSearchID
+}
+
+// saveTab captures the shown tab
+func (m *model) saveTab() searchTab {
+	return searchTab{
+		mode:           m.mode,
+		searchInput:    m.searchInput,
+		searchConfig:   m.searchConfig,
+		searchResults:  m.searchResults,
+		resultIndex:    m.resultIndex,
+		offset:         m.viewport.offset,
+		statusMsg:      m.statusMsg,
+		searching:      m.searching,
+		searchCancel:   m.searchCancel,
+		searchID:       m.searchID,
+		resultStream:   m.resultStream,
+		progress:       m.progress,
+		resultFilter:   m.resultFilter,
+		visibleResults: m.visibleResults,
+		fileCounts:     m.fileCounts,
+		showRefs:       m.showRefs,
+		marked:         m.marked,
+		preview:        m.preview,
+		paneScroll:     m.paneScroll,
+		quickSearch:    m.quickSearch,
+		quickSaved:     m.quickSaved,
+		runningAction:  m.runningAction,
+		background:     m.background,
+		queued:         m.queued,
+		partial:        m.partial,
+		merged:         m.merged,
+		tracker:        m.tracker,
+		targetIndex:    m.targetIndex,
+		checkpoint:     m.checkpoint,
+		collect:        m.collect,
+		collectIndex:   m.collectIndex,
+	}
+}
+
+// loadTab shows a saved tab
+func (m *model) loadTab(t searchTab) {
+	m.mode = t.mode
+	m.searchInput = t.searchInput
+	m.searchConfig = t.searchConfig
+	m.searchResults = t.searchResults
+	m.resultIndex = t.resultIndex
+	m.viewport.offset = t.offset
+	m.statusMsg = t.statusMsg
+	m.searching = t.searching
+	m.searchCancel = t.searchCancel
+	m.searchID = t.searchID
+	m.resultStream = t.resultStream
+	m.progress = t.progress
+	m.resultFilter = t.resultFilter
+	m.visibleResults = t.visibleResults
+	m.fileCounts = t.fileCounts
+	m.showRefs = t.showRefs
+	m.marked = t.marked
+	m.preview = t.preview
+	m.paneScroll = t.paneScroll
+	m.quickSearch = t.quickSearch
+	m.quickSaved = t.quickSaved
+	m.runningAction = t.runningAction
+	m.background = t.background
+	m.queued = t.queued
+	m.partial = t.partial
+	m.merged = t.merged
+	m.tracker = t.tracker
+	m.targetIndex = t.targetIndex
+	m.checkpoint = t.checkpoint
+	m.collect = t.collect
+	m.collectIndex = t.collectIndex
+	m.renderCache.reset()
+}
+
+// newTab opens a tab on the file browser, with the shown tab's settings
+func (m *model) newTab() {
+	if len(m.tabs) == 0 {
+		m.tabs = []searchTab{{}}
+	}
+	m.tabs[m.activeTab] = m.saveTab()
+	m.tabs = append(m.tabs, searchTab{mode: FileBrowserMode, searchConfig: m.searchConfig})
+	m.tabs[len(m.tabs)-1].searchConfig.Query = nil
+	m.showTab(len(m.tabs) - 1)
+	m.statusMsg = fmt.Sprintf("Opened tab %d", m.activeTab+1)
+}
+
+// switchTab shows the tab step places after the shown one, wrapping
+// around. A search keeps running in the tab it was started from.
+func (m *model) switchTab(step int) {
+	if len(m.tabs) < 2 {
+		m.statusMsg = "Only one tab (Ctrl+N opens another)"
+		return
+	}
+	m.tabs[m.activeTab] = m.saveTab()
+	m.showTab((m.activeTab + step + len(m.tabs)) % len(m.tabs))
+}
+
+// showTab makes tab i the shown tab; a count of the pattern typed in the
+// previous one is dropped
+func (m *model) showTab(i int) {
+	m.activeTab = i
+	m.loadTab(m.tabs[i])
+	m.stopLive()
+	m.live = liveCount{}
+}
+
+// closeTab stops the shown tab's searches and shows the next tab
+func (m *model) closeTab() {
+	if len(m.tabs) < 2 {
+		m.statusMsg = "Can't close the only tab"
+		return
+	}
+	m.cancelSearch()
+	m.setBackground(nil)
+	closed := m.activeTab
+	m.tabs = append(m.tabs[:closed:closed], m.tabs[closed+1:]...)
+	m.showTab(min(closed, len(m.tabs)-1))
+	if len(m.tabs) == 1 {
+		m.tabs = nil
+	}
+	m.statusMsg = fmt.Sprintf("Closed tab %d", closed+1)
+}
+
+// tabFor returns the index of the hidden tab whose search or background
+// search has the given ID, or -1
+func (m *model) tabFor(id int) int {
+	for i, t := range m.tabs {
+		if i == m.activeTab {
+			continue
+		}
+		if t.searchID == id || t.background != nil && t.background.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// inTab runs update with tab i shown, then shows the current tab again,
+// so a hidden tab's search messages go through the usual handlers
+func (m *model) inTab(i int, update func(m *model) tea.Cmd) tea.Cmd {
+	active := m.saveTab()
+	m.loadTab(m.tabs[i])
+	cmd := update(m)
+	m.tabs[i] = m.saveTab()
+	m.loadTab(active)
+	return cmd
+}
+
+// tabLabel summarizes a tab for the tab bar
+func tabLabel(t searchTab) string {
+	switch {
+	case t.searching && t.searchResults.Progress.TotalFiles > 0:
+		p := t.searchResults.Progress
+		return fmt.Sprintf("%q %d%%", t.searchResults.Pattern, p.ProcessedFiles*100/p.TotalFiles)
+	case t.searching:
+		return fmt.Sprintf("%q ...", t.searchResults.Pattern)
+	case t.mode == SearchResultsMode || t.mode == PreviewMode:
+		return fmt.Sprintf("%q %d", t.searchResults.Pattern, len(t.searchResults.Results))
+	case t.mode == SearchInputMode:
+		return "new search"
+	}
+	return "browse"
+}
+
+// renderTabBar lists the tabs when there is more than one, on the blank
+// line under the title
+func (m model) renderTabBar() string {
+	if len(m.tabs) < 2 {
+		return ""
+	}
+	var parts []string
+	for i, t := range m.tabs {
+		if i == m.activeTab {
+			t = m.saveTab()
+		}
+		label := fmt.Sprintf(" %d %s ", i+1, tabLabel(t))
+		if i == m.activeTab {
+			parts = append(parts, renderSelected(label))
+		} else {
+			parts = append(parts, helpStyle.Render(label))
+		}
+	}
+	return strings.Join(parts, "│") + "  " + helpStyle.Render("Tab: next, Ctrl+N: new, Ctrl+W: close")
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// TargetProgress is one search root's share of a search's progress
+type TargetProgress struct {
+	Root           string
+	TotalFiles     int64
+	ProcessedFiles int64
+	Matches        int64
+	Stopped        bool // Stopped on its own while the others went on
+}
+
+// targetState counts one root's files and matches; the counters are
+// updated by the walk finding its files and by the search workers
+type targetState struct {
+	root    string
+	total   int64
+	done    int64
+	matches int64
+	stopped int32
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// targetTracker splits a search by root, so its progress can be shown per
+// root and one root can be stopped. The model and the search goroutine
+// share it.
+type targetTracker struct {
+	targets []*targetState
+	mu      sync.Mutex
+	index   map[string]*targetState // By file path, as files are found
+}
+
+// newTargetTracker sets up the roots of a search running under ctx
+func newTargetTracker(ctx context.Context, roots []string) *targetTracker {
+	t := &targetTracker{index: make(map[string]*targetState)}
+	for _, root := range roots {
+		s := &targetState{root: root}
+		s.ctx, s.cancel = context.WithCancel(ctx)
+		t.targets = append(t.targets, s)
+	}
+	return t
+}
+
+// add records a file found under root and counts it in the root's total;
+// a file found again under overlapping roots stays with the first
+func (t *targetTracker) add(root int, file string) *targetState {
+	t.mu.Lock()
+	s, ok := t.index[file]
+	if !ok {
+		s = t.targets[root]
+		t.index[file] = s
+	}
+	t.mu.Unlock()
+	atomic.AddInt64(&s.total, 1)
+	return s
+}
+
+// of returns the root state of a file, or nil for files of no root
+func (t *targetTracker) of(file string) *targetState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.index[file]
+}
+
+// stop cancels root i, keeping its matches so far
+func (t *targetTracker) stop(i int) {
+	s := t.targets[i]
+	atomic.StoreInt32(&s.stopped, 1)
+	s.cancel()
+}
+
+// snapshot returns the progress of every root
+func (t *targetTracker) snapshot() []TargetProgress {
+	progress := make([]TargetProgress, len(t.targets))
+	for i, s := range t.targets {
+		progress[i] = TargetProgress{
+			Root:           s.root,
+			TotalFiles:     atomic.LoadInt64(&s.total),
+			ProcessedFiles: atomic.LoadInt64(&s.done),
+			Matches:        atomic.LoadInt64(&s.matches),
+			Stopped:        atomic.LoadInt32(&s.stopped) != 0,
+		}
+	}
+	return progress
+}
+
+// stoppedTargets lists the roots stopped on their own
+func stoppedTargets(targets []TargetProgress) []string {
+	var roots []string
+	for _, t := range targets {
+		if t.Stopped {
+			roots = append(roots, filepath.Base(t.Root))
+		}
+	}
+	return roots
+}
+
+// showTargets opens the progress of the running search, with a row per root
+// when there are several
+func (m *model) showTargets() {
+	if m.searching && m.collect.collecting() {
+		m.mode = SearchProgressMode
+		m.statusMsg = "↑↓ choose a directory, x prunes it, Esc returns to the results"
+		return
+	}
+	if !m.searching {
+		m.statusMsg = "Progress is shown while a search runs"
+		return
+	}
+	m.mode = SearchProgressMode
+	if len(m.searchResults.Progress.Targets) < 2 {
+		m.statusMsg = "Esc returns to the results"
+		return
+	}
+	m.targetIndex = min(m.targetIndex, len(m.searchResults.Progress.Targets)-1)
+	m.statusMsg = "↑↓ choose a target, x stops it, Esc returns to the results"
+}
+
+// stopTarget stops the chosen root of the running search
+func (m *model) stopTarget() {
+	targets := m.searchResults.Progress.Targets
+	switch {
+	case !m.searching || m.tracker == nil:
+		m.statusMsg = "The search has finished"
+	case m.targetIndex >= len(targets):
+	case targets[m.targetIndex].Stopped:
+		m.statusMsg = fmt.Sprintf("%s is already stopped", targets[m.targetIndex].Root)
+	case targets[m.targetIndex].ProcessedFiles >= targets[m.targetIndex].TotalFiles:
+		m.statusMsg = fmt.Sprintf("%s is already searched", targets[m.targetIndex].Root)
+	default:
+		m.tracker.stop(m.targetIndex)
+		m.statusMsg = fmt.Sprintf("Stopped %s; the other targets go on", targets[m.targetIndex].Root)
+	}
+}
+
+// renderTargets shows a progress row per search root
+func (m model) renderTargets(targets []TargetProgress) string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Targets (↑↓: choose, x: stop one):"))
+	b.WriteString("\n")
+	width := 0
+	for _, t := range targets {
+		width = max(width, len(t.Root))
+	}
+	width = min(width, 50)
+	for i, t := range targets {
+		root := t.Root
+		if len(root) > width {
+			root = "..." + root[len(root)-width+3:]
+		}
+		state := fmt.Sprintf("%d/%d files", t.ProcessedFiles, t.TotalFiles)
+		switch {
+		case t.Stopped:
+			state += ", stopped"
+		case t.ProcessedFiles >= t.TotalFiles:
+			state += ", done"
+		}
+		line := fmt.Sprintf("%-*s  %-24s %d matches", width, root, state, t.Matches)
+		bar := ""
+		if t.TotalFiles > 0 {
+			bar = "  " + m.renderProgressBar(float64(t.ProcessedFiles)/float64(t.TotalFiles)*100, 20)
+		}
+		if i == m.targetIndex {
+			b.WriteString(renderSelected("> "+line) + bar)
+		} else if t.Stopped {
+			b.WriteString(helpStyle.Render("  "+line) + bar)
+		} else {
+			b.WriteString("  " + line + bar)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
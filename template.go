@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// placeholderPattern matches the {{name}} placeholders of a search template
+var placeholderPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// templateParams returns the names of a pattern's placeholders in the order
+// they first appear
+func templateParams(pattern string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(pattern, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// fillTemplate replaces the placeholders of a template with values, which
+// are matched literally
+func fillTemplate(template string, values map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+		return literalValue.Replace(regexp.QuoteMeta(values[name]))
+	})
+}
+
+// literalValue escapes the whitespace QuoteMeta leaves alone, which
+// compileQuery would otherwise read as part of " && ", " || " or a leading
+// "! ", or trim from the edge of a term
+var literalValue = strings.NewReplacer(" ", "[ ]", "\t", `\t`)
+
+// promptTemplate asks for the value of each placeholder of entry's
+// template, offering the values of its last run, then runs the search
+func (m *model) promptTemplate(entry historyEntry) {
+	names := templateParams(entry.Template)
+	values := make(map[string]string, len(names))
+	var ask func(m *model, i int)
+	ask = func(m *model, i int) {
+		name := names[i]
+		def := entry.Params[name]
+		m.prompt = &inputPrompt{
+			label:  fmt.Sprintf("%s (%d/%d): ", name, i+1, len(names)),
+			input:  def,
+			cursor: len([]rune(def)),
+			onRun: func(m *model, value string) tea.Cmd {
+				if value == "" {
+					m.statusMsg = fmt.Sprintf("Cancelled: %s needs a value", name)
+					return nil
+				}
+				values[name] = value
+				if i+1 < len(names) {
+					ask(m, i+1)
+					return nil
+				}
+				entry.Pattern = fillTemplate(entry.Template, values)
+				entry.Params = values
+				m.useSearch(entry)
+				m.searchTemplate = &entry
+				m.savedRun = entry.Name
+				return m.performSearch()
+			},
+		}
+	}
+	ask(m, 0)
+	m.statusMsg = fmt.Sprintf("Template %s: enter %s", entry.Template, strings.Join(names, ", "))
+}
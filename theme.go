@@ -0,0 +1,479 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// colorLevel is how much of the theme the terminal can show
+type colorLevel int
+
+const (
+	colorNone      colorLevel = iota // No escape sequences at all
+	colorMono                        // Bold, underline and reverse video, no colors
+	colorANSI                        // The 16 basic colors
+	colorANSI256                     // The xterm 256-color palette
+	colorTrueColor                   // 24-bit colors
+)
+
+// colorLevelNames are the names accepted by -color and the config file,
+// by colorLevel
+var colorLevelNames = []string{"none", "mono", "16", "256", "truecolor"}
+
+func (l colorLevel) String() string {
+	return colorLevelNames[l]
+}
+
+// colors is the level the styles are built for
+var colors = colorTrueColor
+
+// resolveColorLevel parses a color level name; "" and "auto" detect it
+func resolveColorLevel(name string) (colorLevel, error) {
+	if name == "" || strings.EqualFold(name, "auto") {
+		return detectColorLevel(), nil
+	}
+	for i, n := range colorLevelNames {
+		if strings.EqualFold(n, name) {
+			return colorLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown color level %q (use auto, %s)", name, strings.Join(colorLevelNames, ", "))
+}
+
+// detectColorLevel reads the terminal's color support from the
+// environment. NO_COLOR only drops the colors: terminals without any
+// (vt100, serial consoles, TERM=xterm) still get bold and reverse video,
+// so the cursor and matches stay visible.
+func detectColorLevel() colorLevel {
+	if os.Getenv("TERM") == "dumb" {
+		return colorNone
+	}
+	if termenv.EnvNoColor() {
+		return colorMono
+	}
+	switch termenv.NewOutput(os.Stdout).ColorProfile() {
+	case termenv.TrueColor:
+		return colorTrueColor
+	case termenv.ANSI256:
+		return colorANSI256
+	case termenv.ANSI:
+		return colorANSI
+	}
+	if forced := os.Getenv("CLICOLOR_FORCE"); forced != "" && forced != "0" {
+		return colorANSI
+	}
+	return colorMono
+}
+
+// setColorLevel makes lipgloss map the theme's colors down to a level;
+// the mono level keeps text attributes but builds styles without colors
+func setColorLevel(level colorLevel) {
+	colors = level
+	switch level {
+	case colorNone:
+		lipgloss.SetColorProfile(termenv.Ascii)
+	case colorMono, colorANSI:
+		lipgloss.SetColorProfile(termenv.ANSI)
+	case colorANSI256:
+		lipgloss.SetColorProfile(termenv.ANSI256)
+	default:
+		lipgloss.SetColorProfile(termenv.TrueColor)
+	}
+}
+
+// Theme is a named set of colors used to build the TUI styles. Themes
+// defined in the config file start from their base theme, so they only
+// need the colors they change.
+type Theme struct {
+	Name       string         `toml:"-"`
+	Base       string         `toml:"base"` // Theme the unset colors come from (default "default")
+	Mono       bool           `toml:"mono"` // Text attributes only, as when NO_COLOR is set
+	Title      lipgloss.Color `toml:"title"`
+	TitleBg    lipgloss.Color `toml:"title_bg"`
+	Header     lipgloss.Color `toml:"header"`
+	Directory  lipgloss.Color `toml:"directory"`
+	File       lipgloss.Color `toml:"file"`
+	SelectedFg lipgloss.Color `toml:"selected_fg"`
+	SelectedBg lipgloss.Color `toml:"selected_bg"`
+	Input      lipgloss.Color `toml:"input"`
+	InputBg    lipgloss.Color `toml:"input_bg"`
+	Match      lipgloss.Color `toml:"match"`
+	MatchBg    lipgloss.Color `toml:"match_bg"`
+	Error      lipgloss.Color `toml:"error"`
+	Help       lipgloss.Color `toml:"help"`
+	Status     lipgloss.Color `toml:"status"`
+	Progress   lipgloss.Color `toml:"progress"`
+	Warning    lipgloss.Color `toml:"warning"`
+}
+
+// colorFields returns pointers to the theme's colors, with their config
+// keys, for inheriting and validating them
+func (t *Theme) colorFields() map[string]*lipgloss.Color {
+	return map[string]*lipgloss.Color{
+		"title": &t.Title, "title_bg": &t.TitleBg, "header": &t.Header,
+		"directory": &t.Directory, "file": &t.File,
+		"selected_fg": &t.SelectedFg, "selected_bg": &t.SelectedBg,
+		"input": &t.Input, "input_bg": &t.InputBg,
+		"match": &t.Match, "match_bg": &t.MatchBg,
+		"error": &t.Error, "help": &t.Help, "status": &t.Status,
+		"progress": &t.Progress, "warning": &t.Warning,
+	}
+}
+
+// Built-in themes. The colorblind-safe variants are based on the
+// Okabe-Ito palette and avoid relying on red/green contrast.
+var builtinThemes = []Theme{
+	{
+		Name:  "default",
+		Title: "#FAFAFA", TitleBg: "#7D56F4",
+		Header: "#04B575", Directory: "#7D56F4", File: "#F8F8F2",
+		SelectedFg: "#F8F8F2", SelectedBg: "#44475A",
+		Input: "#50FA7B", InputBg: "#282A36",
+		Match: "#FF5F87", MatchBg: "#3C3C3C",
+		Error: "#FF5555", Help: "#6272A4", Status: "#FFB86C",
+		Progress: "#50FA7B", Warning: "#F1FA8C",
+	},
+	{
+		Name:  "deuteranopia",
+		Title: "#FFFFFF", TitleBg: "#0072B2",
+		Header: "#56B4E9", Directory: "#56B4E9", File: "#F8F8F2",
+		SelectedFg: "#000000", SelectedBg: "#E69F00",
+		Input: "#56B4E9", InputBg: "#282A36",
+		Match: "#000000", MatchBg: "#F0E442",
+		Error: "#D55E00", Help: "#999999", Status: "#E69F00",
+		Progress: "#0072B2", Warning: "#F0E442",
+	},
+	{
+		Name:  "protanopia",
+		Title: "#FFFFFF", TitleBg: "#0072B2",
+		Header: "#56B4E9", Directory: "#56B4E9", File: "#F8F8F2",
+		SelectedFg: "#000000", SelectedBg: "#56B4E9",
+		Input: "#F0E442", InputBg: "#282A36",
+		Match: "#000000", MatchBg: "#F0E442",
+		Error: "#E69F00", Help: "#999999", Status: "#F0E442",
+		Progress: "#56B4E9", Warning: "#E69F00",
+	},
+	{
+		Name:  "dracula",
+		Title: "#282A36", TitleBg: "#BD93F9",
+		Header: "#8BE9FD", Directory: "#BD93F9", File: "#F8F8F2",
+		SelectedFg: "#F8F8F2", SelectedBg: "#44475A",
+		Input: "#50FA7B", InputBg: "#282A36",
+		Match: "#282A36", MatchBg: "#FF79C6",
+		Error: "#FF5555", Help: "#6272A4", Status: "#FFB86C",
+		Progress: "#50FA7B", Warning: "#F1FA8C",
+	},
+	{
+		Name:  "solarized",
+		Title: "#FDF6E3", TitleBg: "#268BD2",
+		Header: "#2AA198", Directory: "#268BD2", File: "#93A1A1",
+		SelectedFg: "#FDF6E3", SelectedBg: "#073642",
+		Input: "#859900", InputBg: "#002B36",
+		Match: "#002B36", MatchBg: "#B58900",
+		Error: "#DC322F", Help: "#586E75", Status: "#CB4B16",
+		Progress: "#859900", Warning: "#B58900",
+	},
+	{
+		// For light terminal backgrounds
+		Name:  "light",
+		Title: "#FFFFFF", TitleBg: "#5A3FC0",
+		Header: "#00794C", Directory: "#5A3FC0", File: "#1F2328",
+		SelectedFg: "#1F2328", SelectedBg: "#D0D7DE",
+		Input: "#0B6E2F", InputBg: "#F6F8FA",
+		Match: "#1F2328", MatchBg: "#FFD33D",
+		Error: "#CF222E", Help: "#57606A", Status: "#9A6700",
+		Progress: "#1A7F37", Warning: "#9A6700",
+	},
+	{
+		// Pure colors that survive 16-color terminals and projectors
+		Name:  "high-contrast",
+		Title: "#000000", TitleBg: "#FFFF00",
+		Header: "#00FFFF", Directory: "#00FFFF", File: "#FFFFFF",
+		SelectedFg: "#000000", SelectedBg: "#FFFFFF",
+		Input: "#FFFFFF", InputBg: "#000000",
+		Match: "#000000", MatchBg: "#FFFF00",
+		Error: "#FF0000", Help: "#FFFFFF", Status: "#FFFF00",
+		Progress: "#00FF00", Warning: "#FFFF00",
+	},
+	{Name: "monochrome", Mono: true},
+}
+
+// themes is the active set: the built-ins, then the config file's own
+// themes by name; a config theme named like a built-in replaces it
+var themes = builtinThemes
+
+// setUserThemes adds the themes defined in the config file
+func setUserThemes(defs map[string]Theme) error {
+	active := append([]Theme(nil), builtinThemes...)
+	byName := make(map[string]Theme)
+	for _, t := range builtinThemes {
+		byName[t.Name] = t
+	}
+
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t := defs[name]
+		t.Name = name
+		// A theme named like a built-in adjusts it, others start from default
+		baseName := t.Base
+		if _, ok := byName[name]; ok && baseName == "" {
+			baseName = name
+		} else if baseName == "" {
+			baseName = "default"
+		}
+		base, ok := byName[baseName]
+		if !ok {
+			return fmt.Errorf("theme %q: unknown base theme %q", name, baseName)
+		}
+		t.Mono = t.Mono || base.Mono
+		fields, baseFields := t.colorFields(), base.colorFields()
+		for key, c := range fields {
+			if *c == "" {
+				*c = *baseFields[key]
+			} else if !validColor(string(*c)) {
+				return fmt.Errorf("theme %q: invalid %s color %q (use #RRGGBB or 0-255)", name, key, *c)
+			}
+		}
+
+		replaced := false
+		for i := range active {
+			if active[i].Name == name {
+				active[i], replaced = t, true
+			}
+		}
+		if !replaced {
+			active = append(active, t)
+		}
+		byName[name] = t
+	}
+	themes = active
+	return nil
+}
+
+// validColor accepts the colors lipgloss understands: #RRGGBB (or #RGB)
+// and ANSI color numbers
+func validColor(c string) bool {
+	if n, err := strconv.Atoi(c); err == nil {
+		return n >= 0 && n <= 255
+	}
+	if !strings.HasPrefix(c, "#") || len(c) != 4 && len(c) != 7 {
+		return false
+	}
+	_, err := strconv.ParseUint(c[1:], 16, 32)
+	return err == nil
+}
+
+// themeNames lists the active themes in picker order
+func themeNames() []string {
+	names := make([]string, len(themes))
+	for i, t := range themes {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// findTheme returns the index of the named theme; "" is the default
+func findTheme(name string) (int, error) {
+	if name == "" {
+		return 0, nil
+	}
+	for i, t := range themes {
+		if strings.EqualFold(t.Name, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown theme %q (use %s)", name, strings.Join(themeNames(), ", "))
+}
+
+// Match highlight styles, for users who can't distinguish the match color
+var matchModes = []string{"color", "underline", "reverse", "bold"}
+
+// findMatchMode returns the index of the named match style
+func findMatchMode(name string) (int, error) {
+	for i, mode := range matchModes {
+		if strings.EqualFold(mode, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown match style %q (use %s)", name, strings.Join(matchModes, ", "))
+}
+
+// applyTheme rebuilds the package styles from a theme and match style,
+// or from text attributes alone when the terminal has no colors
+func applyTheme(t Theme, matchMode string) {
+	if colors <= colorMono || t.Mono {
+		applyMonoTheme(matchMode)
+		return
+	}
+	titleStyle = pairStyle(t.Title, t.TitleBg).Bold(true).Padding(0, 1)
+	headerStyle = lipgloss.NewStyle().Bold(true).Foreground(t.Header)
+	directoryStyle = lipgloss.NewStyle().Foreground(t.Directory).Bold(true)
+	fileStyle = lipgloss.NewStyle().Foreground(t.File)
+	selectedStyle = pairStyle(t.SelectedFg, t.SelectedBg).Bold(true)
+	searchInputStyle = pairStyle(t.Input, t.InputBg).Padding(0, 1)
+	errorStyle = lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	helpStyle = lipgloss.NewStyle().Foreground(t.Help).Italic(true)
+	statusStyle = lipgloss.NewStyle().Foreground(t.Status).Italic(true)
+	suggestionStyle = lipgloss.NewStyle().Foreground(t.Status).Italic(true)
+	progressStyle = lipgloss.NewStyle().Foreground(t.Progress).Bold(true)
+	warningStyle = lipgloss.NewStyle().Foreground(t.Warning).Bold(true)
+	keywordStyle = lipgloss.NewStyle().Foreground(t.Directory).Bold(true)
+	stringStyle = lipgloss.NewStyle().Foreground(t.Input)
+	commentStyle = lipgloss.NewStyle().Foreground(t.Help).Italic(true)
+	numberStyle = lipgloss.NewStyle().Foreground(t.Status)
+
+	switch matchMode {
+	case "underline":
+		matchStyle = lipgloss.NewStyle().Underline(true).Bold(true)
+	case "reverse":
+		matchStyle = lipgloss.NewStyle().Reverse(true).Bold(true)
+	case "bold":
+		matchStyle = lipgloss.NewStyle().Bold(true)
+	default:
+		matchStyle = pairStyle(t.Match, t.MatchBg).Bold(true)
+	}
+}
+
+// pairStyle colors text on a background. The 16-color mapping can turn
+// both into the same color (dark grey on black becomes black on black),
+// so then the pair falls back to reverse video.
+func pairStyle(fg, bg lipgloss.Color) lipgloss.Style {
+	if colors == colorANSI && termenv.ANSI.Color(string(fg)) == termenv.ANSI.Color(string(bg)) {
+		return lipgloss.NewStyle().Reverse(true)
+	}
+	return lipgloss.NewStyle().Foreground(fg).Background(bg)
+}
+
+// applyMonoTheme builds the styles from bold, underline and reverse video;
+// matches are underlined by default, as the selected line is reversed
+func applyMonoTheme(matchMode string) {
+	plain := lipgloss.NewStyle()
+	titleStyle = plain.Bold(true).Reverse(true).Padding(0, 1)
+	headerStyle = plain.Bold(true)
+	directoryStyle = plain.Bold(true)
+	fileStyle = plain
+	selectedStyle = plain.Reverse(true)
+	searchInputStyle = plain.Underline(true).Padding(0, 1)
+	errorStyle = plain.Bold(true)
+	helpStyle = plain
+	statusStyle = plain
+	suggestionStyle = plain
+	progressStyle = plain.Bold(true)
+	warningStyle = plain.Bold(true)
+	keywordStyle = plain.Bold(true)
+	stringStyle = plain
+	commentStyle = plain
+	numberStyle = plain
+
+	switch matchMode {
+	case "reverse":
+		matchStyle = plain.Reverse(true).Bold(true)
+	case "bold":
+		matchStyle = plain.Bold(true)
+	default:
+		matchStyle = plain.Underline(true).Bold(true)
+	}
+}
+
+// renderSelected styles the entry under the cursor. Without any escape
+// sequences the style shows nothing, so a marker points at it instead.
+func renderSelected(s string) string {
+	if colors == colorNone {
+		return "> " + s
+	}
+	return selectedStyle.Render(s)
+}
+
+// applyThemeSelection applies the model's theme and match style choice
+func (m *model) applyThemeSelection() {
+	applyTheme(themes[m.themeIndex], matchModes[m.matchModeIndex])
+}
+
+// openThemePicker lists the themes in config mode, remembering the current
+// one so Esc can go back to it
+func (m *model) openThemePicker() {
+	m.pickingTheme = true
+	m.themeSaved = m.themeIndex
+	m.statusMsg = "Choose a theme; moving the cursor previews it"
+}
+
+// updateThemePicker handles keys while the theme picker is open, applying
+// the highlighted theme as a preview
+func (m *model) updateThemePicker(key string) {
+	switch key {
+	case "esc", "q", "6":
+		m.pickingTheme = false
+		m.themeIndex = m.themeSaved
+		m.applyThemeSelection()
+		m.statusMsg = fmt.Sprintf("Kept theme %s", themes[m.themeIndex].Name)
+		return
+
+	case "up", "k":
+		if m.themeIndex > 0 {
+			m.themeIndex--
+		}
+
+	case "down", "j":
+		if m.themeIndex < len(themes)-1 {
+			m.themeIndex++
+		}
+
+	case "enter":
+		m.pickingTheme = false
+		m.statusMsg = fmt.Sprintf("Theme set to %s", themes[m.themeIndex].Name)
+		if colors <= colorMono {
+			m.statusMsg += fmt.Sprintf(" (not shown: colors are %s)", colors)
+		}
+		return
+	}
+	m.applyThemeSelection()
+}
+
+// renderThemePicker lists the themes with a swatch of their main colors
+func (m model) renderThemePicker() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Themes (↑↓: preview, Enter: keep, Esc: go back):"))
+	b.WriteString("\n")
+	for i, t := range themes {
+		line := fmt.Sprintf("  %-14s", t.Name)
+		if i == m.themeIndex {
+			b.WriteString(renderSelected(line))
+		} else {
+			b.WriteString(line)
+		}
+		b.WriteString(" " + themeSwatch(t))
+		b.WriteString("\n")
+	}
+	if colors <= colorMono {
+		b.WriteString(helpStyle.Render(fmt.Sprintf("Colors are %s, so every theme shows as monochrome (set with -color)", colors)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// themeSwatch shows a theme's colors as blocks, or describes a theme
+// without any
+func themeSwatch(t Theme) string {
+	if t.Mono {
+		return "bold, underline and reverse video"
+	}
+	if colors <= colorMono {
+		return ""
+	}
+	var b strings.Builder
+	for _, c := range []lipgloss.Color{t.TitleBg, t.Header, t.Directory, t.File, t.MatchBg, t.Error, t.Status, t.Warning} {
+		b.WriteString(lipgloss.NewStyle().Foreground(c).Render("██"))
+	}
+	return b.String()
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// trashEntry records where a trashed path came from and where it went
+type trashEntry struct {
+	Original  string
+	TrashPath string
+	InfoPath  string // FreeDesktop .trashinfo file, if any
+}
+
+// trashDir returns the directory that trashed files are moved into and,
+// for FreeDesktop trash, the directory holding .trashinfo metadata
+func trashDir() (files string, info string, err error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", err
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join(home, ".Trash"), "", nil
+	case "windows":
+		// The Recycle Bin has no file-system API; keep a zx-managed trash,
+		// which Explorer doesn't show (see trashLabel)
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return "", "", err
+		}
+		return filepath.Join(cache, "zx", "trash"), "", nil
+	default:
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+		trash := filepath.Join(dataHome, "Trash")
+		return filepath.Join(trash, "files"), filepath.Join(trash, "info"), nil
+	}
+}
+
+// trashLabel names where deleted items go, for status messages
+func trashLabel() string {
+	if runtime.GOOS == "windows" {
+		return "zx's trash (not the Recycle Bin)"
+	}
+	return "trash"
+}
+
+// moveToTrash moves path into the OS trash and returns how to restore it
+func moveToTrash(path string) (trashEntry, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return trashEntry{}, err
+	}
+
+	filesDir, infoDir, err := trashDir()
+	if err != nil {
+		return trashEntry{}, fmt.Errorf("unable to locate trash: %v", err)
+	}
+	if err := os.MkdirAll(filesDir, 0700); err != nil {
+		return trashEntry{}, fmt.Errorf("unable to create trash %s: %v", filesDir, err)
+	}
+	if infoDir != "" {
+		if err := os.MkdirAll(infoDir, 0700); err != nil {
+			return trashEntry{}, fmt.Errorf("unable to create trash %s: %v", infoDir, err)
+		}
+	}
+
+	entry := trashEntry{Original: path}
+	name := uniqueTrashName(filesDir, infoDir, filepath.Base(path))
+	entry.TrashPath = filepath.Join(filesDir, name)
+
+	if infoDir != "" {
+		entry.InfoPath = filepath.Join(infoDir, name+".trashinfo")
+		info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+			(&url.URL{Path: path}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+		if err := os.WriteFile(entry.InfoPath, []byte(info), 0600); err != nil {
+			return trashEntry{}, fmt.Errorf("unable to write trash info for %s: %v", path, err)
+		}
+	}
+
+	if err := movePath(path, entry.TrashPath); err != nil {
+		if entry.InfoPath != "" {
+			os.Remove(entry.InfoPath)
+		}
+		return trashEntry{}, fmt.Errorf("unable to move %s to trash: %v", path, err)
+	}
+	return entry, nil
+}
+
+// restoreFromTrash moves a trashed path back to its original location
+func restoreFromTrash(entry trashEntry) error {
+	if _, err := os.Lstat(entry.Original); err == nil {
+		return fmt.Errorf("cannot restore %s: path already exists", entry.Original)
+	}
+	if err := os.MkdirAll(filepath.Dir(entry.Original), 0755); err != nil {
+		return fmt.Errorf("cannot restore %s: %v", entry.Original, err)
+	}
+	if err := movePath(entry.TrashPath, entry.Original); err != nil {
+		return fmt.Errorf("cannot restore %s: %v", entry.Original, err)
+	}
+	if entry.InfoPath != "" {
+		os.Remove(entry.InfoPath)
+	}
+	return nil
+}
+
+// uniqueTrashName picks a name not yet used in the trash
+func uniqueTrashName(filesDir, infoDir, name string) string {
+	candidate := name
+	for i := 2; ; i++ {
+		_, errFile := os.Lstat(filepath.Join(filesDir, candidate))
+		errInfo := os.ErrNotExist
+		if infoDir != "" {
+			_, errInfo = os.Lstat(filepath.Join(infoDir, candidate+".trashinfo"))
+		}
+		if os.IsNotExist(errFile) && os.IsNotExist(errInfo) {
+			return candidate
+		}
+		ext := filepath.Ext(name)
+		candidate = strings.TrimSuffix(name, ext) + "." + strconv.Itoa(i) + ext
+	}
+}
+
+// movePath renames src to dst, copying across file systems when needed
+func movePath(src, dst string) error {
+	return movePathProgress(context.Background(), src, dst, nil)
+}
+
+// movePathProgress is movePath reporting the bytes copied to progress, if
+// it isn't nil, when src has to be copied; ctx stops the copy
+func movePathProgress(ctx context.Context, src, dst string, progress func(int64)) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) || !errors.Is(linkErr.Err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyPathProgress(ctx, src, dst, progress); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyPath recursively copies a file or directory tree, preserving modes.
+// FIFOs, sockets and devices hold no data to copy and are left out.
+func copyPath(src, dst string) error {
+	return copyPathProgress(context.Background(), src, dst, nil)
+}
+
+// copyPathProgress is copyPath reporting the bytes copied to progress, if
+// it isn't nil; ctx stops the copy
+func copyPathProgress(ctx context.Context, src, dst string, progress func(int64)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+
+	case info.IsDir():
+		// Fill the copy before giving it the source's mode, which may not
+		// allow writing into it
+		if err := os.MkdirAll(dst, 0700); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := copyPathProgress(ctx, filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()), progress); err != nil {
+				return err
+			}
+		}
+		return os.Chmod(dst, info.Mode().Perm())
+
+	case !info.Mode().IsRegular():
+		// Opening a FIFO or device would block or read without end
+		return nil
+
+	default:
+		in, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(progressWriter{ctx, out, progress}, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+}
+
+// progressWriter passes the bytes written through to progress and stops
+// writing once ctx is done
+type progressWriter struct {
+	ctx      context.Context
+	w        io.Writer
+	progress func(int64)
+}
+
+func (p progressWriter) Write(b []byte) (int, error) {
+	if err := p.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := p.w.Write(b)
+	if p.progress != nil {
+		p.progress(int64(n))
+	}
+	return n, err
+}
+
+// pathStats returns the number of files and total bytes under path
+func pathStats(path string) (files int, size int64) {
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, size
+}
+
+// deleteTargets returns the selected items of every directory, or the
+// item under the cursor when nothing is selected
+func (m *model) deleteTargets() []FileItem {
+	targets := m.selectedItems()
+	if len(targets) == 0 && len(m.files) > 0 && m.files[m.selectedFile].Name != ".." {
+		targets = append(targets, m.files[m.selectedFile])
+	}
+	return targets
+}
+
+// confirmTrash summarizes the pending deletion and asks for confirmation
+func (m *model) confirmTrash() {
+	targets := m.deleteTargets()
+	if len(targets) == 0 {
+		m.statusMsg = "Nothing to delete"
+		return
+	}
+
+	fileCount, dirCount, innerFiles := 0, 0, 0
+	var totalSize int64
+	for _, target := range targets {
+		files, size := pathStats(target.Path)
+		totalSize += size
+		if target.IsDir {
+			dirCount++
+			innerFiles += files
+		} else {
+			fileCount++
+		}
+	}
+
+	var parts []string
+	if fileCount > 0 {
+		parts = append(parts, fmt.Sprintf("%d files", fileCount))
+	}
+	if dirCount > 0 {
+		parts = append(parts, fmt.Sprintf("%d directories (%d files inside)", dirCount, innerFiles))
+	}
+	summary := fmt.Sprintf("%s, %s total", strings.Join(parts, " and "), formatSize(totalSize))
+
+	m.prompt = &inputPrompt{
+		label: fmt.Sprintf("Move %s to trash? (y/N): ", summary),
+		onSubmit: func(m *model, value string) {
+			if !strings.EqualFold(value, "y") && !strings.EqualFold(value, "yes") {
+				m.statusMsg = "Delete cancelled"
+				return
+			}
+			m.trashTargets(targets)
+		},
+	}
+}
+
+// trashTargets moves targets to the trash and records them for restore
+func (m *model) trashTargets(targets []FileItem) {
+	var batch []trashEntry
+	var errs []string
+	for _, target := range targets {
+		entry, err := moveToTrash(target.Path)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		batch = append(batch, entry)
+	}
+	if len(batch) > 0 {
+		m.trashBatches = append(m.trashBatches, batch)
+	}
+
+	cursor := m.selectedFile
+	m.loadDirectory()
+	m.selectedFile = min(cursor, max(len(m.files)-1, 0))
+	m.adjustViewport()
+
+	if len(errs) > 0 {
+		m.statusMsg = fmt.Sprintf("Moved %d items to %s, %d failed: %s", len(batch), trashLabel(), len(errs), errs[0])
+	} else {
+		m.statusMsg = fmt.Sprintf("Moved %d items to %s (U to restore)", len(batch), trashLabel())
+	}
+}
+
+// restoreLastTrash restores the most recently trashed batch of this session.
+// Entries that fail to restore stay in the batch, so U can retry them once
+// whatever blocked them is out of the way.
+func (m *model) restoreLastTrash() {
+	if len(m.trashBatches) == 0 {
+		m.statusMsg = "Nothing to restore this session"
+		return
+	}
+
+	last := len(m.trashBatches) - 1
+	restored := 0
+	var remaining []trashEntry
+	var errs []string
+	for _, entry := range m.trashBatches[last] {
+		if err := restoreFromTrash(entry); err != nil {
+			errs = append(errs, err.Error())
+			remaining = append(remaining, entry)
+			continue
+		}
+		restored++
+	}
+	if len(remaining) > 0 {
+		m.trashBatches[last] = remaining
+	} else {
+		m.trashBatches = m.trashBatches[:last]
+	}
+
+	m.loadDirectory()
+	if len(errs) > 0 {
+		m.statusMsg = fmt.Sprintf("Restored %d items, %d failed (U to retry): %s", restored, len(errs), errs[0])
+	} else {
+		m.statusMsg = fmt.Sprintf("Restored %d items from trash", restored)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// toggleTreeView switches the file browser between the listing of
+// currentDir and a tree whose directories expand in place
+func (m *model) toggleTreeView() {
+	m.treeView = !m.treeView
+	if m.treeOpen == nil {
+		m.treeOpen = make(map[string]bool)
+	}
+	m.refreshDirectory()
+	if m.treeView {
+		m.statusMsg = "Tree view: →/Enter expands a directory, ← collapses it, t returns to the listing"
+	} else {
+		m.statusMsg = "List view"
+	}
+}
+
+// expandTree lists the directories left expanded below their entries,
+// once the top level has loaded
+func (m *model) expandTree() {
+	if !m.treeView || m.dirLoad != nil {
+		return
+	}
+	var current string
+	if m.selectedFile < len(m.files) {
+		current = m.files[m.selectedFile].Path
+	}
+	// expandNode inserts after i, so the children are visited in turn
+	for i := 0; i < len(m.files); i++ {
+		file := m.files[i]
+		if file.IsDir && file.Name != ".." && !file.Expanded && m.treeOpen[file.Path] {
+			if err := m.expandNode(i); err != nil {
+				delete(m.treeOpen, file.Path)
+			}
+		}
+	}
+	for i, file := range m.files {
+		if file.Path == current {
+			m.selectedFile = i
+			break
+		}
+	}
+}
+
+// expandNode lists the directory at index i of the tree below it
+func (m *model) expandNode(i int) error {
+	dir := m.files[i]
+	entries, err := os.ReadDir(dir.Path)
+	if err != nil {
+		return err
+	}
+	children := fileItems(dir.Path, entries)
+	m.markSelected(children)
+	sortFileItems(children)
+	for c := range children {
+		children[c].Depth = dir.Depth + 1
+	}
+
+	files := make([]FileItem, 0, len(m.files)+len(children))
+	files = append(files, m.files[:i+1]...)
+	files = append(files, children...)
+	files = append(files, m.files[i+1:]...)
+	m.files = files
+	m.files[i].Expanded = true
+	m.treeOpen[dir.Path] = true
+	return nil
+}
+
+// collapseNode hides the entries below the directory at index i of the
+// tree; those selected stay selected
+func (m *model) collapseNode(i int) {
+	end := i + 1
+	for end < len(m.files) && m.files[end].Depth > m.files[i].Depth {
+		end++
+	}
+	m.files = append(m.files[:i+1:i+1], m.files[end:]...)
+	m.files[i].Expanded = false
+	delete(m.treeOpen, m.files[i].Path)
+}
+
+// toggleNode expands or collapses the directory under the cursor
+func (m *model) toggleNode() {
+	file := m.files[m.selectedFile]
+	switch {
+	case m.dirLoad != nil:
+		m.statusMsg = "Wait for the directory to finish loading"
+	case file.Expanded:
+		m.collapseNode(m.selectedFile)
+		m.statusMsg = fmt.Sprintf("Collapsed %s", file.Name)
+	default:
+		if err := m.expandNode(m.selectedFile); err != nil {
+			m.statusMsg = fmt.Sprintf("Error reading directory: %v", err)
+			return
+		}
+		m.expandTree() // Subdirectories expanded before open again
+		m.statusMsg = fmt.Sprintf("Expanded %s", file.Name)
+	}
+}
+
+// updateTree handles the keys that move through the tree; it reports
+// false for keys the file browser handles as in the listing
+func (m *model) updateTree(key string) bool {
+	if len(m.files) == 0 {
+		return false
+	}
+	file := m.files[m.selectedFile]
+	switch key {
+	case "enter", "right", "l":
+		if !file.IsDir || file.Name == ".." {
+			return key != "enter"
+		}
+		if file.Expanded && key != "enter" {
+			// Step into the expanded directory
+			if m.selectedFile+1 < len(m.files) && m.files[m.selectedFile+1].Depth > file.Depth {
+				m.selectedFile++
+				m.adjustViewport()
+			}
+			return true
+		}
+		m.toggleNode()
+		return true
+
+	case "left":
+		if file.Expanded {
+			m.toggleNode()
+			return true
+		}
+		// Move up to the directory holding the entry
+		for i := m.selectedFile - 1; i >= 0 && file.Depth > 0; i-- {
+			if m.files[i].Depth < file.Depth {
+				m.selectedFile = i
+				m.adjustViewport()
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// underAny reports whether path lies inside one of dirs
+func underAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// treePrefix indents an entry of the tree and marks whether a directory
+// is expanded
+func treePrefix(file FileItem) string {
+	indent := strings.Repeat("  ", file.Depth)
+	switch {
+	case !file.IsDir || file.Name == "..":
+		return indent + "  "
+	case file.Expanded:
+		return indent + "▾ "
+	default:
+		return indent + "▸ "
+	}
+}
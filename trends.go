@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// trendLimit is the number of runs kept per saved search
+const trendLimit = 100
+
+// trendRun is the outcome of one run of a saved search
+type trendRun struct {
+	Time      time.Time `json:"time"`
+	Matches   int       `json:"matches"`
+	Lines     int       `json:"lines"`
+	Files     int       `json:"files"` // Files with matches
+	Truncated bool      `json:"truncated,omitempty"`
+}
+
+// trendsPath returns the file holding the runs of saved searches
+func trendsPath() (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "trends.json"), nil
+}
+
+// loadTrends reads the runs of saved searches, by name
+func loadTrends() (map[string][]trendRun, error) {
+	path, err := trendsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var trends map[string][]trendRun
+	if err := json.Unmarshal(data, &trends); err != nil {
+		return nil, fmt.Errorf("invalid trends %s: %v", path, err)
+	}
+	return trends, nil
+}
+
+// writeTrends replaces the runs of saved searches
+func writeTrends(trends map[string][]trendRun) error {
+	path, err := trendsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(trends, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// recordTrend adds a finished run of a saved search to its trend. Stopped
+// and quick runs are left out, since their counts aren't comparable.
+func (m *model) recordTrend(results SearchResults) error {
+	if results.Saved == "" || results.Progress.Cancelled || results.Quick {
+		return nil
+	}
+	files := make(map[string]bool)
+	for _, r := range results.Results {
+		files[r.FilePath] = true
+	}
+	run := trendRun{
+		Time:      time.Now(),
+		Matches:   totalMatches(results.Results),
+		Lines:     len(results.Results),
+		Files:     len(files),
+		Truncated: results.Truncated,
+	}
+
+	trends := make(map[string][]trendRun, len(m.trends)+1)
+	for name, runs := range m.trends {
+		trends[name] = runs
+	}
+	runs := append(append([]trendRun(nil), trends[results.Saved]...), run)
+	if len(runs) > trendLimit {
+		runs = runs[len(runs)-trendLimit:]
+	}
+	trends[results.Saved] = runs
+	m.trends = trends
+	return writeTrends(trends)
+}
+
+// sparkline draws counts as a row of bars scaled to the largest
+func sparkline(counts []int) string {
+	bars := []rune("▁▂▃▄▅▆▇█")
+	top := 0
+	for _, c := range counts {
+		top = max(top, c)
+	}
+	var b strings.Builder
+	for _, c := range counts {
+		i := 0
+		if top > 0 {
+			i = c * (len(bars) - 1) / top
+		}
+		b.WriteRune(bars[i])
+	}
+	return b.String()
+}
+
+// trendSummary shows the match counts of a saved search's last runs as a
+// sparkline, with the first and last count and the change between them
+func trendSummary(runs []trendRun, width int) string {
+	if len(runs) == 0 {
+		return ""
+	}
+	runs = runs[max(0, len(runs)-width):]
+	counts := make([]int, len(runs))
+	for i, run := range runs {
+		counts[i] = run.Matches
+	}
+	first, last := runs[0], runs[len(runs)-1]
+	if len(runs) == 1 {
+		return fmt.Sprintf("%s %d (first run)", sparkline(counts), last.Matches)
+	}
+	change := last.Matches - first.Matches
+	return fmt.Sprintf("%s %d → %d (%+d over %d runs since %s)", sparkline(counts),
+		first.Matches, last.Matches, change, len(runs), first.Time.Format("2006-01-02"))
+}
+
+// renderTrend shows the trend of the saved search the results are a run
+// of, with its last few runs
+func (m model) renderTrend() string {
+	runs := m.trends[m.searchResults.Saved]
+	if m.searchResults.Saved == "" || len(runs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(statusStyle.Render(fmt.Sprintf("Trend of %q: %s", m.searchResults.Saved, trendSummary(runs, 30))))
+	b.WriteString("\n")
+	var recent []string
+	for _, run := range runs[max(0, len(runs)-5):] {
+		recent = append(recent, fmt.Sprintf("%s %d in %d files", run.Time.Format("Jan _2 15:04"), run.Matches, run.Files))
+	}
+	b.WriteString(helpStyle.Render("  " + strings.Join(recent, " · ")))
+	b.WriteString("\n")
+	return b.String()
+}
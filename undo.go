@@ -0,0 +1,59 @@
+package main
+
+import "fmt"
+
+const maxUndoEntries = 100
+
+// undoEntry is a snapshot of user-adjustable state taken before a change
+type undoEntry struct {
+	description  string
+	selected     map[string]FileItem // Selection at the time of the snapshot
+	resultFilter ResultFilter
+	searchConfig SearchConfig
+}
+
+// pushUndo records the current selection, filters and configuration so the
+// change described by description can be reverted with Ctrl+Z
+func (m *model) pushUndo(description string) {
+	entry := undoEntry{
+		description:  description,
+		selected:     make(map[string]FileItem, len(m.selection)),
+		resultFilter: m.resultFilter,
+		searchConfig: m.searchConfig,
+	}
+	for path, item := range m.selection {
+		entry.selected[path] = item
+	}
+
+	// Copy before appending so earlier model values never share the slice
+	stack := make([]undoEntry, 0, len(m.undoStack)+1)
+	stack = append(stack, m.undoStack...)
+	stack = append(stack, entry)
+	if len(stack) > maxUndoEntries {
+		stack = stack[len(stack)-maxUndoEntries:]
+	}
+	m.undoStack = stack
+}
+
+// undo restores the most recent snapshot
+func (m *model) undo() {
+	if len(m.undoStack) == 0 {
+		m.statusMsg = "Nothing to undo"
+		return
+	}
+
+	entry := m.undoStack[len(m.undoStack)-1]
+	m.undoStack = m.undoStack[:len(m.undoStack)-1]
+
+	m.selection = entry.selected
+	for i := range m.files {
+		_, m.files[i].Selected = m.selection[m.files[i].Path]
+	}
+	m.searchConfig = entry.searchConfig
+	if m.resultFilter != entry.resultFilter {
+		m.resultFilter = entry.resultFilter
+		m.applyResultFilters()
+	}
+
+	m.statusMsg = fmt.Sprintf("Undid: %s", entry.description)
+}
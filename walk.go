@@ -0,0 +1,202 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// minWalkWorkers is the fewest directories a walk reads ahead at once
+const minWalkWorkers = 4
+
+// dirWalker walks a tree in the order of filepath.Walk, calling its walk
+// function on one goroutine so ignore rules, depth limits and pruning come
+// out the same as a plain walk. Meanwhile a fixed pool of workers reads the
+// subdirectories the walk is going to enter ahead of it, so a wide tree on
+// a slow disk or a network share has several listings on the way at once.
+type dirWalker struct {
+	mu      sync.Mutex
+	wake    *sync.Cond
+	queue   []*dirListing // Listings waiting for a worker, the one needed soonest last
+	stopped bool
+}
+
+// dirListing is one directory's entries, each with its Lstat info, sorted
+// by name
+type dirListing struct {
+	path    string
+	claimed bool // Being read, by a worker or the walk itself; guarded by dirWalker.mu
+	done    chan struct{}
+	entries []dirEntry
+	err     error
+}
+
+// dirEntry is one entry of a listing, or the error that left it without
+// info
+type dirEntry struct {
+	path string
+	info os.FileInfo
+	err  error
+}
+
+// parallelWalk walks root like filepath.Walk, reading directories ahead
+// with up to workers goroutines. As with filepath.WalkDir, fn sees a
+// directory before it is read, so one it skips is never read, and sees it
+// again with the error if it can't be read. descend, called on the walk's
+// goroutine as a directory is entered, tells which of its subdirectories
+// the walk will go into; only those are read ahead. An unread subdirectory
+// that fn still enters is read when it gets there.
+func parallelWalk(root string, workers int, descend func(path string, info os.FileInfo) bool, fn filepath.WalkFunc) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		w := &dirWalker{}
+		w.wake = sync.NewCond(&w.mu)
+		for i := 0; i < max(1, workers); i++ {
+			go w.work()
+		}
+		defer w.stop()
+		err = w.walk(root, info, nil, descend, fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkWorkers is how many directories a walk reads ahead at once: as many
+// as the files searched at once but at least minWalkWorkers, so a printed
+// search, which reads one file at a time, still lists several. Network safe
+// mode keeps to its own limit.
+func (c SearchConfig) walkWorkers() int {
+	if c.NetworkSafe {
+		return max(1, c.MaxConcurrency)
+	}
+	return max(c.MaxConcurrency, minWalkWorkers)
+}
+
+// newDirListing prepares the listing of path, read once it is needed
+func newDirListing(path string) *dirListing {
+	return &dirListing{path: path, done: make(chan struct{})}
+}
+
+// walk visits path and, unless fn skips it, the entries of its listing,
+// which is nil when it wasn't read ahead
+func (w *dirWalker) walk(path string, info os.FileInfo, listing *dirListing, descend func(string, os.FileInfo) bool, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+	if err := fn(path, info, nil); err != nil {
+		return err
+	}
+	if listing == nil {
+		listing = newDirListing(path)
+	}
+	w.wait(listing)
+	if listing.err != nil {
+		return fn(path, info, listing.err)
+	}
+
+	// Read the subdirectories the walk goes into ahead, while it goes
+	// through the entries before them
+	children := make([]*dirListing, len(listing.entries))
+	var ahead []*dirListing
+	for i, entry := range listing.entries {
+		if entry.err == nil && entry.info.IsDir() && (descend == nil || descend(entry.path, entry.info)) {
+			children[i] = newDirListing(entry.path)
+			ahead = append(ahead, children[i])
+		}
+	}
+	w.prefetch(ahead)
+
+	for i, entry := range listing.entries {
+		if entry.err != nil {
+			if err := fn(entry.path, nil, entry.err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if err := w.walk(entry.path, entry.info, children[i], descend, fn); err != nil {
+			if !entry.info.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// wait returns once listing is read, reading it here if no worker has
+// started on it
+func (w *dirWalker) wait(listing *dirListing) {
+	w.mu.Lock()
+	claimed := listing.claimed
+	listing.claimed = true
+	w.mu.Unlock()
+	if !claimed {
+		listing.read()
+	}
+	<-listing.done
+}
+
+// prefetch stacks the listings of the directory the walk just entered on
+// top of the others, first one on top, as the walk goes into them first
+func (w *dirWalker) prefetch(listings []*dirListing) {
+	if len(listings) == 0 {
+		return
+	}
+	w.mu.Lock()
+	for i := len(listings) - 1; i >= 0; i-- {
+		w.queue = append(w.queue, listings[i])
+	}
+	w.mu.Unlock()
+	w.wake.Broadcast()
+}
+
+// work reads queued listings until the walk stops
+func (w *dirWalker) work() {
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && !w.stopped {
+			w.wake.Wait()
+		}
+		if w.stopped {
+			w.mu.Unlock()
+			return
+		}
+		listing := w.queue[len(w.queue)-1]
+		w.queue[len(w.queue)-1] = nil
+		w.queue = w.queue[:len(w.queue)-1]
+		claimed := listing.claimed
+		listing.claimed = true
+		w.mu.Unlock()
+		if !claimed {
+			listing.read()
+		}
+	}
+}
+
+// stop lets the workers go, dropping the listings still queued
+func (w *dirWalker) stop() {
+	w.mu.Lock()
+	w.stopped = true
+	w.queue = nil
+	w.mu.Unlock()
+	w.wake.Broadcast()
+}
+
+// read lists the directory, with no entries if it can't be read as a whole
+func (l *dirListing) read() {
+	defer close(l.done)
+	entries, err := os.ReadDir(l.path)
+	if err != nil {
+		l.err = err
+		return
+	}
+	l.entries = make([]dirEntry, len(entries))
+	for i, entry := range entries {
+		path := filepath.Join(l.path, entry.Name())
+		info, err := os.Lstat(path)
+		l.entries[i] = dirEntry{path: path, info: info, err: err}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makeTree creates the files and directories (ending in "/") under root
+func makeTree(t *testing.T, root string, paths []string) {
+	t.Helper()
+	for _, p := range paths {
+		path := filepath.Join(root, p)
+		if strings.HasSuffix(p, "/") {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParallelWalkOrder(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, []string{
+		"a/1", "a/2", "a/b/3", "a/b/c/4", "a/empty/",
+		"d/5", "d/e/6", "d/f/7", "d/g/8",
+		"h", "i/j/k/l/9", "z/10",
+	})
+
+	tests := []struct {
+		name string
+		skip func(rel string, info os.FileInfo) error
+	}{
+		{"all", func(string, os.FileInfo) error { return nil }},
+		{"skip a directory", func(rel string, _ os.FileInfo) error {
+			if rel == "a/b" || rel == "i" {
+				return filepath.SkipDir
+			}
+			return nil
+		}},
+		// SkipDir on a file skips the rest of its directory
+		{"skip from a file", func(rel string, _ os.FileInfo) error {
+			if rel == "d/5" || rel == "a/b/3" {
+				return filepath.SkipDir
+			}
+			return nil
+		}},
+		{"skip the root", func(rel string, _ os.FileInfo) error {
+			if rel == "." {
+				return filepath.SkipDir
+			}
+			return nil
+		}},
+		{"skip all", func(rel string, _ os.FileInfo) error {
+			if rel == "d/e" {
+				return filepath.SkipAll
+			}
+			return nil
+		}},
+	}
+	for _, tt := range tests {
+		visitor := func(visited *[]string) filepath.WalkFunc {
+			return func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				rel, _ := filepath.Rel(root, path)
+				*visited = append(*visited, filepath.ToSlash(rel))
+				return tt.skip(filepath.ToSlash(rel), info)
+			}
+		}
+		var want, got []string
+		if err := filepath.Walk(root, visitor(&want)); err != nil {
+			t.Fatal(err)
+		}
+		for _, workers := range []int{1, 4, 16} {
+			got = nil
+			if err := parallelWalk(root, workers, nil, visitor(&got)); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s with %d workers visited\n%v\nwant\n%v", tt.name, workers, got, want)
+			}
+		}
+	}
+}
+
+func TestParallelWalkUnreadDirectories(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, []string{"a/1", "pruned/2", "skipped/3", "late/4"})
+
+	// The directories descend leaves out aren't read ahead, so a file
+	// added to one before the walk gets there is still found, and one that
+	// fn skips is never read, so removing it early goes unnoticed
+	descend := func(path string, _ os.FileInfo) bool {
+		switch filepath.Base(path) {
+		case "late", "pruned", "skipped":
+			return false
+		}
+		return true
+	}
+	var visited, failed []string
+	err := parallelWalk(root, 4, descend, func(path string, info os.FileInfo, err error) error {
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		if err != nil {
+			failed = append(failed, rel)
+			return nil
+		}
+		visited = append(visited, rel)
+		switch rel {
+		case "a":
+			time.Sleep(50 * time.Millisecond) // Time for any read-ahead to happen
+			if err := os.WriteFile(filepath.Join(root, "late", "5"), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.RemoveAll(filepath.Join(root, "skipped")); err != nil {
+				t.Fatal(err)
+			}
+		case "pruned", "skipped":
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".", "a", "a/1", "late", "late/4", "late/5", "pruned", "skipped"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited %v; want %v", visited, want)
+	}
+	if len(failed) > 0 {
+		t.Errorf("read %v, which the walk skips", failed)
+	}
+}
+
+func TestParallelWalkErrors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	var calls int
+	err := parallelWalk(missing, 2, nil, func(path string, info os.FileInfo, err error) error {
+		calls++
+		if path != missing || info != nil || !os.IsNotExist(err) {
+			t.Errorf("fn(%q, %v, %v); want the root with its error", path, info, err)
+		}
+		return err
+	})
+	if !os.IsNotExist(err) || calls != 1 {
+		t.Errorf("parallelWalk of a missing root = %v after %d calls; want its error after 1", err, calls)
+	}
+}
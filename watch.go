@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// dirSettleTime is how long a burst of changes (an unpacked archive, a
+// build) may go on before the listing is refreshed once
+const dirSettleTime = 200 * time.Millisecond
+
+// dirChangedMsg reports that entries were created, removed or renamed in
+// a watched directory
+type dirChangedMsg struct {
+	dir string
+}
+
+// notify records a change without blocking; changes not yet picked up by
+// waitForDirChange coalesce into one
+func (w *dirWatcher) notify(dir string) {
+	select {
+	case w.changes <- dir:
+	default:
+	}
+}
+
+// waitForDirChange waits for the next change of the watched directory,
+// once it has settled
+func waitForDirChange(w *dirWatcher) tea.Cmd {
+	if w == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		dir := <-w.changes
+		for {
+			select {
+			case dir = <-w.changes:
+			case <-time.After(dirSettleTime):
+				return dirChangedMsg{dir: dir}
+			}
+		}
+	}
+}
+
+// handleDirChanged refreshes the listing if the change is in the directory
+// shown. Directories streamed in batches are left to the r key, since the
+// refresh would restart the stream.
+func (m *model) handleDirChanged(msg dirChangedMsg) tea.Cmd {
+	if msg.dir == m.currentDir && m.dirLoad == nil && len(m.files) < dirBatchSize {
+		before, status := len(m.files), m.statusMsg
+		m.refreshDirectory()
+		if m.mode != FileBrowserMode {
+			m.statusMsg = status // Don't interrupt other views
+		} else if m.dirLoad == nil {
+			m.statusMsg = fmt.Sprintf("Directory changed on disk: %d items (was %d)", len(m.files), before)
+		}
+	}
+	return waitForDirChange(m.watcher)
+}
+
+// refreshDirectory lists currentDir again, keeping the selections and the
+// cursor on the same entry when it still exists
+func (m *model) refreshDirectory() {
+	var current string
+	if m.selectedFile < len(m.files) {
+		current = m.files[m.selectedFile].Path
+	}
+	index, offset := m.selectedFile, m.viewport.offset
+
+	m.loadDirectory()
+	m.selectedFile = min(index, max(len(m.files)-1, 0))
+	for i := range m.files {
+		if m.files[i].Path == current {
+			m.selectedFile = i
+		}
+	}
+	m.viewport.offset = offset
+	m.adjustViewport()
+}
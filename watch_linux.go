@@ -0,0 +1,81 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"sync"
+	"syscall"
+)
+
+// dirWatcher follows the directory shown in the file browser with inotify
+type dirWatcher struct {
+	fd      int
+	mu      sync.Mutex
+	dir     string
+	wd      int // Watch descriptor of dir, or -1
+	changes chan string
+}
+
+// dirWatchEvents are the changes that add or remove listing entries
+const dirWatchEvents = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// newDirWatcher starts watching, or returns nil if inotify is unavailable
+// (the r key still refreshes)
+func newDirWatcher() *dirWatcher {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil
+	}
+	w := &dirWatcher{fd: fd, wd: -1, changes: make(chan string, 1)}
+	go w.read()
+	return w
+}
+
+// watch switches the watch to dir
+func (w *dirWatcher) watch(dir string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if dir == w.dir && w.wd >= 0 {
+		return
+	}
+	if w.wd >= 0 {
+		syscall.InotifyRmWatch(w.fd, uint32(w.wd))
+	}
+	w.dir = dir
+	w.wd = -1
+	if wd, err := syscall.InotifyAddWatch(w.fd, dir, dirWatchEvents); err == nil {
+		w.wd = wd
+	}
+}
+
+// read turns the inotify events of the watched directory into changes
+func (w *dirWatcher) read() {
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := syscall.Read(w.fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || n <= 0 {
+			return
+		}
+		// struct inotify_event: wd, mask, cookie, len, then len bytes of name
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			wd := int32(binary.NativeEndian.Uint32(buf[off:]))
+			nameLen := binary.NativeEndian.Uint32(buf[off+12:])
+			off += syscall.SizeofInotifyEvent + int(nameLen)
+
+			w.mu.Lock()
+			dir, current := w.dir, int(wd) == w.wd
+			w.mu.Unlock()
+			if current {
+				w.notify(dir)
+			}
+		}
+	}
+}
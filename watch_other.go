@@ -0,0 +1,56 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// dirPollInterval is how often the watched directory's modification time
+// is checked; creating, removing or renaming an entry updates it
+const dirPollInterval = time.Second
+
+// dirWatcher follows the directory shown in the file browser by polling
+type dirWatcher struct {
+	mu      sync.Mutex
+	dir     string
+	changes chan string
+}
+
+// newDirWatcher starts polling
+func newDirWatcher() *dirWatcher {
+	w := &dirWatcher{changes: make(chan string, 1)}
+	go w.poll()
+	return w
+}
+
+// watch switches the watch to dir
+func (w *dirWatcher) watch(dir string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.dir = dir
+	w.mu.Unlock()
+}
+
+// poll reports a change when the directory's modification time moves
+func (w *dirWatcher) poll() {
+	var last string
+	var modified time.Time
+	for range time.Tick(dirPollInterval) {
+		w.mu.Lock()
+		dir := w.dir
+		w.mu.Unlock()
+		info, err := os.Stat(dir)
+		if err != nil {
+			continue
+		}
+		if dir == last && !info.ModTime().Equal(modified) {
+			w.notify(dir)
+		}
+		last, modified = dir, info.ModTime()
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exit codes of `zx search`, following grep
+const (
+	exitMatches   = 0
+	exitNoMatches = 1
+	exitFailed    = 2 // A root couldn't be searched
+)
+
+// workspaceReport is the aggregated outcome of a workspace search
+type workspaceReport struct {
+	Workspace    string        `json:"workspace,omitempty"`
+	Pattern      string        `json:"pattern"`
+	Roots        []rootSummary `json:"roots"`
+	Matches      int           `json:"matches"`
+	FilesMatched int           `json:"files_matched"`
+	Failed       int           `json:"failed_roots"`
+	SearchTime   time.Duration `json:"search_time_ns"`
+
+	config SearchConfig
+}
+
+// rootSummary describes the search of one workspace root
+type rootSummary struct {
+	Root          string        `json:"root"`
+	Failed        bool          `json:"failed,omitempty"` // The root is missing or unreadable
+	FilesSearched int           `json:"files_searched"`
+	FilesMatched  int           `json:"files_matched"`
+	Matches       int           `json:"matches"`
+	Errors        []string      `json:"errors,omitempty"`
+	SearchTime    time.Duration `json:"search_time_ns"`
+	Results       []jsonResult  `json:"results,omitempty"`
+
+	results SearchResults
+}
+
+// Workspace is an entry of the [workspaces] table: its list of roots, or a
+// table of the roots and the files the workspace always searches
+type Workspace struct {
+	Roots        []string
+	AlwaysSearch []string
+}
+
+// UnmarshalTOML reads either form of a workspace
+func (w *Workspace) UnmarshalTOML(data interface{}) error {
+	strs := func(key string, v interface{}) ([]string, error) {
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s must be a list of strings", key)
+		}
+		var out []string
+		for _, item := range list {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a list of strings", key)
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	}
+	table, ok := data.(map[string]interface{})
+	if !ok {
+		roots, err := strs("workspace", data)
+		w.Roots = roots
+		return err
+	}
+	for key, v := range table {
+		var err error
+		switch key {
+		case "roots":
+			w.Roots, err = strs(key, v)
+		case "always_search":
+			w.AlwaysSearch, err = strs(key, v)
+		default:
+			err = fmt.Errorf("unknown workspace key %q (use roots, always_search)", key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findWorkspace returns a workspace from the config file, with ~ and ~user
+// expanded in its roots
+func findWorkspace(workspaces map[string]Workspace, name string) (Workspace, error) {
+	ws, ok := workspaces[name]
+	if !ok {
+		var names []string
+		for n := range workspaces {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return ws, fmt.Errorf("unknown workspace %q: define workspaces in the config file", name)
+		}
+		return ws, fmt.Errorf("unknown workspace %q (have %s)", name, strings.Join(names, ", "))
+	}
+	var expanded []string
+	for _, root := range ws.Roots {
+		root, err := expandHome(root)
+		if err != nil {
+			return ws, fmt.Errorf("workspace %q: %v", name, err)
+		}
+		expanded = append(expanded, root)
+	}
+	ws.Roots = expanded
+	return ws, nil
+}
+
+// expandHome replaces a leading ~ in path with the home directory, and
+// ~name with the home directory of user name
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	name, rest := path[1:], ""
+	if i := strings.IndexAny(name, "/"+string(filepath.Separator)); i >= 0 {
+		name, rest = name[:i], name[i+1:]
+	}
+	if name == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, rest), nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", fmt.Errorf("cannot expand %s: %v", path, err)
+	}
+	return filepath.Join(u.HomeDir, rest), nil
+}
+
+// searchWorkspace searches every root in parallel and summarizes each
+func searchWorkspace(name, pattern string, roots []string, config SearchConfig) workspaceReport {
+	start := time.Now()
+	report := workspaceReport{Workspace: name, Pattern: pattern, Roots: make([]rootSummary, len(roots)), config: config}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.NumCPU())
+	for i, root := range roots {
+		wg.Add(1)
+		go func(i int, root string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			report.Roots[i] = searchRoot(pattern, root, config)
+		}(i, root)
+	}
+	wg.Wait()
+
+	for _, root := range report.Roots {
+		report.Matches += root.Matches
+		report.FilesMatched += root.FilesMatched
+		if root.Failed {
+			report.Failed++
+		}
+	}
+	report.SearchTime = time.Since(start)
+	return report
+}
+
+// searchRoot searches one root
+func searchRoot(pattern, root string, config SearchConfig) rootSummary {
+	summary := rootSummary{Root: root}
+	if err := readableRoot(root); err != nil {
+		summary.Failed = true
+		summary.Errors = []string{err.Error()}
+		return summary
+	}
+
+	results := performLegacySearch(pattern, root, config)
+	files, _ := aggregateByFile(results.Results)
+	summary.results = results
+	summary.FilesSearched = results.TotalFiles
+	summary.FilesMatched = len(files)
+	summary.Matches = totalMatches(results.Results)
+	summary.Errors = results.Errors
+	summary.SearchTime = results.SearchTime
+	summary.Results = resultReport{Results: results.Results}.jsonResults()
+	return summary
+}
+
+// readableRoot reports why root can't be searched: it's missing, can't be
+// opened, or is a directory whose entries can't be listed. A root that
+// merely exists would otherwise search as an empty one.
+func readableRoot(root string) error {
+	f, err := os.Open(root)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if _, err := f.Readdirnames(1); err != nil && err != io.EOF {
+			return err
+		}
+	}
+	return nil
+}
+
+// exitCode is 2 if any root failed, else 0 with matches and 1 without
+func (r workspaceReport) exitCode() int {
+	switch {
+	case r.Failed > 0:
+		return exitFailed
+	case r.Matches > 0:
+		return exitMatches
+	}
+	return exitNoMatches
+}
+
+// writeJSON prints the report as one JSON document
+func (r workspaceReport) writeJSON(out io.Writer) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// writeText prints the matches of all roots like plain search output,
+// followed by the summary on errOut
+func (r workspaceReport) writeText(out, errOut io.Writer, aggregate AggregateMode) {
+	for _, root := range r.Roots {
+		writePlainResults(out, errOut, root.results, aggregate)
+	}
+	r.writeSummary(errOut)
+}
+
+// merged returns the results of all roots as one search's
+func (r workspaceReport) merged() SearchResults {
+	merged := SearchResults{Pattern: r.Pattern}
+	var roots []string
+	for _, root := range r.Roots {
+		roots = append(roots, root.Root)
+		merged.Results = append(merged.Results, root.results.Results...)
+		merged.Errors = append(merged.Errors, root.results.Errors...)
+		merged.Failed = append(merged.Failed, root.results.Failed...)
+		merged.TotalFiles += root.results.TotalFiles
+		merged.IndexSkipped += root.results.IndexSkipped
+		merged.BytesRead += root.results.BytesRead
+		merged.PeakWorkers += root.results.PeakWorkers
+		merged.Truncated = merged.Truncated || root.results.Truncated
+	}
+	// Roots are searched a CPU's worth at a time
+	merged.PeakWorkers = min(merged.PeakWorkers, runtime.NumCPU())
+	merged.Target = strings.Join(roots, ", ")
+	merged.SearchTime = r.SearchTime
+	return merged
+}
+
+// writeFormat prints the matches of all roots as one document of a
+// format, followed by the summary on errOut
+func (r workspaceReport) writeFormat(out, errOut io.Writer, f Formatter) error {
+	err := writeFormatted(out, errOut, f, r.merged(), &r.config)
+	r.writeSummary(errOut)
+	return err
+}
+
+// writeSummary prints a line per root and the total
+func (r workspaceReport) writeSummary(errOut io.Writer) {
+	for _, root := range r.Roots {
+		if root.Failed {
+			fmt.Fprintf(errOut, "%s: failed: %s\n", root.Root, strings.Join(root.Errors, "; "))
+			continue
+		}
+		fmt.Fprintf(errOut, "%s: %d matches in %d of %d files (%v)\n",
+			root.Root, root.Matches, root.FilesMatched, root.FilesSearched, root.SearchTime.Round(time.Millisecond))
+	}
+	fmt.Fprintf(errOut, "total: %d matches in %d files across %d roots", r.Matches, r.FilesMatched, len(r.Roots))
+	if r.Failed > 0 {
+		fmt.Fprintf(errOut, ", %d failed", r.Failed)
+	}
+	fmt.Fprintf(errOut, " (%v)\n", r.SearchTime.Round(time.Millisecond))
+}
+
+// runSearchCommand implements `zx search`: a search over the roots of a
+// workspace (or given on the command line), then exits with exitCode. The
+// matches are printed with format if it's set, and followed by statistics
+// on stderr with stats.
+func runSearchCommand(args []string, workspace string, workspaces map[string]Workspace, config SearchConfig, jsonOut bool, format Formatter, aggregate AggregateMode, stats bool) (int, error) {
+	if len(args) == 0 {
+		return exitFailed, fmt.Errorf("usage: zx search [-workspace name] [flags] <pattern> [root...]")
+	}
+	pattern, roots := args[0], args[1:]
+	if workspace != "" {
+		ws, err := findWorkspace(workspaces, workspace)
+		if err != nil {
+			return exitFailed, err
+		}
+		always, err := alwaysSearchPatterns(ws.AlwaysSearch)
+		if err != nil {
+			return exitFailed, fmt.Errorf("workspace %s: %v", workspace, err)
+		}
+		roots = append(ws.Roots, roots...)
+		config.AlwaysSearch = append(config.AlwaysSearch, always...)
+	}
+	if len(roots) == 0 {
+		return exitFailed, fmt.Errorf("no roots to search: name a -workspace or give roots after the pattern")
+	}
+	if _, err := compileQuery(pattern); err != nil {
+		return exitFailed, fmt.Errorf("invalid regex pattern: %v", err)
+	}
+
+	if _, ok := format.(jsonlFormatter); ok && !jsonOut {
+		cliStream = newJSONLStream(os.Stdout)
+	}
+	report := searchWorkspace(workspace, pattern, roots, config)
+	cliProgress.finish()
+	if jsonOut {
+		if err := report.writeJSON(os.Stdout); err != nil {
+			return exitFailed, err
+		}
+	} else if format != nil {
+		if err := report.writeFormat(os.Stdout, os.Stderr, format); err != nil {
+			return exitFailed, err
+		}
+	} else {
+		report.writeText(os.Stdout, os.Stderr, aggregate)
+	}
+	if stats {
+		merged := report.merged()
+		merged.CPUTime = processCPUTime()
+		writeStats(os.Stderr, merged)
+	}
+	return report.exitCode(), nil
+}